@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/grafana/agent/pkg/config"
+)
+
+// checkConfigCommand is the name of the subcommand which validates a config
+// file without running the agent.
+const checkConfigCommand = "check-config"
+
+// Output formats of the check-config subcommand.
+const (
+	checkFormatText = "text"
+	checkFormatJSON = "json"
+)
+
+// checkConfigResult is the result of check-config in the json format.
+type checkConfigResult struct {
+	Valid  bool               `json:"valid"`
+	Errors []checkConfigError `json:"errors,omitempty"`
+}
+
+type checkConfigError struct {
+	// Integration and Instance identify the integration with an invalid
+	// config. They're empty for errors in the rest of the config file.
+	Integration string `json:"integration,omitempty"`
+	Instance    string `json:"instance,omitempty"`
+	Error       string `json:"error"`
+}
+
+// checkConfig loads the config file set by args the same way the agent does,
+// and validates the configs of its integrations. Errors are written to out in
+// the format set by the -format flag. checkConfig returns the exit code of
+// the subcommand: 0 if the config file is valid, 1 otherwise.
+func checkConfig(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet(checkConfigCommand, flag.ContinueOnError)
+	fs.SetOutput(out)
+	format := fs.String("format", checkFormatText, fmt.Sprintf("Format of the result. Supported values: %s, %s.", checkFormatText, checkFormatJSON))
+
+	var res checkConfigResult
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		res.Errors = append(res.Errors, checkConfigError{Error: err.Error()})
+	} else {
+		for _, ierr := range cfg.Integrations.Check() {
+			res.Errors = append(res.Errors, checkConfigError{
+				Integration: ierr.Integration,
+				Instance:    ierr.Instance,
+				Error:       ierr.Err.Error(),
+			})
+		}
+	}
+	res.Valid = len(res.Errors) == 0
+
+	switch *format {
+	case checkFormatJSON:
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(res)
+	default:
+		writeCheckConfigText(out, res)
+	}
+
+	if !res.Valid {
+		return 1
+	}
+	return 0
+}
+
+func writeCheckConfigText(out io.Writer, res checkConfigResult) {
+	if res.Valid {
+		fmt.Fprintln(out, "config file is valid")
+		return
+	}
+	fmt.Fprintf(out, "config file is invalid, found %d error(s):\n", len(res.Errors))
+	for _, e := range res.Errors {
+		switch {
+		case e.Instance != "":
+			fmt.Fprintf(out, "- integration %s (instance %s): %s\n", e.Integration, e.Instance, e.Error)
+		case e.Integration != "":
+			fmt.Fprintf(out, "- integration %s: %s\n", e.Integration, e.Error)
+		default:
+			fmt.Fprintf(out, "- %s\n", e.Error)
+		}
+	}
+}
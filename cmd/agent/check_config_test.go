@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckConfig(t *testing.T) {
+	tt := []struct {
+		name     string
+		config   string
+		args     []string
+		exitCode int
+		output   string
+	}{
+		{
+			name: "valid",
+			config: `
+metrics:
+  wal_directory: /tmp/wal`,
+			exitCode: 0,
+			output:   "config file is valid",
+		},
+		{
+			name: "invalid config file",
+			config: `
+metrics:
+  unknown_field: true`,
+			exitCode: 1,
+			output:   "field unknown_field not found",
+		},
+		{
+			name: "invalid integration",
+			config: `
+integrations:
+  ssl_exporter:
+    enabled: true
+    ssl_targets:
+      - name: example
+        target: example.com:443
+        module: fake`,
+			exitCode: 1,
+			output:   `- integration ssl_exporter: invalid module for ssl_target "example": unknown module "fake"`,
+		},
+		{
+			name:     "missing config file flag",
+			args:     []string{},
+			exitCode: 1,
+			output:   "-config.file flag required",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			args := tc.args
+			if args == nil {
+				path := filepath.Join(t.TempDir(), "agent.yaml")
+				require.NoError(t, os.WriteFile(path, []byte(tc.config), 0600))
+				args = []string{"-config.file", path}
+			}
+
+			var out bytes.Buffer
+			require.Equal(t, tc.exitCode, checkConfig(args, &out), out.String())
+			require.Contains(t, out.String(), tc.output)
+		})
+	}
+}
+
+func TestCheckConfig_JSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+integrations:
+  ssl_exporter:
+    enabled: true
+    ssl_targets:
+      - name: example
+        target: example.com:443
+        module: fake`), 0600))
+
+	var out bytes.Buffer
+	require.Equal(t, 1, checkConfig([]string{"-format", "json", "-config.file", path}, &out))
+
+	var res checkConfigResult
+	require.NoError(t, json.Unmarshal(out.Bytes(), &res))
+	require.False(t, res.Valid)
+	require.Len(t, res.Errors, 1)
+	require.Equal(t, "ssl_exporter", res.Errors[0].Integration)
+}
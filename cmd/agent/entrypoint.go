@@ -8,8 +8,10 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/grafana/agent/pkg/logs"
@@ -18,6 +20,7 @@ import (
 	"github.com/grafana/agent/pkg/server"
 	"github.com/grafana/agent/pkg/traces"
 	"github.com/oklog/run"
+	"github.com/pmezard/go-difflib/difflib"
 	"google.golang.org/grpc"
 	"gopkg.in/yaml.v2"
 
@@ -45,6 +48,9 @@ type Entrypoint struct {
 
 	reloadListener net.Listener
 	reloadServer   *http.Server
+
+	// applied is notified whenever a config is applied.
+	applied chan struct{}
 }
 
 // Reloader is any function that returns a new config.
@@ -56,6 +62,7 @@ func NewEntrypoint(logger *server.Logger, cfg *config.Config, reloader Reloader)
 		ep = &Entrypoint{
 			log:      logger,
 			reloader: reloader,
+			applied:  make(chan struct{}, 1),
 		}
 		err error
 	)
@@ -169,6 +176,11 @@ func (ep *Entrypoint) ApplyConfig(cfg config.Config) error {
 	}
 
 	ep.cfg = cfg
+	select {
+	case ep.applied <- struct{}{}:
+	default:
+	}
+
 	if failed {
 		return fmt.Errorf("changes did not apply successfully")
 	}
@@ -197,29 +209,66 @@ func (ep *Entrypoint) wire(mux *mux.Router, grpc *grpc.Server) {
 
 			return
 		}
+		if !ep.integrations.Ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, "Integrations are not ready yet.\n")
+
+			return
+		}
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintf(w, "Agent is Ready.\n")
 	})
 
-	mux.HandleFunc("/-/config", func(rw http.ResponseWriter, r *http.Request) {
-		ep.mut.Lock()
-		cfg := ep.cfg
-		ep.mut.Unlock()
+	mux.HandleFunc("/-/config", ep.configHandler)
+	mux.HandleFunc("/-/reload", ep.reloadHandler).Methods("GET", "POST")
+	mux.HandleFunc("/-/loglevel", ep.log.LogLevelHandler).Methods("GET", "PUT")
+}
 
-		if cfg.EnableConfigEndpoints {
-			bb, err := yaml.Marshal(cfg)
-			if err != nil {
-				http.Error(rw, fmt.Sprintf("failed to marshal config: %s", err), http.StatusInternalServerError)
-			} else {
-				_, _ = rw.Write(bb)
-			}
-		} else {
-			rw.WriteHeader(http.StatusNotFound)
-			_, _ = rw.Write([]byte("404 - config endpoint is disabled"))
-		}
-	})
+// configHandler prints the applied config. When the diff query parameter is
+// true, it prints a unified diff from the applied config to the config file
+// as it would be loaded by a reload instead.
+func (ep *Entrypoint) configHandler(rw http.ResponseWriter, r *http.Request) {
+	ep.mut.Lock()
+	cfg := ep.cfg
+	ep.mut.Unlock()
 
-	mux.HandleFunc("/-/reload", ep.reloadHandler).Methods("GET", "POST")
+	if !cfg.EnableConfigEndpoints {
+		rw.WriteHeader(http.StatusNotFound)
+		_, _ = rw.Write([]byte("404 - config endpoint is disabled"))
+		return
+	}
+
+	bb, err := yaml.Marshal(cfg)
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("failed to marshal config: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	if diff, _ := strconv.ParseBool(r.URL.Query().Get("diff")); diff {
+		fileCfg, err := ep.reloader()
+		if err != nil {
+			http.Error(rw, fmt.Sprintf("failed to load config file: %s", err), http.StatusInternalServerError)
+			return
+		}
+		fileBytes, err := yaml.Marshal(fileCfg)
+		if err != nil {
+			http.Error(rw, fmt.Sprintf("failed to marshal config: %s", err), http.StatusInternalServerError)
+			return
+		}
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(bb)),
+			B:        difflib.SplitLines(string(fileBytes)),
+			FromFile: "applied",
+			ToFile:   "file",
+			Context:  3,
+		})
+		if err != nil {
+			http.Error(rw, fmt.Sprintf("failed to diff config: %s", err), http.StatusInternalServerError)
+			return
+		}
+		bb = []byte(diff)
+	}
+	_, _ = rw.Write(bb)
 }
 
 func (ep *Entrypoint) reloadHandler(rw http.ResponseWriter, r *http.Request) {
@@ -253,6 +302,74 @@ func (ep *Entrypoint) TriggerReload() bool {
 	return true
 }
 
+// pollConfig re-requests the config file every interval and applies it if it
+// changed, until ctx is canceled.
+func (ep *Entrypoint) pollConfig(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		cfg, err := ep.reloader()
+		if err != nil {
+			level.Error(ep.log).Log("msg", "failed to poll config file", "err", err)
+			continue
+		}
+
+		ep.mut.Lock()
+		checksum := ep.cfg.Checksum()
+		ep.mut.Unlock()
+		if checksum != "" && cfg.Checksum() == checksum {
+			continue
+		}
+
+		level.Info(ep.log).Log("msg", "config file changed, applying new config")
+		cfg.LogDeprecations(ep.log)
+		if err := ep.ApplyConfig(*cfg); err != nil {
+			level.Error(ep.log).Log("msg", "failed to apply polled config file", "err", err)
+		}
+	}
+}
+
+// refreshSecrets reloads the config file whenever the secrets referenced by
+// the current config expire, until ctx is canceled.
+func (ep *Entrypoint) refreshSecrets(ctx context.Context) {
+	for {
+		ep.mut.Lock()
+		ttl := ep.cfg.SecretsTTL()
+		ep.mut.Unlock()
+
+		var (
+			timer   *time.Timer
+			expired <-chan time.Time
+		)
+		if ttl > 0 {
+			timer = time.NewTimer(ttl)
+			expired = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-ep.applied:
+			// The TTL of the secrets of the new config is used.
+		case <-expired:
+			level.Info(ep.log).Log("msg", "secrets referenced by the config expired, reloading config file")
+			ep.TriggerReload()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
 // Stop stops the Entrypoint and all subsystems.
 func (ep *Entrypoint) Stop() {
 	ep.mut.Lock()
@@ -311,6 +428,32 @@ func (ep *Entrypoint) Start() error {
 		srvCancel()
 	})
 
+	ep.mut.Lock()
+	pollInterval := ep.cfg.PollInterval
+	ep.mut.Unlock()
+
+	secretsContext, secretsCancel := context.WithCancel(context.Background())
+	defer secretsCancel()
+
+	g.Add(func() error {
+		ep.refreshSecrets(secretsContext)
+		return nil
+	}, func(e error) {
+		secretsCancel()
+	})
+
+	if pollInterval > 0 {
+		pollContext, pollCancel := context.WithCancel(context.Background())
+		defer pollCancel()
+
+		g.Add(func() error {
+			ep.pollConfig(pollContext, pollInterval)
+			return nil
+		}, func(e error) {
+			pollCancel()
+		})
+	}
+
 	go func() {
 		for range notifier {
 			ep.TriggerReload()
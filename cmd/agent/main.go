@@ -29,6 +29,10 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == checkConfigCommand {
+		os.Exit(checkConfig(os.Args[2:], os.Stdout))
+	}
+
 	// If Windows is trying to run us as a service, go through that
 	// path instead.
 	if IsWindowsService() {
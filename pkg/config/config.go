@@ -2,21 +2,28 @@ package config
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 	"unicode"
 
 	"github.com/drone/envsubst/v2"
+	"github.com/drone/envsubst/v2/parse"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/grafana/agent/pkg/config/features"
 	"github.com/grafana/agent/pkg/logs"
 	"github.com/grafana/agent/pkg/metrics"
+	"github.com/grafana/agent/pkg/resourcedetection"
 	"github.com/grafana/agent/pkg/server"
 	"github.com/grafana/agent/pkg/traces"
 	"github.com/grafana/agent/pkg/util"
@@ -54,6 +61,7 @@ var DefaultConfig = Config{
 	Server:                server.DefaultConfig,
 	Metrics:               metrics.DefaultConfig,
 	Integrations:          DefaultVersionedIntegrations,
+	ResourceDetection:     resourcedetection.DefaultConfig,
 	EnableConfigEndpoints: false,
 }
 
@@ -65,15 +73,62 @@ type Config struct {
 	Traces       traces.Config         `yaml:"traces,omitempty"`
 	Logs         *logs.Config          `yaml:"logs,omitempty"`
 
+	// ResourceDetection discovers attributes about the environment which are
+	// applied to all metrics, logs, and traces pipelines.
+	ResourceDetection resourcedetection.Config `yaml:"resource_detection,omitempty"`
+
 	// Deprecated fields user has used. Generated during UnmarshalYAML.
 	Deprecations []string `yaml:"-"`
 
 	// Remote config options
-	BasicAuthUser     string `yaml:"-"`
-	BasicAuthPassFile string `yaml:"-"`
+	BasicAuthUser          string        `yaml:"-"`
+	BasicAuthPassFile      string        `yaml:"-"`
+	BearerTokenFile        string        `yaml:"-"`
+	OAuth2ClientID         string        `yaml:"-"`
+	OAuth2ClientSecretFile string        `yaml:"-"`
+	OAuth2TokenURL         string        `yaml:"-"`
+	OAuth2Scopes           string        `yaml:"-"`
+	PollInterval           time.Duration `yaml:"-"`
+
+	// SecretsRefreshInterval is how often secrets referenced from secret
+	// managers are fetched again, if they don't expire earlier.
+	SecretsRefreshInterval time.Duration `yaml:"-"`
+
+	// ExpandEnvStrict fails expanding environment variables in the config
+	// file when it references undefined variables without a default value.
+	ExpandEnvStrict bool `yaml:"-"`
+
+	// Config templating options
+	TemplateEnabled   bool   `yaml:"-"`
+	TemplateDetectors string `yaml:"-"`
+	DryRunTemplate    bool   `yaml:"-"`
 
 	// Toggle for config endpoint(s)
 	EnableConfigEndpoints bool `yaml:"-"`
+
+	// checksum is the checksum of the config file the config was loaded from.
+	checksum string
+}
+
+// SecretsTTL returns how long the secrets referenced by c are valid, after
+// which c should be loaded again to refresh them. SecretsTTL returns zero if
+// c doesn't reference secrets which need to be refreshed.
+func (c *Config) SecretsTTL() time.Duration {
+	if !c.Integrations.secretsFromBackend {
+		return 0
+	}
+	ttl := c.Integrations.secretsRefreshIn
+	if c.SecretsRefreshInterval > 0 && (ttl == 0 || c.SecretsRefreshInterval < ttl) {
+		ttl = c.SecretsRefreshInterval
+	}
+	return ttl
+}
+
+// Checksum returns the checksum of the config file c was loaded from, after
+// environment variables were expanded. Checksum returns an empty string if c
+// wasn't loaded by LoadBytes.
+func (c *Config) Checksum() string {
+	return c.checksum
 }
 
 // UnmarshalYAML implements yaml.Unmarshaler.
@@ -195,6 +250,12 @@ func (c *Config) Validate(fs *flag.FlagSet) error {
 	deps := []features.Dependency{
 		{Flag: "config.url.basic-auth-user", Feature: featRemoteConfigs},
 		{Flag: "config.url.basic-auth-password-file", Feature: featRemoteConfigs},
+		{Flag: "config.url.bearer-token-file", Feature: featRemoteConfigs},
+		{Flag: "config.url.oauth2.client-id", Feature: featRemoteConfigs},
+		{Flag: "config.url.oauth2.client-secret-file", Feature: featRemoteConfigs},
+		{Flag: "config.url.oauth2.token-url", Feature: featRemoteConfigs},
+		{Flag: "config.url.oauth2.scopes", Feature: featRemoteConfigs},
+		{Flag: "config.url.poll-interval", Feature: featRemoteConfigs},
 	}
 	return features.Validate(fs, deps)
 }
@@ -208,6 +269,31 @@ func (c *Config) RegisterFlags(f *flag.FlagSet) {
 		"basic auth username for fetching remote config. (requires remote-configs experiment to be enabled")
 	f.StringVar(&c.BasicAuthPassFile, "config.url.basic-auth-password-file", "",
 		"path to file containing basic auth password for fetching remote config. (requires remote-configs experiment to be enabled")
+	f.StringVar(&c.BearerTokenFile, "config.url.bearer-token-file", "",
+		"path to file containing a bearer token for fetching remote config. (requires remote-configs experiment to be enabled")
+	f.StringVar(&c.OAuth2ClientID, "config.url.oauth2.client-id", "",
+		"OAuth2 client ID for fetching remote config. (requires remote-configs experiment to be enabled")
+	f.StringVar(&c.OAuth2ClientSecretFile, "config.url.oauth2.client-secret-file", "",
+		"path to file containing the OAuth2 client secret for fetching remote config. (requires remote-configs experiment to be enabled")
+	f.StringVar(&c.OAuth2TokenURL, "config.url.oauth2.token-url", "",
+		"URL to fetch OAuth2 tokens from for fetching remote config. (requires remote-configs experiment to be enabled")
+	f.StringVar(&c.OAuth2Scopes, "config.url.oauth2.scopes", "",
+		"comma-separated list of OAuth2 scopes for fetching remote config. (requires remote-configs experiment to be enabled")
+	f.DurationVar(&c.PollInterval, "config.url.poll-interval", 0,
+		"how often to fetch the remote config and apply it if it changed. 0 disables polling. (requires remote-configs experiment to be enabled")
+
+	f.DurationVar(&c.SecretsRefreshInterval, "config.secrets-refresh-interval", time.Hour,
+		"how often to fetch secrets referenced from Vault, AWS Secrets Manager or GCP Secret Manager again. Secrets with a TTL are refreshed before they expire. 0 disables refreshing secrets without a TTL.")
+
+	f.BoolVar(&c.ExpandEnvStrict, "config.expand-env.strict", false,
+		"Fails loading the config file when -config.expand-env is used and the config references undefined environment variables without a default value.")
+
+	f.BoolVar(&c.TemplateEnabled, "config.template", false,
+		"Renders the config file as a Go template with facts about the host before loading it.")
+	f.StringVar(&c.TemplateDetectors, "config.template.detectors", strings.Join(resourcedetection.DefaultConfig.Detectors, ","),
+		"Comma-separated list of resource detectors providing the .Resource facts of config templates.")
+	f.BoolVar(&c.DryRunTemplate, "dry-run-template", false,
+		"Prints the rendered config template and exits without running the agent. Requires -config.template.")
 
 	f.BoolVar(&c.EnableConfigEndpoints, "config.enable-read-api", false, "Enables the /-/config and /agent/api/v1/configs/{name} APIs. Be aware that secrets could be exposed by enabling these endpoints!")
 }
@@ -218,19 +304,13 @@ func LoadFile(filename string, expandEnvVars bool, c *Config) error {
 	if err != nil {
 		return fmt.Errorf("error reading config file %w", err)
 	}
-	return LoadBytes(buf, expandEnvVars, c)
+	return loadBytes(buf, expandEnvVars, filepath.Dir(filename), c)
 }
 
 // LoadRemote reads a config from url
 func LoadRemote(url string, expandEnvVars bool, c *Config) error {
-	remoteOpts := &remoteOpts{}
-	if c.BasicAuthUser != "" && c.BasicAuthPassFile != "" {
-		remoteOpts.HTTPClientConfig = &config.HTTPClientConfig{
-			BasicAuth: &config.BasicAuth{
-				Username:     c.BasicAuthUser,
-				PasswordFile: c.BasicAuthPassFile,
-			},
-		}
+	remoteOpts := &remoteOpts{
+		HTTPClientConfig: c.remoteHTTPClientConfig(),
 	}
 
 	if remoteOpts.HTTPClientConfig != nil {
@@ -256,6 +336,44 @@ func LoadRemote(url string, expandEnvVars bool, c *Config) error {
 	return LoadBytes(bb, expandEnvVars, c)
 }
 
+// remoteHTTPClientConfig returns the HTTP client config for fetching remote
+// configs from the credentials set by flags. It returns nil if no credentials
+// are set.
+func (c *Config) remoteHTTPClientConfig() *config.HTTPClientConfig {
+	var (
+		cfg config.HTTPClientConfig
+		set bool
+	)
+	if c.BasicAuthUser != "" && c.BasicAuthPassFile != "" {
+		cfg.BasicAuth = &config.BasicAuth{
+			Username:     c.BasicAuthUser,
+			PasswordFile: c.BasicAuthPassFile,
+		}
+		set = true
+	}
+	if c.BearerTokenFile != "" {
+		cfg.BearerTokenFile = c.BearerTokenFile
+		set = true
+	}
+	if c.OAuth2ClientID != "" || c.OAuth2TokenURL != "" {
+		cfg.OAuth2 = &config.OAuth2{
+			ClientID:         c.OAuth2ClientID,
+			ClientSecretFile: c.OAuth2ClientSecretFile,
+			TokenURL:         c.OAuth2TokenURL,
+		}
+		for _, scope := range strings.Split(c.OAuth2Scopes, ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				cfg.OAuth2.Scopes = append(cfg.OAuth2.Scopes, scope)
+			}
+		}
+		set = true
+	}
+	if !set {
+		return nil
+	}
+	return &cfg
+}
+
 // LoadDynamicConfiguration is used to load configuration from a variety of sources using
 // dynamic loader, this is a templated approach
 func LoadDynamicConfiguration(url string, expandvar bool, c *Config) error {
@@ -282,31 +400,157 @@ func LoadDynamicConfiguration(url string, expandvar bool, c *Config) error {
 // applied to the file and must be done manually if LoadBytes
 // is called directly.
 func LoadBytes(buf []byte, expandEnvVars bool, c *Config) error {
-	// (Optionally) expand with environment variables
-	if expandEnvVars {
-		s, err := envsubst.Eval(string(buf), getenv)
-		if err != nil {
-			return fmt.Errorf("unable to substitute config with environment variables: %w", err)
+	return loadBytes(buf, expandEnvVars, "", c)
+}
+
+// loadBytes loads a config from buf. dir is the directory of the config file
+// buf was read from, or empty if it wasn't read from disk.
+func loadBytes(buf []byte, expandEnvVars bool, dir string, c *Config) error {
+	// Facts are detected once for the config file and the files it includes.
+	var facts *templateFacts
+
+	render := func(buf []byte) ([]byte, error) {
+		// (Optionally) render the config template
+		if c.TemplateEnabled {
+			if facts == nil {
+				detected, err := detectTemplateFacts(server.NewLogger(&c.Server), c.templateDetectors())
+				if err != nil {
+					return nil, fmt.Errorf("failed to detect facts for config template: %w", err)
+				}
+				facts = &detected
+			}
+			var err error
+			buf, err = renderTemplate(buf, *facts)
+			if err != nil {
+				return nil, err
+			}
 		}
-		buf = []byte(s)
+
+		// (Optionally) expand with environment variables
+		if expandEnvVars {
+			return expandEnv(buf, c.ExpandEnvStrict)
+		}
+		return buf, nil
+	}
+
+	buf, err := render(buf)
+	if err != nil {
+		return err
+	}
+	buf, err = mergeIncludes(buf, dir, render)
+	if err != nil {
+		return err
+	}
+
+	if c.DryRunTemplate {
+		return &templateDryRunError{rendered: buf}
 	}
 	// Unmarshal yaml config
-	return yaml.UnmarshalStrict(buf, c)
+	if err := yaml.UnmarshalStrict(buf, c); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(buf)
+	c.checksum = hex.EncodeToString(sum[:])
+	return nil
 }
 
-// getenv is a wrapper around os.Getenv that ignores patterns that are numeric
-// regex capture groups (ie "${1}").
-func getenv(name string) string {
-	numericName := true
+// expandEnv replaces references to environment variables in buf with their
+// values. $$ escapes a literal $. Secret references like ${env:VAR} are left
+// untouched, since they are resolved later. If strict is true, referencing an
+// undefined variable is an error, unless the reference specifies a default
+// value.
+func expandEnv(buf []byte, strict bool) ([]byte, error) {
+	in := escapeSecretRefs(string(buf))
+
+	if strict {
+		tree, err := parse.Parse(in)
+		if err != nil {
+			return nil, fmt.Errorf("unable to substitute config with environment variables: %w", err)
+		}
+		if undefined := undefinedEnvVars(tree.Root, nil); len(undefined) > 0 {
+			return nil, fmt.Errorf("config references undefined environment variables: %s. Use ${VAR:-default} to set a default value, or $${VAR} to escape a reference", strings.Join(undefined, ", "))
+		}
+	}
+
+	s, err := envsubst.Eval(in, getenv)
+	if err != nil {
+		return nil, fmt.Errorf("unable to substitute config with environment variables: %w", err)
+	}
+	return []byte(s), nil
+}
+
+// escapeSecretRefs escapes the secret references in s, which envsubst would
+// otherwise parse as substrings of variables named like the secret provider.
+// References which are already escaped are left as is.
+func escapeSecretRefs(s string) string {
+	var (
+		sb   strings.Builder
+		last int
+	)
+	for _, loc := range secretRefRegexp.FindAllStringIndex(s, -1) {
+		if loc[0] > 0 && s[loc[0]-1] == '$' {
+			continue
+		}
+		sb.WriteString(s[last:loc[0]])
+		sb.WriteString("$")
+		sb.WriteString(s[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	sb.WriteString(s[last:])
+	return sb.String()
+}
+
+// envDefaultFuncs are the functions of variable references which handle
+// undefined variables, like ${VAR:-default}.
+var envDefaultFuncs = map[string]struct{}{
+	"-": {}, ":-": {}, "=": {}, ":=": {}, "+": {}, ":+": {}, "?": {}, ":?": {},
+}
 
+// undefinedEnvVars appends the names of undefined environment variables
+// referenced by node without a default value to res, ignoring duplicates.
+func undefinedEnvVars(node parse.Node, res []string) []string {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		for _, n := range node.Nodes {
+			res = undefinedEnvVars(n, res)
+		}
+	case *parse.FuncNode:
+		_, hasDefault := envDefaultFuncs[node.Name]
+		if !hasDefault && !isNumeric(node.Param) && !contains(res, node.Param) {
+			if _, ok := os.LookupEnv(node.Param); !ok {
+				res = append(res, node.Param)
+			}
+		}
+		for _, n := range node.Args {
+			res = undefinedEnvVars(n, res)
+		}
+	}
+	return res
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isNumeric returns whether name only contains digits.
+func isNumeric(name string) bool {
 	for _, r := range name {
 		if !unicode.IsDigit(r) {
-			numericName = false
-			break
+			return false
 		}
 	}
+	return true
+}
 
-	if numericName {
+// getenv is a wrapper around os.Getenv that ignores patterns that are numeric
+// regex capture groups (ie "${1}").
+func getenv(name string) string {
+	if isNumeric(name) {
 		// We need to add ${} back in since envsubst removes it.
 		return fmt.Sprintf("${%s}", name)
 	}
@@ -333,6 +577,8 @@ func Load(fs *flag.FlagSet, args []string) (*Config, error) {
 				return fmt.Errorf("feature %q can not be enabled with file type %s", featRemoteConfigs, fileTypeDynamic)
 			} else if expandArgs {
 				return fmt.Errorf("-config.expand-env can not be used with file type %s", fileTypeDynamic)
+			} else if c.TemplateEnabled {
+				return fmt.Errorf("-config.template can not be used with file type %s", fileTypeDynamic)
 			}
 
 			return LoadDynamicConfiguration(path, expandArgs, c)
@@ -372,9 +618,18 @@ func load(fs *flag.FlagSet, args []string, loader loaderFunc) (*Config, error) {
 		os.Exit(0)
 	}
 
+	if cfg.DryRunTemplate && !cfg.TemplateEnabled {
+		return nil, fmt.Errorf("-dry-run-template requires -config.template")
+	}
+
 	if file == "" {
 		return nil, fmt.Errorf("-config.file flag required")
 	} else if err := loader(file, fileType, configExpandEnv, &cfg); err != nil {
+		var dryRun *templateDryRunError
+		if errors.As(err, &dryRun) {
+			fmt.Print(string(dryRun.rendered))
+			os.Exit(0)
+		}
 		return nil, fmt.Errorf("error loading config file %s: %w", file, err)
 	}
 
@@ -399,6 +654,13 @@ func load(fs *flag.FlagSet, args []string, loader loaderFunc) (*Config, error) {
 		cfg.Metrics.Global.ExtraMetrics = true
 	}
 
+	// Resource attributes must be applied before defaults, which copy the
+	// global external labels into every metrics instance.
+	if cfg.ResourceDetection.Enabled {
+		l := server.NewLogger(&cfg.Server)
+		cfg.applyResourceDetection(detectedResources.Detect(context.Background(), l, cfg.ResourceDetection))
+	}
+
 	// Finally, apply defaults to config that wasn't specified by file or flag
 	if err := cfg.Validate(fs); err != nil {
 		return nil, fmt.Errorf("error in config file: %w", err)
@@ -128,6 +128,48 @@ metrics:
 	require.Equal(t, expect, c.Metrics.Global.Prometheus.ExternalLabels)
 }
 
+func TestConfig_ExpandEnv(t *testing.T) {
+	t.Setenv("AGENT_TEST_HOST", "agent-1")
+	t.Setenv("AGENT_TEST_EMPTY", "")
+
+	tt := []struct {
+		name   string
+		input  string
+		strict bool
+		expect string
+		err    string
+	}{
+		{name: "defined", input: "host: ${AGENT_TEST_HOST}", expect: "host: agent-1"},
+		{name: "empty", input: "host: ${AGENT_TEST_EMPTY}", expect: "host: "},
+		{name: "default", input: "host: ${AGENT_TEST_UNDEFINED:-localhost}", expect: "host: localhost"},
+		{name: "escaped", input: "password: pa$$word, ref: $${AGENT_TEST_UNDEFINED}", expect: "password: pa$word, ref: ${AGENT_TEST_UNDEFINED}"},
+		{name: "capture group", input: "replacement: ${1}:${2}", expect: "replacement: ${1}:${2}"},
+		{name: "secret refs", input: "password: ${env:AGENT_TEST_UNDEFINED}, escaped: $${file:/secret}", expect: "password: ${env:AGENT_TEST_UNDEFINED}, escaped: ${file:/secret}"},
+		{name: "undefined", input: "host: ${AGENT_TEST_UNDEFINED}", expect: "host: "},
+		{name: "unbraced", input: "password: pa$AGENT_TEST_HOST", expect: "password: pa$AGENT_TEST_HOST"},
+		{
+			name:   "strict undefined",
+			input:  "host: ${AGENT_TEST_UNDEFINED}, password: pa$AGENT_TEST_PASSWORD, again: ${AGENT_TEST_UNDEFINED}",
+			strict: true,
+			err:    "config references undefined environment variables: AGENT_TEST_UNDEFINED.",
+		},
+		{name: "strict default", input: "host: ${AGENT_TEST_UNDEFINED:-localhost}", strict: true, expect: "host: localhost"},
+		{name: "strict secret refs", input: "password: ${vault:secret/data/db#password}", strict: true, expect: "password: ${vault:secret/data/db#password}"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := expandEnv([]byte(tc.input), tc.strict)
+			if tc.err != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, string(actual))
+		})
+	}
+}
+
 func TestConfig_FlagsAreAccepted(t *testing.T) {
 	cfg := `
 metrics:
@@ -452,3 +494,56 @@ func TestLoadDynamicConfigurationExpandError(t *testing.T) {
 	assert.Error(t, err)
 	assert.True(t, strings.Contains(err.Error(), "expand var is not supported when using dynamic configuration, use gomplate env instead"))
 }
+
+func TestConfig_ResourceDetection(t *testing.T) {
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "deployment.environment=prod,team=payments")
+
+	cfg := `
+resource_detection:
+  enabled: true
+  detectors: [env]
+metrics:
+  wal_directory: /tmp/wal
+  global:
+    external_labels:
+      team: observability
+logs:
+  configs:
+  - name: default
+    positions:
+      filename: /tmp/positions.yaml
+    clients:
+    - url: http://loki:3100/loki/api/v1/push
+traces:
+  configs:
+  - name: default
+    receivers:
+      jaeger:
+        protocols:
+          thrift_compact:
+    remote_write:
+    - endpoint: tempo:4317`
+
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	c, err := load(fs, []string{"-config.file", "test"}, func(_, _ string, _ bool, c *Config) error {
+		return LoadBytes([]byte(cfg), false, c)
+	})
+	require.NoError(t, err)
+
+	// Explicitly configured labels take precedence over detected ones.
+	require.Equal(t, labels.FromStrings(
+		"deployment_environment", "prod",
+		"team", "observability",
+	), c.Metrics.Global.Prometheus.ExternalLabels)
+
+	require.Equal(t, model.LabelSet{
+		"deployment_environment": "prod",
+		"team":                   "payments",
+	}, c.Logs.Configs[0].ClientConfigs[0].ExternalLabels.LabelSet)
+
+	require.Equal(t, map[string]string{
+		"deployment.environment": "prod",
+		"team":                   "payments",
+	}, c.Traces.Configs[0].ResourceAttributes)
+	require.False(t, c.Traces.Configs[0].OverrideResourceAttributes)
+}
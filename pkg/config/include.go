@@ -0,0 +1,171 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// includeKey is the top-level key of a config file listing the files to
+// merge into it.
+const includeKey = "include"
+
+// mergeIncludes merges the files included by the config in buf into it. dir
+// is the directory relative paths of included files are resolved from, and
+// is empty if buf wasn't loaded from disk. render is called with the contents
+// of each included file before it's merged.
+//
+// buf is returned unchanged if it doesn't include other files.
+func mergeIncludes(buf []byte, dir string, render func([]byte) ([]byte, error)) ([]byte, error) {
+	var root yaml.MapSlice
+	if err := yaml.Unmarshal(buf, &root); err != nil {
+		// Leave reporting the error to unmarshaling the config.
+		return buf, nil
+	}
+	patterns, root, err := popIncludes(root)
+	if err != nil {
+		return nil, err
+	} else if patterns == nil {
+		return buf, nil
+	} else if dir == "" {
+		return nil, fmt.Errorf("%s is only supported in config files loaded from disk", includeKey)
+	}
+
+	files, err := includedFiles(dir, patterns)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		bb, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading included config file %w", err)
+		}
+		bb, err = render(bb)
+		if err != nil {
+			return nil, fmt.Errorf("included config file %s: %w", file, err)
+		}
+
+		var included yaml.MapSlice
+		if err := yaml.Unmarshal(bb, &included); err != nil {
+			return nil, fmt.Errorf("included config file %s: %w", file, err)
+		}
+		if nested, _, _ := popIncludes(included); nested != nil {
+			return nil, fmt.Errorf("included config file %s: nested %s is not supported", file, includeKey)
+		}
+		root = mergeYAML(root, included).(yaml.MapSlice)
+	}
+	return yaml.Marshal(root)
+}
+
+// popIncludes returns the patterns of the include key of root and root
+// without the key. patterns is nil if root has no include key.
+func popIncludes(root yaml.MapSlice) (patterns []string, rest yaml.MapSlice, err error) {
+	for i, item := range root {
+		if item.Key != includeKey {
+			continue
+		}
+		rest = append(append(rest, root[:i]...), root[i+1:]...)
+
+		switch v := item.Value.(type) {
+		case string:
+			return []string{v}, rest, nil
+		case []interface{}:
+			patterns = make([]string, 0, len(v))
+			for _, p := range v {
+				s, ok := p.(string)
+				if !ok {
+					return nil, nil, fmt.Errorf("%s must be a list of paths", includeKey)
+				}
+				patterns = append(patterns, s)
+			}
+			return patterns, rest, nil
+		case nil:
+			return []string{}, rest, nil
+		default:
+			return nil, nil, fmt.Errorf("%s must be a list of paths", includeKey)
+		}
+	}
+	return nil, root, nil
+}
+
+// includedFiles returns the files matching patterns. Patterns are glob
+// patterns relative to dir, or directories whose .yml and .yaml files are
+// included. Files are returned in the order of the patterns which matched
+// them, with the files matching a pattern sorted by name. Patterns matching
+// no files are ignored.
+func includedFiles(dir string, patterns []string) ([]string, error) {
+	var res []string
+	seen := make(map[string]struct{})
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+
+		var matches []string
+		if fi, err := os.Stat(pattern); err == nil && fi.IsDir() {
+			for _, ext := range []string{"*.yml", "*.yaml"} {
+				m, err := filepath.Glob(filepath.Join(pattern, ext))
+				if err != nil {
+					return nil, err
+				}
+				matches = append(matches, m...)
+			}
+			sort.Strings(matches)
+		} else {
+			m, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s pattern %q: %w", includeKey, pattern, err)
+			}
+			matches = m
+		}
+
+		for _, m := range matches {
+			if fi, err := os.Stat(m); err != nil || fi.IsDir() {
+				continue
+			}
+			if _, ok := seen[m]; ok {
+				continue
+			}
+			seen[m] = struct{}{}
+			res = append(res, m)
+		}
+	}
+	return res, nil
+}
+
+// mergeYAML deep-merges src into dst, which are values decoded from YAML.
+// Maps are merged key by key, lists are concatenated, and other values of
+// src replace the values of dst.
+func mergeYAML(dst, src interface{}) interface{} {
+	switch src := src.(type) {
+	case yaml.MapSlice:
+		dst, ok := dst.(yaml.MapSlice)
+		if !ok {
+			return src
+		}
+		res := append(yaml.MapSlice(nil), dst...)
+	Outer:
+		for _, item := range src {
+			for i := range res {
+				if res[i].Key == item.Key {
+					res[i].Value = mergeYAML(res[i].Value, item.Value)
+					continue Outer
+				}
+			}
+			res = append(res, item)
+		}
+		return res
+	case []interface{}:
+		dst, ok := dst.([]interface{})
+		if !ok {
+			return src
+		}
+		return append(append([]interface{}(nil), dst...), src...)
+	default:
+		return src
+	}
+}
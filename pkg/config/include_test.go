@@ -0,0 +1,109 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMergeYAML(t *testing.T) {
+	var dst, src yaml.MapSlice
+	require.NoError(t, yaml.Unmarshal([]byte(`
+server:
+  log_level: info
+metrics:
+  configs:
+  - name: default
+integrations:
+  agent:
+    enabled: true
+`), &dst))
+	require.NoError(t, yaml.Unmarshal([]byte(`
+server:
+  log_level: debug
+metrics:
+  configs:
+  - name: extra
+integrations:
+  node_exporter:
+    enabled: true
+`), &src))
+
+	bb, err := yaml.Marshal(mergeYAML(dst, src))
+	require.NoError(t, err)
+	require.YAMLEq(t, `
+server:
+  log_level: debug
+metrics:
+  configs:
+  - name: default
+  - name: extra
+integrations:
+  agent:
+    enabled: true
+  node_exporter:
+    enabled: true
+`, string(bb))
+}
+
+func TestLoadFile_Include(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, contents string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+	}
+
+	write("agent.yml", `
+include:
+- conf.d
+- extra/*.yml
+metrics:
+  wal_directory: /tmp/wal
+  configs:
+  - name: default
+`)
+	write("conf.d/10-metrics.yml", `
+metrics:
+  configs:
+  - name: dropped-in
+`)
+	write("conf.d/20-server.yaml", `
+server:
+  log_level: debug
+`)
+	write("conf.d/ignored.txt", `not: yaml: at all`)
+	write("extra/logs.yml", `
+logs:
+  positions_directory: /tmp/positions
+`)
+
+	var cfg Config
+	require.NoError(t, LoadFile(filepath.Join(dir, "agent.yml"), false, &cfg))
+	require.Equal(t, "debug", cfg.Server.LogLevel.String())
+	require.Len(t, cfg.Metrics.Configs, 2)
+	require.Equal(t, "default", cfg.Metrics.Configs[0].Name)
+	require.Equal(t, "dropped-in", cfg.Metrics.Configs[1].Name)
+	require.NotNil(t, cfg.Logs)
+	require.Equal(t, "/tmp/positions", cfg.Logs.PositionsDirectory)
+
+	t.Run("nested includes", func(t *testing.T) {
+		write("conf.d/30-nested.yml", "include: [more.yml]")
+		defer os.Remove(filepath.Join(dir, "conf.d/30-nested.yml"))
+
+		var cfg Config
+		err := LoadFile(filepath.Join(dir, "agent.yml"), false, &cfg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "nested include is not supported")
+	})
+
+	t.Run("not from disk", func(t *testing.T) {
+		var cfg Config
+		err := LoadBytes([]byte("include: [conf.d]"), false, &cfg)
+		require.EqualError(t, err, "include is only supported in config files loaded from disk")
+	})
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/gorilla/mux"
@@ -37,6 +38,16 @@ type VersionedIntegrations struct {
 	configV1 *v1.ManagerConfig
 	configV2 *v2.SubsystemOptions
 
+	// secrets are the fields with secret references resolved by setVersion,
+	// which are redacted when marshaling.
+	secrets []redactedField
+
+	// secretsFromBackend is set if any secret was fetched from a secret
+	// manager, and secretsRefreshIn is how long until the first of them
+	// expires. It's zero if no secret expires.
+	secretsFromBackend bool
+	secretsRefreshIn   time.Duration
+
 	// ExtraIntegrations is used when adding any integrations NOT in the default agent configuration
 	ExtraIntegrations []v2.Config
 }
@@ -56,14 +67,30 @@ func (c *VersionedIntegrations) UnmarshalYAML(unmarshal func(interface{}) error)
 
 // MarshalYAML implements yaml.Marshaler.
 func (c VersionedIntegrations) MarshalYAML() (interface{}, error) {
+	var cfg interface{}
 	switch {
 	case c.configV1 != nil:
-		return c.configV1, nil
+		cfg = c.configV1
 	case c.configV2 != nil:
-		return c.configV2, nil
+		cfg = c.configV2
 	default:
 		return c.raw, nil
 	}
+	if len(c.secrets) == 0 {
+		return cfg, nil
+	}
+
+	// Marshal the config to redact the fields with resolved secrets which
+	// aren't Secrets.
+	bb, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var m yaml.MapSlice
+	if err := yaml.Unmarshal(bb, &m); err != nil {
+		return nil, err
+	}
+	return redactSecrets(m, c.secrets), nil
 }
 
 // IsZero implements yaml.IsZeroer.
@@ -91,18 +118,27 @@ func (c *VersionedIntegrations) ApplyDefaults(scfg *server.Config, mcfg *metrics
 func (c *VersionedIntegrations) setVersion(v integrationsVersion) error {
 	c.version = v
 
+	r := newSecretResolver()
+	raw, err := r.resolveYAML(c.raw)
+	if err != nil {
+		return fmt.Errorf("failed to resolve secret references in integrations: %w", err)
+	}
+	c.secrets = r.resolved
+	c.secretsFromBackend = r.fromBackend
+	c.secretsRefreshIn = r.refreshIn
+
 	switch c.version {
 	case integrationsVersion1:
 		cfg := v1.DefaultManagerConfig
 		c.configV1 = &cfg
-		return yaml.UnmarshalStrict(c.raw, c.configV1)
+		return yaml.UnmarshalStrict(raw, c.configV1)
 	case integrationsVersion2:
 		cfg := v2.DefaultSubsystemOptions
 		// this is needed for dynamic configuration, the unmarshal doesnt work correctly if
 		// this is not nil.
 		c.configV1 = nil
 		c.configV2 = &cfg
-		err := yaml.UnmarshalStrict(c.raw, c.configV2)
+		err := yaml.UnmarshalStrict(raw, c.configV2)
 		if err != nil {
 			return err
 		}
@@ -113,6 +149,56 @@ func (c *VersionedIntegrations) setVersion(v integrationsVersion) error {
 	}
 }
 
+// IntegrationError is an error in the config of an integration.
+type IntegrationError struct {
+	// Integration is the name of the integration.
+	Integration string
+	// Instance is the name of the instance of the integration, if it's named.
+	Instance string
+	Err      error
+}
+
+// Error implements error.
+func (e IntegrationError) Error() string {
+	if e.Instance != "" {
+		return fmt.Sprintf("integration %s (instance %s): %s", e.Integration, e.Instance, e.Err)
+	}
+	return fmt.Sprintf("integration %s: %s", e.Integration, e.Err)
+}
+
+// Check validates the configs of enabled integrations which can be validated
+// without creating them, returning an error for every invalid config. Check
+// must be called after the config is loaded.
+func (c *VersionedIntegrations) Check() []IntegrationError {
+	var errs []IntegrationError
+	check := func(cfg v1.Config, name, instance string) {
+		vc, ok := cfg.(v1.ValidatableConfig)
+		if !ok {
+			return
+		}
+		if err := vc.Validate(); err != nil {
+			errs = append(errs, IntegrationError{Integration: name, Instance: instance, Err: err})
+		}
+	}
+
+	switch {
+	case c.configV1 != nil:
+		for _, ic := range c.configV1.Integrations {
+			if ic.Common.Enabled {
+				check(ic.Config, ic.Name(), ic.Common.Name)
+			}
+		}
+	case c.configV2 != nil:
+		for _, ic := range c.configV2.Configs {
+			if uc, ok := ic.(v2.UpgradedConfig); ok {
+				inner, _ := uc.LegacyConfig()
+				check(inner, ic.Name(), "")
+			}
+		}
+	}
+	return errs
+}
+
 // IntegrationsGlobals is a global struct shared across integrations.
 type IntegrationsGlobals = v2.Globals
 
@@ -120,6 +206,7 @@ type IntegrationsGlobals = v2.Globals
 type Integrations interface {
 	ApplyConfig(*VersionedIntegrations, IntegrationsGlobals) error
 	WireAPI(*mux.Router)
+	Ready() bool
 	Stop()
 }
 
@@ -157,3 +244,7 @@ func (s *v2Integrations) ApplyConfig(cfg *VersionedIntegrations, globals Integra
 	globals.SubsystemOpts = *cfg.configV2
 	return s.Subsystem.ApplyConfig(globals)
 }
+
+// Ready always returns true, since integrations-next doesn't track the health
+// of integrations.
+func (s *v2Integrations) Ready() bool { return true }
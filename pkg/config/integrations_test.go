@@ -43,3 +43,66 @@ integrations:
 	require.NoError(t, err)
 	require.NotNil(t, c.Integrations.configV2)
 }
+
+func TestIntegrations_Check(t *testing.T) {
+	tt := []struct {
+		name   string
+		cfg    string
+		flags  []string
+		expect []string
+	}{
+		{
+			name: "v1",
+			cfg: `
+integrations:
+  ssl_exporter:
+  - name: valid
+    enabled: true
+    ssl_targets:
+    - name: grafana
+      target: grafana.com:443
+  - name: invalid
+    enabled: true
+    ssl_targets:
+    - name: grafana
+      target: grafana.com:443
+      module: fake
+  - name: disabled
+    enabled: false
+    ssl_targets:
+    - name: grafana
+      target: grafana.com:443
+      module: fake`,
+			expect: []string{`integration ssl_exporter (instance invalid): invalid module for ssl_target "grafana": unknown module "fake"`},
+		},
+		{
+			name: "v2",
+			cfg: `
+integrations:
+  ssl_configs:
+  - ssl_targets:
+    - name: grafana
+      target: grafana.com:443
+      module: fake`,
+			flags:  []string{"-enable-features=integrations-next"},
+			expect: []string{`integration ssl: invalid module for ssl_target "grafana": unknown module "fake"`},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ExitOnError)
+			args := append([]string{"-config.file", "test"}, tc.flags...)
+			c, err := load(fs, args, func(_, _ string, _ bool, c *Config) error {
+				return LoadBytes([]byte("metrics:\n  wal_directory: /tmp/wal\n"+tc.cfg), false, c)
+			})
+			require.NoError(t, err)
+
+			var actual []string
+			for _, err := range c.Integrations.Check() {
+				actual = append(actual, err.Error())
+			}
+			require.Equal(t, tc.expect, actual)
+		})
+	}
+}
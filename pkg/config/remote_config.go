@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/url"
 
+	"github.com/hairyhenderson/gomplate/v3/data"
 	"github.com/prometheus/common/config"
 )
 
@@ -13,6 +14,8 @@ import (
 const (
 	httpScheme  = "http"
 	httpsScheme = "https"
+	s3Scheme    = "s3"
+	gcsScheme   = "gs"
 )
 
 // remoteOpts struct contains agent remote config options
@@ -49,6 +52,8 @@ func newRemoteConfig(rawURL string, opts *remoteOpts) (remoteProvider, error) {
 			return nil, fmt.Errorf("error constructing httpProvider: %w", err)
 		}
 		return httpP, nil
+	case s3Scheme, gcsScheme:
+		return &blobProvider{myURL: u}, nil
 	default:
 		return nil, fmt.Errorf("remote config scheme not supported: %s", u.Scheme)
 	}
@@ -98,3 +103,19 @@ func (p httpProvider) retrieve() ([]byte, error) {
 	}
 	return bb, nil
 }
+
+// blobProvider - s3/gs provider. Credentials are taken from the environment
+// of the agent, like the default credentials of the AWS SDK or Google
+// application default credentials.
+type blobProvider struct {
+	myURL *url.URL
+}
+
+// retrieve implements remoteProvider and fetches the config
+func (p blobProvider) retrieve() ([]byte, error) {
+	bb, err := data.ReadBlob(*p.myURL)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching config: %w", err)
+	}
+	return bb, nil
+}
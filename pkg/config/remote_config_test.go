@@ -5,9 +5,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -127,3 +130,77 @@ metrics:
 		})
 	}
 }
+
+func TestLoadRemote_Credentials(t *testing.T) {
+	testCfg := `
+metrics:
+  global:
+    scrape_timeout: 33s
+`
+	tempDir := t.TempDir()
+	tokenFile := filepath.Join(tempDir, "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("bearer-token"), 0644))
+	secretFile := filepath.Join(tempDir, "client-secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("client-secret"), 0644))
+
+	tokenSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "agent" || secret != "client-secret" || r.FormValue("scope") != "config read" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token": "oauth2-token", "token_type": "Bearer"}`))
+	}))
+	defer tokenSvr.Close()
+
+	tests := []struct {
+		name  string
+		cfg   Config
+		token string
+	}{
+		{
+			name:  "bearer token file",
+			cfg:   Config{BearerTokenFile: tokenFile},
+			token: "bearer-token",
+		},
+		{
+			name: "oauth2",
+			cfg: Config{
+				OAuth2ClientID:         "agent",
+				OAuth2ClientSecretFile: secretFile,
+				OAuth2TokenURL:         tokenSvr.URL,
+				OAuth2Scopes:           "config, read",
+			},
+			token: "oauth2-token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer "+tt.token {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_, _ = w.Write([]byte(testCfg))
+			}))
+			defer svr.Close()
+
+			c := tt.cfg
+			require.NoError(t, LoadRemote(svr.URL+"/agent.yml", false, &c))
+			require.Equal(t, model.Duration(33*time.Second), c.Metrics.Global.Prometheus.ScrapeTimeout)
+		})
+	}
+}
+
+func TestLoadBytes_Checksum(t *testing.T) {
+	var a, b, c Config
+	require.NoError(t, LoadBytes([]byte("server:\n  log_level: debug\n"), false, &a))
+	require.NoError(t, LoadBytes([]byte("server:\n  log_level: debug\n"), false, &b))
+	require.NoError(t, LoadBytes([]byte("server:\n  log_level: info\n"), false, &c))
+
+	require.NotEmpty(t, a.Checksum())
+	require.Equal(t, a.Checksum(), b.Checksum())
+	require.NotEqual(t, a.Checksum(), c.Checksum())
+}
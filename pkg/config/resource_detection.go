@@ -0,0 +1,68 @@
+package config
+
+import (
+	"github.com/grafana/agent/pkg/resourcedetection"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// detectedResources holds the attributes found by resource detection for the
+// lifetime of the process. The detectors run when the config file is first
+// loaded, and reloading the config file reuses their attributes.
+var detectedResources resourcedetection.Cache
+
+// applyResourceDetection injects the detected attributes attrs into every
+// pipeline: as external labels for metrics, as client external labels for
+// logs, and as resource attributes for traces.
+//
+// Values which were explicitly configured in a pipeline are kept unless
+// resource detection is configured to override them.
+func (c *Config) applyResourceDetection(attrs resourcedetection.Attributes) {
+	override := c.ResourceDetection.Override
+	detectedLabels := attrs.Labels()
+
+	// Metrics
+	var (
+		global = &c.Metrics.Global.Prometheus
+		lb     = labels.NewBuilder(global.ExternalLabels)
+	)
+	for name, value := range detectedLabels {
+		if override || global.ExternalLabels.Get(string(name)) == "" {
+			lb.Set(string(name), string(value))
+		}
+	}
+	global.ExternalLabels = lb.Labels()
+
+	// Logs
+	if c.Logs != nil {
+		for _, inst := range c.Logs.Configs {
+			for i := range inst.ClientConfigs {
+				cc := &inst.ClientConfigs[i]
+				if cc.ExternalLabels.LabelSet == nil {
+					cc.ExternalLabels.LabelSet = make(model.LabelSet, len(detectedLabels))
+				}
+				for name, value := range detectedLabels {
+					if _, exists := cc.ExternalLabels.LabelSet[name]; override || !exists {
+						cc.ExternalLabels.LabelSet[name] = value
+					}
+				}
+			}
+		}
+	}
+
+	// Traces
+	for i := range c.Traces.Configs {
+		inst := &c.Traces.Configs[i]
+		if override {
+			inst.OverrideResourceAttributes = true
+		}
+		if inst.ResourceAttributes == nil {
+			inst.ResourceAttributes = make(map[string]string, len(attrs))
+		}
+		for k, v := range attrs {
+			if _, exists := inst.ResourceAttributes[k]; override || !exists {
+				inst.ResourceAttributes[k] = v
+			}
+		}
+	}
+}
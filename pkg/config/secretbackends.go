@@ -0,0 +1,197 @@
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	vault "github.com/hashicorp/vault/api"
+	gcpsecretmanager "google.golang.org/api/secretmanager/v1"
+)
+
+// Schemes of secret references resolved through secret managers.
+const (
+	vaultScheme             = "vault"
+	awsSecretsManagerScheme = "aws_secrets_manager"
+	gcpSecretManagerScheme  = "gcp_secret_manager"
+)
+
+// secretBackend fetches secrets from a secret manager.
+type secretBackend interface {
+	// fetch returns the secret at path, or the value of key in the secret if
+	// key is set. fetch also returns how long the secret is valid for, which
+	// is zero for secrets which don't expire.
+	fetch(ctx context.Context, path, key string) (string, time.Duration, error)
+}
+
+// newSecretBackend creates the backend of scheme, configured from the
+// environment of the agent.
+func newSecretBackend(scheme string) (secretBackend, error) {
+	switch scheme {
+	case vaultScheme:
+		return newVaultBackend()
+	case awsSecretsManagerScheme:
+		return newAWSSecretsManagerBackend()
+	case gcpSecretManagerScheme:
+		return newGCPSecretManagerBackend()
+	default:
+		return nil, fmt.Errorf("unsupported scheme %s", scheme)
+	}
+}
+
+// vaultBackend reads secrets from HashiCorp Vault. The address of Vault and
+// the token used are read from the standard Vault environment variables,
+// like VAULT_ADDR and VAULT_TOKEN.
+type vaultBackend struct {
+	client *vault.Client
+}
+
+func newVaultBackend() (*vaultBackend, error) {
+	client, err := vault.NewClient(vault.DefaultConfig())
+	if err != nil {
+		return nil, err
+	}
+	return &vaultBackend{client: client}, nil
+}
+
+func (b *vaultBackend) fetch(_ context.Context, path, key string) (string, time.Duration, error) {
+	if key == "" {
+		return "", 0, fmt.Errorf("reference must end with #<key>")
+	}
+
+	secret, err := b.client.Logical().Read(path)
+	if err != nil {
+		return "", 0, err
+	}
+	if secret == nil {
+		return "", 0, fmt.Errorf("vault secret %s not found", path)
+	}
+
+	data := secret.Data
+	// Secrets of the KV version 2 secrets engine nest their data under data,
+	// next to their metadata.
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+	value, ok := data[key]
+	if !ok || value == nil {
+		return "", 0, fmt.Errorf("vault secret %s has no key %s", path, key)
+	}
+	return stringValue(value), time.Duration(secret.LeaseDuration) * time.Second, nil
+}
+
+// awsSecretsManagerBackend reads secrets from AWS Secrets Manager, using the
+// default credential chain of the AWS SDK.
+type awsSecretsManagerBackend struct {
+	sess *session.Session
+}
+
+func newAWSSecretsManagerBackend() (*awsSecretsManagerBackend, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &awsSecretsManagerBackend{sess: sess}, nil
+}
+
+func (b *awsSecretsManagerBackend) fetch(ctx context.Context, path, key string) (string, time.Duration, error) {
+	// Secrets referenced by ARN are read from the region of the ARN.
+	cfg := aws.NewConfig()
+	if a, err := arn.Parse(path); err == nil {
+		cfg = cfg.WithRegion(a.Region)
+	}
+
+	out, err := secretsmanager.New(b.sess, cfg).GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(path),
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	value := string(out.SecretBinary)
+	if out.SecretString != nil {
+		value = *out.SecretString
+	}
+	if key == "" {
+		return value, 0, nil
+	}
+	value, err = jsonSecretKey(value, key)
+	return value, 0, err
+}
+
+// gcpSecretManagerBackend reads secrets from GCP Secret Manager, using the
+// Google application default credentials.
+type gcpSecretManagerBackend struct {
+	svc *gcpsecretmanager.Service
+}
+
+func newGCPSecretManagerBackend() (*gcpSecretManagerBackend, error) {
+	svc, err := gcpsecretmanager.NewService(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return &gcpSecretManagerBackend{svc: svc}, nil
+}
+
+func (b *gcpSecretManagerBackend) fetch(ctx context.Context, path, key string) (string, time.Duration, error) {
+	// Paths have the form projects/<project>/secrets/<name>, optionally
+	// followed by /versions/<version>. The latest version is used by default.
+	name := path
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+
+	resp, err := b.svc.Projects.Secrets.Versions.Access(name).Context(ctx).Do()
+	if err != nil {
+		return "", 0, err
+	}
+	if resp.Payload == nil {
+		return "", 0, fmt.Errorf("secret %s has no payload", name)
+	}
+	bb, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to decode secret %s: %w", name, err)
+	}
+	if key == "" {
+		return string(bb), 0, nil
+	}
+	value, err := jsonSecretKey(string(bb), key)
+	return value, 0, err
+}
+
+// jsonSecretKey returns the value of key in secret, which is a JSON object.
+func jsonSecretKey(secret, key string) (string, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &m); err != nil {
+		return "", fmt.Errorf("secret isn't a JSON object, so #%s can't be read: %w", key, err)
+	}
+	value, ok := m[key]
+	if !ok || value == nil {
+		return "", fmt.Errorf("secret has no key %s", key)
+	}
+	return stringValue(value), nil
+}
+
+// stringValue returns v as a string. Values which aren't strings are
+// formatted as JSON.
+func stringValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	bb, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(bb)
+}
@@ -0,0 +1,281 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// secretRefRegexp matches references to secrets in the string values of
+// integration configs. References have one of the forms:
+//
+//	${env:<variable>}
+//	${file:<path>}
+//	${kubernetes_secret:<namespace>/<name>#<key>}
+//	${vault:<path>#<key>}
+//	${aws_secrets_manager:<secret id>[#<key>]}
+//	${gcp_secret_manager:projects/<project>/secrets/<name>[/versions/<version>][#<key>]}
+var secretRefRegexp = regexp.MustCompile(`\$\{(env|file|kubernetes_secret|vault|aws_secrets_manager|gcp_secret_manager):([^}]*)\}`)
+
+// secretRefTimeout is the timeout for resolving a Kubernetes secret.
+const secretRefTimeout = 30 * time.Second
+
+// secretResolver resolves the secret references of integration configs.
+type secretResolver struct {
+	// kubeClient returns the client used for resolving kubernetes_secret
+	// references. It's only called if the config references a Kubernetes
+	// secret.
+	kubeClient func() (kubernetes.Interface, error)
+
+	client kubernetes.Interface
+
+	// newBackend returns the backend of secret managers like Vault. It's only
+	// called for schemes which are referenced by the config.
+	newBackend func(scheme string) (secretBackend, error)
+
+	backends map[string]secretBackend
+
+	// resolved holds the fields with secret references, so they can be
+	// redacted when the config is marshaled.
+	resolved []redactedField
+
+	// fromBackend is set if any secret was fetched from a secret manager, and
+	// refreshIn is how long until the first of them should be fetched again
+	// since it expires. refreshIn is zero if no secret expires.
+	fromBackend bool
+	refreshIn   time.Duration
+}
+
+// redactedField is a field of a config which referenced secrets.
+type redactedField struct {
+	// path holds the keys of maps and indexes of lists leading to the field.
+	path []interface{}
+	// value is the value of the field with its references replaced by
+	// <secret>.
+	value string
+}
+
+func newSecretResolver() *secretResolver {
+	return &secretResolver{kubeClient: newKubeClient, newBackend: newSecretBackend}
+}
+
+// newKubeClient returns a client of the cluster the agent runs in, or of the
+// current context of the kubeconfig when running outside of a cluster.
+func newKubeClient() (kubernetes.Interface, error) {
+	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(),
+		&clientcmd.ConfigOverrides{},
+	)
+	config, err := kubeConfig.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// resolveYAML returns buf with all secret references in string values
+// replaced by the referenced secrets. buf is returned unmodified if it
+// doesn't reference any secrets.
+func (r *secretResolver) resolveYAML(buf []byte) ([]byte, error) {
+	if !secretRefRegexp.Match(buf) {
+		return buf, nil
+	}
+
+	var m yaml.MapSlice
+	if err := yaml.Unmarshal(buf, &m); err != nil {
+		return nil, err
+	}
+	resolved, err := r.resolveValue(m, nil)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(resolved)
+}
+
+// resolveValue resolves the secret references in the strings of v, which is
+// a value decoded from YAML found at path.
+func (r *secretResolver) resolveValue(v interface{}, path []interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		for i := range v {
+			value, err := r.resolveValue(v[i].Value, appendPath(path, v[i].Key))
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", v[i].Key, err)
+			}
+			v[i].Value = value
+		}
+		return v, nil
+	case []interface{}:
+		for i := range v {
+			value, err := r.resolveValue(v[i], appendPath(path, i))
+			if err != nil {
+				return nil, err
+			}
+			v[i] = value
+		}
+		return v, nil
+	case string:
+		if !secretRefRegexp.MatchString(v) {
+			return v, nil
+		}
+		r.resolved = append(r.resolved, redactedField{
+			path:  path,
+			value: secretRefRegexp.ReplaceAllString(v, "<secret>"),
+		})
+		return r.resolveString(v)
+	default:
+		return v, nil
+	}
+}
+
+// appendPath returns a copy of path with elem appended, so paths of sibling
+// fields don't share their backing arrays.
+func appendPath(path []interface{}, elem interface{}) []interface{} {
+	return append(append(make([]interface{}, 0, len(path)+1), path...), elem)
+}
+
+// resolveString replaces the secret references in s.
+func (r *secretResolver) resolveString(s string) (string, error) {
+	var firstErr error
+	res := secretRefRegexp.ReplaceAllStringFunc(s, func(ref string) string {
+		if firstErr != nil {
+			return ref
+		}
+		match := secretRefRegexp.FindStringSubmatch(ref)
+		value, err := r.resolve(match[1], match[2])
+		if err != nil {
+			firstErr = fmt.Errorf("failed to resolve %s: %w", ref, err)
+			return ref
+		}
+		return value
+	})
+	return res, firstErr
+}
+
+// resolve returns the secret referenced by path of scheme.
+func (r *secretResolver) resolve(scheme, path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("reference must not be empty")
+	}
+
+	switch scheme {
+	case "env":
+		value, ok := os.LookupEnv(path)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", path)
+		}
+		return value, nil
+
+	case "file":
+		bb, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(bb), "\r\n"), nil
+
+	case "kubernetes_secret":
+		i := strings.LastIndex(path, "#")
+		if i < 0 || i == len(path)-1 {
+			return "", fmt.Errorf("reference must end with #<key>")
+		}
+		name, key := path[:i], path[i+1:]
+		parts := strings.Split(name, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return "", fmt.Errorf("reference must have the form <namespace>/<name>#<key>")
+		}
+
+		if r.client == nil {
+			client, err := r.kubeClient()
+			if err != nil {
+				return "", fmt.Errorf("failed to create kubernetes client: %w", err)
+			}
+			r.client = client
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), secretRefTimeout)
+		defer cancel()
+		secret, err := r.client.CoreV1().Secrets(parts[0]).Get(ctx, parts[1], metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		value, ok := secret.Data[key]
+		if !ok {
+			return "", fmt.Errorf("kubernetes secret %s has no key %s", name, key)
+		}
+		return string(value), nil
+
+	case vaultScheme, awsSecretsManagerScheme, gcpSecretManagerScheme:
+		var key string
+		if i := strings.LastIndex(path, "#"); i >= 0 {
+			path, key = path[:i], path[i+1:]
+		}
+
+		backend, ok := r.backends[scheme]
+		if !ok {
+			var err error
+			backend, err = r.newBackend(scheme)
+			if err != nil {
+				return "", fmt.Errorf("failed to create %s client: %w", scheme, err)
+			}
+			if r.backends == nil {
+				r.backends = make(map[string]secretBackend)
+			}
+			r.backends[scheme] = backend
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), secretRefTimeout)
+		defer cancel()
+		value, ttl, err := backend.fetch(ctx, path, key)
+		if err != nil {
+			return "", err
+		}
+
+		// Secrets are fetched again after two thirds of their TTL, so they're
+		// replaced before they expire.
+		r.fromBackend = true
+		if refreshIn := ttl * 2 / 3; refreshIn > 0 && (r.refreshIn == 0 || refreshIn < r.refreshIn) {
+			r.refreshIn = refreshIn
+		}
+		return value, nil
+	}
+	return "", fmt.Errorf("unsupported scheme %s", scheme)
+}
+
+// redactSecrets replaces the values of the fields of v which referenced
+// secrets, where v is a value decoded from YAML. Only the fields themselves
+// are redacted, so values elsewhere which happen to contain a secret are left
+// untouched.
+func redactSecrets(v interface{}, fields []redactedField) interface{} {
+	for _, f := range fields {
+		v = redactField(v, f.path, f.value)
+	}
+	return v
+}
+
+// redactField sets the field at path in v to value, if it exists.
+func redactField(v interface{}, path []interface{}, value string) interface{} {
+	if len(path) == 0 {
+		return value
+	}
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		for i := range v {
+			if v[i].Key == path[0] {
+				v[i].Value = redactField(v[i].Value, path[1:], value)
+			}
+		}
+	case []interface{}:
+		if i, ok := path[0].(int); ok && i < len(v) {
+			v[i] = redactField(v[i], path[1:], value)
+		}
+	}
+	return v
+}
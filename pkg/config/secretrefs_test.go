@@ -0,0 +1,254 @@
+package config
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSecretResolver_ResolveYAML(t *testing.T) {
+	t.Setenv("REDIS_HOST", "redis.example.com")
+
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("file_password\n"), 0600))
+
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "mysql"},
+		Data:       map[string][]byte{"dsn": []byte("root:kube_password@(mysql:3306)/")},
+	})
+	r := &secretResolver{kubeClient: func() (kubernetes.Interface, error) { return client, nil }}
+
+	in := `
+redis_exporter:
+  redis_addr: ${env:REDIS_HOST}:6379
+  redis_password: ${file:` + passwordFile + `}
+  redis_user: default
+mysqld_exporter:
+  data_source_name: ${kubernetes_secret:monitoring/mysql#dsn}
+`
+	out, err := r.resolveYAML([]byte(in))
+	require.NoError(t, err)
+
+	expect := `
+redis_exporter:
+  redis_addr: redis.example.com:6379
+  redis_password: file_password
+  redis_user: default
+mysqld_exporter:
+  data_source_name: root:kube_password@(mysql:3306)/
+`
+	require.YAMLEq(t, expect, string(out))
+	require.ElementsMatch(t, []redactedField{
+		{path: []interface{}{"redis_exporter", "redis_addr"}, value: "<secret>:6379"},
+		{path: []interface{}{"redis_exporter", "redis_password"}, value: "<secret>"},
+		{path: []interface{}{"mysqld_exporter", "data_source_name"}, value: "<secret>"},
+	}, r.resolved)
+}
+
+func TestSecretResolver_ResolveYAML_Errors(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	r := &secretResolver{kubeClient: func() (kubernetes.Interface, error) { return client, nil }}
+
+	tt := []struct {
+		name string
+		in   string
+		err  string
+	}{
+		{"unset variable", "agent:\n  instance: ${env:SECRET_REFS_UNSET}", "environment variable SECRET_REFS_UNSET is not set"},
+		{"missing file", "agent:\n  instance: ${file:/does/not/exist}", "failed to resolve ${file:/does/not/exist}"},
+		{"missing key", "agent:\n  instance: ${kubernetes_secret:default/secret}", "must end with #<key>"},
+		{"missing secret", "agent:\n  instance: ${kubernetes_secret:default/secret#key}", "not found"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := r.resolveYAML([]byte(tc.in))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func TestSecretResolver_NoReferences(t *testing.T) {
+	in := []byte("agent:\n  enabled: true\nnode_exporter:\n  relabel_configs:\n  - replacement: ${1}\n")
+	out, err := newSecretResolver().resolveYAML(in)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+}
+
+func TestIntegrations_SecretRefsRedacted(t *testing.T) {
+	t.Setenv("REDIS_HOST", "secret_redis_host")
+	t.Setenv("REDIS_PASSWORD", "secret_redis_password")
+
+	cfg := `
+metrics:
+  wal_directory: /tmp/wal
+
+integrations:
+  redis_exporter:
+    enabled: true
+    redis_addr: ${env:REDIS_HOST}:6379
+    redis_password: ${env:REDIS_PASSWORD}`
+
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	c, err := load(fs, []string{"-config.file", "test"}, func(_, _ string, _ bool, c *Config) error {
+		return LoadBytes([]byte(cfg), false, c)
+	})
+	require.NoError(t, err)
+
+	bb, err := yaml.Marshal(c)
+	require.NoError(t, err)
+	require.NotContains(t, string(bb), "secret_redis_host")
+	require.NotContains(t, string(bb), "secret_redis_password")
+	require.Contains(t, string(bb), "redis_addr: <secret>:6379")
+}
+
+func TestRedactSecrets(t *testing.T) {
+	in := `
+redis_exporter:
+  redis_addr: localhost:6379
+  redis_user: admin
+  redis_password: admin
+statsd_exporter:
+  mapping_config:
+    mappings:
+    - match: admin.*
+      name: admin
+    - match: other.*
+      name: ${env:NAME}
+`
+	t.Setenv("NAME", "admin")
+
+	r := &secretResolver{}
+	var m yaml.MapSlice
+	require.NoError(t, yaml.Unmarshal([]byte(in), &m))
+	_, err := r.resolveValue(m, nil)
+	require.NoError(t, err)
+
+	// Only the fields which referenced secrets are redacted, even though
+	// other fields contain the same values.
+	m = nil
+	require.NoError(t, yaml.Unmarshal([]byte(strings.Replace(in, "${env:NAME}", "admin", 1)), &m))
+	bb, err := yaml.Marshal(redactSecrets(m, r.resolved))
+	require.NoError(t, err)
+	require.YAMLEq(t, strings.Replace(in, "${env:NAME}", "<secret>", 1), string(bb))
+}
+
+type fakeSecretBackend map[string]struct {
+	value string
+	ttl   time.Duration
+}
+
+func (b fakeSecretBackend) fetch(_ context.Context, path, key string) (string, time.Duration, error) {
+	s, ok := b[path+"#"+key]
+	if !ok {
+		return "", 0, fmt.Errorf("secret %s not found", path)
+	}
+	return s.value, s.ttl, nil
+}
+
+func TestSecretResolver_Backends(t *testing.T) {
+	backends := map[string]fakeSecretBackend{
+		vaultScheme: {
+			"database/creds/mysql#password": {value: "vault_password", ttl: time.Hour},
+		},
+		awsSecretsManagerScheme: {
+			"arn:aws:secretsmanager:us-east-1:123456789012:secret:redis#": {value: "aws_password"},
+		},
+		gcpSecretManagerScheme: {
+			"projects/agent/secrets/postgres#dsn": {value: "postgresql://gcp@postgres:5432/"},
+		},
+	}
+	var created []string
+	r := &secretResolver{newBackend: func(scheme string) (secretBackend, error) {
+		created = append(created, scheme)
+		return backends[scheme], nil
+	}}
+
+	in := `
+mysqld_exporter:
+  data_source_name: exporter:${vault:database/creds/mysql#password}@(mysql:3306)/
+redis_exporter:
+  redis_password: ${aws_secrets_manager:arn:aws:secretsmanager:us-east-1:123456789012:secret:redis}
+  redis_user: ${vault:database/creds/mysql#password}
+postgres_exporter:
+  data_source_names: ['${gcp_secret_manager:projects/agent/secrets/postgres#dsn}']
+`
+	out, err := r.resolveYAML([]byte(in))
+	require.NoError(t, err)
+
+	expect := `
+mysqld_exporter:
+  data_source_name: exporter:vault_password@(mysql:3306)/
+redis_exporter:
+  redis_password: aws_password
+  redis_user: vault_password
+postgres_exporter:
+  data_source_names: ['postgresql://gcp@postgres:5432/']
+`
+	require.YAMLEq(t, expect, string(out))
+	require.ElementsMatch(t, []string{vaultScheme, awsSecretsManagerScheme, gcpSecretManagerScheme}, created)
+	require.True(t, r.fromBackend)
+	require.Equal(t, 40*time.Minute, r.refreshIn)
+}
+
+func TestVaultBackend_Fetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/secret/data/agent":
+			_, _ = w.Write([]byte(`{"lease_duration": 0, "data": {"data": {"password": "kv_password"}, "metadata": {"version": 3}}}`))
+		case "/v1/database/creds/mysql":
+			_, _ = w.Write([]byte(`{"lease_duration": 3600, "data": {"username": "v-agent", "password": "dynamic_password"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors": []}`))
+		}
+	}))
+	defer srv.Close()
+
+	client, err := vault.NewClient(&vault.Config{Address: srv.URL})
+	require.NoError(t, err)
+	b := &vaultBackend{client: client}
+
+	value, ttl, err := b.fetch(context.Background(), "secret/data/agent", "password")
+	require.NoError(t, err)
+	require.Equal(t, "kv_password", value)
+	require.Zero(t, ttl)
+
+	value, ttl, err = b.fetch(context.Background(), "database/creds/mysql", "password")
+	require.NoError(t, err)
+	require.Equal(t, "dynamic_password", value)
+	require.Equal(t, time.Hour, ttl)
+
+	_, _, err = b.fetch(context.Background(), "secret/data/agent", "username")
+	require.EqualError(t, err, "vault secret secret/data/agent has no key username")
+
+	_, _, err = b.fetch(context.Background(), "secret/data/missing", "password")
+	require.EqualError(t, err, "vault secret secret/data/missing not found")
+}
+
+func TestJSONSecretKey(t *testing.T) {
+	value, err := jsonSecretKey(`{"username": "agent", "port": 5432}`, "port")
+	require.NoError(t, err)
+	require.Equal(t, "5432", value)
+
+	_, err = jsonSecretKey(`{"username": "agent"}`, "password")
+	require.EqualError(t, err, "secret has no key password")
+
+	_, err = jsonSecretKey(`hunter2`, "password")
+	require.Error(t, err)
+}
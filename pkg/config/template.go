@@ -0,0 +1,122 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/resourcedetection"
+)
+
+// templateFacts are the facts about the host which are available to config
+// templates.
+type templateFacts struct {
+	// Hostname is the hostname of the host.
+	Hostname string
+	// IPs are the IP addresses of the network interfaces of the host,
+	// excluding loopback and link-local addresses.
+	IPs []string
+	// Env holds the environment variables of the agent.
+	Env map[string]string
+	// Resource holds the attributes found by the resource detectors set by
+	// -config.template.detectors, like cloud.region or host.name.
+	Resource map[string]string
+}
+
+// detectTemplateFacts returns the facts about the host, running the resource
+// detectors named by detectors. Detectors which fail are logged to l.
+func detectTemplateFacts(l log.Logger, detectors []string) (templateFacts, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return templateFacts{}, fmt.Errorf("failed to get hostname: %w", err)
+	}
+	ips, err := hostIPs()
+	if err != nil {
+		return templateFacts{}, fmt.Errorf("failed to get IP addresses: %w", err)
+	}
+
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if i := strings.Index(kv, "="); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	cfg := resourcedetection.DefaultConfig
+	cfg.Enabled = true
+	cfg.Detectors = detectors
+	if err := cfg.Validate(); err != nil {
+		return templateFacts{}, err
+	}
+	attrs := resourcedetection.Detect(context.Background(), l, cfg)
+
+	return templateFacts{
+		Hostname: hostname,
+		IPs:      ips,
+		Env:      env,
+		Resource: attrs,
+	}, nil
+}
+
+// hostIPs returns the IP addresses of the network interfaces of the host,
+// excluding loopback and link-local addresses.
+func hostIPs() ([]string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipNet.IP
+		if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+			continue
+		}
+		res = append(res, ip.String())
+	}
+	return res, nil
+}
+
+// renderTemplate renders buf as a Go template with facts as its data.
+// Referencing a fact which doesn't exist is an error.
+func renderTemplate(buf []byte, facts templateFacts) ([]byte, error) {
+	tmpl, err := template.New("config").Option("missingkey=error").Parse(string(buf))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config template: %w", err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, facts); err != nil {
+		return nil, fmt.Errorf("failed to render config template: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// templateDetectors returns the resource detectors set by
+// -config.template.detectors.
+func (c *Config) templateDetectors() []string {
+	var res []string
+	for _, d := range strings.Split(c.TemplateDetectors, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+// templateDryRunError is returned by LoadBytes instead of loading the config
+// when -dry-run-template is set. It holds the rendered config.
+type templateDryRunError struct {
+	rendered []byte
+}
+
+func (e *templateDryRunError) Error() string {
+	return "config template was rendered in dry run mode"
+}
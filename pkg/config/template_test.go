@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	facts := templateFacts{
+		Hostname: "web-1",
+		IPs:      []string{"10.0.0.1", "10.0.0.2"},
+		Env:      map[string]string{"CLUSTER": "prod"},
+		Resource: map[string]string{"cloud.region": "us-east-1"},
+	}
+
+	tt := []struct {
+		name   string
+		input  string
+		expect string
+		err    string
+	}{
+		{"hostname", "instance: {{ .Hostname }}", "instance: web-1", ""},
+		{"first ip", "address: {{ index .IPs 0 }}:9090", "address: 10.0.0.1:9090", ""},
+		{"env", "cluster: {{ .Env.CLUSTER }}", "cluster: prod", ""},
+		{"optional env", `team: {{ or (index .Env "TEAM") "none" }}`, "team: none", ""},
+		{"resource", `region: {{ index .Resource "cloud.region" }}`, "region: us-east-1", ""},
+		{"escaped", `template: '{{"{{"}} .Value }}'`, "template: '{{ .Value }}'", ""},
+		{"missing env", "team: {{ .Env.TEAM }}", "", "map has no entry for key"},
+		{"invalid", "team: {{ .Env.TEAM", "", "failed to parse config template"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := renderTemplate([]byte(tc.input), facts)
+			if tc.err != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, string(out))
+		})
+	}
+}
+
+func TestLoadBytes_Template(t *testing.T) {
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	input := `
+server:
+  log_level: {{ if eq .Hostname "` + hostname + `" }}debug{{ else }}info{{ end }}
+`
+
+	var cfg Config
+	cfg.TemplateEnabled = true
+	cfg.TemplateDetectors = "host"
+	require.NoError(t, LoadBytes([]byte(input), false, &cfg))
+	require.Equal(t, "debug", cfg.Server.LogLevel.String())
+
+	t.Run("dry run", func(t *testing.T) {
+		var cfg Config
+		cfg.TemplateEnabled = true
+		cfg.TemplateDetectors = "host"
+		cfg.DryRunTemplate = true
+
+		err := LoadBytes([]byte(input), false, &cfg)
+		require.IsType(t, &templateDryRunError{}, err)
+		require.Equal(t, "\nserver:\n  log_level: debug\n", string(err.(*templateDryRunError).rendered))
+	})
+
+	t.Run("unknown detector", func(t *testing.T) {
+		var cfg Config
+		cfg.TemplateEnabled = true
+		cfg.TemplateDetectors = "host,nope"
+		require.Error(t, LoadBytes([]byte(input), false, &cfg))
+	})
+}
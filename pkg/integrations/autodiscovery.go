@@ -0,0 +1,181 @@
+package integrations
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/pkg/integrations/autodiscovery"
+	"github.com/grafana/agent/pkg/metrics/cluster/configapi"
+	"github.com/grafana/agent/pkg/util"
+)
+
+// autodiscoveryTimeout is the timeout of discovering local services.
+const autodiscoveryTimeout = 30 * time.Second
+
+// DiscoveredService is a local service found by autodiscovery.
+type DiscoveredService struct {
+	autodiscovery.Service
+
+	// Enabled is true if an integration was enabled for the service.
+	Enabled bool `json:"enabled"`
+
+	// Reason explains why no integration was enabled for the service.
+	Reason string `json:"reason,omitempty"`
+}
+
+// runAutodiscovery discovers local services every refresh interval and
+// whenever the config changes, until the manager is stopped.
+func (m *Manager) runAutodiscovery() {
+	defer m.wg.Done()
+
+	// applied is true while integrations enabled by autodiscovery may be
+	// running, and have to be stopped once autodiscovery is disabled.
+	var applied bool
+
+	for {
+		m.cfgMut.RLock()
+		cfg := m.cfg.Autodiscovery
+		m.cfgMut.RUnlock()
+
+		var (
+			services []autodiscovery.Service
+			refresh  <-chan time.Time
+		)
+		if cfg.Enabled {
+			ctx, cancel := context.WithTimeout(m.ctx, autodiscoveryTimeout)
+			services = autodiscovery.New(m.logger, cfg).Discover(ctx)
+			cancel()
+			refresh = time.After(cfg.GetRefreshInterval())
+		}
+		if cfg.Enabled || applied {
+			m.applyDiscoveredServices(services)
+			applied = cfg.Enabled
+		}
+
+		// While disabled, wait for a config change which may enable
+		// autodiscovery.
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-m.autodiscoveryUpdate:
+		case <-refresh:
+		}
+	}
+}
+
+// applyDiscoveredServices enables integrations for services, stopping the
+// integrations of services which are gone.
+func (m *Manager) applyDiscoveredServices(services []autodiscovery.Service) {
+	m.cfgMut.Lock()
+	defer m.cfgMut.Unlock()
+
+	m.integrationsMut.Lock()
+	defer m.integrationsMut.Unlock()
+
+	if m.ctx.Err() != nil {
+		return
+	}
+
+	// Services of integrations configured in the config file are left to
+	// the config file.
+	configured := make(map[string]struct{})
+	for _, ic := range m.cfg.Integrations {
+		if ic.Common.Enabled {
+			configured[ic.Name()] = struct{}{}
+		}
+	}
+	registered := buildIntegrationsMap(registeredIntegrations)
+
+	var (
+		configs  []UnmarshaledConfig
+		statuses = make([]DiscoveredService, 0, len(services))
+	)
+	for _, s := range services {
+		status := DiscoveredService{Service: s}
+
+		ref, isRegistered := registered[s.Integration]
+		_, isConfigured := configured[s.Integration]
+		switch {
+		case !isRegistered:
+			status.Reason = "integration isn't available"
+		case isConfigured:
+			status.Reason = "integration is configured in the config file"
+		default:
+			uc, err := discoveredConfig(s, m.cfg.Autodiscovery, ref)
+			if err != nil {
+				status.Reason = err.Error()
+				break
+			}
+			configs = append(configs, uc)
+			status.Enabled = true
+		}
+		statuses = append(statuses, status)
+	}
+	m.discoveredServices = statuses
+
+	if discoveredConfigsEqual(m.discovered, configs) {
+		return
+	}
+	for _, s := range statuses {
+		if s.Enabled {
+			level.Info(m.logger).Log("msg", "enabling integration for discovered service", "integration", s.Integration, "instance_name", s.Name, "address", s.Address, "source", s.Source)
+		}
+	}
+	m.discovered = configs
+	if err := m.applyConfig(m.cfg); err != nil {
+		level.Error(m.logger).Log("msg", "failed to apply integrations of discovered services", "err", err)
+	}
+}
+
+// discoveredConfig returns the config of the integration for the service s.
+func discoveredConfig(s autodiscovery.Service, cfg autodiscovery.Config, ref Config) (UnmarshaledConfig, error) {
+	raw, err := s.Config(cfg.Integrations[s.Integration])
+	if err != nil {
+		return UnmarshaledConfig{}, err
+	}
+	return buildUnmarshaledConfig(raw, ref)
+}
+
+func discoveredConfigsEqual(a, b []UnmarshaledConfig) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !util.CompareYAML(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// integrationConfigs returns the configs of the integrations configured in
+// cfg and the integrations enabled by autodiscovery. integrationConfigs must
+// be called with the config mutex held.
+func (m *Manager) integrationConfigs(cfg ManagerConfig) []UnmarshaledConfig {
+	if len(m.discovered) == 0 {
+		return cfg.Integrations
+	}
+	res := make([]UnmarshaledConfig, 0, len(cfg.Integrations)+len(m.discovered))
+	res = append(res, cfg.Integrations...)
+	return append(res, m.discovered...)
+}
+
+// DiscoveredServices returns the services found by the last run of
+// autodiscovery.
+func (m *Manager) DiscoveredServices() []DiscoveredService {
+	m.cfgMut.RLock()
+	defer m.cfgMut.RUnlock()
+
+	res := make([]DiscoveredService, len(m.discoveredServices))
+	copy(res, m.discoveredServices)
+	return res
+}
+
+// autodiscoveryHandler returns the services found by autodiscovery.
+func (m *Manager) autodiscoveryHandler(rw http.ResponseWriter, _ *http.Request) {
+	if err := configapi.WriteResponse(rw, http.StatusOK, m.DiscoveredServices()); err != nil {
+		level.Error(m.logger).Log("msg", "failed to write response", "err", err)
+	}
+}
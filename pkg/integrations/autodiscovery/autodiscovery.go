@@ -0,0 +1,307 @@
+// Package autodiscovery detects services running on the local host which
+// integrations can collect metrics from.
+package autodiscovery
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"gopkg.in/yaml.v2"
+)
+
+// Sources of discovered services.
+const (
+	// SourcePorts discovers services by the local TCP ports they listen on.
+	SourcePorts = "ports"
+	// SourceSystemd discovers services by the names of running systemd units.
+	SourceSystemd = "systemd"
+	// SourceDocker discovers services running in Docker containers by the
+	// image and labels of the containers.
+	SourceDocker = "docker"
+)
+
+// Defaults of Config, which are applied to its zero values.
+const (
+	DefaultRefreshInterval = time.Minute
+	DefaultDockerHost      = "unix:///var/run/docker.sock"
+)
+
+// DefaultSources are the sources used if Config.Sources is empty.
+var DefaultSources = []string{SourceDocker, SourceSystemd, SourcePorts}
+
+// Config controls the discovery of local services.
+type Config struct {
+	// Enabled turns on autodiscovery.
+	Enabled bool `yaml:"enabled,omitempty"`
+
+	// RefreshInterval is how often services are discovered.
+	RefreshInterval time.Duration `yaml:"refresh_interval,omitempty"`
+
+	// Sources are the sources services are discovered from. Services found by
+	// multiple sources are reported by the first of them.
+	Sources []string `yaml:"sources,omitempty"`
+
+	// DockerHost is the address of the Docker daemon.
+	DockerHost string `yaml:"docker_host,omitempty"`
+
+	// Integrations limits discovery to the integrations it holds, along with
+	// settings which override the defaults of their discovered instances.
+	// All supported integrations are discovered if Integrations is empty.
+	Integrations map[string]yaml.MapSlice `yaml:"integrations,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for Config.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain Config
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+// Validate returns an error if c is invalid.
+func (c *Config) Validate() error {
+	if c.RefreshInterval < 0 {
+		return fmt.Errorf("refresh_interval must not be negative")
+	}
+	for _, s := range c.Sources {
+		switch s {
+		case SourcePorts, SourceSystemd, SourceDocker:
+		default:
+			return fmt.Errorf("unknown source %q", s)
+		}
+	}
+	for name := range c.Integrations {
+		if _, ok := knownServiceByIntegration(name); !ok {
+			return fmt.Errorf("integration %q doesn't support autodiscovery", name)
+		}
+	}
+	return nil
+}
+
+// GetRefreshInterval returns the refresh interval of c, applying its default.
+func (c *Config) GetRefreshInterval() time.Duration {
+	if c.RefreshInterval == 0 {
+		return DefaultRefreshInterval
+	}
+	return c.RefreshInterval
+}
+
+// knownService describes how to discover the services of an integration.
+type knownService struct {
+	integration string
+	// port is the default port of the service.
+	port int
+	// units are the names of the systemd units of the service, without the
+	// .service suffix and the instance of template units.
+	units []string
+	// images are the names of the Docker images of the service, without
+	// their registry, repository and tag.
+	images []string
+	// config is the default config of the integration for a service.
+	// ${address} is replaced by the address of the service.
+	config string
+}
+
+var knownServices = []knownService{
+	{
+		integration: "mysqld_exporter",
+		port:        3306,
+		units:       []string{"mysql", "mysqld", "mariadb"},
+		images:      []string{"mysql", "mariadb", "percona-server"},
+		config:      "data_source_name: root@(${address})/",
+	},
+	{
+		integration: "postgres_exporter",
+		port:        5432,
+		units:       []string{"postgresql"},
+		images:      []string{"postgres"},
+		config:      "data_source_names: ['postgresql://postgres@${address}/postgres?sslmode=disable']",
+	},
+	{
+		integration: "redis_exporter",
+		port:        6379,
+		units:       []string{"redis", "redis-server"},
+		images:      []string{"redis"},
+		config:      "redis_addr: ${address}",
+	},
+}
+
+func knownServiceByIntegration(name string) (knownService, bool) {
+	for _, ks := range knownServices {
+		if ks.integration == name {
+			return ks, true
+		}
+	}
+	return knownService{}, false
+}
+
+// Service is a discovered service.
+type Service struct {
+	// Integration is the name of the integration collecting metrics from the
+	// service.
+	Integration string `json:"integration"`
+
+	// Name identifies the service, and is used as the name of the instance
+	// of its integration.
+	Name string `json:"name"`
+
+	// Address is the host:port of the service.
+	Address string `json:"address"`
+
+	// Source is the source which discovered the service.
+	Source string `json:"source"`
+}
+
+// Config returns the config of the integration of s, applying overrides to
+// the defaults of the integration. Strings of the config may reference the
+// address of s as ${address}.
+func (s Service) Config(overrides yaml.MapSlice) ([]byte, error) {
+	ks, ok := knownServiceByIntegration(s.Integration)
+	if !ok {
+		return nil, fmt.Errorf("integration %q doesn't support autodiscovery", s.Integration)
+	}
+
+	var cfg yaml.MapSlice
+	if err := yaml.Unmarshal([]byte(ks.config), &cfg); err != nil {
+		return nil, err
+	}
+	for _, o := range overrides {
+		cfg = setKey(cfg, o.Key, o.Value)
+	}
+	cfg = setKey(cfg, "enabled", true)
+	cfg = setKey(cfg, "name", s.Name)
+
+	return yaml.Marshal(replaceAddress(cfg, s.Address))
+}
+
+// setKey sets the value of key in ms.
+func setKey(ms yaml.MapSlice, key, value interface{}) yaml.MapSlice {
+	for i := range ms {
+		if ms[i].Key == key {
+			ms[i].Value = value
+			return ms
+		}
+	}
+	return append(ms, yaml.MapItem{Key: key, Value: value})
+}
+
+// replaceAddress replaces ${address} in the strings of v, which is a value
+// decoded from YAML.
+func replaceAddress(v interface{}, address string) interface{} {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		res := make(yaml.MapSlice, len(v))
+		for i, item := range v {
+			res[i] = yaml.MapItem{Key: item.Key, Value: replaceAddress(item.Value, address)}
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i := range v {
+			res[i] = replaceAddress(v[i], address)
+		}
+		return res
+	case string:
+		return strings.ReplaceAll(v, "${address}", address)
+	default:
+		return v
+	}
+}
+
+// Discoverer discovers local services.
+type Discoverer struct {
+	log log.Logger
+	cfg Config
+
+	// Functions returning the raw data of sources, replaced in tests.
+	readFile       func(name string) ([]byte, error)
+	listUnits      func(ctx context.Context) ([]byte, error)
+	listContainers func(ctx context.Context, host string) ([]container, error)
+}
+
+// New creates a new Discoverer.
+func New(l log.Logger, cfg Config) *Discoverer {
+	return &Discoverer{
+		log: l,
+		cfg: cfg,
+
+		readFile:       readFile,
+		listUnits:      listUnits,
+		listContainers: listContainers,
+	}
+}
+
+// Discover returns the services found by the sources of the Discoverer,
+// sorted by integration and name. Sources which fail are logged and skipped.
+func (d *Discoverer) Discover(ctx context.Context) []Service {
+	sources := d.cfg.Sources
+	if len(sources) == 0 {
+		sources = DefaultSources
+	}
+
+	var (
+		res  []Service
+		seen = make(map[string]struct{})
+	)
+	for _, source := range sources {
+		var (
+			services []Service
+			err      error
+		)
+		switch source {
+		case SourcePorts:
+			services, err = d.discoverPorts()
+		case SourceSystemd:
+			services, err = d.discoverSystemd(ctx)
+		case SourceDocker:
+			services, err = d.discoverDocker(ctx)
+		}
+		if err != nil {
+			level.Debug(d.log).Log("msg", "failed to discover services", "source", source, "err", err)
+			continue
+		}
+
+		for _, s := range services {
+			if !d.discovers(s.Integration) {
+				continue
+			}
+			key := s.Integration + "/" + s.Address
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			res = append(res, s)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Integration != res[j].Integration {
+			return res[i].Integration < res[j].Integration
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// discovers returns whether services of the integration name are discovered.
+func (d *Discoverer) discovers(name string) bool {
+	if len(d.cfg.Integrations) == 0 {
+		return true
+	}
+	_, ok := d.cfg.Integrations[name]
+	return ok
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// serviceName returns a valid instance name from parts.
+func serviceName(parts ...string) string {
+	return invalidNameChars.ReplaceAllString(strings.Join(parts, "-"), "_")
+}
@@ -0,0 +1,120 @@
+package autodiscovery
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+const procNetTCP = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:18EB 00000000:0000 0A 00000000:00000000 00:00000000 00000000   111        0 21042 1 0000000000000000 100 0 0 10 0
+   1: 00000000:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000   112        0 21108 1 0000000000000000 100 0 0 10 0
+   2: 0100007F:0CEA 0100007F:D2F4 01 00000000:00000000 00:00000000 00000000   112        0 31337 1 0000000000000000 20 4 30 10 -1
+`
+
+const systemctlUnits = `cron.service                loaded active running Regular background program processing daemon
+postgresql@14-main.service  loaded active running PostgreSQL Cluster 14-main
+redis-server.service        loaded active running Advanced key-value store
+`
+
+func TestParseListeningPorts(t *testing.T) {
+	require.Equal(t, []int{6379, 3306}, parseListeningPorts([]byte(procNetTCP)))
+}
+
+func TestDiscoverer_Discover(t *testing.T) {
+	d := New(log.NewNopLogger(), Config{Enabled: true})
+	d.readFile = func(name string) ([]byte, error) {
+		if name == "/proc/net/tcp" {
+			return []byte(procNetTCP), nil
+		}
+		return nil, fmt.Errorf("%s not found", name)
+	}
+	d.listUnits = func(context.Context) ([]byte, error) { return []byte(systemctlUnits), nil }
+	d.listContainers = func(_ context.Context, host string) ([]container, error) {
+		require.Equal(t, DefaultDockerHost, host)
+		return []container{
+			{
+				Name:  "orders-db",
+				Image: "docker.io/library/mysql:8.0",
+				Ports: []types.Port{{IP: "0.0.0.0", PrivatePort: 3306, PublicPort: 3306, Type: "tcp"}},
+			},
+			{
+				Name:   "sessions",
+				Image:  "registry.example.com/cache:1.2",
+				Labels: map[string]string{IntegrationLabel: "redis_exporter", PortLabel: "6380"},
+				IPs:    []string{"172.17.0.3"},
+			},
+			{Name: "web", Image: "nginx:latest", IPs: []string{"172.17.0.4"}},
+		}, nil
+	}
+
+	expect := []Service{
+		{Integration: "mysqld_exporter", Name: "docker-orders-db", Address: "localhost:3306", Source: SourceDocker},
+		{Integration: "postgres_exporter", Name: "postgresql_14-main", Address: "localhost:5432", Source: SourceSystemd},
+		{Integration: "redis_exporter", Name: "docker-sessions", Address: "172.17.0.3:6380", Source: SourceDocker},
+		{Integration: "redis_exporter", Name: "redis-server", Address: "localhost:6379", Source: SourceSystemd},
+	}
+	require.Equal(t, expect, d.Discover(context.Background()))
+
+	// Discovery is limited to the configured integrations and sources.
+	d.cfg.Integrations = map[string]yaml.MapSlice{"redis_exporter": nil}
+	d.cfg.Sources = []string{SourcePorts}
+	require.Equal(t, []Service{
+		{Integration: "redis_exporter", Name: "localhost-6379", Address: "localhost:6379", Source: SourcePorts},
+	}, d.Discover(context.Background()))
+}
+
+func TestService_Config(t *testing.T) {
+	s := Service{Integration: "mysqld_exporter", Name: "docker-orders-db", Address: "localhost:3306"}
+
+	bb, err := s.Config(nil)
+	require.NoError(t, err)
+	require.YAMLEq(t, `
+data_source_name: root@(localhost:3306)/
+enabled: true
+name: docker-orders-db
+`, string(bb))
+
+	var overrides yaml.MapSlice
+	require.NoError(t, yaml.Unmarshal([]byte(`
+data_source_name: exporter:secret@(${address})/
+scrape_interval: 30s
+`), &overrides))
+	bb, err = s.Config(overrides)
+	require.NoError(t, err)
+	require.YAMLEq(t, `
+data_source_name: exporter:secret@(localhost:3306)/
+scrape_interval: 30s
+enabled: true
+name: docker-orders-db
+`, string(bb))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tt := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{"valid", "enabled: true\nsources: [docker, ports]\nintegrations:\n  redis_exporter: {}", ""},
+		{"unknown source", "enabled: true\nsources: [kubernetes]", `unknown source "kubernetes"`},
+		{"unsupported integration", "enabled: true\nintegrations:\n  node_exporter: {}", `integration "node_exporter" doesn't support autodiscovery`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var c Config
+			err := yaml.UnmarshalStrict([]byte(tc.config), &c)
+			if tc.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
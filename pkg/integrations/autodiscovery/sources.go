@@ -0,0 +1,268 @@
+package autodiscovery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+// tcpListenState is the state of listening sockets in /proc/net/tcp.
+const tcpListenState = "0A"
+
+func readFile(name string) ([]byte, error) { return os.ReadFile(name) }
+
+// discoverPorts discovers services listening on the default ports of their
+// integrations.
+func (d *Discoverer) discoverPorts() ([]Service, error) {
+	ports := make(map[int]struct{})
+	var found bool
+	for _, name := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		bb, err := d.readFile(name)
+		if err != nil {
+			continue
+		}
+		found = true
+		for _, port := range parseListeningPorts(bb) {
+			ports[port] = struct{}{}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("listening ports can only be read from /proc/net/tcp on Linux")
+	}
+
+	var res []Service
+	for _, ks := range knownServices {
+		if _, ok := ports[ks.port]; !ok {
+			continue
+		}
+		res = append(res, Service{
+			Integration: ks.integration,
+			Name:        serviceName("localhost", strconv.Itoa(ks.port)),
+			Address:     net.JoinHostPort("localhost", strconv.Itoa(ks.port)),
+			Source:      SourcePorts,
+		})
+	}
+	return res, nil
+}
+
+// parseListeningPorts returns the ports of the listening sockets in the
+// contents of /proc/net/tcp or /proc/net/tcp6.
+func parseListeningPorts(bb []byte) []int {
+	var res []int
+	s := bufio.NewScanner(bytes.NewReader(bb))
+	for s.Scan() {
+		// Lines have the form:
+		//   sl  local_address rem_address   st ...
+		//   0: 0100007F:18EB 00000000:0000 0A ...
+		fields := strings.Fields(s.Text())
+		if len(fields) < 4 || fields[3] != tcpListenState {
+			continue
+		}
+		i := strings.LastIndex(fields[1], ":")
+		if i < 0 {
+			continue
+		}
+		port, err := strconv.ParseUint(fields[1][i+1:], 16, 16)
+		if err != nil {
+			continue
+		}
+		res = append(res, int(port))
+	}
+	return res
+}
+
+func listUnits(ctx context.Context) ([]byte, error) {
+	return exec.CommandContext(ctx, "systemctl", "list-units", "--type=service", "--state=running", "--no-legend", "--plain").Output()
+}
+
+// discoverSystemd discovers services by the names of running systemd units.
+// The services are assumed to listen on the default ports of their
+// integrations.
+func (d *Discoverer) discoverSystemd(ctx context.Context) ([]Service, error) {
+	out, err := d.listUnits(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list systemd units: %w", err)
+	}
+
+	var res []Service
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		unit := strings.TrimSuffix(fields[0], ".service")
+
+		// Template units like postgresql@14-main are matched by their
+		// template name.
+		base := unit
+		if i := strings.Index(unit, "@"); i >= 0 {
+			base = unit[:i]
+		}
+		for _, ks := range knownServices {
+			if !contains(ks.units, base) {
+				continue
+			}
+			res = append(res, Service{
+				Integration: ks.integration,
+				Name:        serviceName(unit),
+				Address:     net.JoinHostPort("localhost", strconv.Itoa(ks.port)),
+				Source:      SourceSystemd,
+			})
+		}
+	}
+	return res, nil
+}
+
+// Labels of Docker containers which control autodiscovery.
+const (
+	// IntegrationLabel sets the integration of the service of a container,
+	// overriding the integration detected from its image.
+	IntegrationLabel = "com.grafana.agent.integration"
+	// PortLabel sets the port of the service of a container, overriding the
+	// default port of its integration.
+	PortLabel = "com.grafana.agent.port"
+)
+
+// container is the subset of a Docker container used by autodiscovery.
+type container struct {
+	Name   string
+	Image  string
+	Labels map[string]string
+	// Ports are the exposed ports of the container.
+	Ports []types.Port
+	// IPs are the IP addresses of the container in its networks, sorted by
+	// network name.
+	IPs []string
+}
+
+func listContainers(ctx context.Context, host string) ([]container, error) {
+	cli, err := client.NewClientWithOpts(client.WithHost(host), client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	list, err := cli.ContainerList(ctx, types.ContainerListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]container, 0, len(list))
+	for _, c := range list {
+		ctr := container{Image: c.Image, Labels: c.Labels, Ports: c.Ports}
+		if len(c.Names) > 0 {
+			ctr.Name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		if c.NetworkSettings != nil {
+			networks := make([]string, 0, len(c.NetworkSettings.Networks))
+			for name := range c.NetworkSettings.Networks {
+				networks = append(networks, name)
+			}
+			sort.Strings(networks)
+			for _, name := range networks {
+				if n := c.NetworkSettings.Networks[name]; n != nil && n.IPAddress != "" {
+					ctr.IPs = append(ctr.IPs, n.IPAddress)
+				}
+			}
+		}
+		res = append(res, ctr)
+	}
+	return res, nil
+}
+
+// discoverDocker discovers services running in Docker containers.
+func (d *Discoverer) discoverDocker(ctx context.Context) ([]Service, error) {
+	host := d.cfg.DockerHost
+	if host == "" {
+		host = DefaultDockerHost
+	}
+	containers, err := d.listContainers(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list docker containers: %w", err)
+	}
+
+	var res []Service
+	for _, c := range containers {
+		ks, ok := containerService(c)
+		if !ok {
+			continue
+		}
+		port := ks.port
+		if p, err := strconv.Atoi(c.Labels[PortLabel]); err == nil {
+			port = p
+		}
+		address, ok := containerAddress(c, port)
+		if !ok {
+			continue
+		}
+		res = append(res, Service{
+			Integration: ks.integration,
+			Name:        serviceName("docker", c.Name),
+			Address:     address,
+			Source:      SourceDocker,
+		})
+	}
+	return res, nil
+}
+
+// containerService returns the known service of the integration running in
+// c, from its labels or its image.
+func containerService(c container) (knownService, bool) {
+	if name, ok := c.Labels[IntegrationLabel]; ok {
+		return knownServiceByIntegration(name)
+	}
+
+	// Images have the form [registry/][repository/]name[:tag][@digest].
+	image := c.Image
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	image = image[strings.LastIndex(image, "/")+1:]
+	if i := strings.Index(image, ":"); i >= 0 {
+		image = image[:i]
+	}
+	for _, ks := range knownServices {
+		if contains(ks.images, image) {
+			return ks, true
+		}
+	}
+	return knownService{}, false
+}
+
+// containerAddress returns the address port of c is reachable at. Ports
+// published on the host are preferred over the IP address of the container.
+func containerAddress(c container, port int) (string, bool) {
+	for _, p := range c.Ports {
+		if int(p.PrivatePort) != port || p.PublicPort == 0 || p.Type != "tcp" {
+			continue
+		}
+		host := p.IP
+		if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+			host = "localhost"
+		}
+		return net.JoinHostPort(host, strconv.Itoa(int(p.PublicPort))), true
+	}
+	if len(c.IPs) > 0 {
+		return net.JoinHostPort(c.IPs[0], strconv.Itoa(port)), true
+	}
+	return "", false
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+// Package azure_exporter collects metrics of Azure resources from Azure
+// Monitor.
+package azure_exporter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/integrations"
+	integrations_v2 "github.com/grafana/agent/pkg/integrations/v2"
+	"github.com/grafana/agent/pkg/integrations/v2/metricsutils"
+	config_util "github.com/prometheus/common/config"
+)
+
+// DefaultConfig holds the default settings for the azure_exporter
+// integration.
+var DefaultConfig = Config{
+	CollectInterval:    time.Minute,
+	ResourceManagerURL: "https://management.azure.com/",
+	ActiveDirectoryURL: "https://login.microsoftonline.com/",
+}
+
+// Config controls the azure_exporter integration.
+type Config struct {
+	Subscriptions []string `yaml:"subscriptions"`
+
+	// TenantID, ClientID and ClientSecret authenticate as a service principal.
+	// The managed identity of the host is used if ClientSecret is empty, with
+	// ClientID selecting a user-assigned identity.
+	TenantID     string             `yaml:"tenant_id,omitempty"`
+	ClientID     string             `yaml:"client_id,omitempty"`
+	ClientSecret config_util.Secret `yaml:"client_secret,omitempty"`
+
+	// CollectInterval is how often metrics are collected from Azure Monitor.
+	// Scrapes return the metrics of the most recent collection.
+	CollectInterval time.Duration `yaml:"collect_interval,omitempty"`
+
+	// ResourceManagerURL and ActiveDirectoryURL are the endpoints of the
+	// Azure cloud, which only need to be changed for national clouds.
+	ResourceManagerURL string `yaml:"resource_manager_url,omitempty"`
+	ActiveDirectoryURL string `yaml:"active_directory_url,omitempty"`
+
+	Resources []ResourceConfig `yaml:"resources"`
+}
+
+// ResourceConfig selects the metrics collected from resources of a type.
+type ResourceConfig struct {
+	// Type is the resource type, like Microsoft.Compute/virtualMachines.
+	Type string `yaml:"type"`
+
+	// ResourceGroups limits the resources to these resource groups. Resources
+	// of all resource groups are collected if empty.
+	ResourceGroups []string `yaml:"resource_groups,omitempty"`
+
+	Metrics []string `yaml:"metrics"`
+
+	// Aggregations to collect of every metric. Defaults to Average.
+	Aggregations []string `yaml:"aggregations,omitempty"`
+
+	// Interval is the granularity of the collected data points. Defaults to
+	// one minute.
+	Interval time.Duration `yaml:"interval,omitempty"`
+
+	// Length is how far back data points are looked for. The most recent
+	// data point is collected. Defaults to five times Interval.
+	Length time.Duration `yaml:"length,omitempty"`
+}
+
+// aggregations returns the aggregations of r, applying the default.
+func (r ResourceConfig) aggregations() []string {
+	if len(r.Aggregations) == 0 {
+		return []string{"Average"}
+	}
+	return r.Aggregations
+}
+
+// interval returns the interval of r, applying the default.
+func (r ResourceConfig) interval() time.Duration {
+	if r.Interval == 0 {
+		return time.Minute
+	}
+	return r.Interval
+}
+
+// length returns the length of r, applying the default.
+func (r ResourceConfig) length() time.Duration {
+	if r.Length == 0 {
+		return 5 * r.interval()
+	}
+	return r.Length
+}
+
+// supportedAggregations are the aggregations of Azure Monitor, keyed by
+// their field in metric values.
+var supportedAggregations = map[string]string{
+	"average": "Average",
+	"minimum": "Minimum",
+	"maximum": "Maximum",
+	"total":   "Total",
+	"count":   "Count",
+}
+
+// intervals are the intervals supported by Azure Monitor, formatted as ISO
+// 8601 durations.
+var intervals = map[time.Duration]string{
+	time.Minute:      "PT1M",
+	5 * time.Minute:  "PT5M",
+	15 * time.Minute: "PT15M",
+	30 * time.Minute: "PT30M",
+	time.Hour:        "PT1H",
+	6 * time.Hour:    "PT6H",
+	12 * time.Hour:   "PT12H",
+	24 * time.Hour:   "P1D",
+}
+
+// Validate validates the resource config.
+func (r ResourceConfig) Validate() error {
+	if strings.Count(r.Type, "/") < 1 {
+		return fmt.Errorf("type %q must have the form <provider>/<type>", r.Type)
+	}
+	if len(r.Metrics) == 0 {
+		return fmt.Errorf("at least one metric must be set for type %s", r.Type)
+	}
+	for _, agg := range r.aggregations() {
+		if _, ok := supportedAggregations[strings.ToLower(agg)]; !ok {
+			return fmt.Errorf("unsupported aggregation %q for type %s", agg, r.Type)
+		}
+	}
+	if _, ok := intervals[r.interval()]; !ok {
+		return fmt.Errorf("unsupported interval %s for type %s, must be one of 1m, 5m, 15m, 30m, 1h, 6h, 12h or 24h", r.Interval, r.Type)
+	}
+	if r.Length < 0 {
+		return fmt.Errorf("length of type %s must not be negative", r.Type)
+	}
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for Config.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultConfig
+
+	type plain Config
+	return unmarshal((*plain)(c))
+}
+
+// Name returns the name of the integration that this config represents.
+func (c *Config) Name() string {
+	return "azure_exporter"
+}
+
+// InstanceKey returns the hostname:port of the agent.
+func (c *Config) InstanceKey(agentKey string) (string, error) {
+	return agentKey, nil
+}
+
+// NewIntegration creates a new azure_exporter integration.
+func (c *Config) NewIntegration(l log.Logger) (integrations.Integration, error) {
+	return New(l, c)
+}
+
+func init() {
+	integrations.RegisterIntegration(&Config{})
+	integrations_v2.RegisterLegacy(&Config{}, integrations_v2.TypeMultiplex, metricsutils.NewNamedShim("azure"))
+}
+
+// Validate returns an error if c is invalid.
+func (c *Config) Validate() error {
+	if len(c.Subscriptions) == 0 {
+		return fmt.Errorf("at least one subscription must be set")
+	}
+	if c.ClientSecret != "" && (c.TenantID == "" || c.ClientID == "") {
+		return fmt.Errorf("tenant_id and client_id must be set when client_secret is set")
+	}
+	if c.CollectInterval <= 0 {
+		return fmt.Errorf("collect_interval must be greater than zero")
+	}
+	if len(c.Resources) == 0 {
+		return fmt.Errorf("at least one resource type must be configured")
+	}
+	for _, r := range c.Resources {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// New creates a new azure_exporter integration.
+func New(l log.Logger, c *Config) (integrations.Integration, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	tokens, err := newTokenSource(c)
+	if err != nil {
+		return nil, err
+	}
+
+	e := newExporter(l, c, newClient(c.ResourceManagerURL, tokens))
+	return integrations.NewCollectorIntegration(
+		c.Name(),
+		integrations.WithCollectors(e, e.requests, e.requestErrors),
+		integrations.WithRunner(e.Run),
+	), nil
+}
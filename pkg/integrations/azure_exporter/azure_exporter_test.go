@@ -0,0 +1,158 @@
+package azure_exporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/config"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig_SecretClientSecret(t *testing.T) {
+	stringCfg := `
+prometheus:
+  wal_directory: /tmp/agent
+integrations:
+  azure_exporter:
+    enabled: true
+    subscriptions: [sub]
+    tenant_id: tenant
+    client_id: client
+    client_secret: secret_client_secret
+    resources:
+    - type: Microsoft.Compute/virtualMachines
+      metrics: [Percentage CPU]
+`
+	config.CheckSecret(t, stringCfg, "secret_client_secret")
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tt := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name: "valid",
+			config: `
+subscriptions: [sub]
+resources:
+- type: Microsoft.Compute/virtualMachines
+  metrics: [Percentage CPU]
+  aggregations: [Average, Maximum]
+  interval: 5m`,
+		},
+		{
+			name: "service principal without tenant",
+			config: `
+subscriptions: [sub]
+client_secret: secret
+resources:
+- type: Microsoft.Compute/virtualMachines
+  metrics: [Percentage CPU]`,
+			err: "tenant_id and client_id must be set",
+		},
+		{
+			name: "unsupported interval",
+			config: `
+subscriptions: [sub]
+resources:
+- type: Microsoft.Compute/virtualMachines
+  metrics: [Percentage CPU]
+  interval: 2m`,
+			err: "unsupported interval 2m0s",
+		},
+		{
+			name: "unsupported aggregation",
+			config: `
+subscriptions: [sub]
+resources:
+- type: Microsoft.Compute/virtualMachines
+  metrics: [Percentage CPU]
+  aggregations: [Median]`,
+			err: `unsupported aggregation "Median"`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var c Config
+			require.NoError(t, yaml.UnmarshalStrict([]byte(tc.config), &c))
+
+			err := c.Validate()
+			if tc.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
+
+func TestExporter_Collect(t *testing.T) {
+	const vmID = "/subscriptions/sub/resourceGroups/prod/providers/Microsoft.Compute/virtualMachines/vm1"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		switch r.URL.Path {
+		case "/subscriptions/sub/resources":
+			require.Equal(t, "resourceType eq 'Microsoft.Compute/virtualMachines'", r.URL.Query().Get("$filter"))
+			_, _ = w.Write([]byte(`{"value": [
+				{"id": "` + vmID + `", "name": "vm1", "type": "Microsoft.Compute/virtualMachines"},
+				{"id": "/subscriptions/sub/resourceGroups/dev/providers/Microsoft.Compute/virtualMachines/vm2", "name": "vm2", "type": "Microsoft.Compute/virtualMachines"}
+			]}`))
+		case vmID + "/providers/Microsoft.Insights/metrics":
+			require.Equal(t, "Percentage CPU", r.URL.Query().Get("metricnames"))
+			require.Equal(t, "PT1M", r.URL.Query().Get("interval"))
+			_, _ = w.Write([]byte(`{"value": [{
+				"name": {"value": "Percentage CPU"},
+				"unit": "Percent",
+				"timeseries": [{"data": [
+					{"timeStamp": "2022-01-01T00:00:00Z", "average": 10, "maximum": 20},
+					{"timeStamp": "2022-01-01T00:01:00Z", "average": 12.5},
+					{"timeStamp": "2022-01-01T00:02:00Z"}
+				]}]
+			}]}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := DefaultConfig
+	cfg.Subscriptions = []string{"sub"}
+	cfg.Resources = []ResourceConfig{{
+		Type:           "Microsoft.Compute/virtualMachines",
+		ResourceGroups: []string{"PROD"},
+		Metrics:        []string{"Percentage CPU"},
+		Aggregations:   []string{"Average", "Maximum"},
+	}}
+	require.NoError(t, cfg.Validate())
+
+	e := newExporter(log.NewNopLogger(), &cfg, newClient(srv.URL+"/", staticToken("token")))
+	e.metrics = e.collect(context.Background(), time.Now())
+
+	expect := `
+# HELP azure_microsoft_compute_virtualmachines_percentage_cpu_average Azure Monitor metric Percentage CPU of Microsoft.Compute/virtualMachines, aggregated by Average.
+# TYPE azure_microsoft_compute_virtualmachines_percentage_cpu_average gauge
+azure_microsoft_compute_virtualmachines_percentage_cpu_average{resource_group="prod",resource_id="/subscriptions/sub/resourceGroups/prod/providers/Microsoft.Compute/virtualMachines/vm1",resource_name="vm1",subscription_id="sub",unit="Percent"} 12.5
+# HELP azure_microsoft_compute_virtualmachines_percentage_cpu_maximum Azure Monitor metric Percentage CPU of Microsoft.Compute/virtualMachines, aggregated by Maximum.
+# TYPE azure_microsoft_compute_virtualmachines_percentage_cpu_maximum gauge
+azure_microsoft_compute_virtualmachines_percentage_cpu_maximum{resource_group="prod",resource_id="/subscriptions/sub/resourceGroups/prod/providers/Microsoft.Compute/virtualMachines/vm1",resource_name="vm1",subscription_id="sub",unit="Percent"} 20
+`
+	require.NoError(t, testutil.CollectAndCompare(e, strings.NewReader(expect)))
+}
+
+type staticToken string
+
+func (t staticToken) Token(context.Context) (string, error) { return string(t), nil }
@@ -0,0 +1,207 @@
+package azure_exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/adal"
+)
+
+const (
+	resourcesAPIVersion = "2021-04-01"
+	metricsAPIVersion   = "2018-01-01"
+
+	// maxMetricsPerRequest is the maximum number of metric names of a
+	// metrics request.
+	maxMetricsPerRequest = 20
+)
+
+// tokenSource returns access tokens for the Azure Resource Manager API.
+type tokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// adalTokenSource is a tokenSource of a service principal or managed
+// identity. Tokens are refreshed before they expire.
+type adalTokenSource struct {
+	spt *adal.ServicePrincipalToken
+}
+
+// newTokenSource returns the tokenSource for the credentials of c.
+func newTokenSource(c *Config) (tokenSource, error) {
+	resource := c.ResourceManagerURL
+
+	if c.ClientSecret != "" {
+		oauthConfig, err := adal.NewOAuthConfig(c.ActiveDirectoryURL, c.TenantID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid active directory config: %w", err)
+		}
+		spt, err := adal.NewServicePrincipalToken(*oauthConfig, c.ClientID, string(c.ClientSecret), resource)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create service principal token: %w", err)
+		}
+		return &adalTokenSource{spt: spt}, nil
+	}
+
+	endpoint, err := adal.GetMSIVMEndpoint()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get managed identity endpoint: %w", err)
+	}
+	var spt *adal.ServicePrincipalToken
+	if c.ClientID != "" {
+		spt, err = adal.NewServicePrincipalTokenFromMSIWithUserAssignedID(endpoint, resource, c.ClientID)
+	} else {
+		spt, err = adal.NewServicePrincipalTokenFromMSI(endpoint, resource)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create managed identity token: %w", err)
+	}
+	return &adalTokenSource{spt: spt}, nil
+}
+
+func (s *adalTokenSource) Token(ctx context.Context) (string, error) {
+	if err := s.spt.EnsureFreshWithContext(ctx); err != nil {
+		return "", fmt.Errorf("failed to refresh token: %w", err)
+	}
+	return s.spt.OAuthToken(), nil
+}
+
+// client is a client of the Azure Resource Manager API.
+type client struct {
+	baseURL string
+	tokens  tokenSource
+	http    *http.Client
+}
+
+func newClient(baseURL string, tokens tokenSource) *client {
+	return &client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		tokens:  tokens,
+		http:    http.DefaultClient,
+	}
+}
+
+// resource is an Azure resource.
+type resource struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// resourceGroup returns the resource group of the resource.
+func (r resource) resourceGroup() string {
+	parts := strings.Split(r.ID, "/")
+	for i := 0; i < len(parts)-1; i++ {
+		if strings.EqualFold(parts[i], "resourceGroups") {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+// listResources returns the resources of resourceType in subscription.
+func (c *client) listResources(ctx context.Context, subscription, resourceType string) ([]resource, error) {
+	q := url.Values{}
+	q.Set("api-version", resourcesAPIVersion)
+	q.Set("$filter", fmt.Sprintf("resourceType eq '%s'", resourceType))
+	next := fmt.Sprintf("%s/subscriptions/%s/resources?%s", c.baseURL, url.PathEscape(subscription), q.Encode())
+
+	var res []resource
+	for next != "" {
+		var page struct {
+			Value    []resource `json:"value"`
+			NextLink string     `json:"nextLink"`
+		}
+		if err := c.get(ctx, next, &page); err != nil {
+			return nil, err
+		}
+		res = append(res, page.Value...)
+		next = page.NextLink
+	}
+	return res, nil
+}
+
+// metricValue is the value of an aggregation of a metric.
+type metricValue struct {
+	metric      string
+	unit        string
+	aggregation string
+	value       float64
+}
+
+// getMetrics returns the most recent value of every aggregation of metrics
+// of the resource with id. Metrics without data points are omitted.
+func (c *client) getMetrics(ctx context.Context, id string, metrics, aggregations []string, interval string, start, end time.Time) ([]metricValue, error) {
+	q := url.Values{}
+	q.Set("api-version", metricsAPIVersion)
+	q.Set("metricnames", strings.Join(metrics, ","))
+	q.Set("aggregation", strings.Join(aggregations, ","))
+	q.Set("interval", interval)
+	q.Set("timespan", start.UTC().Format(time.RFC3339)+"/"+end.UTC().Format(time.RFC3339))
+
+	var resp struct {
+		Value []struct {
+			Name struct {
+				Value string `json:"value"`
+			} `json:"name"`
+			Unit       string `json:"unit"`
+			Timeseries []struct {
+				Data []map[string]interface{} `json:"data"`
+			} `json:"timeseries"`
+		} `json:"value"`
+	}
+	if err := c.get(ctx, c.baseURL+id+"/providers/Microsoft.Insights/metrics?"+q.Encode(), &resp); err != nil {
+		return nil, err
+	}
+
+	var res []metricValue
+	for _, m := range resp.Value {
+		for _, ts := range m.Timeseries {
+			for _, agg := range aggregations {
+				field := strings.ToLower(agg)
+
+				// Data points are in ascending order of time and aggregations
+				// are missing from intervals without data.
+				for i := len(ts.Data) - 1; i >= 0; i-- {
+					if v, ok := ts.Data[i][field].(float64); ok {
+						res = append(res, metricValue{metric: m.Name.Value, unit: m.Unit, aggregation: supportedAggregations[field], value: v})
+						break
+					}
+				}
+			}
+		}
+	}
+	return res, nil
+}
+
+// get requests u and decodes the JSON response into v.
+func (c *client) get(ctx context.Context, u string, v interface{}) error {
+	token, err := c.tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
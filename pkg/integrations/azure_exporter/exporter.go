@@ -0,0 +1,176 @@
+package azure_exporter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var labelNames = []string{"subscription_id", "resource_group", "resource_name", "resource_id", "unit"}
+
+// exporter collects metrics from Azure Monitor in the background and caches
+// the results of the most recent collection.
+type exporter struct {
+	log    log.Logger
+	cfg    *Config
+	client *client
+
+	requests      *prometheus.CounterVec
+	requestErrors *prometheus.CounterVec
+
+	mut     sync.RWMutex
+	metrics []prometheus.Metric
+}
+
+func newExporter(l log.Logger, c *Config, client *client) *exporter {
+	return &exporter{
+		log:    l,
+		cfg:    c,
+		client: client,
+
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "azure_exporter_api_requests_total",
+			Help: "Total number of requests made to the Azure APIs.",
+		}, []string{"api"}),
+		requestErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "azure_exporter_api_request_errors_total",
+			Help: "Total number of requests to the Azure APIs which failed.",
+		}, []string{"api"}),
+	}
+}
+
+// Describe implements prometheus.Collector. The exporter is an unchecked
+// collector since the collected metrics depend on the configured metrics.
+func (e *exporter) Describe(chan<- *prometheus.Desc) {}
+
+// Collect writes the metrics of the most recent collection to ch.
+func (e *exporter) Collect(ch chan<- prometheus.Metric) {
+	e.mut.RLock()
+	defer e.mut.RUnlock()
+
+	for _, m := range e.metrics {
+		ch <- m
+	}
+}
+
+// Run collects metrics every collect interval until ctx is canceled.
+func (e *exporter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.cfg.CollectInterval)
+	defer ticker.Stop()
+
+	for {
+		metrics := e.collect(ctx, time.Now())
+
+		e.mut.Lock()
+		if ctx.Err() == nil {
+			e.metrics = metrics
+		}
+		e.mut.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// collect collects the metrics of all resources. Errors are logged and the
+// metrics of the failed resources omitted.
+func (e *exporter) collect(ctx context.Context, now time.Time) []prometheus.Metric {
+	var res []prometheus.Metric
+
+	for _, subscription := range e.cfg.Subscriptions {
+		for _, rc := range e.cfg.Resources {
+			e.requests.WithLabelValues("resources").Inc()
+			resources, err := e.client.listResources(ctx, subscription, rc.Type)
+			if err != nil {
+				e.requestErrors.WithLabelValues("resources").Inc()
+				level.Warn(e.log).Log("msg", "failed to list resources", "subscription", subscription, "type", rc.Type, "err", err)
+				continue
+			}
+
+			for _, r := range resources {
+				if !inResourceGroups(r, rc.ResourceGroups) {
+					continue
+				}
+				metrics, err := e.collectResource(ctx, now, subscription, rc, r)
+				if err != nil {
+					level.Warn(e.log).Log("msg", "failed to collect metrics of resource", "resource", r.ID, "err", err)
+					continue
+				}
+				res = append(res, metrics...)
+			}
+		}
+	}
+	return res
+}
+
+// inResourceGroups returns true if r is in one of groups, or groups is
+// empty. Resource group names are case insensitive.
+func inResourceGroups(r resource, groups []string) bool {
+	if len(groups) == 0 {
+		return true
+	}
+	for _, g := range groups {
+		if strings.EqualFold(r.resourceGroup(), g) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *exporter) collectResource(ctx context.Context, now time.Time, subscription string, rc ResourceConfig, r resource) ([]prometheus.Metric, error) {
+	var res []prometheus.Metric
+
+	metrics := rc.Metrics
+	for len(metrics) > 0 {
+		n := len(metrics)
+		if n > maxMetricsPerRequest {
+			n = maxMetricsPerRequest
+		}
+
+		e.requests.WithLabelValues("metrics").Inc()
+		values, err := e.client.getMetrics(ctx, r.ID, metrics[:n], rc.aggregations(), intervals[rc.interval()], now.Add(-rc.length()), now)
+		if err != nil {
+			e.requestErrors.WithLabelValues("metrics").Inc()
+			return nil, fmt.Errorf("failed to get metrics: %w", err)
+		}
+
+		for _, v := range values {
+			desc := prometheus.NewDesc(
+				promName(rc.Type, v.metric, v.aggregation),
+				fmt.Sprintf("Azure Monitor metric %s of %s, aggregated by %s.", v.metric, rc.Type, v.aggregation),
+				labelNames, nil,
+			)
+			m, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, v.value, subscription, r.resourceGroup(), r.Name, r.ID, v.unit)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, m)
+		}
+		metrics = metrics[n:]
+	}
+	return res, nil
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// promName returns the name of the metric of a resource type built from
+// parts, like azure_microsoft_compute_virtualmachines_percentage_cpu_average
+// for the Average of the Percentage CPU metric of virtual machines.
+func promName(resourceType string, parts ...string) string {
+	name := "azure_" + resourceType
+	for _, p := range parts {
+		name += "_" + p
+	}
+	name = invalidNameChars.ReplaceAllString(strings.ToLower(name), "_")
+	return strings.TrimSuffix(name, "_")
+}
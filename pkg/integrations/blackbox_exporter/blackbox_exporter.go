@@ -0,0 +1,205 @@
+// Package blackbox_exporter embeds https://github.com/prometheus/blackbox_exporter
+package blackbox_exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/integrations"
+	"github.com/grafana/agent/pkg/integrations/config"
+	"github.com/grafana/agent/pkg/util"
+	blackbox_config "github.com/prometheus/blackbox_exporter/config"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultModule is the module used for targets which don't set a module.
+const DefaultModule = "http_2xx"
+
+// defaultModules holds the modules used when neither a config file nor an
+// inline config is given.
+const defaultModules = `
+modules:
+  http_2xx:
+    prober: http
+  http_post_2xx:
+    prober: http
+    http:
+      method: POST
+  tcp_connect:
+    prober: tcp
+  icmp:
+    prober: icmp
+`
+
+// DefaultConfig holds the default settings for the blackbox_exporter
+// integration.
+var DefaultConfig = Config{
+	BlackboxTargets:    make([]BlackboxTarget, 0),
+	ProbeTimeoutOffset: 500 * time.Millisecond,
+}
+
+// BlackboxTarget defines a target to be probed by the integration.
+type BlackboxTarget struct {
+	Name   string `yaml:"name"`
+	Target string `yaml:"address"`
+	Module string `yaml:"module,omitempty"`
+}
+
+// Config configures the blackbox integration.
+type Config struct {
+	BlackboxConfigFile string           `yaml:"config_file,omitempty"`
+	BlackboxConfig     util.RawYAML     `yaml:"blackbox_config,omitempty"`
+	BlackboxTargets    []BlackboxTarget `yaml:"blackbox_targets"`
+
+	// ProbeTimeoutOffset is subtracted from the scrape timeout to get the
+	// timeout of probes, leaving time for the scrape to complete.
+	ProbeTimeoutOffset time.Duration `yaml:"probe_timeout_offset,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for Config.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultConfig
+
+	type plain Config
+	return unmarshal((*plain)(c))
+}
+
+// Name returns the name of the integration.
+func (c *Config) Name() string {
+	return "blackbox"
+}
+
+// InstanceKey returns the hostname:port of the agent.
+func (c *Config) InstanceKey(agentKey string) (string, error) {
+	return agentKey, nil
+}
+
+// NewIntegration creates a new blackbox integration.
+func (c *Config) NewIntegration(l log.Logger) (integrations.Integration, error) {
+	return New(l, c)
+}
+
+func init() {
+	integrations.RegisterIntegration(&Config{})
+}
+
+// LoadBlackboxConfig loads the blackbox modules from configFile, or from
+// inline if no file is given. The default modules are returned if neither is
+// set.
+func LoadBlackboxConfig(configFile string, inline util.RawYAML) (*blackbox_config.Config, error) {
+	if configFile != "" && len(inline) > 0 {
+		return nil, fmt.Errorf("config_file and blackbox_config must not both be set")
+	}
+
+	bb := []byte(defaultModules)
+	switch {
+	case configFile != "":
+		var err error
+		bb, err = os.ReadFile(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load blackbox config from file %v: %w", configFile, err)
+		}
+	case len(inline) > 0:
+		bb = inline
+	}
+
+	modules := &blackbox_config.Config{}
+	if err := yaml.UnmarshalStrict(bb, modules); err != nil {
+		return nil, fmt.Errorf("failed to parse blackbox config: %w", err)
+	}
+	return modules, nil
+}
+
+// ValidateTargets returns an error if targets are missing their name or
+// address, have duplicate names, or use modules missing from modules.
+func ValidateTargets(targets []BlackboxTarget, modules *blackbox_config.Config) error {
+	names := make(map[string]struct{}, len(targets))
+	for _, target := range targets {
+		if target.Name == "" || target.Target == "" {
+			return fmt.Errorf("failed to load blackbox_targets; the `name` and `address` fields are mandatory")
+		}
+		if _, exist := names[target.Name]; exist {
+			return fmt.Errorf("failed to load blackbox_targets; found multiple targets with name %q", target.Name)
+		}
+		names[target.Name] = struct{}{}
+
+		module := target.Module
+		if module == "" {
+			module = DefaultModule
+		}
+		if _, ok := modules.Modules[module]; !ok {
+			return fmt.Errorf("blackbox_target %q uses unknown module %q", target.Name, module)
+		}
+	}
+	return nil
+}
+
+// New creates a new blackbox_exporter integration.
+func New(log log.Logger, c *Config) (integrations.Integration, error) {
+	modules, err := LoadBlackboxConfig(c.BlackboxConfigFile, c.BlackboxConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateTargets(c.BlackboxTargets, modules); err != nil {
+		return nil, err
+	}
+	if c.ProbeTimeoutOffset < 0 {
+		return nil, fmt.Errorf("probe_timeout_offset must not be negative")
+	}
+
+	return &Integration{
+		cfg:     c,
+		handler: NewHandler(log, modules, c.BlackboxTargets, c.ProbeTimeoutOffset),
+	}, nil
+}
+
+// Integration is the blackbox_exporter integration. The integration probes
+// targets over HTTP, TCP, ICMP, DNS and gRPC.
+type Integration struct {
+	cfg     *Config
+	handler http.Handler
+}
+
+// MetricsHandler implements Integration.
+func (i *Integration) MetricsHandler() (http.Handler, error) {
+	return i.handler, nil
+}
+
+// Run satisfies Integration.Run.
+func (i *Integration) Run(ctx context.Context) error {
+	// We don't need to do anything here, so we can just wait for the context to
+	// finish.
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// ScrapeConfigs satisfies Integration.ScrapeConfigs.
+func (i *Integration) ScrapeConfigs() []config.ScrapeConfig {
+	var res []config.ScrapeConfig
+	for _, target := range i.cfg.BlackboxTargets {
+		res = append(res, config.ScrapeConfig{
+			JobName:     i.cfg.Name() + "/" + target.Name,
+			MetricsPath: "/metrics",
+			QueryParams: TargetParams(target),
+		})
+	}
+	return res
+}
+
+// TargetParams returns the query parameters for probing target.
+func TargetParams(target BlackboxTarget) url.Values {
+	module := target.Module
+	if module == "" {
+		module = DefaultModule
+	}
+
+	params := url.Values{}
+	params.Add("target", target.Target)
+	params.Add("module", module)
+	return params
+}
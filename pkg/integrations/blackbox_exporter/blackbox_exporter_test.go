@@ -0,0 +1,128 @@
+package blackbox_exporter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	blackbox_config "github.com/prometheus/blackbox_exporter/config"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig_Modules(t *testing.T) {
+	cfgText := `
+blackbox_targets:
+  - name: grafana
+    address: https://grafana.com
+  - name: dns
+    address: 8.8.8.8:53
+    module: dns_grafana
+blackbox_config:
+  modules:
+    http_2xx:
+      prober: http
+    dns_grafana:
+      prober: dns
+      dns:
+        query_name: grafana.com
+`
+
+	var cfg Config
+	require.NoError(t, yaml.UnmarshalStrict([]byte(cfgText), &cfg))
+	require.Equal(t, DefaultConfig.ProbeTimeoutOffset, cfg.ProbeTimeoutOffset)
+
+	modules, err := LoadBlackboxConfig(cfg.BlackboxConfigFile, cfg.BlackboxConfig)
+	require.NoError(t, err)
+	require.Equal(t, "grafana.com", modules.Modules["dns_grafana"].DNS.QueryName)
+	require.NoError(t, ValidateTargets(cfg.BlackboxTargets, modules))
+
+	i, err := New(log.NewNopLogger(), &cfg)
+	require.NoError(t, err)
+
+	scrapeConfigs := i.ScrapeConfigs()
+	require.Len(t, scrapeConfigs, 2)
+	require.Equal(t, "blackbox/grafana", scrapeConfigs[0].JobName)
+	require.Equal(t, "module=http_2xx&target=https%3A%2F%2Fgrafana.com", scrapeConfigs[0].QueryParams.Encode())
+	require.Equal(t, "module=dns_grafana&target=8.8.8.8%3A53", scrapeConfigs[1].QueryParams.Encode())
+}
+
+func TestLoadBlackboxConfig_Default(t *testing.T) {
+	modules, err := LoadBlackboxConfig("", nil)
+	require.NoError(t, err)
+	require.Contains(t, modules.Modules, DefaultModule)
+
+	_, err = LoadBlackboxConfig("blackbox.yml", []byte("modules: {}"))
+	require.EqualError(t, err, "config_file and blackbox_config must not both be set")
+}
+
+func TestValidateTargets(t *testing.T) {
+	modules, err := LoadBlackboxConfig("", nil)
+	require.NoError(t, err)
+
+	tt := []struct {
+		name    string
+		targets []BlackboxTarget
+		err     string
+	}{
+		{
+			name:    "missing address",
+			targets: []BlackboxTarget{{Name: "example"}},
+			err:     "failed to load blackbox_targets; the `name` and `address` fields are mandatory",
+		},
+		{
+			name:    "duplicate names",
+			targets: []BlackboxTarget{{Name: "example", Target: "a"}, {Name: "example", Target: "b"}},
+			err:     `failed to load blackbox_targets; found multiple targets with name "example"`,
+		},
+		{
+			name:    "unknown module",
+			targets: []BlackboxTarget{{Name: "example", Target: "a", Module: "dns"}},
+			err:     `blackbox_target "example" uses unknown module "dns"`,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.EqualError(t, ValidateTargets(tc.targets, modules), tc.err)
+		})
+	}
+}
+
+func TestHandler_InvalidParams(t *testing.T) {
+	modules, err := LoadBlackboxConfig("", nil)
+	require.NoError(t, err)
+	h := NewHandler(log.NewNopLogger(), modules, nil, DefaultConfig.ProbeTimeoutOffset)
+
+	for _, query := range []string{"", "?target=a&target=b", "?target=a&module=a&module=b"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics"+query, nil))
+		require.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
+
+func TestProbeTimeout(t *testing.T) {
+	tt := []struct {
+		name          string
+		scrapeTimeout string
+		moduleTimeout time.Duration
+		expect        time.Duration
+	}{
+		{name: "default", expect: defaultProbeTimeout - time.Second},
+		{name: "scrape timeout", scrapeTimeout: "10", expect: 9 * time.Second},
+		{name: "lower module timeout", scrapeTimeout: "10", moduleTimeout: 5 * time.Second, expect: 5 * time.Second},
+		{name: "higher module timeout", scrapeTimeout: "10", moduleTimeout: time.Minute, expect: 9 * time.Second},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tc.scrapeTimeout != "" {
+				r.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", tc.scrapeTimeout)
+			}
+			timeout, err := probeTimeout(r, blackbox_config.Module{Timeout: tc.moduleTimeout}, time.Second)
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, timeout)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package blackbox_exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	blackbox_config "github.com/prometheus/blackbox_exporter/config"
+	"github.com/prometheus/blackbox_exporter/prober"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// probers holds the probe functions by the name of their prober.
+var probers = map[string]prober.ProbeFn{
+	"http": prober.ProbeHTTP,
+	"tcp":  prober.ProbeTCP,
+	"icmp": prober.ProbeICMP,
+	"dns":  prober.ProbeDNS,
+	"grpc": prober.ProbeGRPC,
+}
+
+// defaultProbeTimeout is the timeout of probes of requests which don't send
+// their scrape timeout.
+const defaultProbeTimeout = 120 * time.Second
+
+// NewHandler returns a handler which probes the target given by the target
+// query parameter with the module given by the module query parameter. The
+// target may be the name of one of targets, in which case its address is
+// probed with its module unless another module is given.
+func NewHandler(logger log.Logger, modules *blackbox_config.Config, targets []BlackboxTarget, timeoutOffset time.Duration) http.Handler {
+	byName := make(map[string]BlackboxTarget, len(targets))
+	for _, target := range targets {
+		byName[target.Name] = target
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		if len(params["target"]) != 1 || params.Get("target") == "" {
+			http.Error(w, "'target' parameter must be specified once", http.StatusBadRequest)
+			return
+		}
+		if len(params["module"]) > 1 {
+			http.Error(w, "'module' parameter must only be specified once", http.StatusBadRequest)
+			return
+		}
+
+		target, moduleName := params.Get("target"), params.Get("module")
+		if t, ok := byName[target]; ok {
+			target = t.Target
+			if moduleName == "" {
+				moduleName = t.Module
+			}
+		}
+		if moduleName == "" {
+			moduleName = DefaultModule
+		}
+
+		module, ok := modules.Modules[moduleName]
+		if !ok {
+			http.Error(w, fmt.Sprintf("Unknown module %q", moduleName), http.StatusBadRequest)
+			return
+		}
+		probe, ok := probers[module.Prober]
+		if !ok {
+			http.Error(w, fmt.Sprintf("Unknown prober %q", module.Prober), http.StatusBadRequest)
+			return
+		}
+		if hostname := params.Get("hostname"); module.Prober == "http" && hostname != "" {
+			module.HTTP.Headers = withHostHeader(module.HTTP.Headers, hostname)
+		}
+
+		timeout, err := probeTimeout(r, module, timeoutOffset)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to parse timeout from Prometheus header: %s", err), http.StatusInternalServerError)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		var (
+			probeSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+				Name: "probe_success",
+				Help: "Displays whether or not the probe was a success",
+			})
+			probeDuration = prometheus.NewGauge(prometheus.GaugeOpts{
+				Name: "probe_duration_seconds",
+				Help: "Returns how long the probe took to complete in seconds",
+			})
+			registry = prometheus.NewRegistry()
+		)
+		registry.MustRegister(probeSuccess, probeDuration)
+
+		l := log.With(logger, "module", moduleName, "target", target)
+		start := time.Now()
+		success := probe(ctx, target, module, registry, l)
+		duration := time.Since(start).Seconds()
+
+		probeDuration.Set(duration)
+		if success {
+			probeSuccess.Set(1)
+		} else {
+			level.Debug(l).Log("msg", "probe failed", "duration_seconds", duration)
+		}
+
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
+
+// probeTimeout returns the timeout of a probe with module requested by r. The
+// timeout is the scrape timeout sent by Prometheus minus offset, unless the
+// module has a lower timeout.
+func probeTimeout(r *http.Request, module blackbox_config.Module, offset time.Duration) (time.Duration, error) {
+	timeout := defaultProbeTimeout
+	if v := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); v != "" {
+		seconds, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, err
+		}
+		if seconds > 0 {
+			timeout = time.Duration(seconds * float64(time.Second))
+		}
+	}
+
+	timeout -= offset
+	if module.Timeout > 0 && module.Timeout < timeout {
+		timeout = module.Timeout
+	}
+	return timeout, nil
+}
+
+// withHostHeader returns a copy of headers with the Host header set to
+// hostname, so the headers of the module aren't modified.
+func withHostHeader(headers map[string]string, hostname string) map[string]string {
+	res := make(map[string]string, len(headers)+1)
+	for k, v := range headers {
+		if http.CanonicalHeaderKey(k) != "Host" {
+			res[k] = v
+		}
+	}
+	res["Host"] = hostname
+	return res
+}
@@ -0,0 +1,47 @@
+package cloudwatch_exporter
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi/resourcegroupstaggingapiiface"
+)
+
+// clients are the AWS API clients for a region and role.
+type clients struct {
+	cloudwatch cloudwatchiface.CloudWatchAPI
+	tagging    resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI
+}
+
+// clientsFactory creates the clients for a region and role.
+type clientsFactory func(region string, role Role) (clients, error)
+
+// newAWSClients returns a clientsFactory which creates clients for the AWS
+// APIs. Roles are assumed through the STS endpoint of stsRegion. Credentials
+// are otherwise taken from the environment of the agent.
+func newAWSClients(stsRegion string) clientsFactory {
+	return func(region string, role Role) (clients, error) {
+		sess, err := session.NewSession()
+		if err != nil {
+			return clients{}, err
+		}
+
+		cfg := aws.NewConfig().WithRegion(region)
+		if role.RoleARN != "" {
+			stsSess := sess.Copy(aws.NewConfig().WithRegion(stsRegion))
+			cfg = cfg.WithCredentials(stscreds.NewCredentials(stsSess, role.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+				if role.ExternalID != "" {
+					p.ExternalID = aws.String(role.ExternalID)
+				}
+			}))
+		}
+
+		return clients{
+			cloudwatch: cloudwatch.New(sess, cfg),
+			tagging:    resourcegroupstaggingapi.New(sess, cfg),
+		}, nil
+	}
+}
@@ -0,0 +1,107 @@
+// Package cloudwatch_exporter collects metrics of AWS resources from
+// CloudWatch. Its config follows the discovery and static jobs of
+// https://github.com/nerdswords/yet-another-cloudwatch-exporter
+package cloudwatch_exporter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/integrations"
+	integrations_v2 "github.com/grafana/agent/pkg/integrations/v2"
+	"github.com/grafana/agent/pkg/integrations/v2/metricsutils"
+)
+
+// DefaultConfig holds the default settings for the cloudwatch_exporter
+// integration.
+var DefaultConfig = Config{
+	STSRegion:       "us-east-1",
+	CollectInterval: 5 * time.Minute,
+}
+
+// Config controls the cloudwatch_exporter integration.
+type Config struct {
+	// STSRegion is the region of the STS endpoint used for assuming roles.
+	STSRegion string `yaml:"sts_region,omitempty"`
+
+	// CollectInterval is how often metrics are collected from CloudWatch.
+	// Scrapes return the metrics of the most recent collection, which keeps
+	// the number of CloudWatch API requests independent of the scrape
+	// interval.
+	CollectInterval time.Duration `yaml:"collect_interval,omitempty"`
+
+	Discovery DiscoveryConfig `yaml:"discovery,omitempty"`
+	Static    []StaticJob     `yaml:"static,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for Config.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultConfig
+
+	type plain Config
+	return unmarshal((*plain)(c))
+}
+
+// Name returns the name of the integration that this config represents.
+func (c *Config) Name() string {
+	return "cloudwatch_exporter"
+}
+
+// InstanceKey returns the hostname:port of the agent.
+func (c *Config) InstanceKey(agentKey string) (string, error) {
+	return agentKey, nil
+}
+
+// NewIntegration creates a new cloudwatch_exporter integration.
+func (c *Config) NewIntegration(l log.Logger) (integrations.Integration, error) {
+	return New(l, c)
+}
+
+func init() {
+	integrations.RegisterIntegration(&Config{})
+	integrations_v2.RegisterLegacy(&Config{}, integrations_v2.TypeMultiplex, metricsutils.NewNamedShim("cloudwatch"))
+}
+
+// Validate returns an error if c is invalid.
+func (c *Config) Validate() error {
+	if c.CollectInterval <= 0 {
+		return fmt.Errorf("collect_interval must be greater than zero")
+	}
+	if len(c.Discovery.Jobs) == 0 && len(c.Static) == 0 {
+		return fmt.Errorf("at least one discovery or static job must be configured")
+	}
+	for i := range c.Discovery.Jobs {
+		if err := c.Discovery.Jobs[i].Validate(); err != nil {
+			return fmt.Errorf("invalid discovery job %d: %w", i, err)
+		}
+	}
+	for i := range c.Static {
+		if err := c.Static[i].Validate(); err != nil {
+			return fmt.Errorf("invalid static job %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// New creates a new cloudwatch_exporter integration.
+func New(l log.Logger, c *Config) (integrations.Integration, error) {
+	return newIntegration(l, c, newAWSClients(c.STSRegion))
+}
+
+func newIntegration(l log.Logger, c *Config, newClients clientsFactory) (integrations.Integration, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	e, err := newExporter(l, c, newClients)
+	if err != nil {
+		return nil, err
+	}
+
+	return integrations.NewCollectorIntegration(
+		c.Name(),
+		integrations.WithCollectors(e, e.requests, e.requestErrors),
+		integrations.WithRunner(e.Run),
+	), nil
+}
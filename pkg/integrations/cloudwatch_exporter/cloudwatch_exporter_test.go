@@ -0,0 +1,238 @@
+package cloudwatch_exporter
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi/resourcegroupstaggingapiiface"
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tt := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name: "valid",
+			config: `
+discovery:
+  jobs:
+  - type: AWS/EC2
+    regions: [us-east-2]
+    search_tags:
+    - key: env
+      value: prod.*
+    metrics:
+    - name: CPUUtilization
+      statistics: [Average, p99]
+static:
+- name: my-queue
+  namespace: AWS/SQS
+  regions: [us-east-2]
+  dimensions:
+  - name: QueueName
+    value: my-queue
+  metrics:
+  - name: NumberOfMessagesSent
+    statistics: [Sum]
+    period: 1m`,
+		},
+		{
+			name:   "no jobs",
+			config: `{}`,
+			err:    "at least one discovery or static job must be configured",
+		},
+		{
+			name: "unsupported type",
+			config: `
+discovery:
+  jobs:
+  - type: AWS/Unknown
+    regions: [us-east-2]
+    metrics: [{name: Foo, statistics: [Sum]}]`,
+			err: `unsupported type "AWS/Unknown"`,
+		},
+		{
+			name: "invalid statistic",
+			config: `
+static:
+- name: foo
+  namespace: AWS/SQS
+  regions: [us-east-2]
+  metrics: [{name: Foo, statistics: [Median]}]`,
+			err: `unsupported statistic "Median"`,
+		},
+		{
+			name: "invalid search tag",
+			config: `
+discovery:
+  jobs:
+  - type: AWS/EC2
+    regions: [us-east-2]
+    search_tags: [{key: env, value: "("}]
+    metrics: [{name: Foo, statistics: [Sum]}]`,
+			err: "invalid value of search tag env",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var c Config
+			require.NoError(t, yaml.UnmarshalStrict([]byte(tc.config), &c))
+
+			err := c.Validate()
+			if tc.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
+
+func TestExporter_Collect(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.Discovery = DiscoveryConfig{
+		ExportedTags: map[string][]string{"AWS/EC2": {"Name"}},
+		Jobs: []DiscoveryJob{{
+			Type:       "AWS/EC2",
+			Regions:    []string{"us-east-2"},
+			SearchTags: []Tag{{Key: "env", Value: "prod"}},
+			Metrics:    []Metric{{Name: "CPUUtilization", Statistics: []string{"Average"}}},
+		}},
+	}
+	cfg.Static = []StaticJob{{
+		Name:       "my-queue",
+		Namespace:  "AWS/SQS",
+		Regions:    []string{"us-east-2"},
+		Dimensions: []Dimension{{Name: "QueueName", Value: "my-queue"}},
+		Metrics:    []Metric{{Name: "NumberOfMessagesSent", Statistics: []string{"Sum"}}},
+	}}
+	require.NoError(t, cfg.Validate())
+
+	tagging := &fakeTagging{
+		resources: []*resourcegroupstaggingapi.ResourceTagMapping{
+			{
+				ResourceARN: aws.String("arn:aws:ec2:us-east-2:123456789012:instance/i-prod"),
+				Tags:        tags("env", "prod", "Name", "web"),
+			},
+			{
+				ResourceARN: aws.String("arn:aws:ec2:us-east-2:123456789012:instance/i-dev"),
+				Tags:        tags("env", "dev", "Name", "dev"),
+			},
+		},
+	}
+	cw := &fakeCloudWatch{
+		values: map[string]float64{
+			"CPUUtilization/InstanceId=i-prod/Average":    42,
+			"NumberOfMessagesSent/QueueName=my-queue/Sum": 7,
+		},
+	}
+
+	var roles []Role
+	e, err := newExporter(log.NewNopLogger(), &cfg, func(region string, role Role) (clients, error) {
+		require.Equal(t, "us-east-2", region)
+		roles = append(roles, role)
+		return clients{cloudwatch: cw, tagging: tagging}, nil
+	})
+	require.NoError(t, err)
+
+	e.metrics = e.collect(context.Background(), time.Now())
+
+	expect := `
+# HELP aws_ec2_cpuutilization_average CloudWatch metric AWS/EC2 CPUUtilization Average.
+# TYPE aws_ec2_cpuutilization_average gauge
+aws_ec2_cpuutilization_average{dimension_InstanceId="i-prod",name="arn:aws:ec2:us-east-2:123456789012:instance/i-prod",region="us-east-2",tag_Name="web"} 42
+# HELP aws_ec2_info Information about AWS/EC2 resources discovered through their tags.
+# TYPE aws_ec2_info gauge
+aws_ec2_info{dimension_InstanceId="i-prod",name="arn:aws:ec2:us-east-2:123456789012:instance/i-prod",region="us-east-2",tag_Name="web"} 1
+# HELP aws_sqs_numberofmessagessent_sum CloudWatch metric AWS/SQS NumberOfMessagesSent Sum.
+# TYPE aws_sqs_numberofmessagessent_sum gauge
+aws_sqs_numberofmessagessent_sum{dimension_QueueName="my-queue",name="my-queue",region="us-east-2"} 7
+`
+	require.NoError(t, testutil.CollectAndCompare(e, strings.NewReader(expect)))
+
+	// Clients are created once per region and role.
+	e.collect(context.Background(), time.Now())
+	require.Equal(t, []Role{{}}, roles)
+	require.Equal(t, 4.0, testutil.ToFloat64(e.requests.WithLabelValues("GetMetricData")))
+}
+
+func TestServices_ARNs(t *testing.T) {
+	tt := map[string]struct{ arn, value string }{
+		"AWS/EC2":            {"arn:aws:ec2:us-east-2:123456789012:instance/i-0123", "i-0123"},
+		"AWS/RDS":            {"arn:aws:rds:us-east-2:123456789012:db:my-db", "my-db"},
+		"AWS/ELB":            {"arn:aws:elasticloadbalancing:us-east-2:123456789012:loadbalancer/my-elb", "my-elb"},
+		"AWS/ApplicationELB": {"arn:aws:elasticloadbalancing:us-east-2:123456789012:loadbalancer/app/my-alb/50dc6c495c0c9188", "app/my-alb/50dc6c495c0c9188"},
+		"AWS/Lambda":         {"arn:aws:lambda:us-east-2:123456789012:function:my-function", "my-function"},
+		"AWS/SQS":            {"arn:aws:sqs:us-east-2:123456789012:my-queue", "my-queue"},
+		"AWS/DynamoDB":       {"arn:aws:dynamodb:us-east-2:123456789012:table/my-table", "my-table"},
+	}
+	for namespace, tc := range tt {
+		match := services[namespace].arnRegexp.FindStringSubmatch(tc.arn)
+		require.NotNil(t, match, namespace)
+		require.Equal(t, tc.value, match[1], namespace)
+	}
+
+	// Application load balancers must not be discovered as classic load
+	// balancers.
+	require.Nil(t, services["AWS/ELB"].arnRegexp.FindStringSubmatch(tt["AWS/ApplicationELB"].arn))
+}
+
+func tags(kv ...string) []*resourcegroupstaggingapi.Tag {
+	var res []*resourcegroupstaggingapi.Tag
+	for i := 0; i < len(kv); i += 2 {
+		res = append(res, &resourcegroupstaggingapi.Tag{Key: aws.String(kv[i]), Value: aws.String(kv[i+1])})
+	}
+	return res
+}
+
+// fakeTagging returns resources from the tagging API.
+type fakeTagging struct {
+	resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI
+	resources []*resourcegroupstaggingapi.ResourceTagMapping
+}
+
+func (f *fakeTagging) GetResourcesPagesWithContext(_ aws.Context, _ *resourcegroupstaggingapi.GetResourcesInput, fn func(*resourcegroupstaggingapi.GetResourcesOutput, bool) bool, _ ...request.Option) error {
+	fn(&resourcegroupstaggingapi.GetResourcesOutput{ResourceTagMappingList: f.resources}, true)
+	return nil
+}
+
+// fakeCloudWatch returns values from the CloudWatch API. Values are keyed by
+// metric name, dimensions and statistic.
+type fakeCloudWatch struct {
+	cloudwatchiface.CloudWatchAPI
+	values map[string]float64
+}
+
+func (f *fakeCloudWatch) GetMetricDataPagesWithContext(_ aws.Context, input *cloudwatch.GetMetricDataInput, fn func(*cloudwatch.GetMetricDataOutput, bool) bool, _ ...request.Option) error {
+	out := &cloudwatch.GetMetricDataOutput{}
+	for _, q := range input.MetricDataQueries {
+		key := aws.StringValue(q.MetricStat.Metric.MetricName)
+		for _, dim := range q.MetricStat.Metric.Dimensions {
+			key += "/" + aws.StringValue(dim.Name) + "=" + aws.StringValue(dim.Value)
+		}
+		key += "/" + aws.StringValue(q.MetricStat.Stat)
+
+		result := &cloudwatch.MetricDataResult{Id: q.Id}
+		if v, ok := f.values[key]; ok {
+			result.Values = []*float64{aws.Float64(v)}
+		}
+		out.MetricDataResults = append(out.MetricDataResults, result)
+	}
+	fn(out, true)
+	return nil
+}
@@ -0,0 +1,174 @@
+package cloudwatch_exporter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultMetricPeriod is the period of metrics which don't set one.
+const DefaultMetricPeriod = 5 * time.Minute
+
+// Role is an AWS IAM role assumed for collecting metrics.
+type Role struct {
+	RoleARN    string `yaml:"role_arn"`
+	ExternalID string `yaml:"external_id,omitempty"`
+}
+
+// Tag selects discovered resources by tag. Value is a regular expression
+// which must match the value of the tag.
+type Tag struct {
+	Key   string `yaml:"key"`
+	Value string `yaml:"value"`
+}
+
+// Dimension is a CloudWatch dimension of the metrics of a static job.
+type Dimension struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+// Metric configures a CloudWatch metric to collect.
+type Metric struct {
+	Name       string   `yaml:"name"`
+	Statistics []string `yaml:"statistics"`
+
+	// Period is the granularity of the collected data points. Defaults to
+	// DefaultMetricPeriod.
+	Period time.Duration `yaml:"period,omitempty"`
+
+	// Length is how far back data points are looked for. The most recent
+	// data point is collected. Defaults to Period.
+	Length time.Duration `yaml:"length,omitempty"`
+}
+
+// period returns the period of m, applying the default.
+func (m Metric) period() time.Duration {
+	if m.Period == 0 {
+		return DefaultMetricPeriod
+	}
+	return m.Period
+}
+
+// length returns the length of m, applying the default.
+func (m Metric) length() time.Duration {
+	if m.Length == 0 {
+		return m.period()
+	}
+	return m.Length
+}
+
+// statisticRegexp matches the statistics supported by CloudWatch, including
+// percentiles like p99 or p99.9.
+var statisticRegexp = regexp.MustCompile(`^(Average|Sum|Minimum|Maximum|SampleCount|p\d{1,2}(\.\d+)?)$`)
+
+// Validate validates the metric.
+func (m Metric) Validate() error {
+	if m.Name == "" {
+		return fmt.Errorf("metric name must not be empty")
+	}
+	if len(m.Statistics) == 0 {
+		return fmt.Errorf("metric %s must have at least one statistic", m.Name)
+	}
+	for _, stat := range m.Statistics {
+		if !statisticRegexp.MatchString(stat) {
+			return fmt.Errorf("metric %s has unsupported statistic %q", m.Name, stat)
+		}
+	}
+	if m.Period < 0 || m.Period%time.Second != 0 {
+		return fmt.Errorf("period of metric %s must be a positive number of seconds", m.Name)
+	}
+	if m.Length < 0 {
+		return fmt.Errorf("length of metric %s must not be negative", m.Name)
+	}
+	return nil
+}
+
+// DiscoveryConfig configures collecting metrics from resources discovered
+// by their tags.
+type DiscoveryConfig struct {
+	// ExportedTags are the tags added as labels to the metrics of discovered
+	// resources, keyed by namespace.
+	ExportedTags map[string][]string `yaml:"exported_tags,omitempty"`
+
+	Jobs []DiscoveryJob `yaml:"jobs,omitempty"`
+}
+
+// DiscoveryJob collects metrics from the resources of a namespace which
+// match the search tags.
+type DiscoveryJob struct {
+	// Type is the CloudWatch namespace of the resources, like AWS/EC2.
+	Type       string   `yaml:"type"`
+	Regions    []string `yaml:"regions"`
+	Roles      []Role   `yaml:"roles,omitempty"`
+	SearchTags []Tag    `yaml:"search_tags,omitempty"`
+	Metrics    []Metric `yaml:"metrics"`
+}
+
+// Validate validates the discovery job.
+func (j *DiscoveryJob) Validate() error {
+	if _, ok := services[j.Type]; !ok {
+		return fmt.Errorf("unsupported type %q, must be one of %s", j.Type, strings.Join(serviceNamespaces(), ", "))
+	}
+	if len(j.Regions) == 0 {
+		return fmt.Errorf("at least one region must be set")
+	}
+	for _, tag := range j.SearchTags {
+		if tag.Key == "" {
+			return fmt.Errorf("search tag key must not be empty")
+		}
+		if _, err := regexp.Compile(tag.Value); err != nil {
+			return fmt.Errorf("invalid value of search tag %s: %w", tag.Key, err)
+		}
+	}
+	return validateMetrics(j.Metrics)
+}
+
+// StaticJob collects metrics with fixed dimensions.
+type StaticJob struct {
+	// Name is the value of the name label of the collected metrics.
+	Name       string      `yaml:"name"`
+	Namespace  string      `yaml:"namespace"`
+	Regions    []string    `yaml:"regions"`
+	Roles      []Role      `yaml:"roles,omitempty"`
+	Dimensions []Dimension `yaml:"dimensions,omitempty"`
+	Metrics    []Metric    `yaml:"metrics"`
+}
+
+// Validate validates the static job.
+func (j *StaticJob) Validate() error {
+	if j.Name == "" || j.Namespace == "" {
+		return fmt.Errorf("the `name` and `namespace` fields are mandatory")
+	}
+	if len(j.Regions) == 0 {
+		return fmt.Errorf("at least one region must be set")
+	}
+	for _, dim := range j.Dimensions {
+		if dim.Name == "" || dim.Value == "" {
+			return fmt.Errorf("dimension names and values must not be empty")
+		}
+	}
+	return validateMetrics(j.Metrics)
+}
+
+func validateMetrics(metrics []Metric) error {
+	if len(metrics) == 0 {
+		return fmt.Errorf("at least one metric must be set")
+	}
+	for _, m := range metrics {
+		if err := m.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rolesOrDefault returns roles, or the default credentials if roles is
+// empty.
+func rolesOrDefault(roles []Role) []Role {
+	if len(roles) == 0 {
+		return []Role{{}}
+	}
+	return roles
+}
@@ -0,0 +1,428 @@
+package cloudwatch_exporter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxQueriesPerRequest is the maximum number of queries of a GetMetricData
+// request.
+const maxQueriesPerRequest = 500
+
+// exporter collects metrics from CloudWatch in the background and caches
+// the results of the most recent collection.
+type exporter struct {
+	log        log.Logger
+	cfg        *Config
+	newClients clientsFactory
+	searchTags [][]searchTag // search tags of every discovery job
+
+	clientsMut sync.Mutex
+	clients    map[clientsKey]clients
+
+	requests      *prometheus.CounterVec
+	requestErrors *prometheus.CounterVec
+
+	mut     sync.RWMutex
+	metrics []prometheus.Metric
+}
+
+type clientsKey struct {
+	region string
+	role   Role
+}
+
+type searchTag struct {
+	key   string
+	value *regexp.Regexp
+}
+
+func newExporter(l log.Logger, c *Config, newClients clientsFactory) (*exporter, error) {
+	searchTags := make([][]searchTag, len(c.Discovery.Jobs))
+	for i, job := range c.Discovery.Jobs {
+		for _, tag := range job.SearchTags {
+			re, err := regexp.Compile(tag.Value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of search tag %s: %w", tag.Key, err)
+			}
+			searchTags[i] = append(searchTags[i], searchTag{key: tag.Key, value: re})
+		}
+	}
+
+	return &exporter{
+		log:        l,
+		cfg:        c,
+		newClients: newClients,
+		searchTags: searchTags,
+		clients:    make(map[clientsKey]clients),
+
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cloudwatch_exporter_api_requests_total",
+			Help: "Total number of requests made to the AWS APIs.",
+		}, []string{"api"}),
+		requestErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cloudwatch_exporter_api_request_errors_total",
+			Help: "Total number of requests to the AWS APIs which failed.",
+		}, []string{"api"}),
+	}, nil
+}
+
+// Describe implements prometheus.Collector. The exporter is an unchecked
+// collector since the collected metrics depend on the discovered resources.
+func (e *exporter) Describe(chan<- *prometheus.Desc) {}
+
+// Collect writes the metrics of the most recent collection to ch.
+func (e *exporter) Collect(ch chan<- prometheus.Metric) {
+	e.mut.RLock()
+	defer e.mut.RUnlock()
+
+	for _, m := range e.metrics {
+		ch <- m
+	}
+}
+
+// Run collects metrics every collect interval until ctx is canceled.
+func (e *exporter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.cfg.CollectInterval)
+	defer ticker.Stop()
+
+	for {
+		metrics := e.collect(ctx, time.Now())
+
+		e.mut.Lock()
+		if ctx.Err() == nil {
+			e.metrics = metrics
+		}
+		e.mut.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// query is a CloudWatch metric statistic to collect.
+type query struct {
+	namespace  string
+	metric     Metric
+	stat       string
+	dimensions []Dimension
+
+	labelNames  []string
+	labelValues []string
+}
+
+// collect collects the metrics of all jobs. Errors are logged and the
+// metrics of the failed jobs omitted.
+func (e *exporter) collect(ctx context.Context, now time.Time) []prometheus.Metric {
+	var res []prometheus.Metric
+
+	for i, job := range e.cfg.Discovery.Jobs {
+		for _, region := range job.Regions {
+			for _, role := range rolesOrDefault(job.Roles) {
+				metrics, err := e.collectDiscoveryJob(ctx, now, job, e.searchTags[i], region, role)
+				if err != nil {
+					level.Warn(e.log).Log("msg", "failed to collect discovery job", "type", job.Type, "region", region, "role_arn", role.RoleARN, "err", err)
+					continue
+				}
+				res = append(res, metrics...)
+			}
+		}
+	}
+
+	for _, job := range e.cfg.Static {
+		for _, region := range job.Regions {
+			for _, role := range rolesOrDefault(job.Roles) {
+				metrics, err := e.collectStaticJob(ctx, now, job, region, role)
+				if err != nil {
+					level.Warn(e.log).Log("msg", "failed to collect static job", "name", job.Name, "region", region, "role_arn", role.RoleARN, "err", err)
+					continue
+				}
+				res = append(res, metrics...)
+			}
+		}
+	}
+
+	return res
+}
+
+func (e *exporter) collectDiscoveryJob(ctx context.Context, now time.Time, job DiscoveryJob, tags []searchTag, region string, role Role) ([]prometheus.Metric, error) {
+	c, err := e.getClients(region, role)
+	if err != nil {
+		return nil, err
+	}
+
+	svc := services[job.Type]
+	resources, err := e.discoverResources(ctx, c, svc, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	exportedTags := e.cfg.Discovery.ExportedTags[job.Type]
+	labelNames := []string{"name", "region", promLabel("dimension_" + svc.dimension)}
+	for _, tag := range exportedTags {
+		labelNames = append(labelNames, promLabel("tag_"+tag))
+	}
+
+	var (
+		res      []prometheus.Metric
+		queries  []*query
+		infoDesc = prometheus.NewDesc(
+			promName(job.Type, "info"),
+			fmt.Sprintf("Information about %s resources discovered through their tags.", job.Type),
+			labelNames, nil,
+		)
+	)
+	for _, r := range resources {
+		labelValues := []string{r.arn, region, r.dimensionValue}
+		for _, tag := range exportedTags {
+			labelValues = append(labelValues, r.tags[tag])
+		}
+		res = append(res, prometheus.MustNewConstMetric(infoDesc, prometheus.GaugeValue, 1, labelValues...))
+
+		for _, m := range job.Metrics {
+			for _, stat := range m.Statistics {
+				queries = append(queries, &query{
+					namespace:   job.Type,
+					metric:      m,
+					stat:        stat,
+					dimensions:  []Dimension{{Name: svc.dimension, Value: r.dimensionValue}},
+					labelNames:  labelNames,
+					labelValues: labelValues,
+				})
+			}
+		}
+	}
+
+	metrics, err := e.getMetricData(ctx, c, now, queries)
+	if err != nil {
+		return nil, err
+	}
+	return append(res, metrics...), nil
+}
+
+func (e *exporter) collectStaticJob(ctx context.Context, now time.Time, job StaticJob, region string, role Role) ([]prometheus.Metric, error) {
+	c, err := e.getClients(region, role)
+	if err != nil {
+		return nil, err
+	}
+
+	labelNames := []string{"name", "region"}
+	labelValues := []string{job.Name, region}
+	for _, dim := range job.Dimensions {
+		labelNames = append(labelNames, promLabel("dimension_"+dim.Name))
+		labelValues = append(labelValues, dim.Value)
+	}
+
+	var queries []*query
+	for _, m := range job.Metrics {
+		for _, stat := range m.Statistics {
+			queries = append(queries, &query{
+				namespace:   job.Namespace,
+				metric:      m,
+				stat:        stat,
+				dimensions:  job.Dimensions,
+				labelNames:  labelNames,
+				labelValues: labelValues,
+			})
+		}
+	}
+	return e.getMetricData(ctx, c, now, queries)
+}
+
+// getClients returns the clients for region and role, creating them on
+// first use. Clients are reused so credentials of assumed roles are cached.
+func (e *exporter) getClients(region string, role Role) (clients, error) {
+	e.clientsMut.Lock()
+	defer e.clientsMut.Unlock()
+
+	key := clientsKey{region: region, role: role}
+	if c, ok := e.clients[key]; ok {
+		return c, nil
+	}
+	c, err := e.newClients(region, role)
+	if err != nil {
+		return clients{}, fmt.Errorf("failed to create AWS clients: %w", err)
+	}
+	e.clients[key] = c
+	return c, nil
+}
+
+// resource is a resource discovered through the tagging API.
+type resource struct {
+	arn            string
+	dimensionValue string
+	tags           map[string]string
+}
+
+// discoverResources returns the resources of svc which match tags.
+func (e *exporter) discoverResources(ctx context.Context, c clients, svc service, tags []searchTag) ([]resource, error) {
+	input := &resourcegroupstaggingapi.GetResourcesInput{
+		ResourceTypeFilters: []*string{aws.String(svc.resourceType)},
+	}
+	for _, tag := range tags {
+		input.TagFilters = append(input.TagFilters, &resourcegroupstaggingapi.TagFilter{Key: aws.String(tag.key)})
+	}
+
+	var res []resource
+	e.requests.WithLabelValues("GetResources").Inc()
+	err := c.tagging.GetResourcesPagesWithContext(ctx, input, func(page *resourcegroupstaggingapi.GetResourcesOutput, _ bool) bool {
+		for _, mapping := range page.ResourceTagMappingList {
+			r := resource{
+				arn:  aws.StringValue(mapping.ResourceARN),
+				tags: make(map[string]string, len(mapping.Tags)),
+			}
+			for _, tag := range mapping.Tags {
+				r.tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+
+			match := svc.arnRegexp.FindStringSubmatch(r.arn)
+			if match == nil || !matchTags(r.tags, tags) {
+				continue
+			}
+			r.dimensionValue = match[1]
+			res = append(res, r)
+		}
+		return true
+	})
+	if err != nil {
+		e.requestErrors.WithLabelValues("GetResources").Inc()
+		return nil, fmt.Errorf("failed to discover resources: %w", err)
+	}
+	return res, nil
+}
+
+// matchTags returns true if the values of tags match all search tags.
+func matchTags(tags map[string]string, search []searchTag) bool {
+	for _, st := range search {
+		value, ok := tags[st.key]
+		if !ok || !st.value.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// getMetricData returns the most recent data point of every query. Queries
+// without data points are omitted.
+func (e *exporter) getMetricData(ctx context.Context, c clients, now time.Time, queries []*query) ([]prometheus.Metric, error) {
+	// Queries of a request share their time range, which depends on the
+	// length of the metric.
+	byLength := make(map[time.Duration][]*query)
+	for _, q := range queries {
+		byLength[q.metric.length()] = append(byLength[q.metric.length()], q)
+	}
+
+	var res []prometheus.Metric
+	for length, queries := range byLength {
+		for len(queries) > 0 {
+			n := len(queries)
+			if n > maxQueriesPerRequest {
+				n = maxQueriesPerRequest
+			}
+			metrics, err := e.getMetricDataBatch(ctx, c, now.Add(-length), now, queries[:n])
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, metrics...)
+			queries = queries[n:]
+		}
+	}
+	return res, nil
+}
+
+func (e *exporter) getMetricDataBatch(ctx context.Context, c clients, start, end time.Time, queries []*query) ([]prometheus.Metric, error) {
+	input := &cloudwatch.GetMetricDataInput{
+		StartTime: aws.Time(start),
+		EndTime:   aws.Time(end),
+		ScanBy:    aws.String(cloudwatch.ScanByTimestampDescending),
+	}
+	for i, q := range queries {
+		metric := &cloudwatch.Metric{
+			Namespace:  aws.String(q.namespace),
+			MetricName: aws.String(q.metric.Name),
+		}
+		for _, dim := range q.dimensions {
+			metric.Dimensions = append(metric.Dimensions, &cloudwatch.Dimension{
+				Name:  aws.String(dim.Name),
+				Value: aws.String(dim.Value),
+			})
+		}
+		input.MetricDataQueries = append(input.MetricDataQueries, &cloudwatch.MetricDataQuery{
+			Id: aws.String(fmt.Sprintf("q%d", i)),
+			MetricStat: &cloudwatch.MetricStat{
+				Metric: metric,
+				Period: aws.Int64(int64(q.metric.period().Seconds())),
+				Stat:   aws.String(q.stat),
+			},
+			ReturnData: aws.Bool(true),
+		})
+	}
+
+	// Data points are returned newest first, so the first value of every
+	// query is its most recent one.
+	values := make(map[string]float64, len(queries))
+	e.requests.WithLabelValues("GetMetricData").Inc()
+	err := c.cloudwatch.GetMetricDataPagesWithContext(ctx, input, func(page *cloudwatch.GetMetricDataOutput, _ bool) bool {
+		for _, result := range page.MetricDataResults {
+			id := aws.StringValue(result.Id)
+			if _, ok := values[id]; ok || len(result.Values) == 0 {
+				continue
+			}
+			values[id] = aws.Float64Value(result.Values[0])
+		}
+		return true
+	})
+	if err != nil {
+		e.requestErrors.WithLabelValues("GetMetricData").Inc()
+		return nil, fmt.Errorf("failed to get metric data: %w", err)
+	}
+
+	var res []prometheus.Metric
+	for i, q := range queries {
+		value, ok := values[fmt.Sprintf("q%d", i)]
+		if !ok {
+			continue
+		}
+		desc := prometheus.NewDesc(
+			promName(q.namespace, q.metric.Name, q.stat),
+			fmt.Sprintf("CloudWatch metric %s %s %s.", q.namespace, q.metric.Name, q.stat),
+			q.labelNames, nil,
+		)
+		res = append(res, prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, q.labelValues...))
+	}
+	return res, nil
+}
+
+// promName returns the name of the metric of a CloudWatch namespace built
+// from parts, like aws_ec2_cpuutilization_average for the Average of the
+// CPUUtilization metric of AWS/EC2.
+func promName(namespace string, parts ...string) string {
+	name := "aws_" + strings.TrimPrefix(namespace, "AWS/")
+	for _, p := range parts {
+		name += "_" + p
+	}
+	return promLabel(strings.ToLower(name))
+}
+
+// invalidLabelChars matches characters which aren't valid in metric and
+// label names.
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// promLabel replaces the characters of s which aren't valid in label names.
+func promLabel(s string) string {
+	return invalidLabelChars.ReplaceAllString(s, "_")
+}
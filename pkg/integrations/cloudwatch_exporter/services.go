@@ -0,0 +1,86 @@
+package cloudwatch_exporter
+
+import (
+	"regexp"
+	"sort"
+)
+
+// service describes how resources of a CloudWatch namespace are discovered
+// through the resource groups tagging API.
+type service struct {
+	// resourceType is the resource type filter for the tagging API.
+	resourceType string
+
+	// dimension is the dimension identifying resources in the metrics of the
+	// namespace.
+	dimension string
+
+	// arnRegexp extracts the value of the dimension from the ARN of a
+	// resource. Resources whose ARN doesn't match are skipped.
+	arnRegexp *regexp.Regexp
+}
+
+// services are the namespaces supported by discovery jobs.
+var services = map[string]service{
+	"AWS/EC2": {
+		resourceType: "ec2:instance",
+		dimension:    "InstanceId",
+		arnRegexp:    regexp.MustCompile(`:instance/(.+)$`),
+	},
+	"AWS/EBS": {
+		resourceType: "ec2:volume",
+		dimension:    "VolumeId",
+		arnRegexp:    regexp.MustCompile(`:volume/(.+)$`),
+	},
+	"AWS/RDS": {
+		resourceType: "rds:db",
+		dimension:    "DBInstanceIdentifier",
+		arnRegexp:    regexp.MustCompile(`:db:(.+)$`),
+	},
+	"AWS/ELB": {
+		resourceType: "elasticloadbalancing:loadbalancer",
+		dimension:    "LoadBalancerName",
+		arnRegexp:    regexp.MustCompile(`:loadbalancer/([^/]+)$`),
+	},
+	"AWS/ApplicationELB": {
+		resourceType: "elasticloadbalancing:loadbalancer",
+		dimension:    "LoadBalancer",
+		arnRegexp:    regexp.MustCompile(`:loadbalancer/(app/.+)$`),
+	},
+	"AWS/NetworkELB": {
+		resourceType: "elasticloadbalancing:loadbalancer",
+		dimension:    "LoadBalancer",
+		arnRegexp:    regexp.MustCompile(`:loadbalancer/(net/.+)$`),
+	},
+	"AWS/Lambda": {
+		resourceType: "lambda:function",
+		dimension:    "FunctionName",
+		arnRegexp:    regexp.MustCompile(`:function:([^:]+)$`),
+	},
+	"AWS/SQS": {
+		resourceType: "sqs",
+		dimension:    "QueueName",
+		arnRegexp:    regexp.MustCompile(`^arn:[^:]+:sqs:[^:]*:[^:]*:(.+)$`),
+	},
+	"AWS/DynamoDB": {
+		resourceType: "dynamodb:table",
+		dimension:    "TableName",
+		arnRegexp:    regexp.MustCompile(`:table/([^/]+)$`),
+	},
+	"AWS/ECS": {
+		resourceType: "ecs:cluster",
+		dimension:    "ClusterName",
+		arnRegexp:    regexp.MustCompile(`:cluster/(.+)$`),
+	},
+}
+
+// serviceNamespaces returns the sorted namespaces supported by discovery
+// jobs.
+func serviceNamespaces() []string {
+	namespaces := make([]string, 0, len(services))
+	for namespace := range services {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
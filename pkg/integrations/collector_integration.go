@@ -9,6 +9,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/version"
+	"github.com/prometheus/prometheus/model/relabel"
 )
 
 // CollectorIntegration is an integration exposing metrics from one or more Prometheus collectors.
@@ -17,6 +18,8 @@ type CollectorIntegration struct {
 	cs                     []prometheus.Collector
 	includeExporterMetrics bool
 	runner                 func(context.Context) error
+	handler                func(prefix string) (http.Handler, error)
+	metricRelabelConfigs   []*relabel.Config
 }
 
 // NewCollectorIntegration creates a basic integration that exposes metrics from multiple prometheus.Collector.
@@ -53,6 +56,22 @@ func WithRunner(runner func(context.Context) error) CollectorIntegrationConfig {
 	}
 }
 
+// WithHTTPHandler exposes additional HTTP endpoints for the
+// CollectorIntegration. See HTTPIntegration for more information.
+func WithHTTPHandler(handler func(prefix string) (http.Handler, error)) CollectorIntegrationConfig {
+	return func(i *CollectorIntegration) {
+		i.handler = handler
+	}
+}
+
+// WithMetricRelabelConfigs applies relabel configs to the metrics exposed by
+// the CollectorIntegration.
+func WithMetricRelabelConfigs(cfgs []*relabel.Config) CollectorIntegrationConfig {
+	return func(i *CollectorIntegration) {
+		i.metricRelabelConfigs = cfgs
+	}
+}
+
 // WithExporterMetricsIncluded can enable the exporter metrics if the flag provided is enabled.
 func WithExporterMetricsIncluded(included bool) CollectorIntegrationConfig {
 	return func(i *CollectorIntegration) {
@@ -75,8 +94,13 @@ func (i *CollectorIntegration) MetricsHandler() (http.Handler, error) {
 		return nil, fmt.Errorf("couldn't register %s: %w", i.name, err)
 	}
 
+	var g prometheus.Gatherer = r
+	if len(i.metricRelabelConfigs) > 0 {
+		g = NewRelabelGatherer(r, i.metricRelabelConfigs)
+	}
+
 	handler := promhttp.HandlerFor(
-		r,
+		g,
 		promhttp.HandlerOpts{
 			ErrorHandling: promhttp.ContinueOnError,
 		},
@@ -99,6 +123,14 @@ func (i *CollectorIntegration) ScrapeConfigs() []config.ScrapeConfig {
 	}}
 }
 
+// Handler satisfies HTTPIntegration.Handler.
+func (i *CollectorIntegration) Handler(prefix string) (http.Handler, error) {
+	if i.handler == nil {
+		return nil, nil
+	}
+	return i.handler(prefix)
+}
+
 // Run satisfies Integration.Run.
 func (i *CollectorIntegration) Run(ctx context.Context) error {
 	return i.runner(ctx)
@@ -4,8 +4,10 @@ package config
 
 import (
 	"net/url"
+	"strings"
 	"time"
 
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/relabel"
 )
 
@@ -16,14 +18,61 @@ import (
 //     Common config.Common `yaml:",inline"`
 //   }
 type Common struct {
-	Enabled              bool              `yaml:"enabled,omitempty"`
+	Enabled bool `yaml:"enabled,omitempty"`
+
+	// Name identifies an instance of an integration which is configured with
+	// multiple instances.
+	Name string `yaml:"name,omitempty"`
+
 	InstanceKey          *string           `yaml:"instance,omitempty"`
 	ScrapeIntegration    *bool             `yaml:"scrape_integration,omitempty"`
 	ScrapeInterval       time.Duration     `yaml:"scrape_interval,omitempty"`
 	ScrapeTimeout        time.Duration     `yaml:"scrape_timeout,omitempty"`
 	RelabelConfigs       []*relabel.Config `yaml:"relabel_configs,omitempty"`
 	MetricRelabelConfigs []*relabel.Config `yaml:"metric_relabel_configs,omitempty"`
+	MetricAllowlist      []relabel.Regexp  `yaml:"metric_allowlist,omitempty"`
+	MetricDenylist       []relabel.Regexp  `yaml:"metric_denylist,omitempty"`
 	WALTruncateFrequency time.Duration     `yaml:"wal_truncate_frequency,omitempty"`
+
+	// Limits of scrapes of the integration. 0 is unlimited.
+	SampleLimit         uint `yaml:"sample_limit,omitempty"`
+	MaxSeriesPerTarget  int  `yaml:"max_series_per_target,omitempty"`
+	MaxLabelsPerSeries  int  `yaml:"max_labels_per_series,omitempty"`
+	MaxLabelNameLength  int  `yaml:"max_label_name_length,omitempty"`
+	MaxLabelValueLength int  `yaml:"max_label_value_length,omitempty"`
+
+	// ExtraLabels are added to all metrics of the integration, overriding the
+	// labels of the integrations config.
+	ExtraLabels model.LabelSet `yaml:"extra_labels,omitempty"`
+}
+
+// FilterRelabelConfigs returns metric relabel configs which keep only the
+// metrics whose names match any regex of allowlist, and drop the metrics
+// whose names match any regex of denylist. Either list may be empty.
+func FilterRelabelConfigs(allowlist, denylist []relabel.Regexp) []*relabel.Config {
+	var cfgs []*relabel.Config
+	if len(allowlist) > 0 {
+		cfgs = append(cfgs, filterRelabelConfig(allowlist, relabel.Keep))
+	}
+	if len(denylist) > 0 {
+		cfgs = append(cfgs, filterRelabelConfig(denylist, relabel.Drop))
+	}
+	return cfgs
+}
+
+// filterRelabelConfig returns a relabel config performing action on the
+// metrics whose names match any of rr.
+func filterRelabelConfig(rr []relabel.Regexp, action relabel.Action) *relabel.Config {
+	alternatives := make([]string, 0, len(rr))
+	for _, r := range rr {
+		alternatives = append(alternatives, "(?:"+r.String()+")")
+	}
+
+	cfg := relabel.DefaultRelabelConfig
+	cfg.SourceLabels = model.LabelNames{model.MetricNameLabel}
+	cfg.Regex = relabel.MustNewRegexp(strings.Join(alternatives, "|"))
+	cfg.Action = action
+	return &cfg
 }
 
 // ScrapeConfig is a subset of options used by integrations to inform how samples
@@ -0,0 +1,231 @@
+// Package gcp_exporter embeds https://github.com/prometheus-community/stackdriver_exporter
+package gcp_exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/integrations"
+	integrations_v2 "github.com/grafana/agent/pkg/integrations/v2"
+	"github.com/grafana/agent/pkg/integrations/v2/metricsutils"
+	"github.com/prometheus-community/stackdriver_exporter/collectors"
+	"github.com/prometheus/client_golang/prometheus"
+	config_util "github.com/prometheus/common/config"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"golang.org/x/time/rate"
+	"google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+)
+
+// DefaultConfig holds the default settings for the gcp_exporter integration.
+var DefaultConfig = Config{
+	RequestInterval: 5 * time.Minute,
+	RequestTimeout:  10 * time.Second,
+}
+
+// Config controls the gcp_exporter integration.
+type Config struct {
+	ProjectIDs []string `yaml:"project_ids"`
+
+	// MetricPrefixes are the prefixes of the metric types to collect, like
+	// compute.googleapis.com/instance/cpu.
+	MetricPrefixes []string `yaml:"metric_prefixes"`
+
+	// ExtraFilters are additional filters of the metrics of a prefix, in the
+	// form <metric prefix>:<filter>.
+	ExtraFilters []string `yaml:"extra_filters,omitempty"`
+
+	// RequestInterval is the time range of the data points requested on every
+	// scrape, and RequestOffset how far it's shifted into the past.
+	RequestInterval time.Duration `yaml:"request_interval,omitempty"`
+	RequestOffset   time.Duration `yaml:"request_offset,omitempty"`
+
+	// IngestDelay shifts the time range of the data points by the ingest
+	// delay of every metric.
+	IngestDelay bool `yaml:"ingest_delay,omitempty"`
+
+	// DropDelegatedProjects drops metrics of projects other than the
+	// collected project, which are returned for metrics scopes.
+	DropDelegatedProjects bool `yaml:"drop_delegated_projects,omitempty"`
+
+	RequestTimeout time.Duration `yaml:"request_timeout,omitempty"`
+
+	// RateLimit limits the requests per second to the Monitoring API, shared
+	// by all projects. Requests aren't limited if zero.
+	RateLimit      float64 `yaml:"rate_limit,omitempty"`
+	RateLimitBurst int     `yaml:"rate_limit_burst,omitempty"`
+
+	// Credentials is the JSON key of a service account, which CredentialsFile
+	// may be used to read from instead. Application default credentials are
+	// used if neither is set.
+	Credentials     config_util.Secret `yaml:"credentials,omitempty"`
+	CredentialsFile string             `yaml:"credentials_file,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for Config.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultConfig
+
+	type plain Config
+	return unmarshal((*plain)(c))
+}
+
+// Name returns the name of the integration that this config represents.
+func (c *Config) Name() string {
+	return "gcp_exporter"
+}
+
+// InstanceKey returns the IDs of the collected projects.
+func (c *Config) InstanceKey(_ string) (string, error) {
+	return strings.Join(c.ProjectIDs, ","), nil
+}
+
+// NewIntegration creates a new gcp_exporter integration.
+func (c *Config) NewIntegration(l log.Logger) (integrations.Integration, error) {
+	return New(l, c)
+}
+
+func init() {
+	integrations.RegisterIntegration(&Config{})
+	integrations_v2.RegisterLegacy(&Config{}, integrations_v2.TypeMultiplex, metricsutils.NewNamedShim("gcp"))
+}
+
+// Validate returns an error if c is invalid.
+func (c *Config) Validate() error {
+	if len(c.ProjectIDs) == 0 {
+		return fmt.Errorf("at least one project_id must be set")
+	}
+	if len(c.MetricPrefixes) == 0 {
+		return fmt.Errorf("at least one metric_prefix must be set")
+	}
+	if _, err := parseExtraFilters(c.ExtraFilters); err != nil {
+		return err
+	}
+	if c.RequestInterval <= 0 || c.RequestTimeout <= 0 {
+		return fmt.Errorf("request_interval and request_timeout must be greater than zero")
+	}
+	if c.RateLimit < 0 || c.RateLimitBurst < 0 {
+		return fmt.Errorf("rate_limit and rate_limit_burst must not be negative")
+	}
+	if c.Credentials != "" && c.CredentialsFile != "" {
+		return fmt.Errorf("credentials and credentials_file must not both be set")
+	}
+	return nil
+}
+
+// parseExtraFilters parses filters of the form <metric prefix>:<filter>.
+func parseExtraFilters(filters []string) ([]collectors.MetricFilter, error) {
+	res := make([]collectors.MetricFilter, 0, len(filters))
+	for _, f := range filters {
+		parts := strings.SplitN(f, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("extra filter %q must have the form <metric prefix>:<filter>", f)
+		}
+		res = append(res, collectors.MetricFilter{Prefix: parts[0], Modifier: parts[1]})
+	}
+	return res, nil
+}
+
+// New creates a new gcp_exporter integration.
+func New(l log.Logger, c *Config) (integrations.Integration, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	filters, err := parseExtraFilters(c.ExtraFilters)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := c.httpClient(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	svc, err := monitoring.NewService(context.Background(), option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create monitoring service: %w", err)
+	}
+
+	opts := collectors.MonitoringCollectorOptions{
+		MetricTypePrefixes:    c.MetricPrefixes,
+		ExtraFilters:          filters,
+		RequestInterval:       c.RequestInterval,
+		RequestOffset:         c.RequestOffset,
+		IngestDelay:           c.IngestDelay,
+		DropDelegatedProjects: c.DropDelegatedProjects,
+	}
+
+	var cs []prometheus.Collector
+	for _, project := range c.ProjectIDs {
+		col, err := collectors.NewMonitoringCollector(
+			project, svc, opts, l,
+			collectors.NewInMemoryDeltaCounterStore(l, 30*time.Minute),
+			collectors.NewInMemoryDeltaDistributionStore(l, 30*time.Minute),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create collector of project %s: %w", project, err)
+		}
+		cs = append(cs, col)
+	}
+
+	return integrations.NewCollectorIntegration(c.Name(), integrations.WithCollectors(cs...)), nil
+}
+
+// httpClient returns the client authenticated with the credentials of c
+// used for requests to the Monitoring API.
+func (c *Config) httpClient(ctx context.Context) (*http.Client, error) {
+	var (
+		creds *google.Credentials
+		err   error
+	)
+	switch {
+	case c.Credentials != "":
+		creds, err = google.CredentialsFromJSON(ctx, []byte(c.Credentials), monitoring.MonitoringReadScope)
+	case c.CredentialsFile != "":
+		var bb []byte
+		bb, err = os.ReadFile(c.CredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read credentials_file: %w", err)
+		}
+		creds, err = google.CredentialsFromJSON(ctx, bb, monitoring.MonitoringReadScope)
+	default:
+		creds, err = google.FindDefaultCredentials(ctx, monitoring.MonitoringReadScope)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load credentials: %w", err)
+	}
+
+	var transport http.RoundTripper = &oauth2.Transport{
+		Source: creds.TokenSource,
+		Base:   http.DefaultTransport,
+	}
+	if c.RateLimit > 0 {
+		burst := c.RateLimitBurst
+		if burst == 0 {
+			burst = 1
+		}
+		transport = &rateLimitedTransport{
+			next:    transport,
+			limiter: rate.NewLimiter(rate.Limit(c.RateLimit), burst),
+		}
+	}
+	return &http.Client{Transport: transport, Timeout: c.RequestTimeout}, nil
+}
+
+// rateLimitedTransport waits for the limiter before sending requests.
+type rateLimitedTransport struct {
+	next    http.RoundTripper
+	limiter *rate.Limiter
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,120 @@
+package gcp_exporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/pkg/config"
+	"github.com/prometheus-community/stackdriver_exporter/collectors"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig_SecretCredentials(t *testing.T) {
+	stringCfg := `
+prometheus:
+  wal_directory: /tmp/agent
+integrations:
+  gcp_exporter:
+    enabled: true
+    project_ids: [my-project]
+    metric_prefixes: [compute.googleapis.com/instance/cpu]
+    credentials: secret_service_account_key
+`
+	config.CheckSecret(t, stringCfg, "secret_service_account_key")
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tt := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name: "valid",
+			config: `
+project_ids: [my-project]
+metric_prefixes: [compute.googleapis.com/instance/cpu]
+extra_filters: ['compute.googleapis.com/instance/cpu:metric.labels.instance_name=starts_with("prod")']
+rate_limit: 5`,
+		},
+		{
+			name:   "no projects",
+			config: `metric_prefixes: [compute.googleapis.com/instance/cpu]`,
+			err:    "at least one project_id must be set",
+		},
+		{
+			name:   "no prefixes",
+			config: `project_ids: [my-project]`,
+			err:    "at least one metric_prefix must be set",
+		},
+		{
+			name: "invalid extra filter",
+			config: `
+project_ids: [my-project]
+metric_prefixes: [compute.googleapis.com/instance/cpu]
+extra_filters: [no-filter]`,
+			err: `extra filter "no-filter" must have the form <metric prefix>:<filter>`,
+		},
+		{
+			name: "both credentials",
+			config: `
+project_ids: [my-project]
+metric_prefixes: [compute.googleapis.com/instance/cpu]
+credentials: "{}"
+credentials_file: /etc/gcp/key.json`,
+			err: "credentials and credentials_file must not both be set",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var c Config
+			require.NoError(t, yaml.UnmarshalStrict([]byte(tc.config), &c))
+
+			err := c.Validate()
+			if tc.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestParseExtraFilters(t *testing.T) {
+	filters, err := parseExtraFilters([]string{`pubsub.googleapis.com/subscription:resource.labels.subscription_id=monitoring.regex.full_match("us-west4.*")`})
+	require.NoError(t, err)
+	require.Equal(t, []collectors.MetricFilter{{
+		Prefix:   "pubsub.googleapis.com/subscription",
+		Modifier: `resource.labels.subscription_id=monitoring.regex.full_match("us-west4.*")`,
+	}}, filters)
+}
+
+func TestRateLimitedTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &rateLimitedTransport{
+		next:    http.DefaultTransport,
+		limiter: rate.NewLimiter(rate.Every(time.Hour), 1),
+	}}
+
+	// The first request uses the burst.
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// The second request would have to wait for an hour, which exceeds its
+	// deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	_, err = client.Do(req)
+	require.Error(t, err)
+}
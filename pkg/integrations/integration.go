@@ -29,6 +29,16 @@ type Config interface {
 	NewIntegration(l log.Logger) (Integration, error)
 }
 
+// A ValidatableConfig is a Config which can be validated without creating its
+// integration, which may connect to the system it integrates with. Validate
+// is used to check configs before they're applied.
+type ValidatableConfig interface {
+	Config
+
+	// Validate returns an error if the config is invalid.
+	Validate() error
+}
+
 // An Integration is a process that integrates with some external system and
 // pulls telemetry data.
 type Integration interface {
@@ -48,3 +58,16 @@ type Integration interface {
 	// not return the ctx error.
 	Run(ctx context.Context) error
 }
+
+// An HTTPIntegration is an Integration which exposes HTTP endpoints in addition
+// to its metrics.
+type HTTPIntegration interface {
+	Integration
+
+	// Handler returns an http.Handler which will be invoked for any endpoint
+	// under prefix, other than the metrics endpoint. If Handler returns nil,
+	// requests will be answered with 404 Not Found.
+	//
+	// prefix will not be removed from the HTTP request.
+	Handler(prefix string) (http.Handler, error)
+}
@@ -0,0 +1,86 @@
+package jmx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/pkg/util/textmetrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+var upDesc = prometheus.NewDesc(
+	"jmx_up",
+	"Whether the last scrape of the JMX exporter or Jolokia succeeded.",
+	nil, nil,
+)
+
+// source is where the collector gets metrics from.
+type source interface {
+	collect(ctx context.Context) ([]prometheus.Metric, error)
+}
+
+// collector collects the metrics of a source at scrape time.
+type collector struct {
+	log     log.Logger
+	src     source
+	timeout time.Duration
+}
+
+func newCollector(l log.Logger, src source, timeout time.Duration) *collector {
+	return &collector{log: l, src: src, timeout: timeout}
+}
+
+// Describe implements prometheus.Collector. The collector is an unchecked
+// collector since the collected metrics depend on the application.
+func (c *collector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	metrics, err := c.src.collect(ctx)
+	up := 1.0
+	if err != nil {
+		level.Warn(c.log).Log("msg", "failed to collect jmx metrics", "err", err)
+		metrics, up = nil, 0
+	}
+	for _, m := range metrics {
+		ch <- m
+	}
+	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, up)
+}
+
+// exporterSource scrapes the metrics endpoint of a JMX exporter.
+type exporterSource struct {
+	client *http.Client
+	url    string
+}
+
+func (s *exporterSource) collect(ctx context.Context) ([]prometheus.Metric, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(expfmt.FmtText))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	metrics, err := textmetrics.Parse(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metrics: %w", err)
+	}
+	return metrics, nil
+}
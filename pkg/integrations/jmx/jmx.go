@@ -0,0 +1,194 @@
+// Package jmx collects metrics of Java applications, either from the HTTP
+// endpoint of the JMX exporter or by reading MBeans through a Jolokia agent.
+package jmx
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/integrations"
+	integrations_v2 "github.com/grafana/agent/pkg/integrations/v2"
+	"github.com/grafana/agent/pkg/integrations/v2/metricsutils"
+	config_util "github.com/prometheus/common/config"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultConfig holds the default settings for the jmx integration.
+var DefaultConfig = Config{
+	MBeans:           []string{"*:*"},
+	HTTPClientConfig: config_util.DefaultHTTPClientConfig,
+	Timeout:          10 * time.Second,
+}
+
+// Config controls the jmx integration.
+type Config struct {
+	// JMXExporterURL is the URL of the metrics endpoint of a JMX exporter
+	// Java agent or standalone server, like http://localhost:9404/metrics.
+	// Its metrics are collected as is.
+	JMXExporterURL string `yaml:"jmx_exporter_url,omitempty"`
+
+	// JolokiaURL is the URL of the Jolokia agent of the application, like
+	// http://localhost:8778/jolokia/. The attributes of the MBeans read
+	// through Jolokia are mapped to metrics by Rules.
+	JolokiaURL string `yaml:"jolokia_url,omitempty"`
+
+	// MBeans are the object name patterns of the MBeans read through Jolokia.
+	MBeans []string `yaml:"mbeans,omitempty"`
+
+	// Rules map the attributes of MBeans to metrics. The first rule matching
+	// an attribute is applied, and attributes not matching any rule are
+	// dropped. Without rules, all attributes are collected with default
+	// names.
+	Rules []Rule `yaml:"rules,omitempty"`
+
+	// RulesFile is a JMX exporter config file whose rules are applied after
+	// Rules.
+	RulesFile string `yaml:"rules_file,omitempty"`
+
+	LowercaseOutputName       bool `yaml:"lowercase_output_name,omitempty"`
+	LowercaseOutputLabelNames bool `yaml:"lowercase_output_label_names,omitempty"`
+
+	// HTTPClientConfig configures the client used for requests to the JMX
+	// exporter or Jolokia.
+	HTTPClientConfig config_util.HTTPClientConfig `yaml:"http_client_config,omitempty"`
+
+	// Timeout is the timeout of requests to the JMX exporter or Jolokia.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for Config.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultConfig
+
+	type plain Config
+	return unmarshal((*plain)(c))
+}
+
+// Name returns the name of the integration that this config represents.
+func (c *Config) Name() string {
+	return "jmx"
+}
+
+// InstanceKey returns the hostname:port of the JMX exporter or Jolokia.
+func (c *Config) InstanceKey(_ string) (string, error) {
+	u, err := url.Parse(c.targetURL())
+	if err != nil {
+		return "", fmt.Errorf("could not parse url: %w", err)
+	}
+	return u.Host, nil
+}
+
+// NewIntegration creates a new jmx integration.
+func (c *Config) NewIntegration(l log.Logger) (integrations.Integration, error) {
+	return New(l, c)
+}
+
+func init() {
+	integrations.RegisterIntegration(&Config{})
+	integrations_v2.RegisterLegacy(&Config{}, integrations_v2.TypeMultiplex, metricsutils.NewNamedShim("jmx"))
+}
+
+// targetURL returns the URL of the JMX exporter or Jolokia.
+func (c *Config) targetURL() string {
+	if c.JMXExporterURL != "" {
+		return c.JMXExporterURL
+	}
+	return c.JolokiaURL
+}
+
+// Validate returns an error if c is invalid.
+func (c *Config) Validate() error {
+	switch {
+	case c.JMXExporterURL == "" && c.JolokiaURL == "":
+		return fmt.Errorf("one of jmx_exporter_url or jolokia_url must be set")
+	case c.JMXExporterURL != "" && c.JolokiaURL != "":
+		return fmt.Errorf("only one of jmx_exporter_url or jolokia_url may be set")
+	case c.JMXExporterURL != "" && (len(c.Rules) > 0 || c.RulesFile != ""):
+		return fmt.Errorf("rules and rules_file are only supported with jolokia_url")
+	case c.Timeout <= 0:
+		return fmt.Errorf("timeout must be greater than zero")
+	}
+	if _, err := url.Parse(c.targetURL()); err != nil {
+		return fmt.Errorf("could not parse url: %w", err)
+	}
+	return c.HTTPClientConfig.Validate()
+}
+
+// rulesFile is the subset of a JMX exporter config file used by the
+// integration.
+type rulesFile struct {
+	LowercaseOutputName       bool       `yaml:"lowercaseOutputName"`
+	LowercaseOutputLabelNames bool       `yaml:"lowercaseOutputLabelNames"`
+	Rules                     []fileRule `yaml:"rules"`
+}
+
+// fileRule is a Rule as written in a JMX exporter config file.
+type fileRule struct {
+	Pattern           string            `yaml:"pattern"`
+	Name              string            `yaml:"name"`
+	Value             string            `yaml:"value"`
+	ValueFactor       float64           `yaml:"valueFactor"`
+	Labels            map[string]string `yaml:"labels"`
+	Help              string            `yaml:"help"`
+	Type              string            `yaml:"type"`
+	AttrNameSnakeCase bool              `yaml:"attrNameSnakeCase"`
+}
+
+// loadMapper returns the mapper of MBean attributes to metrics, combining the
+// settings of c and its rules file.
+func (c *Config) loadMapper() (*mapper, error) {
+	var (
+		rules          = c.Rules
+		lowercaseName  = c.LowercaseOutputName
+		lowercaseLabel = c.LowercaseOutputLabelNames
+	)
+	if c.RulesFile != "" {
+		bb, err := os.ReadFile(c.RulesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rules_file: %w", err)
+		}
+		var f rulesFile
+		if err := yaml.Unmarshal(bb, &f); err != nil {
+			return nil, fmt.Errorf("failed to parse rules_file %s: %w", c.RulesFile, err)
+		}
+		for _, r := range f.Rules {
+			rules = append(rules, Rule(r))
+		}
+		lowercaseName = lowercaseName || f.LowercaseOutputName
+		lowercaseLabel = lowercaseLabel || f.LowercaseOutputLabelNames
+	}
+	return newMapper(rules, lowercaseName, lowercaseLabel)
+}
+
+// New creates a new jmx integration.
+func New(l log.Logger, c *Config) (integrations.Integration, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	client, err := config_util.NewClientFromConfig(c.HTTPClientConfig, "jmx")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http client: %w", err)
+	}
+
+	var col *collector
+	if c.JMXExporterURL != "" {
+		col = newCollector(l, &exporterSource{client: client, url: c.JMXExporterURL}, c.Timeout)
+	} else {
+		m, err := c.loadMapper()
+		if err != nil {
+			return nil, err
+		}
+		src := &jolokiaSource{
+			client: client,
+			url:    c.JolokiaURL,
+			mbeans: c.MBeans,
+			mapper: m,
+			log:    l,
+		}
+		col = newCollector(l, src, c.Timeout)
+	}
+	return integrations.NewCollectorIntegration(c.Name(), integrations.WithCollectors(col)), nil
+}
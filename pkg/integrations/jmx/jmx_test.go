@@ -0,0 +1,341 @@
+package jmx
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tt := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{"jmx exporter", "jmx_exporter_url: http://localhost:9404/metrics", ""},
+		{"jolokia", "jolokia_url: http://localhost:8778/jolokia/\nrules:\n- pattern: java.lang", ""},
+		{"no url", "timeout: 5s", "one of jmx_exporter_url or jolokia_url must be set"},
+		{"both urls", "jmx_exporter_url: http://localhost:9404/metrics\njolokia_url: http://localhost:8778/jolokia/", "only one of"},
+		{"rules with jmx exporter", "jmx_exporter_url: http://localhost:9404/metrics\nrules_file: rules.yml", "only supported with jolokia_url"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var c Config
+			require.NoError(t, yaml.UnmarshalStrict([]byte(tc.config), &c))
+
+			err := c.Validate()
+			if tc.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
+
+func TestMapper_MapAttribute(t *testing.T) {
+	gc := attribute{
+		domain: "java.lang",
+		properties: []property{
+			{key: "type", value: "GarbageCollector"},
+			{key: "name", value: "G1 Young Generation"},
+		},
+		name:  "CollectionCount",
+		value: 12.0,
+	}
+	heap := attribute{
+		domain:     "java.lang",
+		properties: []property{{key: "type", value: "Memory"}},
+		keys:       []string{"HeapMemoryUsage"},
+		name:       "used",
+		value:      1048576.0,
+	}
+	verbose := attribute{
+		domain:     "java.lang",
+		properties: []property{{key: "type", value: "Memory"}},
+		name:       "Verbose",
+		value:      true,
+	}
+
+	tt := []struct {
+		name   string
+		rules  []Rule
+		attr   attribute
+		expect *sample
+	}{
+		{
+			name: "default",
+			attr: gc,
+			expect: &sample{
+				name:        "java_lang_GarbageCollector_CollectionCount",
+				help:        "(java.lang<type=GarbageCollector, name=G1 Young Generation><>CollectionCount)",
+				valueType:   prometheus.UntypedValue,
+				labelNames:  []string{"name"},
+				labelValues: []string{"G1 Young Generation"},
+				value:       12,
+			},
+		},
+		{
+			name: "default composite",
+			attr: heap,
+			expect: &sample{
+				name:      "java_lang_Memory_HeapMemoryUsage_used",
+				help:      "(java.lang<type=Memory><HeapMemoryUsage>used)",
+				valueType: prometheus.UntypedValue,
+				value:     1048576,
+			},
+		},
+		{
+			name: "rule",
+			rules: []Rule{{
+				Pattern: `java.lang<type=GarbageCollector, name=(.+)><>CollectionCount`,
+				Name:    "jvm_gc_collections_total",
+				Labels:  map[string]string{"gc": "$1"},
+				Help:    "Number of collections of $1.",
+				Type:    "counter",
+			}},
+			attr: gc,
+			expect: &sample{
+				name:        "jvm_gc_collections_total",
+				help:        "Number of collections of G1 Young Generation.",
+				valueType:   prometheus.CounterValue,
+				labelNames:  []string{"gc"},
+				labelValues: []string{"G1 Young Generation"},
+				value:       12,
+			},
+		},
+		{
+			name: "value factor and snake case",
+			rules: []Rule{{
+				Pattern: `<>CollectionCount`,
+				Name:    "jvm_gc_collections_total",
+				// Doesn't match, since the attribute name is snake cased.
+				AttrNameSnakeCase: true,
+			}, {
+				Pattern:           `<>collection_count`,
+				Name:              "jvm_gc_collections_halved",
+				ValueFactor:       0.5,
+				Type:              "GAUGE",
+				AttrNameSnakeCase: true,
+			}},
+			attr: gc,
+			expect: &sample{
+				name:      "jvm_gc_collections_halved",
+				help:      "(java.lang<type=GarbageCollector, name=G1 Young Generation><>collection_count)",
+				valueType: prometheus.GaugeValue,
+				value:     6,
+			},
+		},
+		{
+			name: "value from pattern",
+			rules: []Rule{{
+				Pattern: `java.lang<type=Memory><HeapMemoryUsage>used: (\d+)`,
+				Name:    "jvm_memory_heap_used_bytes",
+				Value:   "$1",
+			}},
+			attr: heap,
+			expect: &sample{
+				name:      "jvm_memory_heap_used_bytes",
+				help:      "(java.lang<type=Memory><HeapMemoryUsage>used)",
+				valueType: prometheus.UntypedValue,
+				value:     1048576,
+			},
+		},
+		{
+			name:  "boolean",
+			rules: []Rule{{Pattern: `Verbose`, Name: "jvm_memory_verbose"}},
+			attr:  verbose,
+			expect: &sample{
+				name:      "jvm_memory_verbose",
+				help:      "(java.lang<type=Memory><>Verbose)",
+				valueType: prometheus.UntypedValue,
+				value:     1,
+			},
+		},
+		{
+			name:  "no matching rule",
+			rules: []Rule{{Pattern: `Verbose`, Name: "jvm_memory_verbose"}},
+			attr:  gc,
+		},
+		{
+			name:  "empty name",
+			rules: []Rule{{Pattern: `Verbose()`, Name: "$1"}},
+			attr:  verbose,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := newMapper(tc.rules, false, false)
+			require.NoError(t, err)
+
+			s, ok := m.mapAttribute(&tc.attr)
+			if tc.expect == nil {
+				require.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			require.Equal(t, *tc.expect, s)
+		})
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	src := "java.lang<type=Memory><HeapMemoryUsage>used: 1024"
+	re := regexp.MustCompile(`<type=(\w+)><(\w+)>(\w+)`)
+	match := re.FindStringSubmatchIndex(src)
+
+	require.Equal(t, "jvm_Memory_HeapMemoryUsage_used_bytes", expandTemplate("jvm_$1_$2_$3_bytes", src, match))
+	require.Equal(t, "Memory0", expandTemplate("$10", src, match))
+	require.Equal(t, "$1", expandTemplate(`\$1`, src, match))
+	require.Equal(t, "no groups", expandTemplate("no groups", src, nil))
+}
+
+func TestParseObjectName(t *testing.T) {
+	domain, props, err := parseObjectName(`kafka.server:type=BrokerTopicMetrics,name="Bytes,In",topic=orders`)
+	require.NoError(t, err)
+	require.Equal(t, "kafka.server", domain)
+	require.Equal(t, []property{
+		{key: "type", value: "BrokerTopicMetrics"},
+		{key: "name", value: `"Bytes,In"`},
+		{key: "topic", value: "orders"},
+	}, props)
+
+	_, _, err = parseObjectName("kafka.server")
+	require.Error(t, err)
+}
+
+func TestConfig_LoadMapper(t *testing.T) {
+	rulesFile := filepath.Join(t.TempDir(), "rules.yml")
+	require.NoError(t, os.WriteFile(rulesFile, []byte(`
+lowercaseOutputName: true
+rules:
+- pattern: 'java.lang<type=Threading><>ThreadCount'
+  name: JVM_Threads
+  valueFactor: 2
+`), 0600))
+
+	c := Config{
+		JolokiaURL: "http://localhost:8778/jolokia/",
+		Rules:      []Rule{{Pattern: "Verbose", Name: ""}},
+		RulesFile:  rulesFile,
+	}
+	m, err := c.loadMapper()
+	require.NoError(t, err)
+	require.True(t, m.lowercaseName)
+	require.Len(t, m.rules, 2)
+
+	s, ok := m.mapAttribute(&attribute{
+		domain:     "java.lang",
+		properties: []property{{key: "type", value: "Threading"}},
+		name:       "ThreadCount",
+		value:      21.0,
+	})
+	require.True(t, ok)
+	require.Equal(t, "jvm_threads", s.name)
+	require.Equal(t, 42.0, s.value)
+}
+
+func TestJolokiaSource_Collect(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqs []jolokiaRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&reqs))
+		require.Len(t, reqs, 2)
+
+		_, _ = w.Write([]byte(`[
+  {
+    "status": 200,
+    "value": {
+      "java.lang:type=Memory": {
+        "HeapMemoryUsage": {"committed": 4194304, "used": 1048576},
+        "ObjectName": {"objectName": "java.lang:type=Memory"},
+        "Verbose": false
+      },
+      "java.lang:type=GarbageCollector,name=G1 Young Generation": {
+        "CollectionCount": 12,
+        "MemoryPoolNames": ["G1 Eden Space", "G1 Survivor Space"]
+      }
+    }
+  },
+  {"status": 404, "error": "javax.management.InstanceNotFoundException"}
+]`))
+	}))
+	defer srv.Close()
+
+	m, err := newMapper([]Rule{
+		{Pattern: `java.lang<type=Memory><HeapMemoryUsage>(\w+)`, Name: "jvm_memory_heap_$1_bytes", Type: "GAUGE", Help: "Heap memory $1."},
+		{Pattern: `java.lang<type=GarbageCollector, name=(.+)><>CollectionCount`, Name: "jvm_gc_collections_total", Labels: map[string]string{"gc": "$1"}, Type: "COUNTER"},
+	}, false, false)
+	require.NoError(t, err)
+
+	src := &jolokiaSource{
+		client: srv.Client(),
+		url:    srv.URL,
+		mbeans: []string{"java.lang:*", "kafka.server:*"},
+		mapper: m,
+		log:    log.NewNopLogger(),
+	}
+	col := newCollector(log.NewNopLogger(), src, DefaultConfig.Timeout)
+
+	expect := `
+# HELP jmx_up Whether the last scrape of the JMX exporter or Jolokia succeeded.
+# TYPE jmx_up gauge
+jmx_up 1
+# HELP jvm_gc_collections_total (java.lang<type=GarbageCollector, name=G1 Young Generation><>CollectionCount)
+# TYPE jvm_gc_collections_total counter
+jvm_gc_collections_total{gc="G1 Young Generation"} 12
+# HELP jvm_memory_heap_committed_bytes Heap memory committed.
+# TYPE jvm_memory_heap_committed_bytes gauge
+jvm_memory_heap_committed_bytes 4194304
+# HELP jvm_memory_heap_used_bytes Heap memory used.
+# TYPE jvm_memory_heap_used_bytes gauge
+jvm_memory_heap_used_bytes 1048576
+`
+	require.NoError(t, testutil.CollectAndCompare(col, strings.NewReader(expect)))
+}
+
+func TestExporterSource_Collect(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`# HELP jvm_threads_current Current thread count of a JVM
+# TYPE jvm_threads_current gauge
+jvm_threads_current 21.0
+`))
+	}))
+	defer srv.Close()
+
+	col := newCollector(log.NewNopLogger(), &exporterSource{client: srv.Client(), url: srv.URL + "/metrics"}, DefaultConfig.Timeout)
+	expect := `
+# HELP jmx_up Whether the last scrape of the JMX exporter or Jolokia succeeded.
+# TYPE jmx_up gauge
+jmx_up 1
+# HELP jvm_threads_current Current thread count of a JVM
+# TYPE jvm_threads_current gauge
+jvm_threads_current 21
+`
+	require.NoError(t, testutil.CollectAndCompare(col, strings.NewReader(expect)))
+
+	col = newCollector(log.NewNopLogger(), &exporterSource{client: srv.Client(), url: srv.URL + "/missing"}, DefaultConfig.Timeout)
+	expect = `
+# HELP jmx_up Whether the last scrape of the JMX exporter or Jolokia succeeded.
+# TYPE jmx_up gauge
+jmx_up 0
+`
+	require.NoError(t, testutil.CollectAndCompare(col, strings.NewReader(expect)))
+}
@@ -0,0 +1,280 @@
+package jmx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// jolokiaSource reads MBeans through the Jolokia agent of an application and
+// maps their attributes to metrics.
+type jolokiaSource struct {
+	client *http.Client
+	url    string
+	mbeans []string
+	mapper *mapper
+	log    log.Logger
+}
+
+type jolokiaRequest struct {
+	Type   string                 `json:"type"`
+	MBean  string                 `json:"mbean"`
+	Config map[string]interface{} `json:"config,omitempty"`
+}
+
+type jolokiaResponse struct {
+	Status int         `json:"status"`
+	Error  string      `json:"error,omitempty"`
+	Value  interface{} `json:"value,omitempty"`
+}
+
+// collect reads all MBeans matching the configured patterns with a single
+// bulk request.
+func (s *jolokiaSource) collect(ctx context.Context) ([]prometheus.Metric, error) {
+	reqs := make([]jolokiaRequest, 0, len(s.mbeans))
+	for _, mbean := range s.mbeans {
+		reqs = append(reqs, jolokiaRequest{
+			Type:  "read",
+			MBean: mbean,
+			Config: map[string]interface{}{
+				// Keep the order of the key properties of object names, which
+				// determines the default names of metrics.
+				"canonicalNaming": false,
+				// Skip attributes that can't be read instead of failing the
+				// whole request.
+				"ignoreErrors": true,
+			},
+		})
+	}
+	body, err := json.Marshal(reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var responses []jolokiaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&responses); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(responses) != len(reqs) {
+		return nil, fmt.Errorf("expected %d responses, got %d", len(reqs), len(responses))
+	}
+
+	var samples []sample
+	for i, r := range responses {
+		mbean := reqs[i].MBean
+		switch r.Status {
+		case http.StatusOK:
+		case http.StatusNotFound:
+			// No MBean matches the pattern.
+			continue
+		default:
+			return nil, fmt.Errorf("failed to read %s: %s", mbean, r.Error)
+		}
+
+		beans, err := responseBeans(mbean, r.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", mbean, err)
+		}
+		names := make([]string, 0, len(beans))
+		for name := range beans {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			domain, props, err := parseObjectName(name)
+			if err != nil {
+				level.Debug(s.log).Log("msg", "skipping mbean", "mbean", name, "err", err)
+				continue
+			}
+			attrs := beans[name]
+			attrNames := make([]string, 0, len(attrs))
+			for attrName := range attrs {
+				attrNames = append(attrNames, attrName)
+			}
+			sort.Strings(attrNames)
+
+			for _, attrName := range attrNames {
+				a := attribute{domain: domain, properties: props, name: attrName}
+				flattenAttribute(a, attrs[attrName], func(a *attribute) {
+					if smp, ok := s.mapper.mapAttribute(a); ok {
+						samples = append(samples, smp)
+					}
+				})
+			}
+		}
+	}
+	return newMetrics(s.log, samples), nil
+}
+
+// responseBeans returns the attributes of the MBeans in the value of a read
+// response, by object name. The value maps object names to attributes for
+// patterns, and is the attributes of the MBean otherwise.
+func responseBeans(mbean string, value interface{}) (map[string]map[string]interface{}, error) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected value of type %T", value)
+	}
+	if !isPattern(mbean) {
+		return map[string]map[string]interface{}{mbean: m}, nil
+	}
+
+	beans := make(map[string]map[string]interface{}, len(m))
+	for name, attrs := range m {
+		attrs, ok := attrs.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		beans[name] = attrs
+	}
+	return beans, nil
+}
+
+// isPattern returns whether mbean is an object name pattern.
+func isPattern(mbean string) bool {
+	return strings.ContainsAny(mbean, "*?")
+}
+
+// flattenAttribute calls fn for every value of a with the given value.
+// Composite and tabular values are flattened, with the name of a appended to
+// its keys and the keys of the value as names. Arrays aren't supported.
+func flattenAttribute(a attribute, value interface{}, fn func(*attribute)) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			child := a
+			child.keys = append(append([]string(nil), a.keys...), a.name)
+			child.name = k
+			flattenAttribute(child, v[k], fn)
+		}
+	case []interface{}:
+	default:
+		a.value = v
+		fn(&a)
+	}
+}
+
+// parseObjectName splits an object name like java.lang:type=Memory into its
+// domain and key properties.
+func parseObjectName(name string) (string, []property, error) {
+	i := strings.Index(name, ":")
+	if i < 0 {
+		return "", nil, fmt.Errorf("object name has no domain")
+	}
+	domain, rest := name[:i], name[i+1:]
+	if rest == "" {
+		return "", nil, fmt.Errorf("object name has no key properties")
+	}
+
+	var props []property
+	for _, kv := range splitProperties(rest) {
+		j := strings.Index(kv, "=")
+		if j < 0 {
+			return "", nil, fmt.Errorf("invalid key property %q", kv)
+		}
+		props = append(props, property{key: kv[:j], value: kv[j+1:]})
+	}
+	return domain, props, nil
+}
+
+// splitProperties splits the key properties of an object name at commas
+// outside of quoted values.
+func splitProperties(s string) []string {
+	var (
+		res    []string
+		start  int
+		quoted bool
+	)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				res = append(res, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(res, s[start:])
+}
+
+// newMetrics converts samples into metrics. Samples are dropped if they
+// repeat the name and labels of a previous sample, or if their label names
+// differ from previous samples of the same name, since they can't be
+// collected together.
+func newMetrics(l log.Logger, samples []sample) []prometheus.Metric {
+	type family struct {
+		desc       *prometheus.Desc
+		valueType  prometheus.ValueType
+		labelNames string
+	}
+	var (
+		families = make(map[string]*family)
+		seen     = make(map[string]struct{})
+		res      = make([]prometheus.Metric, 0, len(samples))
+	)
+	for _, s := range samples {
+		labelNames := strings.Join(s.labelNames, ",")
+		f, ok := families[s.name]
+		if !ok {
+			f = &family{
+				desc:       prometheus.NewDesc(s.name, s.help, s.labelNames, nil),
+				valueType:  s.valueType,
+				labelNames: labelNames,
+			}
+			families[s.name] = f
+		}
+		if f.labelNames != labelNames {
+			level.Debug(l).Log("msg", "dropping sample with inconsistent label names", "name", s.name, "labels", labelNames)
+			continue
+		}
+
+		key := s.name + "\xff" + strings.Join(s.labelValues, "\xff")
+		if _, ok := seen[key]; ok {
+			level.Debug(l).Log("msg", "dropping duplicate sample", "name", s.name, "labels", labelNames)
+			continue
+		}
+		seen[key] = struct{}{}
+
+		m, err := prometheus.NewConstMetric(f.desc, f.valueType, s.value, s.labelValues...)
+		if err != nil {
+			level.Debug(l).Log("msg", "dropping invalid sample", "name", s.name, "err", err)
+			continue
+		}
+		res = append(res, m)
+	}
+	return res
+}
@@ -0,0 +1,368 @@
+package jmx
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Rule maps MBean attributes to metrics, like the rules of the JMX exporter.
+// Rules are matched against attributes in the form
+//
+//	domain<beanProperties><keys>attribute: value
+//
+// like java.lang<type=Memory><HeapMemoryUsage>used: 1024, where keys are the
+// keys of the composite or tabular data holding the value, if any.
+type Rule struct {
+	// Pattern is a regex matched against attributes. It isn't anchored, and
+	// its capture groups can be referenced as $1 in the other fields. Defaults
+	// to matching all attributes.
+	Pattern string `yaml:"pattern,omitempty"`
+
+	// Name is the name of the metric. Attributes are collected with the
+	// default name if Name isn't set, and dropped if it expands to an empty
+	// string.
+	Name string `yaml:"name,omitempty"`
+
+	// Value overrides the value of the attribute, which allows collecting
+	// attributes that aren't numbers or booleans.
+	Value string `yaml:"value,omitempty"`
+
+	// ValueFactor multiplies the value. Defaults to 1.
+	ValueFactor float64 `yaml:"value_factor,omitempty"`
+
+	// Labels of the metric. Labels with an empty name or value are ignored.
+	Labels map[string]string `yaml:"labels,omitempty"`
+
+	// Help of the metric. Defaults to the matched MBean and attribute.
+	Help string `yaml:"help,omitempty"`
+
+	// Type of the metric, one of GAUGE, COUNTER or UNTYPED. Defaults to
+	// UNTYPED.
+	Type string `yaml:"type,omitempty"`
+
+	// AttrNameSnakeCase converts the names of attributes to snake case before
+	// matching them.
+	AttrNameSnakeCase bool `yaml:"attr_name_snake_case,omitempty"`
+}
+
+// compiledRule is a Rule with its pattern compiled.
+type compiledRule struct {
+	Rule
+	pattern   *regexp.Regexp
+	valueType prometheus.ValueType
+}
+
+// mapper maps MBean attributes to samples.
+type mapper struct {
+	rules          []compiledRule
+	lowercaseName  bool
+	lowercaseLabel bool
+}
+
+func newMapper(rules []Rule, lowercaseName, lowercaseLabel bool) (*mapper, error) {
+	m := &mapper{lowercaseName: lowercaseName, lowercaseLabel: lowercaseLabel}
+
+	// Like the JMX exporter, collect all attributes with default names if
+	// there are no rules.
+	if len(rules) == 0 {
+		rules = []Rule{{}}
+	}
+	for i, r := range rules {
+		cr := compiledRule{Rule: r, valueType: prometheus.UntypedValue}
+		if r.Pattern != "" {
+			re, err := regexp.Compile("^.*(?:" + r.Pattern + ").*$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern of rule %d: %w", i, err)
+			}
+			cr.pattern = re
+		}
+		switch strings.ToUpper(r.Type) {
+		case "", "UNTYPED":
+		case "GAUGE":
+			cr.valueType = prometheus.GaugeValue
+		case "COUNTER":
+			cr.valueType = prometheus.CounterValue
+		default:
+			return nil, fmt.Errorf("invalid type %q of rule %d", r.Type, i)
+		}
+		if cr.ValueFactor == 0 {
+			cr.ValueFactor = 1
+		}
+		if (len(r.Labels) > 0 || r.Help != "") && r.Name == "" {
+			return nil, fmt.Errorf("rule %d must set name to set labels or help", i)
+		}
+		m.rules = append(m.rules, cr)
+	}
+	return m, nil
+}
+
+// attribute is a value of an MBean attribute.
+type attribute struct {
+	domain string
+	// properties are the key properties of the MBean, in the order of its
+	// object name.
+	properties []property
+	// keys are the keys of the composite or tabular data holding the value.
+	keys  []string
+	name  string
+	value interface{}
+}
+
+type property struct{ key, value string }
+
+// sample is a metric sample mapped from an attribute.
+type sample struct {
+	name        string
+	help        string
+	valueType   prometheus.ValueType
+	labelNames  []string
+	labelValues []string
+	value       float64
+}
+
+// beanName returns the MBean and keys of a in the form matched by rules.
+func (a *attribute) beanName() string {
+	var sb strings.Builder
+	sb.WriteString(a.domain)
+	sb.WriteString("<")
+	for i, p := range a.properties {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(p.key + "=" + p.value)
+	}
+	sb.WriteString("><")
+	sb.WriteString(strings.Join(a.keys, ", "))
+	sb.WriteString(">")
+	return sb.String()
+}
+
+// mapAttribute maps a to a sample. It returns false if a is dropped.
+func (m *mapper) mapAttribute(a *attribute) (sample, bool) {
+	beanName := a.beanName()
+	for _, r := range m.rules {
+		attrName := a.name
+		if r.AttrNameSnakeCase {
+			attrName = toSnakeCase(attrName)
+		}
+		matchName := beanName + attrName + ": " + formatValue(a.value)
+
+		var match []int
+		if r.pattern != nil {
+			match = r.pattern.FindStringSubmatchIndex(matchName)
+			if match == nil {
+				continue
+			}
+		}
+		if r.Name == "" {
+			return m.defaultSample(a, attrName, r)
+		}
+
+		expand := func(template string) string {
+			return expandTemplate(template, matchName, match)
+		}
+
+		name := safeName(expand(r.Name))
+		if name == "" {
+			return sample{}, false
+		}
+		if m.lowercaseName {
+			name = strings.ToLower(name)
+		}
+
+		value, ok := attributeValue(a.value)
+		if r.Value != "" {
+			v, err := strconv.ParseFloat(expand(r.Value), 64)
+			value, ok = v, err == nil
+		}
+		if !ok {
+			return sample{}, false
+		}
+
+		s := sample{
+			name:      name,
+			help:      expand(r.Help),
+			valueType: r.valueType,
+			value:     value * r.ValueFactor,
+		}
+		if s.help == "" {
+			s.help = "(" + beanName + attrName + ")"
+		}
+
+		labelNames := make([]string, 0, len(r.Labels))
+		for k := range r.Labels {
+			labelNames = append(labelNames, k)
+		}
+		sort.Strings(labelNames)
+		for _, k := range labelNames {
+			labelName, labelValue := safeName(expand(k)), expand(r.Labels[k])
+			if labelName == "" || labelValue == "" {
+				continue
+			}
+			if m.lowercaseLabel {
+				labelName = strings.ToLower(labelName)
+			}
+			s.labelNames = append(s.labelNames, labelName)
+			s.labelValues = append(s.labelValues, labelValue)
+		}
+		return s, true
+	}
+	return sample{}, false
+}
+
+// defaultSample maps a to a sample named after its domain, the value of its
+// first property, its keys and its name. The other properties become labels.
+func (m *mapper) defaultSample(a *attribute, attrName string, r compiledRule) (sample, bool) {
+	value, ok := attributeValue(a.value)
+	if !ok {
+		return sample{}, false
+	}
+
+	parts := []string{a.domain}
+	if len(a.properties) > 0 {
+		parts = append(parts, a.properties[0].value)
+	}
+	parts = append(parts, a.keys...)
+	parts = append(parts, attrName)
+
+	name := safeName(strings.Join(parts, "_"))
+	if m.lowercaseName {
+		name = strings.ToLower(name)
+	}
+
+	s := sample{
+		name:      name,
+		help:      "(" + a.beanName() + attrName + ")",
+		valueType: r.valueType,
+		value:     value * r.ValueFactor,
+	}
+	for i := 1; i < len(a.properties); i++ {
+		labelName := safeName(a.properties[i].key)
+		if m.lowercaseLabel {
+			labelName = strings.ToLower(labelName)
+		}
+		s.labelNames = append(s.labelNames, labelName)
+		s.labelValues = append(s.labelValues, a.properties[i].value)
+	}
+	return s, true
+}
+
+// attributeValue returns the value of numeric and boolean attributes.
+func attributeValue(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// expandTemplate replaces references to the capture groups of match in
+// template, like $1, with the text they matched in src. References follow
+// the Java syntax used by the rules of the JMX exporter, so $1_total
+// references group 1.
+func expandTemplate(template, src string, match []int) string {
+	if !strings.Contains(template, "$") {
+		return template
+	}
+
+	var (
+		sb     strings.Builder
+		groups = len(match)/2 - 1
+	)
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		if c == '\\' && i+1 < len(template) {
+			i++
+			sb.WriteByte(template[i])
+			continue
+		}
+		if c != '$' || i+1 >= len(template) || !isDigit(template[i+1]) {
+			sb.WriteByte(c)
+			continue
+		}
+
+		// Like Java, take as many digits as form a valid group number.
+		group := int(template[i+1] - '0')
+		i++
+		for i+1 < len(template) && isDigit(template[i+1]) {
+			next := group*10 + int(template[i+1]-'0')
+			if next > groups {
+				break
+			}
+			group = next
+			i++
+		}
+		if group <= groups && match[2*group] >= 0 {
+			sb.WriteString(src[match[2*group]:match[2*group+1]])
+		}
+	}
+	return sb.String()
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// formatValue formats the value of an attribute for matching it against
+// rules. Numbers are formatted without exponents, like Java formats longs.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// safeName replaces the characters of s that aren't valid in metric and
+// label names with underscores, collapsing consecutive underscores.
+func safeName(s string) string {
+	var sb strings.Builder
+	prevUnderscore := false
+	for i, r := range s {
+		if i == 0 && r >= '0' && r <= '9' {
+			sb.WriteRune('_')
+		}
+		valid := r == ':' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+		if !valid || r == '_' {
+			if !prevUnderscore {
+				sb.WriteRune('_')
+			}
+			prevUnderscore = true
+			continue
+		}
+		sb.WriteRune(r)
+		prevUnderscore = false
+	}
+	return sb.String()
+}
+
+// toSnakeCase converts a camel case name like HeapMemoryUsage to snake case.
+func toSnakeCase(s string) string {
+	var sb strings.Builder
+	var prev rune
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 && prev != '_' && !unicode.IsUpper(prev) {
+				sb.WriteRune('_')
+			}
+			sb.WriteRune(unicode.ToLower(r))
+		} else {
+			sb.WriteRune(r)
+		}
+		prev = r
+	}
+	return sb.String()
+}
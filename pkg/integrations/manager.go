@@ -2,9 +2,11 @@ package integrations
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"path"
 	"strconv"
 	"strings"
@@ -16,7 +18,10 @@ import (
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/gorilla/mux"
+	"github.com/grafana/agent/pkg/integrations/autodiscovery"
+	"github.com/grafana/agent/pkg/integrations/config"
 	"github.com/grafana/agent/pkg/metrics"
+	"github.com/grafana/agent/pkg/metrics/cluster/configapi"
 	"github.com/grafana/agent/pkg/metrics/instance"
 	"github.com/grafana/agent/pkg/metrics/instance/configstore"
 	"github.com/grafana/agent/pkg/server"
@@ -63,6 +68,10 @@ type ManagerConfig struct {
 
 	IntegrationRestartBackoff time.Duration `yaml:"integration_restart_backoff,omitempty"`
 
+	// Autodiscovery enables integrations for services running on the local
+	// host.
+	Autodiscovery autodiscovery.Config `yaml:"autodiscovery,omitempty"`
+
 	// ListenPort tells the integration Manager which port the Agent is
 	// listening on for generating Prometheus instance configs.
 	ListenPort int `yaml:"-"`
@@ -76,6 +85,11 @@ type ManagerConfig struct {
 	// This is set to true if the Server TLSConfig Cert and Key path are set
 	ServerUsingTLS bool `yaml:"-"`
 
+	// Credentials used to scrape integrations when the server requires
+	// authentication.
+	BasicAuth     *config_util.BasicAuth     `yaml:"http_basic_auth,omitempty"`
+	Authorization *config_util.Authorization `yaml:"http_authorization,omitempty"`
+
 	// We use this config to check if we need to reload integrations or not
 	// The Integrations Configs don't have prometheus defaults applied which
 	// can cause us skip reload when scrape configs change
@@ -152,6 +166,12 @@ func (c *ManagerConfig) ApplyDefaults(scfg *server.Config, mcfg *metrics.Config)
 		if scrapeIntegration && mcfg.WALDir == "" {
 			return fmt.Errorf("no wal_directory configured")
 		}
+
+		// Integrations are scraped through the server, which must accept the
+		// scrapes.
+		if scrapeIntegration && scfg.HTTP.AuthConfig.Enabled() && c.BasicAuth == nil && c.Authorization == nil {
+			return fmt.Errorf("http_basic_auth or http_authorization must be set to scrape integrations when server.http_auth_config is set")
+		}
 	}
 
 	return nil
@@ -176,8 +196,21 @@ type Manager struct {
 	integrationsMut sync.RWMutex
 	integrations    map[string]*integrationProcess
 
-	handlerMut   sync.Mutex
-	handlerCache map[string]handlerCacheEntry
+	// disabled holds the keys of integrations which were disabled at
+	// runtime through the API. They aren't run until they're enabled again,
+	// even if the config is reloaded.
+	disabled map[string]struct{}
+
+	// discovered holds the configs of integrations enabled by autodiscovery,
+	// and discoveredServices the services found by its last run. Both are
+	// guarded by the config and integrations mutexes.
+	discovered          []UnmarshaledConfig
+	discoveredServices  []DiscoveredService
+	autodiscoveryUpdate chan struct{}
+
+	handlerMut       sync.Mutex
+	handlerCache     map[string]handlerCacheEntry
+	httpHandlerCache map[string]handlerCacheEntry
 }
 
 // NewManager creates a new integrations manager. NewManager must be given an
@@ -196,8 +229,12 @@ func NewManager(cfg ManagerConfig, logger log.Logger, im instance.Manager, valid
 		validator: validate,
 
 		integrations: make(map[string]*integrationProcess, len(cfg.Integrations)),
+		disabled:     make(map[string]struct{}),
 
-		handlerCache: make(map[string]handlerCacheEntry),
+		autodiscoveryUpdate: make(chan struct{}, 1),
+
+		handlerCache:     make(map[string]handlerCacheEntry),
+		httpHandlerCache: make(map[string]handlerCacheEntry),
 	}
 
 	var err error
@@ -209,13 +246,14 @@ func NewManager(cfg ManagerConfig, logger log.Logger, im instance.Manager, valid
 	if err := m.ApplyConfig(cfg); err != nil {
 		return nil, fmt.Errorf("failed applying config: %w", err)
 	}
+
+	m.wg.Add(1)
+	go m.runAutodiscovery()
 	return m, nil
 }
 
 // ApplyConfig updates the configuration of the integrations subsystem.
 func (m *Manager) ApplyConfig(cfg ManagerConfig) error {
-	var failed bool
-
 	m.cfgMut.Lock()
 	defer m.cfgMut.Unlock()
 
@@ -237,15 +275,69 @@ func (m *Manager) ApplyConfig(cfg ManagerConfig) error {
 		// No-op
 	}
 
+	// Rerun autodiscovery, since the services it enables integrations for
+	// depend on the config.
+	select {
+	case m.autodiscoveryUpdate <- struct{}{}:
+	default:
+	}
+
+	return m.applyConfig(cfg)
+}
+
+// applyConfig starts, stops, and updates integrations to match cfg.
+// applyConfig must be called with the config and integrations mutexes held.
+func (m *Manager) applyConfig(cfg ManagerConfig) error {
+	var failed bool
+
+	// instanceKeys maps the instance labels of the integrations to the name
+	// of the instance using them. Instances of an integration must have
+	// different instance labels, since their metrics would conflict
+	// otherwise.
+	instanceKeys := make(map[string]string)
+
 	// Iterate over our integrations. New or changed integrations will be
 	// started, with their existing counterparts being shut down.
-	for _, ic := range cfg.Integrations {
-		if !ic.Common.Enabled {
+	integrations := m.integrationConfigs(cfg)
+	for _, ic := range integrations {
+		if !m.shouldRun(ic) {
 			continue
 		}
 		// Key is used to identify the instance of this integration within the
 		// instance manager and within our set of running integrations.
-		key := integrationKey(ic.Name())
+		key := integrationKey(ic.Name(), ic.Common.Name)
+
+		// Find what instance label should be used to represent this integration.
+		var (
+			instanceKey string
+			err         error
+		)
+		if kp := ic.Common.InstanceKey; kp != nil {
+			// Common config takes precedence.
+			instanceKey = strings.TrimSpace(*kp)
+		} else {
+			instanceKey, err = ic.InstanceKey(fmt.Sprintf("%s:%d", m.hostname, cfg.ListenPort))
+		}
+		if err == nil {
+			if other, exist := instanceKeys[ic.Name()+"/"+instanceKey]; exist {
+				err = fmt.Errorf("instance label %q is already used by instance %q, instance must be set to a unique value", instanceKey, other)
+			} else {
+				instanceKeys[ic.Name()+"/"+instanceKey] = ic.Common.Name
+			}
+		}
+		if err != nil {
+			level.Error(m.logger).Log("msg", "failed to get instance key for integration. it will not run or be scraped", "integration", ic.Name(), "instance_name", ic.Common.Name, "err", err)
+			failed = true
+
+			// Stop the integration if it was running before. Its instance won't be
+			// cleaned up once it's removed from the map, so clean it up here.
+			if p, exist := m.integrations[key]; exist {
+				p.stop()
+				delete(m.integrations, key)
+			}
+			_ = m.im.DeleteConfig(key)
+			continue
+		}
 
 		// Look for an existing integration with the same key. If it exists and
 		// is unchanged, we have nothing to do. Otherwise, we're going to recreate
@@ -259,9 +351,12 @@ func (m *Manager) ApplyConfig(cfg ManagerConfig) error {
 		}
 
 		l := log.With(m.logger, "integration", ic.Name())
+		if ic.Common.Name != "" {
+			l = log.With(l, "instance_name", ic.Common.Name)
+		}
 		i, err := ic.NewIntegration(l)
 		if err != nil {
-			level.Error(m.logger).Log("msg", "failed to initialize integration. it will not run or be scraped", "integration", ic.Name(), "err", err)
+			level.Error(m.logger).Log("msg", "failed to initialize integration. it will not run or be scraped", "integration", ic.Name(), "instance_name", ic.Common.Name, "err", err)
 			failed = true
 
 			// If this integration was running before, its instance won't be cleaned
@@ -270,24 +365,6 @@ func (m *Manager) ApplyConfig(cfg ManagerConfig) error {
 			continue
 		}
 
-		// Find what instance label should be used to represent this integration.
-		var instanceKey string
-		if kp := ic.Common.InstanceKey; kp != nil {
-			// Common config takes precedence.
-			instanceKey = strings.TrimSpace(*kp)
-		} else {
-			instanceKey, err = ic.InstanceKey(fmt.Sprintf("%s:%d", m.hostname, cfg.ListenPort))
-			if err != nil {
-				level.Error(m.logger).Log("msg", "failed to get instance key for integration. it will not run or be scraped", "integration", ic.Name(), "err", err)
-				failed = true
-
-				// If this integration was running before, its instance won't be cleaned
-				// up since it's now removed from the map. We need to clean it up here.
-				_ = m.im.DeleteConfig(key)
-				continue
-			}
-		}
-
 		// Create, start, and register the new integration.
 		ctx, cancel := context.WithCancel(m.ctx)
 		p := &integrationProcess{
@@ -310,10 +387,10 @@ func (m *Manager) ApplyConfig(cfg ManagerConfig) error {
 	// ApplyConfig.
 	for key, process := range m.integrations {
 		foundConfig := false
-		for _, ic := range cfg.Integrations {
-			if integrationKey(ic.Name()) == key {
+		for _, ic := range integrations {
+			if integrationKey(ic.Name(), ic.Common.Name) == key {
 				// If this is disabled then we should delete from integrations
-				if !ic.Common.Enabled {
+				if !m.shouldRun(ic) {
 					break
 				}
 				foundConfig = true
@@ -367,6 +444,70 @@ func (m *Manager) ApplyConfig(cfg ManagerConfig) error {
 	return nil
 }
 
+// shouldRun returns true if the integration configured by ic is enabled and
+// wasn't disabled at runtime.
+func (m *Manager) shouldRun(ic UnmarshaledConfig) bool {
+	_, disabled := m.disabled[integrationKey(ic.Name(), ic.Common.Name)]
+	return ic.Common.Enabled && !disabled
+}
+
+// errIntegrationNotFound is returned when enabling or disabling an
+// integration which isn't enabled in the config.
+var errIntegrationNotFound = errors.New("integration not found")
+
+// SetIntegrationEnabled enables or disables the instance instanceName of the
+// integration named name at runtime, starting or stopping the instance and
+// its scrape without reloading the config. All instances of the integration
+// are toggled if instanceName is empty. Only integrations which are enabled
+// in the config or by autodiscovery can be toggled. Integrations stay
+// disabled across config reloads until they're enabled again.
+func (m *Manager) SetIntegrationEnabled(name, instanceName string, enabled bool) error {
+	m.cfgMut.Lock()
+	defer m.cfgMut.Unlock()
+
+	m.integrationsMut.Lock()
+	defer m.integrationsMut.Unlock()
+
+	var (
+		found   bool
+		changed []string
+	)
+	for _, ic := range m.integrationConfigs(m.cfg) {
+		if ic.Name() != name || !ic.Common.Enabled {
+			continue
+		}
+		if instanceName != "" && ic.Common.Name != instanceName {
+			continue
+		}
+		found = true
+
+		key := integrationKey(ic.Name(), ic.Common.Name)
+		if _, disabled := m.disabled[key]; disabled == enabled {
+			changed = append(changed, key)
+		}
+	}
+	if !found {
+		if instanceName != "" {
+			return fmt.Errorf("%w: %s/%s", errIntegrationNotFound, name, instanceName)
+		}
+		return fmt.Errorf("%w: %s", errIntegrationNotFound, name)
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	for _, key := range changed {
+		if enabled {
+			delete(m.disabled, key)
+			level.Info(m.logger).Log("msg", "enabling integration", "integration", key)
+		} else {
+			m.disabled[key] = struct{}{}
+			level.Info(m.logger).Log("msg", "disabling integration", "integration", key)
+		}
+	}
+	return m.applyConfig(m.cfg)
+}
+
 // integrationProcess is a running integration.
 type integrationProcess struct {
 	log         log.Logger
@@ -375,6 +516,7 @@ type integrationProcess struct {
 	cfg         UnmarshaledConfig
 	instanceKey string // Value for the `instance` label
 	i           Integration
+	ps          processStatus
 
 	wg   *sync.WaitGroup
 	wait func(cfg Config, err error)
@@ -393,10 +535,13 @@ func (p *integrationProcess) Run() {
 	defer p.wg.Done()
 
 	for {
+		p.ps.started(time.Now())
 		err := p.i.Run(p.ctx)
 		if err != nil && err != context.Canceled {
+			p.ps.exited(err)
 			p.wait(p.cfg, err)
 		} else {
+			p.ps.exited(nil)
 			level.Info(p.log).Log("msg", "stopped integration", "integration", p.cfg.Name())
 			break
 		}
@@ -423,45 +568,59 @@ func (m *Manager) instanceConfigForIntegration(p *integrationProcess, cfg Manage
 		schema = "https"
 		httpClientConfig.TLSConfig = cfg.TLSConfig
 	}
+	httpClientConfig.BasicAuth = cfg.BasicAuth
+	httpClientConfig.Authorization = cfg.Authorization
 
 	var scrapeConfigs []*promConfig.ScrapeConfig
 
 	for _, isc := range p.i.ScrapeConfigs() {
 		sc := &promConfig.ScrapeConfig{
 			JobName:                 fmt.Sprintf("integrations/%s", isc.JobName),
-			MetricsPath:             path.Join("/integrations", p.cfg.Name(), isc.MetricsPath),
+			MetricsPath:             path.Join("/integrations", p.cfg.Name(), p.cfg.Common.Name, isc.MetricsPath),
 			Params:                  isc.QueryParams,
 			Scheme:                  schema,
 			HonorLabels:             false,
 			HonorTimestamps:         true,
 			ScrapeInterval:          model.Duration(common.ScrapeInterval),
 			ScrapeTimeout:           model.Duration(common.ScrapeTimeout),
-			ServiceDiscoveryConfigs: m.scrapeServiceDiscovery(cfg),
+			ServiceDiscoveryConfigs: m.scrapeServiceDiscovery(cfg, common.ExtraLabels),
 			RelabelConfigs:          relabelConfigs,
-			MetricRelabelConfigs:    common.MetricRelabelConfigs,
+			MetricRelabelConfigs:    append(config.FilterRelabelConfigs(common.MetricAllowlist, common.MetricDenylist), common.MetricRelabelConfigs...),
 			HTTPClientConfig:        httpClientConfig,
+			SampleLimit:             common.SampleLimit,
 		}
 
 		scrapeConfigs = append(scrapeConfigs, sc)
 	}
 
 	instanceCfg := instance.DefaultConfig
-	instanceCfg.Name = integrationKey(p.cfg.Name())
+	instanceCfg.Name = integrationKey(p.cfg.Name(), p.cfg.Common.Name)
 	instanceCfg.ScrapeConfigs = scrapeConfigs
 	instanceCfg.RemoteWrite = cfg.PrometheusRemoteWrite
 	if common.WALTruncateFrequency > 0 {
 		instanceCfg.WALTruncateFrequency = common.WALTruncateFrequency
 	}
+	instanceCfg.MaxSeriesPerTarget = common.MaxSeriesPerTarget
+	instanceCfg.MaxLabelsPerSeries = common.MaxLabelsPerSeries
+	instanceCfg.MaxLabelNameLength = common.MaxLabelNameLength
+	instanceCfg.MaxLabelValueLength = common.MaxLabelValueLength
 	return instanceCfg
 }
 
 // integrationKey returns the key for an integration Config, used for its
-// instance name and name in the process cache.
-func integrationKey(name string) string {
-	return fmt.Sprintf("integration/%s", name)
+// instance name and name in the process cache. instanceName is the name of
+// the instance of the integration, which is empty for integrations configured
+// with a single unnamed instance.
+func integrationKey(name, instanceName string) string {
+	if instanceName == "" {
+		return fmt.Sprintf("integration/%s", name)
+	}
+	return fmt.Sprintf("integration/%s/%s", name, instanceName)
 }
 
-func (m *Manager) scrapeServiceDiscovery(cfg ManagerConfig) discovery.Configs {
+// scrapeServiceDiscovery returns the service discovery of the scrape configs
+// of an integration, with extraLabels added to the labels of the target.
+func (m *Manager) scrapeServiceDiscovery(cfg ManagerConfig, extraLabels model.LabelSet) discovery.Configs {
 	// A blank host somehow works, but it then requires a sever name to be set under tls.
 	newHost := cfg.ListenHost
 	if newHost == "" {
@@ -473,6 +632,9 @@ func (m *Manager) scrapeServiceDiscovery(cfg ManagerConfig) discovery.Configs {
 	for k, v := range cfg.Labels {
 		labels[k] = v
 	}
+	for k, v := range extraLabels {
+		labels[k] = v
+	}
 
 	return discovery.Configs{
 		discovery.StaticConfig{{
@@ -482,16 +644,106 @@ func (m *Manager) scrapeServiceDiscovery(cfg ManagerConfig) discovery.Configs {
 	}
 }
 
-// WireAPI hooks up /metrics routes per-integration.
+// WireAPI hooks up /metrics routes per-integration. Other endpoints under
+// /integrations/{name}/ are routed to integrations which implement
+// HTTPIntegration. The endpoints of each instance of an integration are also
+// under /integrations/{name}/{instance}/, where {instance} is the name or the
+// instance label of the instance.
 func (m *Manager) WireAPI(r *mux.Router) {
+	r.HandleFunc("/agent/api/v1/integrations/status", m.statusHandler).Methods("GET")
+	r.HandleFunc("/agent/api/v1/integrations/autodiscovery", m.autodiscoveryHandler).Methods("GET")
+	r.HandleFunc("/agent/api/v1/integrations/{name}/enable", m.setEnabledHandler(true)).Methods("POST")
+	r.HandleFunc("/agent/api/v1/integrations/{name}/disable", m.setEnabledHandler(false)).Methods("POST")
+	r.HandleFunc("/agent/api/v1/integrations/{name}/{instance}/enable", m.setEnabledHandler(true)).Methods("POST")
+	r.HandleFunc("/agent/api/v1/integrations/{name}/{instance}/disable", m.setEnabledHandler(false)).Methods("POST")
+
 	r.HandleFunc("/integrations/{name}/metrics", func(rw http.ResponseWriter, r *http.Request) {
 		m.integrationsMut.RLock()
 		defer m.integrationsMut.RUnlock()
 
-		key := integrationKey(mux.Vars(r)["name"])
+		key := integrationKey(mux.Vars(r)["name"], "")
 		handler := m.loadHandler(key)
 		handler.ServeHTTP(rw, r)
 	})
+
+	r.PathPrefix("/integrations/{name}/").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		m.integrationsMut.RLock()
+		defer m.integrationsMut.RUnlock()
+
+		name := mux.Vars(r)["name"]
+		key, prefix := integrationKey(name, ""), "/integrations/"+name+"/"
+
+		// Paths of instances may start with the name or instance label of the
+		// instance, which is otherwise part of the path handled by the
+		// integration.
+		segment := strings.SplitN(strings.TrimPrefix(r.URL.Path, prefix), "/", 2)[0]
+		if instanceKey, ok := m.lookupInstance(name, segment); ok {
+			key, prefix = instanceKey, prefix+segment+"/"
+		}
+
+		var handler http.Handler
+		if r.URL.Path == prefix+"metrics" {
+			handler = m.loadHandler(key)
+		} else {
+			handler = m.loadHTTPHandler(key, prefix)
+		}
+		handler.ServeHTTP(rw, r)
+	})
+}
+
+// lookupInstance returns the key of the instance of the integration name
+// whose name or instance label is segment. Names take precedence over
+// instance labels. lookupInstance should be called with a read lock on the
+// integrations mutex.
+func (m *Manager) lookupInstance(name, segment string) (string, bool) {
+	if segment == "" {
+		return "", false
+	}
+	if key := integrationKey(name, segment); m.integrations[key] != nil {
+		return key, true
+	}
+	for key, p := range m.integrations {
+		if p.cfg.Name() == name && p.instanceKey == segment {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// instanceMetricsPath returns the path of the metrics endpoint of the
+// instance of the integration name with the instance label instanceKey.
+func instanceMetricsPath(name, instanceKey string) string {
+	return "/integrations/" + name + "/" + url.PathEscape(instanceKey) + "/metrics"
+}
+
+// integrationStateResponse is returned by the handlers which enable and
+// disable integrations.
+type integrationStateResponse struct {
+	Name         string `json:"name"`
+	InstanceName string `json:"instance_name,omitempty"`
+	Enabled      bool   `json:"enabled"`
+}
+
+// setEnabledHandler returns a handler which enables or disables the
+// integration named by the request, or only the instance of the integration
+// named by the request.
+func (m *Manager) setEnabledHandler(enabled bool) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		name, instanceName := mux.Vars(r)["name"], mux.Vars(r)["instance"]
+
+		var err error
+		switch err = m.SetIntegrationEnabled(name, instanceName, enabled); {
+		case errors.Is(err, errIntegrationNotFound):
+			err = configapi.WriteError(rw, http.StatusNotFound, err)
+		case err != nil:
+			err = configapi.WriteError(rw, http.StatusInternalServerError, err)
+		default:
+			err = configapi.WriteResponse(rw, http.StatusOK, integrationStateResponse{Name: name, InstanceName: instanceName, Enabled: enabled})
+		}
+		if err != nil {
+			level.Error(m.logger).Log("msg", "failed to write response", "err", err)
+		}
+	}
 }
 
 // loadHandler will perform a dynamic lookup of an HTTP handler for an
@@ -527,6 +779,44 @@ func (m *Manager) loadHandler(key string) http.Handler {
 	return cacheEntry.handler
 }
 
+// loadHTTPHandler will perform a dynamic lookup of the HTTPIntegration
+// handler for an integration. loadHTTPHandler should be called with a read
+// lock on the integrations mutex.
+func (m *Manager) loadHTTPHandler(key string, prefix string) http.Handler {
+	m.handlerMut.Lock()
+	defer m.handlerMut.Unlock()
+
+	// Instances are served under multiple prefixes, and every prefix needs its
+	// own handler.
+	cacheKey := key + "\x00" + prefix
+
+	p, ok := m.integrations[key]
+	if !ok {
+		delete(m.httpHandlerCache, cacheKey)
+		return http.NotFoundHandler()
+	}
+
+	cacheEntry, ok := m.httpHandlerCache[cacheKey]
+	if ok && cacheEntry.process == p {
+		return cacheEntry.handler
+	}
+
+	var handler http.Handler = http.NotFoundHandler()
+	if hi, ok := p.i.(HTTPIntegration); ok {
+		h, err := hi.Handler(prefix)
+		if err != nil {
+			level.Error(m.logger).Log("msg", "could not create http handler for integration", "integration", p.cfg.Name(), "err", err)
+			return http.HandlerFunc(internalServiceError)
+		} else if h != nil {
+			handler = h
+		}
+	}
+
+	cacheEntry = handlerCacheEntry{handler: handler, process: p}
+	m.httpHandlerCache[cacheKey] = cacheEntry
+	return cacheEntry.handler
+}
+
 func internalServiceError(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
 }
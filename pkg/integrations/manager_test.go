@@ -2,20 +2,28 @@ package integrations
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/cortexproject/cortex/pkg/util/test"
 	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/grafana/agent/pkg/integrations/autodiscovery"
 	"github.com/grafana/agent/pkg/integrations/config"
 	"github.com/grafana/agent/pkg/metrics/instance"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/model"
 	promConfig "github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/discovery"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/prometheus/prometheus/scrape"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
 	"gopkg.in/yaml.v2"
@@ -51,10 +59,44 @@ use_hostname_label: true
 	require.YAMLEq(t, cfgText, string(outBytes))
 }
 
+var registerTestIntegrationOnce sync.Once
+
+// registerTestIntegration registers testIntegrationA, which can only be
+// registered once.
+func registerTestIntegration() {
+	registerTestIntegrationOnce.Do(func() { RegisterIntegration(&testIntegrationA{}) })
+}
+
+// Test that integrations with multiple instances can be unmarshaled and
+// remarshaled back out to text.
+func TestConfig_RemarshalMultipleInstances(t *testing.T) {
+	registerTestIntegration()
+	cfgText := `
+scrape_integrations: true
+replace_instance_label: true
+integration_restart_backoff: 5s
+use_hostname_label: true
+test:
+- name: first
+  text: Hello, world!
+  truth: true
+- name: second
+  text: Goodbye, world!
+  truth: true
+`
+	var cfg ManagerConfig
+	require.NoError(t, yaml.Unmarshal([]byte(cfgText), &cfg))
+	require.Len(t, cfg.Integrations, 2)
+
+	outBytes, err := yaml.Marshal(cfg)
+	require.NoError(t, err)
+	require.YAMLEq(t, cfgText, string(outBytes))
+}
+
 // Test that embedded integration fields in the struct can be unmarshaled and
 // remarshaled back out to text.
 func TestConfig_Remarshal(t *testing.T) {
-	RegisterIntegration(&testIntegrationA{})
+	registerTestIntegration()
 	cfgText := `
 scrape_integrations: true
 replace_instance_label: true
@@ -109,6 +151,31 @@ agent:
 	require.Equal(t, result.Get("instance"), expectHostname+":12345")
 }
 
+func TestConfig_MetricFilters(t *testing.T) {
+	cfgText := `
+metric_allowlist: [node_cpu_.*, node_memory_.*]
+metric_denylist: [node_cpu_guest_.*]
+`
+
+	var common config.Common
+	require.NoError(t, yaml.UnmarshalStrict([]byte(cfgText), &common))
+	relabels := config.FilterRelabelConfigs(common.MetricAllowlist, common.MetricDenylist)
+	require.Len(t, relabels, 2)
+
+	for name, keep := range map[string]bool{
+		"node_cpu_seconds_total":       true,
+		"node_memory_MemFree_bytes":    true,
+		"node_cpu_guest_seconds_total": false,
+		"node_network_up":              false,
+		"xnode_cpu_seconds_total":      false,
+	} {
+		result := relabel.Process(labels.FromStrings("__name__", name), relabels...)
+		require.Equal(t, keep, result != nil, name)
+	}
+
+	require.Error(t, yaml.UnmarshalStrict([]byte("metric_denylist: ['(']"), &common))
+}
+
 func TestManager_instanceConfigForIntegration(t *testing.T) {
 	mock := newMockIntegration()
 	icfg := mockConfig{Integration: mock}
@@ -126,6 +193,53 @@ func TestManager_instanceConfigForIntegration(t *testing.T) {
 	require.Equal(t, "/integrations/mock/metrics", cfg.ScrapeConfigs[0].MetricsPath)
 }
 
+func TestManager_instanceConfigForIntegration_ExtraLabels(t *testing.T) {
+	mock := newMockIntegration()
+	icfg := mockConfig{Integration: mock}
+
+	im := instance.NewBasicManager(instance.DefaultBasicManagerConfig, log.NewNopLogger(), mockInstanceFactory)
+	m, err := NewManager(mockManagerConfig(), log.NewNopLogger(), im, noOpValidator)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	mcfg := mockManagerConfig()
+	mcfg.Labels = model.LabelSet{"cluster": "prod", "team": "platform"}
+
+	uc := makeUnmarshaledConfig(icfg, true)
+	uc.Common.ExtraLabels = model.LabelSet{"team": "databases", "owner": "alice"}
+	p := &integrationProcess{instanceKey: "key", cfg: uc, i: mock}
+	cfg := m.instanceConfigForIntegration(p, mcfg)
+
+	require.Len(t, cfg.ScrapeConfigs, 1)
+	sd := cfg.ScrapeConfigs[0].ServiceDiscoveryConfigs[0].(discovery.StaticConfig)
+	require.Equal(t, model.LabelValue("prod"), sd[0].Labels["cluster"])
+	require.Equal(t, model.LabelValue("databases"), sd[0].Labels["team"])
+	require.Equal(t, model.LabelValue("alice"), sd[0].Labels["owner"])
+}
+
+func TestManager_instanceConfigForIntegration_ScrapeLimits(t *testing.T) {
+	mock := newMockIntegration()
+	icfg := mockConfig{Integration: mock}
+
+	im := instance.NewBasicManager(instance.DefaultBasicManagerConfig, log.NewNopLogger(), mockInstanceFactory)
+	m, err := NewManager(mockManagerConfig(), log.NewNopLogger(), im, noOpValidator)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	uc := makeUnmarshaledConfig(icfg, true)
+	uc.Common.SampleLimit = 1000
+	uc.Common.MaxSeriesPerTarget = 500
+	uc.Common.MaxLabelValueLength = 256
+	p := &integrationProcess{instanceKey: "key", cfg: uc, i: mock}
+	cfg := m.instanceConfigForIntegration(p, mockManagerConfig())
+
+	require.Len(t, cfg.ScrapeConfigs, 1)
+	require.Equal(t, uint(1000), cfg.ScrapeConfigs[0].SampleLimit)
+	require.Equal(t, 500, cfg.MaxSeriesPerTarget)
+	require.Equal(t, 256, cfg.MaxLabelValueLength)
+	require.Zero(t, cfg.MaxLabelsPerSeries)
+}
+
 func makeUnmarshaledConfig(cfg Config, enabled bool) UnmarshaledConfig {
 	return UnmarshaledConfig{Config: cfg, Common: config.Common{Enabled: enabled}}
 }
@@ -200,6 +314,314 @@ func TestManager_StartsIntegrations(t *testing.T) {
 	})
 }
 
+func TestManager_HTTPIntegration(t *testing.T) {
+	mock := newMockIntegration()
+	icfg := mockConfig{Integration: mock}
+
+	cfg := mockManagerConfig()
+	cfg.Integrations = append(cfg.Integrations, makeUnmarshaledConfig(icfg, true))
+
+	im := instance.NewBasicManager(instance.DefaultBasicManagerConfig, log.NewNopLogger(), mockInstanceFactory)
+	m, err := NewManager(cfg, log.NewNopLogger(), im, noOpValidator)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	r := mux.NewRouter()
+	m.WireAPI(r)
+
+	test.Poll(t, time.Second, 1, func() interface{} {
+		return int(mock.startedCount.Load())
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/integrations/mock/probe", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "prefix=/integrations/mock/ path=/integrations/mock/probe", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/integrations/unknown/probe", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestManager_NamedInstances(t *testing.T) {
+	var (
+		mockA, mockB         = newMockIntegration(), newMockIntegration()
+		instanceA, instanceB = "a.example.com", "b.example.com"
+	)
+
+	cfg := mockManagerConfig()
+	cfg.Integrations = append(cfg.Integrations,
+		UnmarshaledConfig{Config: mockConfig{Integration: mockA}, Common: config.Common{Enabled: true, Name: "a", InstanceKey: &instanceA}},
+		UnmarshaledConfig{Config: mockConfig{Integration: mockB}, Common: config.Common{Enabled: true, Name: "b", InstanceKey: &instanceB}},
+	)
+
+	im := instance.NewBasicManager(instance.DefaultBasicManagerConfig, log.NewNopLogger(), mockInstanceFactory)
+	m, err := NewManager(cfg, log.NewNopLogger(), im, noOpValidator)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	r := mux.NewRouter()
+	m.WireAPI(r)
+
+	test.Poll(t, time.Second, 2, func() interface{} {
+		return int(mockA.startedCount.Load() + mockB.startedCount.Load())
+	})
+
+	configs := im.ListConfigs()
+	require.Len(t, configs, 2)
+	require.Equal(t, "/integrations/mock/a/metrics", configs["integration/mock/a"].ScrapeConfigs[0].MetricsPath)
+	require.Equal(t, "/integrations/mock/b/metrics", configs["integration/mock/b"].ScrapeConfigs[0].MetricsPath)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/integrations/mock/b/probe", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "prefix=/integrations/mock/b/ path=/integrations/mock/b/probe", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/integrations/mock/a/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// Instances of an integration can't have the same instance label.
+	cfg.Integrations = append([]UnmarshaledConfig(nil), cfg.Integrations...)
+	cfg.Integrations[1].Common.InstanceKey = &instanceA
+	require.Error(t, m.ApplyConfig(cfg))
+	require.Len(t, m.integrations, 1)
+	require.Len(t, im.ListConfigs(), 1)
+}
+
+func TestManager_InstanceEndpoints(t *testing.T) {
+	var (
+		mockA, mockB         = newMockIntegration(), newMockIntegration()
+		instanceA, instanceB = "a.example.com:9100", "b.example.com:9100"
+	)
+
+	cfg := mockManagerConfig()
+	cfg.Integrations = append(cfg.Integrations,
+		UnmarshaledConfig{Config: mockConfig{Integration: mockA}, Common: config.Common{Enabled: true, InstanceKey: &instanceA}},
+	)
+
+	im := instance.NewBasicManager(instance.DefaultBasicManagerConfig, log.NewNopLogger(), mockInstanceFactory)
+	m, err := NewManager(cfg, log.NewNopLogger(), im, noOpValidator)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	r := mux.NewRouter()
+	m.WireAPI(r)
+
+	test.Poll(t, time.Second, 1, func() interface{} {
+		return int(mockA.startedCount.Load())
+	})
+
+	// Unnamed instances are exposed under their instance label in addition
+	// to /integrations/{name}/.
+	for _, path := range []string{"/integrations/mock/metrics", "/integrations/mock/a.example.com:9100/metrics"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, rec.Code, path)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/integrations/mock/a.example.com:9100/probe", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "prefix=/integrations/mock/a.example.com:9100/ path=/integrations/mock/a.example.com:9100/probe", rec.Body.String())
+
+	status := m.Status()
+	require.Len(t, status, 1)
+	require.Equal(t, instanceA, status[0].Instance)
+	require.Equal(t, "/integrations/mock/a.example.com:9100/metrics", status[0].MetricsPath)
+
+	// Named instances are exposed under both their name and instance label.
+	cfg.Integrations = []UnmarshaledConfig{
+		{Config: mockConfig{Integration: mockA}, Common: config.Common{Enabled: true, Name: "a", InstanceKey: &instanceA}},
+		{Config: mockConfig{Integration: mockB}, Common: config.Common{Enabled: true, Name: "b", InstanceKey: &instanceB}},
+	}
+	require.NoError(t, m.ApplyConfig(cfg))
+
+	for _, path := range []string{"/integrations/mock/b/probe", "/integrations/mock/b.example.com:9100/probe"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, rec.Code, path)
+		require.Equal(t, "prefix="+strings.TrimSuffix(path, "probe")+" path="+path, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/integrations/mock/c.example.com:9100/metrics", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestManager_EnableDisableAPI(t *testing.T) {
+	mock := newMockIntegration()
+	icfg := mockConfig{Integration: mock}
+
+	cfg := mockManagerConfig()
+	cfg.Integrations = append(cfg.Integrations, makeUnmarshaledConfig(icfg, true))
+
+	im := instance.NewBasicManager(instance.DefaultBasicManagerConfig, log.NewNopLogger(), mockInstanceFactory)
+	m, err := NewManager(cfg, log.NewNopLogger(), im, noOpValidator)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	r := mux.NewRouter()
+	m.WireAPI(r)
+
+	post := func(path string) int {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusOK, post("/agent/api/v1/integrations/mock/disable"))
+	require.Len(t, m.integrations, 0, "Integration was disabled so should be removed from map")
+	require.Len(t, im.ListConfigs(), 0, "Integration was disabled so should not be scraped")
+
+	// The integration stays disabled when the config is reloaded.
+	_ = m.ApplyConfig(generateMockConfigWithEnabledFlag(true))
+	require.Len(t, m.integrations, 0, "Integration was disabled so should be removed from map")
+
+	require.Equal(t, http.StatusOK, post("/agent/api/v1/integrations/mock/enable"))
+	require.Len(t, m.integrations, 1, "Integration was enabled so should be here")
+	require.Len(t, im.ListConfigs(), 1, "Integration was enabled so should be scraped")
+
+	require.Equal(t, http.StatusNotFound, post("/agent/api/v1/integrations/unknown/disable"))
+}
+
+func TestManager_EnableDisableAPI_NamedInstances(t *testing.T) {
+	var (
+		mockA, mockB         = newMockIntegration(), newMockIntegration()
+		instanceA, instanceB = "a.example.com", "b.example.com"
+	)
+
+	cfg := mockManagerConfig()
+	cfg.Integrations = append(cfg.Integrations,
+		UnmarshaledConfig{Config: mockConfig{Integration: mockA}, Common: config.Common{Enabled: true, Name: "a", InstanceKey: &instanceA}},
+		UnmarshaledConfig{Config: mockConfig{Integration: mockB}, Common: config.Common{Enabled: true, Name: "b", InstanceKey: &instanceB}},
+	)
+
+	im := instance.NewBasicManager(instance.DefaultBasicManagerConfig, log.NewNopLogger(), mockInstanceFactory)
+	m, err := NewManager(cfg, log.NewNopLogger(), im, noOpValidator)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	r := mux.NewRouter()
+	m.WireAPI(r)
+
+	post := func(path string) int {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusOK, post("/agent/api/v1/integrations/mock/a/disable"))
+	require.Len(t, m.integrations, 1)
+	require.Contains(t, m.integrations, "integration/mock/b")
+
+	require.Equal(t, http.StatusOK, post("/agent/api/v1/integrations/mock/disable"))
+	require.Len(t, m.integrations, 0)
+
+	require.Equal(t, http.StatusOK, post("/agent/api/v1/integrations/mock/b/enable"))
+	require.Len(t, m.integrations, 1)
+	require.Contains(t, m.integrations, "integration/mock/b")
+
+	require.Equal(t, http.StatusNotFound, post("/agent/api/v1/integrations/mock/c/enable"))
+}
+
+func TestManager_Status(t *testing.T) {
+	mock := newMockIntegration()
+	icfg := mockConfig{Integration: mock}
+
+	cfg := mockManagerConfig()
+	cfg.Integrations = append(cfg.Integrations, makeUnmarshaledConfig(icfg, true))
+
+	im := instance.NewBasicManager(instance.DefaultBasicManagerConfig, log.NewNopLogger(), mockInstanceFactory)
+	m, err := NewManager(cfg, log.NewNopLogger(), im, noOpValidator)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	r := mux.NewRouter()
+	m.WireAPI(r)
+
+	test.Poll(t, time.Second, StateRunning, func() interface{} {
+		return m.Status()[0].State
+	})
+	require.True(t, m.Ready())
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/agent/api/v1/integrations/status", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp struct {
+		Data []IntegrationStatus `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 1)
+	require.Equal(t, "mock", resp.Data[0].Name)
+	require.Equal(t, StateRunning, resp.Data[0].State)
+	require.True(t, resp.Data[0].Healthy)
+
+	require.NoError(t, m.SetIntegrationEnabled("mock", "", false))
+	require.Equal(t, []IntegrationStatus{{Name: "mock", State: StateDisabled, Healthy: true}}, m.Status())
+	require.True(t, m.Ready())
+}
+
+// TestManager_ReadyWithFailedScrape ensures that a failed scrape of a running
+// integration makes it unhealthy without making the manager unready.
+func TestManager_ReadyWithFailedScrape(t *testing.T) {
+	mock := newMockIntegration()
+	icfg := mockConfig{Integration: mock}
+
+	cfg := mockManagerConfig()
+	cfg.Integrations = append(cfg.Integrations, makeUnmarshaledConfig(icfg, true))
+
+	target := scrape.NewTarget(labels.FromStrings(model.AddressLabel, "127.0.0.1:12345"), nil, nil)
+	target.Report(time.Now(), time.Second, fmt.Errorf("connection refused"))
+	factory := func(_ instance.Config) (instance.ManagedInstance, error) {
+		return failedScrapeInstance{target: target}, nil
+	}
+
+	im := instance.NewBasicManager(instance.DefaultBasicManagerConfig, log.NewNopLogger(), factory)
+	m, err := NewManager(cfg, log.NewNopLogger(), im, noOpValidator)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	test.Poll(t, time.Second, StateRunning, func() interface{} {
+		return m.Status()[0].State
+	})
+
+	status := m.Status()[0]
+	require.False(t, status.Healthy)
+	require.Equal(t, "connection refused", status.LastScrapeError)
+	require.True(t, m.Ready())
+}
+
+// failedScrapeInstance is an instance whose only target failed its last
+// scrape.
+type failedScrapeInstance struct {
+	instance.NoOpInstance
+	target *scrape.Target
+}
+
+func (i failedScrapeInstance) TargetsActive() map[string][]*scrape.Target {
+	return map[string][]*scrape.Target{"integrations/mock": {i.target}}
+}
+
+func TestIntegrationProcess_Status(t *testing.T) {
+	p := &integrationProcess{cfg: makeUnmarshaledConfig(mockConfig{}, true)}
+	now := time.Now()
+
+	p.ps.started(now)
+	status := p.status(now.Add(time.Minute))
+	require.Equal(t, StateRunning, status.State)
+	require.Equal(t, float64(60), status.UptimeSeconds)
+
+	p.ps.exited(fmt.Errorf("exporter crashed"))
+	status = p.status(now.Add(time.Minute))
+	require.Equal(t, StateRestarting, status.State)
+	require.Equal(t, "exporter crashed", status.LastError)
+
+	p.ps.exited(nil)
+	require.Equal(t, StateStopped, p.status(now).State)
+}
+
 func TestManager_RestartsIntegrations(t *testing.T) {
 	mock := newMockIntegration()
 	icfg := mockConfig{Integration: mock}
@@ -396,6 +818,12 @@ func (i *mockIntegration) ScrapeConfigs() []config.ScrapeConfig {
 	}}
 }
 
+func (i *mockIntegration) Handler(prefix string) (http.Handler, error) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "prefix=%s path=%s", prefix, r.URL.Path)
+	}), nil
+}
+
 func (i *mockIntegration) Run(ctx context.Context) error {
 	i.startedCount.Inc()
 	i.running.Store(true)
@@ -430,3 +858,32 @@ func mockPromConfigWithValues(scrapeInterval model.Duration, scrapeTimeout model
 		ScrapeTimeout:  scrapeTimeout,
 	}
 }
+
+func TestManager_Autodiscovery(t *testing.T) {
+	im := instance.NewBasicManager(instance.DefaultBasicManagerConfig, log.NewNopLogger(), mockInstanceFactory)
+	m, err := NewManager(mockManagerConfig(), log.NewNopLogger(), im, noOpValidator)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	// No integrations of discovered services are registered in tests.
+	services := []autodiscovery.Service{
+		{Integration: "redis_exporter", Name: "redis-server", Address: "localhost:6379", Source: autodiscovery.SourceSystemd},
+	}
+	m.applyDiscoveredServices(services)
+	require.Empty(t, m.Status())
+
+	r := mux.NewRouter()
+	m.WireAPI(r)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/agent/api/v1/integrations/autodiscovery", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp struct {
+		Data []DiscoveredService `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, []DiscoveredService{
+		{Service: services[0], Reason: "integration isn't available"},
+	}, resp.Data)
+}
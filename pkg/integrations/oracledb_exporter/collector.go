@@ -0,0 +1,117 @@
+package oracledb_exporter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "oracledb"
+
+// collector runs the requests of metrics against the database on every
+// scrape.
+type collector struct {
+	log          log.Logger
+	db           *sql.DB
+	metrics      []Metric
+	queryTimeout time.Duration
+
+	up           *prometheus.Desc
+	scrapeErrors *prometheus.CounterVec
+}
+
+func newCollector(l log.Logger, db *sql.DB, metrics []Metric, queryTimeout time.Duration) *collector {
+	return &collector{
+		log:          l,
+		db:           db,
+		metrics:      metrics,
+		queryTimeout: queryTimeout,
+
+		up: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "up"),
+			"Whether the Oracle database server is up.",
+			nil, nil,
+		),
+		scrapeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "scrape_errors_total",
+			Help:      "Total number of errors while running the requests of metrics.",
+		}, []string{"context"}),
+	}
+}
+
+// Describe implements prometheus.Collector. The collector is unchecked
+// since custom metrics are only known once their requests return.
+func (c *collector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	defer c.scrapeErrors.Collect(ch)
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.queryTimeout)
+	defer cancel()
+
+	if err := c.db.PingContext(ctx); err != nil {
+		level.Error(c.log).Log("msg", "failed to ping oracledb", "err", err)
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1)
+
+	for _, m := range c.metrics {
+		metrics, err := c.scrapeMetric(ctx, m)
+		if err != nil {
+			level.Error(c.log).Log("msg", "failed to scrape metric", "context", m.Context, "err", err)
+			c.scrapeErrors.WithLabelValues(m.Context).Inc()
+			continue
+		}
+		for _, metric := range metrics {
+			ch <- metric
+		}
+	}
+}
+
+// scrapeMetric runs the request of m and converts the returned rows into
+// metrics.
+func (c *collector) scrapeMetric(ctx context.Context, m Metric) ([]prometheus.Metric, error) {
+	rows, err := c.db.QueryContext(ctx, m.Request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run request: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []map[string]string
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		row := make(map[string]string, len(columns))
+		for i, column := range columns {
+			row[strings.ToLower(column)] = values[i].String
+		}
+		results = append(results, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return buildMetrics(m, results)
+}
@@ -0,0 +1,213 @@
+package oracledb_exporter
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metric is a query whose results are exposed as metrics. The format
+// matches the metric files of oracledb_exporter.
+type Metric struct {
+	// Context is added to the name of the metrics, which are named
+	// oracledb_<context>_<column>.
+	Context string `toml:"context"`
+
+	// Labels are the columns exposed as labels.
+	Labels []string `toml:"labels"`
+
+	// MetricsDesc maps the columns exposed as metrics to their help text.
+	MetricsDesc map[string]string `toml:"metricsdesc"`
+
+	// MetricsType maps columns of MetricsDesc to their type, which is either
+	// gauge or counter. Columns are gauges by default.
+	MetricsType map[string]string `toml:"metricstype"`
+
+	// FieldToAppend is a column whose value is appended to the name of the
+	// metrics instead of the name of the metric column.
+	FieldToAppend string `toml:"fieldtoappend"`
+
+	Request string `toml:"request"`
+
+	// IgnoreZeroResult doesn't report an error when the query returns no
+	// rows.
+	IgnoreZeroResult bool `toml:"ignorezeroresult"`
+}
+
+// Validate validates the metric.
+func (m *Metric) Validate() error {
+	if m.Context == "" {
+		return fmt.Errorf("context must not be empty")
+	}
+	if m.Request == "" {
+		return fmt.Errorf("request of metric %s must not be empty", m.Context)
+	}
+	if len(m.MetricsDesc) == 0 {
+		return fmt.Errorf("metricsdesc of metric %s must not be empty", m.Context)
+	}
+	for column, typ := range m.MetricsType {
+		if _, ok := m.MetricsDesc[column]; !ok {
+			return fmt.Errorf("metricstype of metric %s has unknown column %q", m.Context, column)
+		}
+		if typ := strings.ToLower(typ); typ != "gauge" && typ != "counter" {
+			return fmt.Errorf("metric %s has unsupported type %q for column %q", m.Context, typ, column)
+		}
+	}
+	return nil
+}
+
+type metricsFile struct {
+	Metric []Metric `toml:"metric"`
+}
+
+// ParseMetrics parses metrics in the format of the metric files of
+// oracledb_exporter.
+func ParseMetrics(data []byte) ([]Metric, error) {
+	var f metricsFile
+	if err := toml.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	for i := range f.Metric {
+		if err := f.Metric[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return f.Metric, nil
+}
+
+// LoadMetrics returns the default metrics unless disableDefault is set,
+// followed by the metrics of files.
+func LoadMetrics(disableDefault bool, files []string) ([]Metric, error) {
+	var res []Metric
+	if !disableDefault {
+		metrics, err := ParseMetrics([]byte(defaultMetrics))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse default metrics: %w", err)
+		}
+		res = append(res, metrics...)
+	}
+
+	for _, file := range files {
+		bb, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read custom metrics file %s: %w", file, err)
+		}
+		metrics, err := ParseMetrics(bb)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse custom metrics file %s: %w", file, err)
+		}
+		res = append(res, metrics...)
+	}
+	return res, nil
+}
+
+// buildMetrics converts the rows returned by the request of m into metrics.
+// Rows map lowercase column names to their values.
+func buildMetrics(m Metric, rows []map[string]string) ([]prometheus.Metric, error) {
+	if len(rows) == 0 && !m.IgnoreZeroResult {
+		return nil, fmt.Errorf("no rows returned for metric %s", m.Context)
+	}
+
+	labelNames := make([]string, len(m.Labels))
+	for i, l := range m.Labels {
+		labelNames[i] = cleanName(l)
+	}
+
+	var res []prometheus.Metric
+	for _, row := range rows {
+		labelValues := make([]string, len(m.Labels))
+		for i, l := range m.Labels {
+			labelValues[i] = row[strings.ToLower(l)]
+		}
+
+		for column, help := range m.MetricsDesc {
+			raw, ok := row[strings.ToLower(column)]
+			if !ok {
+				return nil, fmt.Errorf("column %s of metric %s not returned by request", column, m.Context)
+			}
+			value, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q of column %s of metric %s: %w", raw, column, m.Context, err)
+			}
+
+			name := column
+			if m.FieldToAppend != "" {
+				name = row[strings.ToLower(m.FieldToAppend)]
+			}
+			valueType := prometheus.GaugeValue
+			if strings.ToLower(m.MetricsType[column]) == "counter" {
+				valueType = prometheus.CounterValue
+			}
+
+			desc := prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, cleanName(m.Context), cleanName(name)),
+				help, labelNames, nil,
+			)
+			metric, err := prometheus.NewConstMetric(desc, valueType, value, labelValues...)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, metric)
+		}
+	}
+	return res, nil
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// cleanName lowercases s and replaces the characters which aren't valid in
+// metric and label names.
+func cleanName(s string) string {
+	s = invalidNameChars.ReplaceAllString(strings.ToLower(strings.TrimSpace(s)), "_")
+	return strings.Trim(s, "_")
+}
+
+// defaultMetrics are the default metrics of oracledb_exporter.
+const defaultMetrics = `
+[[metric]]
+context = "sessions"
+labels = [ "status", "type" ]
+metricsdesc = { value = "Gauge metric with count of sessions by status and type." }
+request = "SELECT status, type, COUNT(*) as value FROM v$session GROUP BY status, type"
+
+[[metric]]
+context = "resource"
+labels = [ "resource_name" ]
+metricsdesc = { current_utilization = "Generic counter metric from v$resource_limit view in Oracle (current value).", limit_value = "Generic counter metric from v$resource_limit view in Oracle (UNLIMITED: -1)." }
+request = "SELECT resource_name, current_utilization, CASE WHEN TRIM(limit_value) LIKE 'UNLIMITED' THEN '-1' ELSE TRIM(limit_value) END as limit_value FROM v$resource_limit"
+
+[[metric]]
+context = "asm_diskgroup"
+labels = [ "name" ]
+metricsdesc = { total = "Total size of ASM disk group.", free = "Free space available on ASM disk group." }
+request = "SELECT name, total_mb*1024*1024 as total, free_mb*1024*1024 as free FROM v$asm_diskgroup_stat WHERE EXISTS (SELECT 1 FROM v$datafile WHERE name LIKE '+%')"
+ignorezeroresult = true
+
+[[metric]]
+context = "activity"
+metricsdesc = { value = "Generic counter metric from v$sysstat view in Oracle." }
+fieldtoappend = "name"
+request = "SELECT name, value FROM v$sysstat WHERE name IN ('parse count (total)', 'execute count', 'user commits', 'user rollbacks')"
+
+[[metric]]
+context = "process"
+metricsdesc = { count = "Gauge metric with count of processes." }
+request = "SELECT COUNT(*) as count FROM v$process"
+
+[[metric]]
+context = "wait_time"
+metricsdesc = { value = "Generic counter metric from v$waitclassmetric view in Oracle." }
+fieldtoappend = "wait_class"
+request = "SELECT n.wait_class as wait_class, round(m.time_waited/m.intsize_csec, 3) as value FROM v$waitclassmetric m, v$system_wait_class n WHERE m.wait_class_id = n.wait_class_id AND n.wait_class != 'Idle'"
+
+[[metric]]
+context = "tablespace"
+labels = [ "tablespace", "type" ]
+metricsdesc = { bytes = "Generic counter metric of tablespaces bytes in Oracle.", max_bytes = "Generic counter metric of tablespaces max bytes in Oracle.", free = "Generic counter metric of tablespaces free bytes in Oracle.", used_percent = "Gauge metric showing as a percentage of how much of the tablespace has been used." }
+request = "SELECT dt.tablespace_name as tablespace, dt.contents as type, dt.block_size * dtum.used_space as bytes, dt.block_size * dtum.tablespace_size as max_bytes, dt.block_size * (dtum.tablespace_size - dtum.used_space) as free, dtum.used_percent FROM dba_tablespace_usage_metrics dtum, dba_tablespaces dt WHERE dtum.tablespace_name = dt.tablespace_name ORDER BY tablespace"
+`
@@ -3,6 +3,7 @@ package integrations
 import (
 	"fmt"
 	"reflect"
+	"regexp"
 	"strings"
 
 	"github.com/grafana/agent/pkg/integrations/config"
@@ -16,6 +17,10 @@ var (
 
 	emptyStructType = reflect.TypeOf(struct{}{})
 	configsType     = reflect.TypeOf(Configs{})
+
+	// instanceNameRegexp matches valid names of integration instances, which
+	// are used in URL paths.
+	instanceNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
 )
 
 // RegisterIntegration dynamically registers a new integration. The Config
@@ -56,6 +61,47 @@ type UnmarshaledConfig struct {
 	Common config.Common
 }
 
+// rawConfigs holds the raw YAML of the instances of an integration. An
+// integration with a single instance is represented as an object, and one
+// with multiple instances as a list of objects.
+type rawConfigs []util.RawYAML
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (r *rawConfigs) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	// The list is tried first, since a list unmarshals into the yaml.MapSlice
+	// of a single instance without an error.
+	var list []util.RawYAML
+	if err := unmarshal(&list); err == nil {
+		*r = list
+		return nil
+	}
+
+	var single util.RawYAML
+	if err := unmarshal(&single); err != nil {
+		return err
+	}
+	*r = rawConfigs{single}
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler. The raw YAML is converted to maps,
+// since yaml.v2 doesn't marshal values returned by MarshalYAML with their own
+// MarshalYAML.
+func (r rawConfigs) MarshalYAML() (interface{}, error) {
+	list := make([]yaml.MapSlice, 0, len(r))
+	for _, raw := range r {
+		ms, err := raw.Map()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, ms)
+	}
+	if len(list) == 1 {
+		return list[0], nil
+	}
+	return list, nil
+}
+
 // MarshalYAML helps implement yaml.Marshaller for structs that have a Configs
 // field that should be inlined in the YAML string.
 func MarshalYAML(v interface{}) (interface{}, error) {
@@ -105,21 +151,24 @@ func MarshalYAML(v interface{}) (interface{}, error) {
 		if err != nil {
 			return nil, fmt.Errorf("integrations: cannot marshal integration %q: %w", c.Name(), err)
 		}
-		field.Set(rawConfig)
+		if field.IsNil() {
+			field.Set(reflect.ValueOf(&rawConfigs{}))
+		}
+		raw := field.Interface().(*rawConfigs)
+		*raw = append(*raw, rawConfig)
 	}
 
 	return cfgPointer.Interface(), nil
 }
 
-// getRawIntegrationConfig turns an UnmarshaledConfig into the *util.RawYAML
+// getRawIntegrationConfig turns an UnmarshaledConfig into the util.RawYAML
 // used to represent it in configs.
-func getRawIntegrationConfig(uc UnmarshaledConfig) (v reflect.Value, err error) {
+func getRawIntegrationConfig(uc UnmarshaledConfig) (util.RawYAML, error) {
 	bb, err := util.MarshalYAMLMerged(uc.Common, uc.Config)
 	if err != nil {
-		return v, err
+		return nil, err
 	}
-	raw := util.RawYAML(bb)
-	return reflect.ValueOf(&raw), nil
+	return util.RawYAML(bb), nil
 }
 
 // UnmarshalYAML helps implement yaml.Unmarshaller for structs that have a
@@ -192,7 +241,7 @@ func unmarshalIntegrationsWithList(integrations []Config, out interface{}, unmar
 	// a Config.
 	integrationLookup := buildIntegrationsMap(integrations)
 	for i := outVal.NumField(); i < cfgVal.NumField(); i++ {
-		// Our integrations are unmarshaled as *util.RawYAML. If it's nil, we treat
+		// Our integrations are unmarshaled as *rawConfigs. If it's nil, we treat
 		// it as not defined.
 		fieldType := cfgVal.Type().Field(i)
 		field := cfgVal.Field(i)
@@ -205,20 +254,49 @@ func unmarshalIntegrationsWithList(integrations []Config, out interface{}, unmar
 		if !ok {
 			return fmt.Errorf("integration %q not registered", configName)
 		}
-		uc, err := buildUnmarshaledConfig(field.Interface().(*util.RawYAML), configReference)
-		if err != nil {
-			return fmt.Errorf("failed to unmarshal integration %q: %w", configName, err)
+
+		raw := *field.Interface().(*rawConfigs)
+		names := make(map[string]struct{}, len(raw))
+		for _, r := range raw {
+			uc, err := buildUnmarshaledConfig(r, configReference)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal integration %q: %w", configName, err)
+			}
+			if err := validateInstanceName(uc, len(raw), names); err != nil {
+				return fmt.Errorf("invalid integration %q: %w", configName, err)
+			}
+			*configs = append(*configs, uc)
 		}
-		*configs = append(*configs, uc)
 	}
 
 	return nil
 }
 
+// validateInstanceName validates the name of uc, which is one of n instances
+// of an integration. Instances must have unique names if there's more than
+// one of them. names holds the names of the previously validated instances.
+func validateInstanceName(uc UnmarshaledConfig, n int, names map[string]struct{}) error {
+	name := uc.Common.Name
+	switch {
+	case name == "" && n > 1:
+		return fmt.Errorf("name must be set for every instance when configuring multiple instances")
+	case name == "":
+		return nil
+	case !instanceNameRegexp.MatchString(name):
+		return fmt.Errorf("name %q must only contain alphanumeric characters, underscores, dashes and dots", name)
+	}
+
+	if _, exist := names[name]; exist {
+		return fmt.Errorf("multiple instances named %q", name)
+	}
+	names[name] = struct{}{}
+	return nil
+}
+
 // getConfigTypeForIntegrations returns a dynamic struct type that has all of
 // the same fields as out including the fields for the provided integrations.
 //
-// integrations are unmarshaled to *util.RawYAML for deferred unmarshaling.
+// integrations are unmarshaled to *rawConfigs for deferred unmarshaling.
 func getConfigTypeForIntegrations(integrations []Config, out reflect.Type) reflect.Type {
 	// Initial exported fields map one-to-one.
 	var fields []reflect.StructField
@@ -240,7 +318,7 @@ func getConfigTypeForIntegrations(integrations []Config, out reflect.Type) refle
 		fields = append(fields, reflect.StructField{
 			Name: fieldName,
 			Tag:  reflect.StructTag(fmt.Sprintf(`yaml:"%s,omitempty"`, cfg.Name())),
-			Type: reflect.PtrTo(reflect.TypeOf(util.RawYAML{})),
+			Type: reflect.PtrTo(reflect.TypeOf(rawConfigs{})),
 		})
 	}
 	return reflect.StructOf(fields)
@@ -256,12 +334,12 @@ func buildIntegrationsMap(in []Config) map[string]Config {
 
 // buildUnmarshaledConfig converts raw YAML into an UnmarshaledConfig where the
 // config type is the same as ref.
-func buildUnmarshaledConfig(raw *util.RawYAML, ref Config) (uc UnmarshaledConfig, err error) {
+func buildUnmarshaledConfig(raw util.RawYAML, ref Config) (uc UnmarshaledConfig, err error) {
 	// Initialize uc.Config so it can be unmarshaled properly as an interface.
 	uc = UnmarshaledConfig{
 		Config: cloneIntegration(ref),
 	}
-	err = util.UnmarshalYAMLMerged(*raw, &uc.Common, uc.Config)
+	err = util.UnmarshalYAMLMerged(raw, &uc.Common, uc.Config)
 	return
 }
 
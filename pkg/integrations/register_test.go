@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/integrations/config"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
 )
@@ -41,6 +42,48 @@ test:
 	require.Equal(t, expect, fullCfg)
 }
 
+func TestIntegrationRegistration_MultipleInstances(t *testing.T) {
+	var cfgToParse = `
+test:
+- name: first
+  text: Hello, world!
+- name: second
+  text: Goodbye, world!
+  truth: false
+`
+
+	var fullCfg testFullConfig
+	err := yaml.UnmarshalStrict([]byte(cfgToParse), &fullCfg)
+	require.NoError(t, err)
+
+	expect := Configs{
+		{Config: &testIntegrationA{Text: "Hello, world!", Truth: true}, Common: config.Common{Name: "first"}},
+		{Config: &testIntegrationA{Text: "Goodbye, world!", Truth: false}, Common: config.Common{Name: "second"}},
+	}
+	require.Equal(t, expect, fullCfg.Configs)
+}
+
+func TestIntegrationRegistration_InvalidInstanceNames(t *testing.T) {
+	tt := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{"missing name", "test:\n- name: first\n- text: unnamed", "name must be set for every instance"},
+		{"duplicate name", "test:\n- name: first\n- name: first", `multiple instances named "first"`},
+		{"invalid name", "test:\n  name: first/second", `name "first/second" must only contain`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var fullCfg testFullConfig
+			err := yaml.UnmarshalStrict([]byte(tc.config), &fullCfg)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
 type testIntegrationA struct {
 	Text  string `yaml:"text"`
 	Truth bool   `yaml:"truth"`
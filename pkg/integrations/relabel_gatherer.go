@@ -0,0 +1,71 @@
+package integrations
+
+import (
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+)
+
+// NewRelabelGatherer returns a prometheus.Gatherer which applies relabel
+// configs to every metric gathered from g. The metric name can be relabeled
+// through the __name__ label, and metrics can be dropped through relabeling.
+func NewRelabelGatherer(g prometheus.Gatherer, cfgs []*relabel.Config) prometheus.Gatherer {
+	return &relabelGatherer{g: g, cfgs: cfgs}
+}
+
+type relabelGatherer struct {
+	g    prometheus.Gatherer
+	cfgs []*relabel.Config
+}
+
+// Gather implements prometheus.Gatherer.
+func (rg *relabelGatherer) Gather() ([]*dto.MetricFamily, error) {
+	// Errors from gathering are returned along with the metrics that could be
+	// gathered, so relabeling is still applied when err is non-nil.
+	mfs, err := rg.g.Gather()
+
+	families := make(map[string]*dto.MetricFamily)
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			lset := make(labels.Labels, 0, len(m.Label)+1)
+			lset = append(lset, labels.Label{Name: model.MetricNameLabel, Value: mf.GetName()})
+			for _, lp := range m.Label {
+				lset = append(lset, labels.Label{Name: lp.GetName(), Value: lp.GetValue()})
+			}
+			sort.Sort(lset)
+
+			lset = relabel.Process(lset, rg.cfgs...)
+			if lset == nil {
+				continue
+			}
+
+			m.Label = m.Label[:0]
+			for _, l := range lset {
+				if l.Name == model.MetricNameLabel {
+					continue
+				}
+				name, value := l.Name, l.Value
+				m.Label = append(m.Label, &dto.LabelPair{Name: &name, Value: &value})
+			}
+
+			name := lset.Get(model.MetricNameLabel)
+			family, ok := families[name]
+			if !ok {
+				family = &dto.MetricFamily{Name: &name, Help: mf.Help, Type: mf.Type}
+				families[name] = family
+			}
+			family.Metric = append(family.Metric, m)
+		}
+	}
+
+	res := make([]*dto.MetricFamily, 0, len(families))
+	for _, family := range families {
+		res = append(res, family)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].GetName() < res[j].GetName() })
+	return res, err
+}
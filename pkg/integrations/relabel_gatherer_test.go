@@ -0,0 +1,55 @@
+package integrations
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestRelabelGatherer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	notAfter := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cert_not_after",
+		Help: "NotAfter expressed as a Unix Epoch Time",
+	}, []string{"cn", "dnsnames"})
+	notAfter.WithLabelValues("example.com", ",example.com,www.example.com,").Set(1)
+	notAfter.WithLabelValues("internal", ",internal,").Set(2)
+
+	success := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_success",
+		Help: "If the probe was a success",
+	})
+	success.Set(1)
+	reg.MustRegister(notAfter, success)
+
+	var cfgs []*relabel.Config
+	err := yaml.UnmarshalStrict([]byte(`
+- regex: dnsnames
+  action: labeldrop
+- source_labels: [cn]
+  regex: internal
+  action: drop
+- source_labels: [__name__]
+  regex: probe_success
+  target_label: __name__
+  replacement: ssl_probe_success
+`), &cfgs)
+	require.NoError(t, err)
+
+	expect := `
+# HELP cert_not_after NotAfter expressed as a Unix Epoch Time
+# TYPE cert_not_after gauge
+cert_not_after{cn="example.com"} 1
+# HELP ssl_probe_success If the probe was a success
+# TYPE ssl_probe_success gauge
+ssl_probe_success 1
+`
+	g := NewRelabelGatherer(reg, cfgs)
+	require.NoError(t, testutil.GatherAndCompare(g, strings.NewReader(expect)))
+}
@@ -0,0 +1,12 @@
+package script_exporter
+
+import "os/exec"
+
+// setProcessGroup is a no-op on Windows.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the process of cmd. Processes started by the
+// command keep running on Windows.
+func killProcessGroup(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+}
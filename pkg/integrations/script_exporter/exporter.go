@@ -0,0 +1,171 @@
+package script_exporter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/pkg/util/textmetrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	successDesc = prometheus.NewDesc(
+		"script_exporter_success",
+		"Whether the last run of the command succeeded and its output could be parsed.",
+		nil, nil,
+	)
+	durationDesc = prometheus.NewDesc(
+		"script_exporter_duration_seconds",
+		"Duration of the last run of the command.",
+		nil, nil,
+	)
+	exitCodeDesc = prometheus.NewDesc(
+		"script_exporter_exit_code",
+		"Exit code of the last run of the command. -1 if the command couldn't be started or was killed.",
+		nil, nil,
+	)
+	lastRunDesc = prometheus.NewDesc(
+		"script_exporter_last_run_timestamp_seconds",
+		"Timestamp of the last run of the command.",
+		nil, nil,
+	)
+)
+
+// exporter runs the command in the background and caches the metrics of the
+// most recent run.
+type exporter struct {
+	log log.Logger
+	cfg *Config
+
+	mut     sync.RWMutex
+	metrics []prometheus.Metric
+}
+
+func newExporter(l log.Logger, c *Config) *exporter {
+	return &exporter{log: l, cfg: c}
+}
+
+// Describe implements prometheus.Collector. The exporter is an unchecked
+// collector since the collected metrics depend on the output of the command.
+func (e *exporter) Describe(chan<- *prometheus.Desc) {}
+
+// Collect writes the metrics of the most recent run to ch.
+func (e *exporter) Collect(ch chan<- prometheus.Metric) {
+	e.mut.RLock()
+	defer e.mut.RUnlock()
+
+	for _, m := range e.metrics {
+		ch <- m
+	}
+}
+
+// Run runs the command every collect interval until ctx is canceled.
+func (e *exporter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.cfg.CollectInterval)
+	defer ticker.Stop()
+
+	for {
+		metrics := e.collect(ctx)
+
+		e.mut.Lock()
+		if ctx.Err() == nil {
+			e.metrics = metrics
+		}
+		e.mut.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// collect runs the command and returns the metrics of its output, along with
+// the metrics describing the run. The metrics of the output are omitted if
+// the command failed.
+func (e *exporter) collect(ctx context.Context) []prometheus.Metric {
+	start := time.Now()
+	out, exitCode, err := e.run(ctx)
+	duration := time.Since(start)
+
+	var metrics []prometheus.Metric
+	if err == nil {
+		metrics, err = textmetrics.Parse(bytes.NewReader(out))
+		if err != nil {
+			err = fmt.Errorf("failed to parse output: %w", err)
+		}
+	}
+
+	success := 1.0
+	if err != nil {
+		if ctx.Err() == nil {
+			level.Warn(e.log).Log("msg", "failed to collect metrics from command", "command", e.cfg.Command, "err", err)
+		}
+		metrics, success = nil, 0
+	}
+	return append(metrics,
+		prometheus.MustNewConstMetric(successDesc, prometheus.GaugeValue, success),
+		prometheus.MustNewConstMetric(durationDesc, prometheus.GaugeValue, duration.Seconds()),
+		prometheus.MustNewConstMetric(exitCodeDesc, prometheus.GaugeValue, float64(exitCode)),
+		prometheus.MustNewConstMetric(lastRunDesc, prometheus.GaugeValue, float64(start.UnixNano())/1e9),
+	)
+}
+
+// run runs the command and returns its stdout and exit code.
+func (e *exporter) run(ctx context.Context) ([]byte, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.cfg.Timeout)
+	defer cancel()
+
+	cmd := exec.Command(e.cfg.Command, e.cfg.Args...)
+	cmd.Dir = e.cfg.WorkingDir
+	if len(e.cfg.Env) > 0 {
+		cmd.Env = os.Environ()
+		for k, v := range e.cfg.Env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	// Kill the whole process group when the command times out, since Wait
+	// doesn't return while processes started by the command still hold its
+	// output open.
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, -1, err
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(cmd)
+		case <-done:
+		}
+	}()
+	err := cmd.Wait()
+	close(done)
+
+	exitCode := cmd.ProcessState.ExitCode()
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return nil, exitCode, fmt.Errorf("command timed out after %s", e.cfg.Timeout)
+	case err != nil:
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			err = fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return nil, exitCode, err
+	}
+	return stdout.Bytes(), exitCode, nil
+}
@@ -0,0 +1,99 @@
+// Package script_exporter runs commands and collects the metrics they print
+// in the Prometheus text exposition format.
+package script_exporter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/integrations"
+	integrations_v2 "github.com/grafana/agent/pkg/integrations/v2"
+	"github.com/grafana/agent/pkg/integrations/v2/metricsutils"
+)
+
+// DefaultConfig holds the default settings for the script_exporter
+// integration.
+var DefaultConfig = Config{
+	CollectInterval: time.Minute,
+	Timeout:         30 * time.Second,
+}
+
+// Config controls the script_exporter integration.
+type Config struct {
+	// Command is the path of the executable to run, which is looked up in
+	// PATH if it doesn't contain a path separator.
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args,omitempty"`
+
+	// WorkingDir is the working directory of the command. Defaults to the
+	// working directory of the agent.
+	WorkingDir string `yaml:"working_dir,omitempty"`
+
+	// Env holds environment variables set for the command in addition to the
+	// environment of the agent.
+	Env map[string]string `yaml:"env,omitempty"`
+
+	// CollectInterval is how often the command is run. Scrapes return the
+	// metrics of the most recent run.
+	CollectInterval time.Duration `yaml:"collect_interval,omitempty"`
+
+	// Timeout is how long the command may run before it's killed.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for Config.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultConfig
+
+	type plain Config
+	return unmarshal((*plain)(c))
+}
+
+// Name returns the name of the integration that this config represents.
+func (c *Config) Name() string {
+	return "script_exporter"
+}
+
+// InstanceKey returns the hostname:port of the agent.
+func (c *Config) InstanceKey(agentKey string) (string, error) {
+	return agentKey, nil
+}
+
+// NewIntegration creates a new script_exporter integration.
+func (c *Config) NewIntegration(l log.Logger) (integrations.Integration, error) {
+	return New(l, c)
+}
+
+func init() {
+	integrations.RegisterIntegration(&Config{})
+	integrations_v2.RegisterLegacy(&Config{}, integrations_v2.TypeMultiplex, metricsutils.NewNamedShim("script"))
+}
+
+// Validate returns an error if c is invalid.
+func (c *Config) Validate() error {
+	if c.Command == "" {
+		return fmt.Errorf("command must be set")
+	}
+	if c.CollectInterval <= 0 || c.Timeout <= 0 {
+		return fmt.Errorf("collect_interval and timeout must be greater than zero")
+	}
+	if c.Timeout > c.CollectInterval {
+		return fmt.Errorf("timeout must not be greater than collect_interval")
+	}
+	return nil
+}
+
+// New creates a new script_exporter integration.
+func New(l log.Logger, c *Config) (integrations.Integration, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	e := newExporter(l, c)
+	return integrations.NewCollectorIntegration(
+		c.Name(),
+		integrations.WithCollectors(e),
+		integrations.WithRunner(e.Run),
+	), nil
+}
@@ -0,0 +1,111 @@
+package script_exporter
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tt := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{"valid", "command: /usr/local/bin/check.sh\nargs: [--verbose]\ntimeout: 10s", ""},
+		{"missing command", "args: [--verbose]", "command must be set"},
+		{"timeout greater than interval", "command: check.sh\ncollect_interval: 10s", "timeout must not be greater than collect_interval"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var c Config
+			require.NoError(t, yaml.UnmarshalStrict([]byte(tc.config), &c))
+
+			err := c.Validate()
+			if tc.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
+
+func TestExporter_Collect(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test requires sh")
+	}
+
+	tt := []struct {
+		name     string
+		script   string
+		timeout  time.Duration
+		success  float64
+		exitCode float64
+		metric   string
+	}{
+		{
+			name:     "success",
+			script:   `echo "# HELP check_value Value of the check."; echo "check_value{check=\"$CHECK\"} 42"`,
+			success:  1,
+			exitCode: 0,
+			metric:   `check_value{check="disk"} 42`,
+		},
+		{
+			name:     "failure",
+			script:   `echo "check_value 42"; echo "disk not mounted" >&2; exit 3`,
+			success:  0,
+			exitCode: 3,
+		},
+		{
+			name:     "timeout",
+			script:   `sleep 10`,
+			timeout:  100 * time.Millisecond,
+			success:  0,
+			exitCode: -1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := DefaultConfig
+			cfg.Command = "sh"
+			cfg.Args = []string{"-c", tc.script}
+			cfg.Env = map[string]string{"CHECK": "disk"}
+			if tc.timeout > 0 {
+				cfg.Timeout = tc.timeout
+			}
+			require.NoError(t, cfg.Validate())
+
+			e := newExporter(log.NewNopLogger(), &cfg)
+			e.metrics = e.collect(context.Background())
+
+			expect := `
+# HELP script_exporter_exit_code Exit code of the last run of the command. -1 if the command couldn't be started or was killed.
+# TYPE script_exporter_exit_code gauge
+script_exporter_exit_code ` + strconv.FormatFloat(tc.exitCode, 'g', -1, 64) + `
+# HELP script_exporter_success Whether the last run of the command succeeded and its output could be parsed.
+# TYPE script_exporter_success gauge
+script_exporter_success ` + strconv.FormatFloat(tc.success, 'g', -1, 64) + `
+`
+			require.NoError(t, testutil.CollectAndCompare(e, strings.NewReader(expect), "script_exporter_success", "script_exporter_exit_code"))
+
+			if tc.metric != "" {
+				expect := "# HELP check_value Value of the check.\n# TYPE check_value untyped\n" + tc.metric + "\n"
+				require.NoError(t, testutil.CollectAndCompare(e, strings.NewReader(expect), "check_value"))
+			} else {
+				require.Zero(t, testutil.CollectAndCount(e, "check_value"))
+			}
+		})
+	}
+}
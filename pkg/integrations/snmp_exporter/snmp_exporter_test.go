@@ -0,0 +1,24 @@
+package snmp_exporter
+
+import (
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrapeConfigs_TargetParams(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.SnmpTargets = []SNMPTarget{
+		{Name: "switch", Target: "192.168.1.2", Module: "if_mib", WalkParams: "private"},
+		{Name: "router", Target: "192.168.1.3"},
+	}
+
+	i, err := New(log.NewNopLogger(), &cfg)
+	require.NoError(t, err)
+
+	scrapeConfigs := i.ScrapeConfigs()
+	require.Len(t, scrapeConfigs, 2)
+	require.Equal(t, "module=if_mib&target=192.168.1.2&walk_params=private", scrapeConfigs[0].QueryParams.Encode())
+	require.Equal(t, "target=192.168.1.3", scrapeConfigs[1].QueryParams.Encode())
+}
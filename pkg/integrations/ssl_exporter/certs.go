@@ -0,0 +1,216 @@
+package ssl_exporter
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// certRecorder records the certificates collected by a probe, so they can be
+// served by the certificates API. A nil certRecorder records nothing.
+type certRecorder struct {
+	// source holds labels which are added to the source of every
+	// certificate recorded through this recorder.
+	source map[string]string
+	list   *recordedCerts
+
+	// next also records every certificate recorded through this recorder,
+	// if non-nil.
+	next *certRecorder
+}
+
+type recordedCerts struct {
+	mut   sync.Mutex
+	certs []recordedCert
+}
+
+// recordedCert is a certificate collected by a probe. source describes where
+// the certificate was found, using the labels of its metrics, like the file
+// or secret. position is the index of the certificate in its chain.
+type recordedCert struct {
+	source   map[string]string
+	position int
+	cert     *x509.Certificate
+}
+
+func newCertRecorder() *certRecorder {
+	return &certRecorder{list: &recordedCerts{}}
+}
+
+// withSource returns a recorder which records to the same certificates as r,
+// adding the label name with value to the source of every certificate.
+func (r *certRecorder) withSource(name, value string) *certRecorder {
+	source := make(map[string]string, len(r.source)+1)
+	for k, v := range r.source {
+		source[k] = v
+	}
+	source[name] = value
+	return &certRecorder{source: source, list: r.list, next: r.next}
+}
+
+// tee returns a recorder which records to both r and other. r may be nil,
+// in which case other is returned.
+func (r *certRecorder) tee(other *certRecorder) *certRecorder {
+	if r == nil {
+		return other
+	}
+	if r.next != nil {
+		other = other.tee(r.next)
+	}
+	return &certRecorder{source: r.source, list: r.list, next: other}
+}
+
+// record records cert at position in the chain found at source.
+func (r *certRecorder) record(source map[string]string, position int, cert *x509.Certificate) {
+	if r == nil {
+		return
+	}
+
+	merged := make(map[string]string, len(r.source)+len(source))
+	for k, v := range r.source {
+		merged[k] = v
+	}
+	for k, v := range source {
+		merged[k] = v
+	}
+	r.next.record(source, position, cert)
+
+	r.list.mut.Lock()
+	defer r.list.mut.Unlock()
+	r.list.certs = append(r.list.certs, recordedCert{source: merged, position: position, cert: cert})
+}
+
+// certificates returns the recorded certificates in the order they were
+// recorded. It returns nil if r is nil.
+func (r *certRecorder) certificates() []recordedCert {
+	if r == nil {
+		return nil
+	}
+	r.list.mut.Lock()
+	defer r.list.mut.Unlock()
+	return append([]recordedCert(nil), r.list.certs...)
+}
+
+type certRecorderKey struct{}
+
+// withCertRecorder returns a context which makes probers record the
+// certificates they collect to r.
+func withCertRecorder(ctx context.Context, r *certRecorder) context.Context {
+	return context.WithValue(ctx, certRecorderKey{}, r)
+}
+
+// certRecorderFrom returns the certRecorder of ctx, or nil if ctx has none.
+func certRecorderFrom(ctx context.Context) *certRecorder {
+	r, _ := ctx.Value(certRecorderKey{}).(*certRecorder)
+	return r
+}
+
+// recordCert records cert with the certRecorder of ctx, if any.
+func recordCert(ctx context.Context, source map[string]string, position int, cert *x509.Certificate) {
+	certRecorderFrom(ctx).record(source, position, cert)
+}
+
+// certDetails describes a certificate served by the certificates API.
+type certDetails struct {
+	Source             map[string]string `json:"source,omitempty"`
+	ChainPosition      int               `json:"chain_position"`
+	Subject            string            `json:"subject"`
+	Issuer             string            `json:"issuer"`
+	SerialNumber       string            `json:"serial_number"`
+	DNSNames           []string          `json:"dns_names,omitempty"`
+	IPAddresses        []string          `json:"ip_addresses,omitempty"`
+	EmailAddresses     []string          `json:"email_addresses,omitempty"`
+	URIs               []string          `json:"uris,omitempty"`
+	NotBefore          time.Time         `json:"not_before"`
+	NotAfter           time.Time         `json:"not_after"`
+	IsCA               bool              `json:"is_ca"`
+	PublicKeyAlgorithm string            `json:"public_key_algorithm"`
+	SignatureAlgorithm string            `json:"signature_algorithm"`
+	FingerprintSHA1    string            `json:"fingerprint_sha1"`
+	FingerprintSHA256  string            `json:"fingerprint_sha256"`
+}
+
+func newCertDetails(rc recordedCert) certDetails {
+	cert := rc.cert
+
+	var ips, uris []string
+	for _, ip := range cert.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		uris = append(uris, uri.String())
+	}
+	sha1Sum := sha1.Sum(cert.Raw)
+
+	return certDetails{
+		Source:             rc.source,
+		ChainPosition:      rc.position,
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SerialNumber:       cert.SerialNumber.String(),
+		DNSNames:           cert.DNSNames,
+		IPAddresses:        ips,
+		EmailAddresses:     cert.EmailAddresses,
+		URIs:               uris,
+		NotBefore:          cert.NotBefore.UTC(),
+		NotAfter:           cert.NotAfter.UTC(),
+		IsCA:               cert.IsCA,
+		PublicKeyAlgorithm: cert.PublicKeyAlgorithm.String(),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		FingerprintSHA1:    hex.EncodeToString(sha1Sum[:]),
+		FingerprintSHA256:  fingerprintSHA256(cert),
+	}
+}
+
+// targetCerts is the response of the certificates API.
+type targetCerts struct {
+	Name         string        `json:"name"`
+	Target       string        `json:"target"`
+	LastProbe    time.Time     `json:"last_probe"`
+	Certificates []certDetails `json:"certificates"`
+}
+
+// certsHandler returns the certificates collected by the most recent probe of
+// the configured target named by the name route variable.
+func (e *Exporter) certsHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var (
+		target SSLTarget
+		found  bool
+	)
+	for _, t := range e.targets() {
+		if t.Name == name {
+			target, found = t, true
+			break
+		}
+	}
+	if !found {
+		http.Error(w, "target not found", http.StatusNotFound)
+		return
+	}
+
+	e.statusMut.RLock()
+	status := e.statuses[name]
+	e.statusMut.RUnlock()
+
+	resp := targetCerts{
+		Name:         target.Name,
+		Target:       target.Target,
+		LastProbe:    status.LastProbe,
+		Certificates: make([]certDetails, 0, len(status.certs)),
+	}
+	for _, rc := range status.certs {
+		resp.Certificates = append(resp.Certificates, newCertDetails(rc))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,131 @@
+package ssl_exporter
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	config_util "github.com/prometheus/common/config"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// ClientCertConfig configures the client certificate presented to a target
+// which requires mutual TLS. The certificate and key can be set inline, read
+// from files, or read from a Kubernetes secret. Exactly one of these sources
+// must be used.
+type ClientCertConfig struct {
+	// Cert and Key hold the PEM-encoded certificate and key.
+	Cert string             `yaml:"cert,omitempty"`
+	Key  config_util.Secret `yaml:"key,omitempty"`
+
+	// CertFile and KeyFile are paths to the PEM-encoded certificate and key,
+	// or references to secrets holding them, like vault:<path>#<field>.
+	CertFile string `yaml:"cert_file,omitempty"`
+	KeyFile  string `yaml:"key_file,omitempty"`
+
+	// KubernetesSecret references a kubernetes.io/tls secret holding the
+	// certificate and key.
+	KubernetesSecret *KubernetesSecretRef `yaml:"kubernetes_secret,omitempty"`
+}
+
+// KubernetesSecretRef references a kubernetes.io/tls secret.
+type KubernetesSecretRef struct {
+	Namespace string `yaml:"namespace"`
+	Name      string `yaml:"name"`
+
+	// Kubeconfig is the path of the kubeconfig used to connect to
+	// Kubernetes. If empty, the KUBECONFIG environment variable, the default
+	// kubeconfig location, or the in-cluster config is used.
+	Kubeconfig string `yaml:"kubeconfig,omitempty"`
+}
+
+// Validate ensures that exactly one source of the client certificate is
+// configured.
+func (c *ClientCertConfig) Validate() error {
+	var (
+		inline  = c.Cert != "" || c.Key != ""
+		files   = c.CertFile != "" || c.KeyFile != ""
+		secret  = c.KubernetesSecret != nil
+		sources int
+	)
+	for _, set := range []bool{inline, files, secret} {
+		if set {
+			sources++
+		}
+	}
+
+	switch {
+	case sources == 0:
+		return fmt.Errorf("one of cert and key, cert_file and key_file, or kubernetes_secret must be set")
+	case sources > 1:
+		return fmt.Errorf("only one of cert and key, cert_file and key_file, or kubernetes_secret may be set")
+	case inline && (c.Cert == "" || c.Key == ""):
+		return fmt.Errorf("cert and key must be set together")
+	case files && (c.CertFile == "" || c.KeyFile == ""):
+		return fmt.Errorf("cert_file and key_file must be set together")
+	case secret && (c.KubernetesSecret.Namespace == "" || c.KubernetesSecret.Name == ""):
+		return fmt.Errorf("kubernetes_secret requires namespace and name to be set")
+	}
+	for _, file := range []string{c.CertFile, c.KeyFile} {
+		if _, _, err := parseSecretRef(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load loads the client certificate, resolving secret references with
+// secrets. The certificate is loaded on every probe so that rotated
+// certificates are picked up.
+func (c *ClientCertConfig) load(ctx context.Context, secrets *secretResolver) (tls.Certificate, error) {
+	switch {
+	case c.CertFile != "":
+		return loadKeyPair(ctx, secrets, c.CertFile, c.KeyFile)
+	case c.KubernetesSecret != nil:
+		client, err := newKubeClient(c.KubernetesSecret.Kubeconfig)
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		return loadSecretCertificate(ctx, client, c.KubernetesSecret)
+	default:
+		return tls.X509KeyPair([]byte(c.Cert), []byte(c.Key))
+	}
+}
+
+// loadSecretCertificate loads the certificate and key from the secret
+// referenced by ref.
+func loadSecretCertificate(ctx context.Context, client kubernetes.Interface, ref *KubernetesSecretRef) (tls.Certificate, error) {
+	secret, err := client.CoreV1().Secrets(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if secret.Type != v1.SecretTypeTLS {
+		return tls.Certificate{}, fmt.Errorf("secret %s/%s is of type %q, expected %q", ref.Namespace, ref.Name, secret.Type, v1.SecretTypeTLS)
+	}
+	return tls.X509KeyPair(secret.Data[v1.TLSCertKey], secret.Data[v1.TLSPrivateKeyKey])
+}
+
+// newKubeClient returns a Kubernetes client (clientset) from the supplied
+// kubeconfig path, the KUBECONFIG environment variable, the default config file
+// location ($HOME/.kube/config) or from the in-cluster service account environment.
+//
+// Copied from github.com/ribbybibby/ssl_exporter/v2/prober.
+func newKubeClient(path string) (*kubernetes.Clientset, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if path != "" {
+		loadingRules.ExplicitPath = path
+	}
+	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		loadingRules,
+		&clientcmd.ConfigOverrides{},
+	)
+	config, err := kubeConfig.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return kubernetes.NewForConfig(config)
+}
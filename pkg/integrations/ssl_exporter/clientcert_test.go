@@ -0,0 +1,150 @@
+package ssl_exporter
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	config_util "github.com/prometheus/common/config"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestClientCertConfig_Validate(t *testing.T) {
+	tt := []struct {
+		name   string
+		cfg    ClientCertConfig
+		expect string
+	}{
+		{"inline", ClientCertConfig{Cert: "cert", Key: "key"}, ""},
+		{"files", ClientCertConfig{CertFile: "cert.pem", KeyFile: "key.pem"}, ""},
+		{"secret", ClientCertConfig{KubernetesSecret: &KubernetesSecretRef{Namespace: "default", Name: "client"}}, ""},
+		{"empty", ClientCertConfig{}, "one of cert and key, cert_file and key_file, or kubernetes_secret must be set"},
+		{"multiple", ClientCertConfig{Cert: "cert", Key: "key", CertFile: "cert.pem", KeyFile: "key.pem"}, "only one of cert and key, cert_file and key_file, or kubernetes_secret may be set"},
+		{"missing key", ClientCertConfig{Cert: "cert"}, "cert and key must be set together"},
+		{"missing key file", ClientCertConfig{CertFile: "cert.pem"}, "cert_file and key_file must be set together"},
+		{"incomplete secret", ClientCertConfig{KubernetesSecret: &KubernetesSecretRef{Name: "client"}}, "kubernetes_secret requires namespace and name to be set"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.Validate()
+			if tc.expect == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expect)
+			}
+		})
+	}
+}
+
+func TestExporter_ClientCert(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	certPEM, keyPEM := generateKeyPair(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0600))
+
+	e, err := NewSSLExporter(Options{
+		Namespace: "ssl_exporter",
+		SSLConfig: &ssl_config.Config{
+			Modules: map[string]ssl_config.Module{
+				"https": {Prober: "https", TLSConfig: ssl_config.TLSConfig{InsecureSkipVerify: true}},
+			},
+		},
+		DefaultModule:       "https",
+		MaxConcurrentProbes: 1,
+		log:                 log.NewNopLogger(),
+	})
+	require.NoError(t, err)
+
+	target := srv.Listener.Addr().String()
+
+	_, err = e.probe(context.Background(), SSLTarget{Name: "server", Target: target})
+	require.Error(t, err, "probe without a client certificate should fail")
+
+	_, err = e.probe(context.Background(), SSLTarget{
+		Name:       "server",
+		Target:     target,
+		ClientCert: &ClientCertConfig{Cert: string(certPEM), Key: config_util.Secret(keyPEM)},
+	})
+	require.NoError(t, err)
+
+	_, err = e.probe(context.Background(), SSLTarget{
+		Name:       "server",
+		Target:     target,
+		ClientCert: &ClientCertConfig{CertFile: certFile, KeyFile: keyFile},
+	})
+	require.NoError(t, err)
+}
+
+func TestLoadSecretCertificate(t *testing.T) {
+	certPEM, keyPEM := generateKeyPair(t)
+
+	client := fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "client"},
+			Type:       v1.SecretTypeTLS,
+			Data:       map[string][]byte{v1.TLSCertKey: certPEM, v1.TLSPrivateKeyKey: keyPEM},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "opaque"},
+			Type:       v1.SecretTypeOpaque,
+		},
+	)
+
+	cert, err := loadSecretCertificate(context.Background(), client, &KubernetesSecretRef{Namespace: "default", Name: "client"})
+	require.NoError(t, err)
+	require.Len(t, cert.Certificate, 1)
+
+	_, err = loadSecretCertificate(context.Background(), client, &KubernetesSecretRef{Namespace: "default", Name: "opaque"})
+	require.EqualError(t, err, `secret default/opaque is of type "Opaque", expected "kubernetes.io/tls"`)
+
+	_, err = loadSecretCertificate(context.Background(), client, &KubernetesSecretRef{Namespace: "default", Name: "missing"})
+	require.Error(t, err)
+}
+
+// generateKeyPair returns a PEM-encoded self-signed certificate and its key.
+func generateKeyPair(t *testing.T) (certPEM, keyPEM []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM
+}
@@ -0,0 +1,69 @@
+package ssl_exporter
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io/ioutil"
+	"time"
+
+	"github.com/go-kit/log/level"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+)
+
+// sslConfig returns the current SSL config.
+func (e *Exporter) sslConfig() *ssl_config.Config {
+	e.sslConfigMut.RLock()
+	defer e.sslConfigMut.RUnlock()
+	return e.loadedSSLConfig
+}
+
+// watchConfigFile reloads the SSL config whenever the contents of
+// Options.ConfigFile change, until ctx is canceled. An invalid file is
+// logged and the previous config is kept.
+func (e *Exporter) watchConfigFile(ctx context.Context) error {
+	ticker := time.NewTicker(e.options.ConfigReloadInterval)
+	defer ticker.Stop()
+
+	// The config passed to the exporter was loaded when the exporter was
+	// created, so only changes from that point on need to be applied.
+	lastHash := e.configHash
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		hash, err := hashFile(e.options.ConfigFile)
+		if err != nil {
+			level.Error(e.options.log).Log("msg", "failed to read ssl config file", "file", e.options.ConfigFile, "err", err)
+			continue
+		} else if bytes.Equal(hash, lastHash) {
+			continue
+		}
+
+		cfg, err := ssl_config.LoadConfig(e.options.ConfigFile)
+		if err != nil {
+			level.Error(e.options.log).Log("msg", "failed to reload ssl config file, keeping previous config", "file", e.options.ConfigFile, "err", err)
+		} else {
+			e.sslConfigMut.Lock()
+			e.loadedSSLConfig = cfg
+			e.sslConfigMut.Unlock()
+			level.Info(e.options.log).Log("msg", "reloaded ssl config file", "file", e.options.ConfigFile)
+		}
+
+		// Don't retry loading an invalid file until it changes again.
+		lastHash = hash
+	}
+}
+
+func hashFile(filename string) ([]byte, error) {
+	bb, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.Sum256(bb)
+	return h[:], nil
+}
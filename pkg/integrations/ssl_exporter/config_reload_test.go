@@ -0,0 +1,56 @@
+package ssl_exporter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExporter_WatchConfigFile(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "ssl.yml")
+	writeConfig := func(contents string) {
+		require.NoError(t, os.WriteFile(configFile, []byte(contents), 0600))
+	}
+	writeConfig(`
+modules:
+  first:
+    prober: file
+`)
+
+	cfg, err := ssl_config.LoadConfig(configFile)
+	require.NoError(t, err)
+
+	e, err := NewSSLExporter(Options{
+		Namespace:            "ssl_exporter",
+		SSLConfig:            cfg,
+		ConfigFile:           configFile,
+		ConfigReloadInterval: 10 * time.Millisecond,
+		log:                  log.NewNopLogger(),
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = e.watchConfigFile(ctx) }()
+
+	writeConfig(`
+modules:
+  second:
+    prober: file
+`)
+	require.Eventually(t, func() bool {
+		_, ok := e.sslConfig().Modules["second"]
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// An invalid file should not replace the current config.
+	writeConfig(`modules: [`)
+	time.Sleep(100 * time.Millisecond)
+	require.Contains(t, e.sslConfig().Modules, "second")
+}
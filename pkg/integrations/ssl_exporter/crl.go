@@ -0,0 +1,181 @@
+package ssl_exporter
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// crlCacheDuration is the longest time a CRL is cached for. CRLs are
+	// fetched again earlier when their next update is due.
+	crlCacheDuration = time.Hour
+
+	// maxCRLSize limits the size of fetched CRLs.
+	maxCRLSize = 32 << 20
+)
+
+// crlCache fetches and caches the CRLs used for checking whether
+// certificates are revoked.
+type crlCache struct {
+	client *http.Client
+
+	mut     sync.Mutex
+	entries map[string]crlEntry
+}
+
+type crlEntry struct {
+	list    *pkix.CertificateList
+	expires time.Time
+}
+
+func newCRLCache() *crlCache {
+	return &crlCache{
+		client:  &http.Client{},
+		entries: make(map[string]crlEntry),
+	}
+}
+
+// get returns the CRL at url, fetching it if it isn't cached.
+func (c *crlCache) get(ctx context.Context, url string) (*pkix.CertificateList, error) {
+	c.mut.Lock()
+	entry, ok := c.entries[url]
+	c.mut.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.list, nil
+	}
+
+	list, err := c.fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	expires := time.Now().Add(crlCacheDuration)
+	if next := list.TBSCertList.NextUpdate; next.After(time.Now()) && next.Before(expires) {
+		expires = next
+	}
+
+	c.mut.Lock()
+	c.entries[url] = crlEntry{list: list, expires: expires}
+	c.mut.Unlock()
+	return list, nil
+}
+
+func (c *crlCache) fetch(ctx context.Context, url string) (*pkix.CertificateList, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d fetching CRL %s", resp.StatusCode, url)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCRLSize))
+	if err != nil {
+		return nil, err
+	}
+	//nolint:staticcheck // x509.ParseRevocationList requires Go 1.19.
+	return x509.ParseCRL(data)
+}
+
+// collectMetrics checks the certificates presented over the connection
+// against the CRLs of their HTTP distribution points. Certificates are
+// checked against the first CRL which could be fetched and, when the issuer
+// of the certificate is known, whose signature is valid. Certificates
+// without a usable CRL are skipped.
+func (c *crlCache) collectMetrics(ctx context.Context, state tls.ConnectionState, registry *prometheus.Registry) error {
+	var (
+		certRevoked = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "cert_revoked"),
+				Help: "If the certificate is listed in the CRL of its issuer",
+			},
+			[]string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		)
+		crlNextUpdate = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "crl_next_update"),
+				Help: "The nextUpdate value in the CRL, expressed as a Unix Epoch Time",
+			},
+			[]string{"url"},
+		)
+		crlFetchSuccess = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "crl_fetch_success"),
+				Help: "If the CRL was fetched and its signature is valid",
+			},
+			[]string{"url"},
+		)
+	)
+	registry.MustRegister(certRevoked, crlNextUpdate, crlFetchSuccess)
+
+	certs := uniq(state.PeerCertificates)
+	for i, cert := range certs {
+		issuer := findIssuer(cert, certs, state.VerifiedChains)
+
+		for _, url := range cert.CRLDistributionPoints {
+			if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+				continue
+			}
+
+			list, err := c.get(ctx, url)
+			if err == nil && issuer != nil {
+				err = issuer.CheckCRLSignature(list)
+			}
+			if err != nil {
+				crlFetchSuccess.WithLabelValues(url).Set(0)
+				continue
+			}
+			crlFetchSuccess.WithLabelValues(url).Set(1)
+
+			if next := list.TBSCertList.NextUpdate; !next.IsZero() {
+				crlNextUpdate.WithLabelValues(url).Set(float64(next.Unix()))
+			}
+
+			var revoked float64
+			for _, rc := range list.TBSCertList.RevokedCertificates {
+				if rc.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+					revoked = 1
+					break
+				}
+			}
+			certRevoked.WithLabelValues(labelValues(cert, i)...).Set(revoked)
+			break
+		}
+	}
+
+	return nil
+}
+
+// findIssuer returns the certificate which signed cert from the peer
+// certificates or verified chains of a connection, or nil if it isn't
+// found.
+func findIssuer(cert *x509.Certificate, certs []*x509.Certificate, verifiedChains [][]*x509.Certificate) *x509.Certificate {
+	candidates := append([]*x509.Certificate{}, certs...)
+	for _, chain := range verifiedChains {
+		candidates = append(candidates, chain...)
+	}
+	for _, c := range candidates {
+		if c == cert {
+			continue
+		}
+		if cert.CheckSignatureFrom(c) == nil {
+			return c
+		}
+	}
+	return nil
+}
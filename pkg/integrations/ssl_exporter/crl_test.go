@@ -0,0 +1,97 @@
+package ssl_exporter
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCRLCache_CollectMetrics(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ca := selfSignedCert(t, caKey, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	})
+
+	nextUpdate := time.Now().Add(time.Hour).Truncate(time.Second)
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now(),
+		NextUpdate: nextUpdate,
+		RevokedCertificates: []pkix.RevokedCertificate{
+			{SerialNumber: big.NewInt(2), RevocationTime: time.Now()},
+		},
+	}, ca, caKey)
+	require.NoError(t, err)
+
+	var fetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		_, _ = w.Write(crl)
+	}))
+	defer srv.Close()
+
+	issue := func(serial int64) *x509.Certificate {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               pkix.Name{CommonName: "example.com"},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			CRLDistributionPoints: []string{srv.URL + "/ca.crl"},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		require.NoError(t, err)
+		cert, err := x509.ParseCertificate(der)
+		require.NoError(t, err)
+		return cert
+	}
+
+	cache := newCRLCache()
+	tt := []struct {
+		name    string
+		serial  int64
+		revoked float64
+	}{
+		{"valid", 1, 0},
+		{"revoked", 2, 1},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			state := tls.ConnectionState{PeerCertificates: []*x509.Certificate{issue(tc.serial), ca}}
+			require.NoError(t, cache.collectMetrics(context.Background(), state, reg))
+
+			values := make(map[string]float64)
+			fams, err := reg.Gather()
+			require.NoError(t, err)
+			for _, mf := range fams {
+				require.Len(t, mf.GetMetric(), 1, mf.GetName())
+				values[mf.GetName()] = mf.GetMetric()[0].GetGauge().GetValue()
+			}
+			require.Equal(t, tc.revoked, values["ssl_cert_revoked"])
+			require.Equal(t, float64(1), values["ssl_crl_fetch_success"])
+			require.Equal(t, float64(nextUpdate.Unix()), values["ssl_crl_next_update"])
+		})
+	}
+
+	// The CRL should be cached until its next update.
+	require.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+}
@@ -0,0 +1,180 @@
+package ssl_exporter
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/discovery/dns"
+	"github.com/prometheus/prometheus/discovery/file"
+	"github.com/prometheus/prometheus/discovery/kubernetes"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+)
+
+const (
+	// moduleLabel may be set through relabeling to choose the module used
+	// for probing a discovered target.
+	moduleLabel = "__param_module"
+
+	ingressSchemeLabel = "__meta_kubernetes_ingress_scheme"
+)
+
+// DiscoveryConfig configures the discovery of SSL targets.
+type DiscoveryConfig struct {
+	// Module used for probing discovered targets, unless overridden by
+	// setting the __param_module label through relabeling.
+	Module string `yaml:"module,omitempty"`
+
+	KubernetesSDConfigs []*kubernetes.SDConfig `yaml:"kubernetes_sd_configs,omitempty"`
+	FileSDConfigs       []*file.SDConfig       `yaml:"file_sd_configs,omitempty"`
+	DNSSDConfigs        []*dns.SDConfig        `yaml:"dns_sd_configs,omitempty"`
+
+	// RelabelConfigs are applied to discovered targets before they're
+	// probed. Targets can be dropped through relabeling.
+	RelabelConfigs []*relabel.Config `yaml:"relabel_configs,omitempty"`
+}
+
+// Enabled returns true when at least one discovery mechanism is configured.
+func (c DiscoveryConfig) Enabled() bool {
+	return len(c.sdConfigs()) > 0
+}
+
+func (c DiscoveryConfig) sdConfigs() discovery.Configs {
+	var res discovery.Configs
+	for _, sd := range c.KubernetesSDConfigs {
+		res = append(res, sd)
+	}
+	for _, sd := range c.FileSDConfigs {
+		res = append(res, sd)
+	}
+	for _, sd := range c.DNSSDConfigs {
+		res = append(res, sd)
+	}
+	return res
+}
+
+// discoverer runs service discovery and converts discovered target groups
+// into SSL targets.
+type discoverer struct {
+	log      log.Logger
+	cfg      DiscoveryConfig
+	onUpdate func([]SSLTarget)
+}
+
+// Run runs discovery until ctx is canceled. onUpdate is invoked with the full
+// set of discovered targets every time it changes.
+func (d *discoverer) Run(ctx context.Context) error {
+	mgr := discovery.NewManager(ctx, d.log, discovery.Name("ssl_exporter"))
+	err := mgr.ApplyConfig(map[string]discovery.Configs{
+		"ssl_exporter": d.cfg.sdConfigs(),
+	})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := mgr.Run(); err != nil {
+			level.Error(d.log).Log("msg", "service discovery exited with error", "err", err)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case tgs := <-mgr.SyncCh():
+			d.onUpdate(d.targets(tgs))
+		}
+	}
+}
+
+// targets converts discovered target groups into SSL targets. The resulting
+// targets are named after their address, and targets with duplicate
+// addresses are only returned once.
+func (d *discoverer) targets(tgs map[string][]*targetgroup.Group) []SSLTarget {
+	var (
+		seen = make(map[string]struct{})
+		res  []SSLTarget
+	)
+
+	for _, groups := range tgs {
+		for _, group := range groups {
+			if group == nil {
+				continue
+			}
+
+			for _, t := range group.Targets {
+				target, ok := d.target(group.Labels.Merge(t))
+				if !ok {
+					continue
+				}
+				if _, exist := seen[target.Name]; exist {
+					continue
+				}
+				seen[target.Name] = struct{}{}
+				res = append(res, target)
+			}
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+func (d *discoverer) target(discovered model.LabelSet) (SSLTarget, bool) {
+	// Only hosts which are served over TLS are of interest for ingresses.
+	// Ingress targets don't include a port, so the default HTTPS port is
+	// used.
+	if scheme, ok := discovered[ingressSchemeLabel]; ok {
+		if scheme != "https" {
+			return SSLTarget{}, false
+		}
+		addr := string(discovered[model.AddressLabel])
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			discovered = discovered.Clone()
+			discovered[model.AddressLabel] = model.LabelValue(net.JoinHostPort(addr, "443"))
+		}
+	}
+
+	// Hostnames from DNS records are fully qualified and end with a dot,
+	// which isn't valid for verifying certificates against.
+	if host, port, err := net.SplitHostPort(string(discovered[model.AddressLabel])); err == nil && strings.HasSuffix(host, ".") {
+		discovered = discovered.Clone()
+		discovered[model.AddressLabel] = model.LabelValue(net.JoinHostPort(strings.TrimSuffix(host, "."), port))
+	}
+
+	lset := make(labels.Labels, 0, len(discovered))
+	for name, value := range discovered {
+		lset = append(lset, labels.Label{Name: string(name), Value: string(value)})
+	}
+	sort.Sort(lset)
+
+	lset = relabel.Process(lset, d.cfg.RelabelConfigs...)
+	if lset == nil {
+		return SSLTarget{}, false
+	}
+
+	addr := lset.Get(model.AddressLabel)
+	if addr == "" {
+		level.Warn(d.log).Log("msg", "ignoring discovered target without an address", "labels", lset.String())
+		return SSLTarget{}, false
+	}
+
+	module := d.cfg.Module
+	if m := lset.Get(moduleLabel); m != "" {
+		module = m
+	}
+
+	return SSLTarget{
+		Name:   addr,
+		Target: addr,
+		Module: module,
+	}, true
+}
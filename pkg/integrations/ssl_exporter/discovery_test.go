@@ -0,0 +1,229 @@
+package ssl_exporter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/discovery/file"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/model/relabel"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestDiscoverer_Targets(t *testing.T) {
+	var relabelConfigs []*relabel.Config
+	err := yaml.UnmarshalStrict([]byte(`
+- source_labels: [__meta_kubernetes_service_port_name]
+  regex: http
+  action: drop
+- source_labels: [__meta_kubernetes_namespace]
+  regex: kube-system
+  target_label: __param_module
+  replacement: tcp_internal
+`), &relabelConfigs)
+	require.NoError(t, err)
+
+	d := &discoverer{
+		log: log.NewNopLogger(),
+		cfg: DiscoveryConfig{
+			Module:         "https",
+			RelabelConfigs: relabelConfigs,
+		},
+	}
+
+	tgs := map[string][]*targetgroup.Group{
+		"ssl_exporter": {
+			{
+				Source: "ingress/default/web",
+				Labels: model.LabelSet{"__meta_kubernetes_namespace": "default"},
+				Targets: []model.LabelSet{
+					{model.AddressLabel: "example.com", ingressSchemeLabel: "https"},
+					{model.AddressLabel: "plain.example.com", ingressSchemeLabel: "http"},
+					// Duplicate hosts from different paths are only probed
+					// once.
+					{model.AddressLabel: "example.com", ingressSchemeLabel: "https"},
+				},
+			},
+			{
+				Source: "svc/kube-system/api",
+				Labels: model.LabelSet{"__meta_kubernetes_namespace": "kube-system"},
+				Targets: []model.LabelSet{
+					{model.AddressLabel: "api.kube-system.svc:443", "__meta_kubernetes_service_port_name": "https"},
+					{model.AddressLabel: "api.kube-system.svc:80", "__meta_kubernetes_service_port_name": "http"},
+				},
+			},
+			{
+				Source: "_https._tcp.example.com",
+				Targets: []model.LabelSet{
+					{model.AddressLabel: "backend-1.example.com.:8443", "__meta_dns_name": "_https._tcp.example.com."},
+				},
+			},
+		},
+	}
+
+	require.Equal(t, []SSLTarget{
+		{Name: "api.kube-system.svc:443", Target: "api.kube-system.svc:443", Module: "tcp_internal"},
+		{Name: "backend-1.example.com:8443", Target: "backend-1.example.com:8443", Module: "https"},
+		{Name: "example.com:443", Target: "example.com:443", Module: "https"},
+	}, d.targets(tgs))
+}
+
+func TestDiscoverer_File(t *testing.T) {
+	sdFile := filepath.Join(t.TempDir(), "targets.yml")
+	writeFile := func(contents string) {
+		require.NoError(t, os.WriteFile(sdFile, []byte(contents), 0600))
+	}
+	writeFile(`
+- targets: [example.com:443]
+  labels:
+    __param_module: https
+`)
+
+	var cfg DiscoveryConfig
+	err := yaml.UnmarshalStrict([]byte(`
+module: tcp
+file_sd_configs:
+- files: [`+sdFile+`]
+`), &cfg)
+	require.NoError(t, err)
+	require.True(t, cfg.Enabled())
+	require.IsType(t, &file.SDConfig{}, cfg.sdConfigs()[0])
+
+	updates := make(chan []SSLTarget, 10)
+	d := &discoverer{
+		log:      log.NewNopLogger(),
+		cfg:      cfg,
+		onUpdate: func(ts []SSLTarget) { updates <- ts },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = d.Run(ctx) }()
+
+	waitForTargets := func(expect []SSLTarget) {
+		t.Helper()
+		timeout := time.After(10 * time.Second)
+		for {
+			select {
+			case ts := <-updates:
+				if reflect.DeepEqual(ts, expect) {
+					return
+				}
+			case <-timeout:
+				require.FailNow(t, "timed out waiting for targets", "expected %v", expect)
+			}
+		}
+	}
+
+	waitForTargets([]SSLTarget{{Name: "example.com:443", Target: "example.com:443", Module: "https"}})
+
+	// Changes to the file should be picked up.
+	writeFile(`
+- targets: [grafana.com:443]
+`)
+	waitForTargets([]SSLTarget{{Name: "grafana.com:443", Target: "grafana.com:443", Module: "tcp"}})
+}
+
+func TestScheduler_DiscoveredTargets(t *testing.T) {
+	certFile := writeTestCert(t, t.TempDir())
+
+	e, err := NewSSLExporter(Options{
+		Namespace: "ssl_exporter",
+		SSLTargets: []SSLTarget{
+			{Name: "static", Target: certFile, Module: "file"},
+		},
+		SSLConfig:           ssl_config.DefaultConfig,
+		MaxConcurrentProbes: 1,
+		ProbeInterval:       time.Hour,
+		log:                 log.NewNopLogger(),
+	})
+	require.NoError(t, err)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(e)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = e.Run(ctx) }()
+
+	probedTargets := func() map[string]struct{} {
+		res := make(map[string]struct{})
+		fams, err := reg.Gather()
+		require.NoError(t, err)
+		for _, mf := range fams {
+			if mf.GetName() != "ssl_probe_success" {
+				continue
+			}
+			for _, m := range mf.GetMetric() {
+				for _, l := range m.GetLabel() {
+					if l.GetName() == targetLabel {
+						res[l.GetValue()] = struct{}{}
+					}
+				}
+			}
+		}
+		return res
+	}
+
+	e.setDiscoveredTargets([]SSLTarget{{Name: "discovered", Target: certFile, Module: "file"}})
+	require.Eventually(t, func() bool {
+		return len(probedTargets()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Removing the discovered target should remove its cached results.
+	e.setDiscoveredTargets(nil)
+	require.Eventually(t, func() bool {
+		_, found := probedTargets()["discovered"]
+		return !found
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Contains(t, probedTargets(), "static")
+}
+
+func TestExporter_ForgetsRemovedDiscoveredTargets(t *testing.T) {
+	certFile := writeTestCert(t, t.TempDir())
+
+	e, err := NewSSLExporter(Options{
+		Namespace: "ssl_exporter",
+		SSLTargets: []SSLTarget{
+			{Name: "static", Target: certFile, Module: "file"},
+		},
+		SSLConfig:           ssl_config.DefaultConfig,
+		MaxConcurrentProbes: 1,
+		Quarantine:          QuarantineConfig{FailureThreshold: 1},
+		log:                 log.NewNopLogger(),
+	})
+	require.NoError(t, err)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(e)
+
+	e.setDiscoveredTargets([]SSLTarget{
+		{Name: "discovered", Target: certFile + ".missing", Module: "file"},
+	})
+	_, err = reg.Gather()
+	require.NoError(t, err)
+
+	_, quarantined := e.quarantine.check("discovered")
+	require.True(t, quarantined)
+	require.Contains(t, e.counts, "discovered")
+	require.Contains(t, e.statuses, "discovered")
+
+	// Targets which are removed by discovery are forgotten even though they
+	// aren't probed in the background.
+	e.setDiscoveredTargets(nil)
+
+	_, quarantined = e.quarantine.check("discovered")
+	require.False(t, quarantined)
+	require.NotContains(t, e.counts, "discovered")
+	require.NotContains(t, e.statuses, "discovered")
+	require.Contains(t, e.counts, "static")
+}
@@ -0,0 +1,58 @@
+package ssl_exporter
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// Reasons a probe can fail, as reported by ssl_probe_error_info.
+const (
+	reasonDNSError          = "dns_error"
+	reasonConnectionRefused = "connection_refused"
+	reasonTimeout           = "timeout"
+	reasonHandshakeFailure  = "handshake_failure"
+	reasonCertExpired       = "cert_expired"
+	reasonHostnameMismatch  = "hostname_mismatch"
+	reasonUntrustedChain    = "untrusted_chain"
+	reasonUnknown           = "unknown"
+)
+
+// classifyProbeError returns the reason for a failed probe.
+func classifyProbeError(err error) string {
+	var (
+		dnsErr       *net.DNSError
+		invalidErr   x509.CertificateInvalidError
+		hostnameErr  x509.HostnameError
+		authorityErr x509.UnknownAuthorityError
+		netErr       net.Error
+	)
+
+	switch {
+	case errors.As(err, &dnsErr):
+		return reasonDNSError
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return reasonConnectionRefused
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return reasonTimeout
+	case errors.As(err, &invalidErr) && invalidErr.Reason == x509.Expired:
+		return reasonCertExpired
+	case errors.As(err, &hostnameErr):
+		return reasonHostnameMismatch
+	case errors.As(err, &authorityErr):
+		return reasonUntrustedChain
+	case errors.As(err, &invalidErr):
+		// Certificates which are otherwise invalid fail the handshake.
+		return reasonHandshakeFailure
+	}
+
+	// TLS alerts and other handshake errors are not exported by crypto/tls,
+	// so they can only be identified by their message.
+	if msg := err.Error(); strings.Contains(msg, "tls:") || strings.Contains(msg, "handshake") {
+		return reasonHandshakeFailure
+	}
+	return reasonUnknown
+}
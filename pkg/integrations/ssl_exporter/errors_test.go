@@ -0,0 +1,45 @@
+package ssl_exporter
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyProbeError(t *testing.T) {
+	// Find a port nothing is listening on to get a real connection refused
+	// error.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	require.NoError(t, lis.Close())
+	_, refusedErr := net.Dial("tcp", addr)
+	require.Error(t, refusedErr)
+
+	tt := []struct {
+		name   string
+		err    error
+		expect string
+	}{
+		{"dns", &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "example.invalid"}}, reasonDNSError},
+		{"connection refused", refusedErr, reasonConnectionRefused},
+		{"deadline", fmt.Errorf("probing: %w", context.DeadlineExceeded), reasonTimeout},
+		{"expired", x509.CertificateInvalidError{Reason: x509.Expired}, reasonCertExpired},
+		{"expired wrapped by https", &url.Error{Op: "Get", URL: "https://example.com", Err: x509.CertificateInvalidError{Reason: x509.Expired}}, reasonCertExpired},
+		{"hostname", x509.HostnameError{Host: "example.com", Certificate: &x509.Certificate{}}, reasonHostnameMismatch},
+		{"untrusted", x509.UnknownAuthorityError{}, reasonUntrustedChain},
+		{"tls alert", errors.New("remote error: tls: handshake failure"), reasonHandshakeFailure},
+		{"unknown", errors.New("something else"), reasonUnknown},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expect, classifyProbeError(tc.err))
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package ssl_exporter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+// expiringCertLabels are the labels which identify a certificate in the
+// metrics of every prober, and are used for the ssl_cert_expiring_within
+// metric.
+var expiringCertLabels = []string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"}
+
+// expiryThreshold is a threshold of the ssl_cert_expiring_within metric.
+type expiryThreshold struct {
+	// label is the threshold as configured, like 30d, used as the value of
+	// the threshold label.
+	label    string
+	duration time.Duration
+}
+
+// parseExpiryThresholds parses thresholds, which are Prometheus durations
+// like 7d. It returns an error if any threshold is invalid, isn't positive,
+// or is set more than once.
+func parseExpiryThresholds(thresholds []string) ([]expiryThreshold, error) {
+	res := make([]expiryThreshold, 0, len(thresholds))
+	seen := make(map[time.Duration]struct{}, len(thresholds))
+	for _, threshold := range thresholds {
+		d, err := model.ParseDuration(threshold)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expiry threshold %q: %w", threshold, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("expiry threshold %q must be positive", threshold)
+		}
+		if _, ok := seen[time.Duration(d)]; ok {
+			return nil, fmt.Errorf("expiry threshold %q is set more than once", threshold)
+		}
+		seen[time.Duration(d)] = struct{}{}
+		res = append(res, expiryThreshold{label: threshold, duration: time.Duration(d)})
+	}
+	return res, nil
+}
+
+// expiryMetrics returns the ssl_cert_expiring_within metrics of the
+// certificates in the metric families collected by a probe, created with
+// newMetric. Certificates are found through the NotAfter metrics of the
+// probers. Certificates found more than once, like a certificate in several
+// files, only have one metric per threshold.
+func (e *Exporter) expiryMetrics(fams []*dto.MetricFamily, now time.Time, newMetric func(key string, value float64, labelValues ...string) prometheus.Metric) []prometheus.Metric {
+	thresholds := e.expiryThresholds
+	if len(thresholds) == 0 {
+		return nil
+	}
+
+	var (
+		res  []prometheus.Metric
+		seen = make(map[string]struct{})
+	)
+	for _, mf := range fams {
+		// The verified chains hold the certificates presented by targets
+		// along with the trusted roots, which don't expire with them.
+		if !strings.HasSuffix(mf.GetName(), "cert_not_after") || mf.GetName() == "ssl_verified_cert_not_after" {
+			continue
+		}
+		for _, m := range mf.Metric {
+			values := make(map[string]string, len(m.Label))
+			for _, l := range m.Label {
+				values[l.GetName()] = l.GetValue()
+			}
+			certValues := make([]string, 0, len(expiringCertLabels))
+			for _, name := range expiringCertLabels {
+				certValues = append(certValues, values[name])
+			}
+			key := strings.Join(certValues, "\xff")
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+
+			notAfter := time.Unix(int64(m.GetGauge().GetValue()), 0)
+			for _, threshold := range thresholds {
+				var expiring float64
+				if notAfter.Sub(now) < threshold.duration {
+					expiring = 1
+				}
+				labelValues := append(append([]string{}, certValues...), threshold.label)
+				res = append(res, newMetric("ssl_cert_expiring_within", expiring, labelValues...))
+			}
+		}
+	}
+	return res
+}
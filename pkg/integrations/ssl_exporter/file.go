@@ -0,0 +1,106 @@
+package ssl_exporter
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/bmatcuk/doublestar/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+)
+
+// probeFile collects certificate metrics from local files matching the
+// target glob.
+//
+// Copied from github.com/ribbybibby/ssl_exporter/v2/prober.
+func probeFile(ctx context.Context, logger log.Logger, target string, module ssl_config.Module, registry *prometheus.Registry) error {
+	errCh := make(chan error, 1)
+
+	go func() {
+		files, err := doublestar.Glob(target)
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		if len(files) == 0 {
+			errCh <- fmt.Errorf("No files found")
+		} else {
+			errCh <- collectFileMetrics(ctx, logger, "", files, os.ReadFile, registry)
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("context timeout, ran out of time")
+	case err := <-errCh:
+		return err
+	}
+}
+
+// collectFileMetrics collects metrics for the certificates in files, which
+// are read with readFile. host is the host the files were read from and is
+// empty for local files.
+func collectFileMetrics(ctx context.Context, logger log.Logger, host string, files []string, readFile func(string) ([]byte, error), registry *prometheus.Registry) error {
+	var (
+		totalCerts   int
+		fileNotAfter = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "file_cert_not_after"),
+				Help: "NotAfter expressed as a Unix Epoch Time for a certificate found in a file",
+			},
+			[]string{"host", "file", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		)
+		fileNotBefore = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "file_cert_not_before"),
+				Help: "NotBefore expressed as a Unix Epoch Time for a certificate found in a file",
+			},
+			[]string{"host", "file", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		)
+	)
+	registry.MustRegister(fileNotAfter, fileNotBefore)
+
+	for _, f := range files {
+		data, err := readFile(f)
+		if err != nil {
+			level.Debug(logger).Log("msg", fmt.Sprintf("Error reading file %s: %s", f, err))
+			continue
+		}
+		certs, err := decodeCertificates(data)
+		if err != nil {
+			return err
+		}
+		totalCerts += len(certs)
+		for i, cert := range certs {
+			labels := append([]string{host, f}, labelValues(cert, i)...)
+			recordCert(ctx, fileCertSource(host, f), i, cert)
+
+			if !cert.NotAfter.IsZero() {
+				fileNotAfter.WithLabelValues(labels...).Set(float64(cert.NotAfter.Unix()))
+			}
+
+			if !cert.NotBefore.IsZero() {
+				fileNotBefore.WithLabelValues(labels...).Set(float64(cert.NotBefore.Unix()))
+			}
+		}
+	}
+
+	if totalCerts == 0 {
+		return fmt.Errorf("No certificates found")
+	}
+
+	return nil
+}
+
+// fileCertSource returns the source of a certificate found in file on host,
+// which is empty for local files.
+func fileCertSource(host, file string) map[string]string {
+	if host == "" {
+		return map[string]string{"file": file}
+	}
+	return map[string]string{"host": host, "file": file}
+}
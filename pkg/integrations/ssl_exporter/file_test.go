@@ -0,0 +1,59 @@
+package ssl_exporter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileProber_CertLabels(t *testing.T) {
+	leafPEM, err := os.ReadFile(writeTestCert(t, t.TempDir()))
+	require.NoError(t, err)
+	caPEM, _ := generateKeyPair(t)
+
+	bundle := filepath.Join(t.TempDir(), "bundle.pem")
+	require.NoError(t, os.WriteFile(bundle, append(leafPEM, caPEM...), 0600))
+
+	reg := prometheus.NewRegistry()
+	require.NoError(t, probeFile(context.Background(), log.NewNopLogger(), bundle, defaultSSLConfig().Modules["file"], reg))
+
+	fams, err := reg.Gather()
+	require.NoError(t, err)
+
+	positions := make(map[string]string)
+	fingerprints := make(map[string]string)
+	for _, mf := range fams {
+		if mf.GetName() != "ssl_file_cert_not_after" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			positions[labels["cn"]] = labels["chain_position"]
+			fingerprints[labels["cn"]] = labels["fingerprint_sha256"]
+		}
+	}
+
+	require.Equal(t, map[string]string{"example.com": "0", "client": "1"}, positions)
+	require.Equal(t, fingerprint(t, leafPEM), fingerprints["example.com"])
+	require.Equal(t, fingerprint(t, caPEM), fingerprints["client"])
+}
+
+func fingerprint(t *testing.T, certPEM []byte) string {
+	t.Helper()
+
+	block, _ := pem.Decode(certPEM)
+	require.NotNil(t, block)
+	sum := sha256.Sum256(block.Bytes)
+	return hex.EncodeToString(sum[:])
+}
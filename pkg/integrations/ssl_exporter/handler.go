@@ -0,0 +1,114 @@
+package ssl_exporter
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/gorilla/mux"
+	"github.com/grafana/agent/pkg/integrations"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Handler returns the HTTP handler for the endpoints of the Exporter other
+// than metrics. See integrations.HTTPIntegration for more information.
+func (e *Exporter) Handler(prefix string) (http.Handler, error) {
+	r := mux.NewRouter()
+	r.Handle(path.Join(prefix, e.options.ProbePath), http.HandlerFunc(e.probeHandler))
+	if e.options.StatusPath != "" {
+		r.Handle(path.Join(prefix, e.options.StatusPath), e.statusHandler(path.Join(prefix, e.options.ProbePath)))
+	}
+	r.Handle(path.Join(prefix, "api/v1/ssl/targets/{name:.+}/certs"), http.HandlerFunc(e.certsHandler))
+	return r, nil
+}
+
+// adhocProbers are the probers which may be used for probing targets which
+// aren't configured. Other probers read local files, Kubernetes secrets or
+// Vault secrets, which must not be exposed to callers of the /probe endpoint.
+var adhocProbers = map[string]struct{}{
+	"tcp":   {},
+	"https": {},
+}
+
+// probeHandler probes the target given by the target query parameter on
+// demand and returns the resulting metrics. The module query parameter may be
+// used to choose between configured targets with the same address.
+//
+// Targets which aren't configured are only probed when ad-hoc probes are
+// allowed, and only with modules using a network prober.
+func (e *Exporter) probeHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		query  = r.URL.Query()
+		module = query.Get("module")
+	)
+	if query.Get("target") == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+
+	target, ok := e.configuredTarget(query.Get("target"), module)
+	if !ok {
+		if !e.options.AllowAdhocProbes {
+			http.Error(w, "target is not configured and ad-hoc probes are disabled", http.StatusForbidden)
+			return
+		}
+		target = SSLTarget{
+			Name:   query.Get("target"),
+			Target: query.Get("target"),
+			Module: module,
+		}
+	}
+
+	mod, _, err := resolveModule(target.Module, e.options.DefaultModule, e.sslConfig())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, network := adhocProbers[mod.Prober]; !ok && !network {
+		http.Error(w, fmt.Sprintf("prober %q can't be used for ad-hoc probes", mod.Prober), http.StatusForbidden)
+		return
+	}
+
+	// Probes on demand aren't recorded in the probe counters, so they don't
+	// skew the results of scheduled probes.
+	metrics, _ := e.probe(r.Context(), target)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(probeResults(metrics))
+
+	var g prometheus.Gatherer = reg
+	if len(e.options.MetricRelabelConfigs) > 0 {
+		g = integrations.NewRelabelGatherer(reg, e.options.MetricRelabelConfigs)
+	}
+
+	h := promhttp.HandlerFor(g, promhttp.HandlerOpts{})
+	h.ServeHTTP(w, r)
+}
+
+// configuredTarget returns the configured or discovered target with the
+// address target. If module isn't empty, the target must use module.
+func (e *Exporter) configuredTarget(target, module string) (SSLTarget, bool) {
+	sslConfig := e.sslConfig()
+	for _, t := range e.targets() {
+		if t.Target != target {
+			continue
+		}
+		if module == "" || moduleName(t.Module, e.options.DefaultModule, sslConfig) == module {
+			return t, true
+		}
+	}
+	return SSLTarget{}, false
+}
+
+// probeResults is an unchecked prometheus.Collector which collects the
+// results of a single probe.
+type probeResults []prometheus.Metric
+
+func (probeResults) Describe(chan<- *prometheus.Desc) {}
+
+func (pr probeResults) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range pr {
+		ch <- m
+	}
+}
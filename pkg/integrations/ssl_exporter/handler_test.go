@@ -0,0 +1,166 @@
+package ssl_exporter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-kit/log"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExporter_ProbeHandler(t *testing.T) {
+	certFile := writeTestCert(t, t.TempDir())
+
+	newHandler := func(allowAdhoc bool) http.Handler {
+		e, err := NewSSLExporter(Options{
+			Namespace:           "ssl_exporter",
+			ProbePath:           "/probe",
+			SSLTargets:          []SSLTarget{{Name: "cert", Target: certFile, Module: "file"}},
+			SSLConfig:           ssl_config.DefaultConfig,
+			MaxConcurrentProbes: 1,
+			AllowAdhocProbes:    allowAdhoc,
+			log:                 log.NewNopLogger(),
+		})
+		require.NoError(t, err)
+
+		h, err := e.Handler("/integrations/ssl_exporter/")
+		require.NoError(t, err)
+		return h
+	}
+
+	probe := func(h http.Handler, query url.Values) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/integrations/ssl_exporter/probe?"+query.Encode(), nil)
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tt := []struct {
+		name       string
+		allowAdhoc bool
+		query      url.Values
+		expect     int
+	}{
+		{"configured target", false, url.Values{"target": {certFile}}, http.StatusOK},
+		{"configured target and module", false, url.Values{"target": {certFile}, "module": {"file"}}, http.StatusOK},
+		{"missing target", false, url.Values{"module": {"file"}}, http.StatusBadRequest},
+		{"unconfigured target", false, url.Values{"target": {"/etc/passwd"}, "module": {"file"}}, http.StatusForbidden},
+		{"unconfigured module", false, url.Values{"target": {certFile}, "module": {"tcp"}}, http.StatusForbidden},
+		{"ad-hoc file prober", true, url.Values{"target": {"/etc/passwd"}, "module": {"file"}}, http.StatusForbidden},
+		{"ad-hoc unknown module", true, url.Values{"target": {"localhost:443"}, "module": {"fake"}}, http.StatusBadRequest},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := probe(newHandler(tc.allowAdhoc), tc.query)
+			require.Equal(t, tc.expect, rec.Code, rec.Body.String())
+			if tc.expect == http.StatusOK {
+				require.Contains(t, rec.Body.String(), `ssl_probe_success{ssl_target="cert"} 1`)
+				require.Contains(t, rec.Body.String(), "ssl_file_cert_not_after")
+			}
+		})
+	}
+}
+
+func TestExporter_StatusHandler(t *testing.T) {
+	certFile := writeTestCert(t, t.TempDir())
+
+	e, err := NewSSLExporter(Options{
+		Namespace:  "ssl_exporter",
+		ProbePath:  "/probe",
+		StatusPath: "/status",
+		SSLTargets: []SSLTarget{
+			{Name: "cert", Target: certFile, Module: "file"},
+			{Name: "missing", Target: "/does/not/exist.pem", Module: "file"},
+			{Name: "pending", Target: certFile, Module: "file"},
+		},
+		SSLConfig:           defaultSSLConfig(),
+		MaxConcurrentProbes: 1,
+		log:                 log.NewNopLogger(),
+	})
+	require.NoError(t, err)
+
+	e.probeTarget(context.Background(), e.options.SSLTargets[0])
+	e.probeTarget(context.Background(), e.options.SSLTargets[1])
+
+	h, err := e.Handler("/integrations/ssl_exporter/")
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/integrations/ssl_exporter/status?format=json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var statuses []targetStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &statuses))
+	require.Len(t, statuses, 3)
+
+	require.Equal(t, "cert", statuses[0].Name)
+	require.True(t, statuses[0].Success)
+	require.NotNil(t, statuses[0].LeafCert)
+	require.Equal(t, "example.com", statuses[0].LeafCert.CommonName)
+
+	require.Equal(t, "missing", statuses[1].Name)
+	require.False(t, statuses[1].Success)
+	require.NotEmpty(t, statuses[1].Error)
+
+	require.Equal(t, "pending", statuses[2].Name)
+	require.True(t, statuses[2].LastProbe.IsZero())
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/integrations/ssl_exporter/status", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "example.com, issued by example.com")
+	require.Contains(t, rec.Body.String(), "Not probed yet")
+}
+
+func TestExporter_CertsHandler(t *testing.T) {
+	certFile := writeTestCert(t, t.TempDir())
+
+	e, err := NewSSLExporter(Options{
+		Namespace:           "ssl_exporter",
+		ProbePath:           "/probe",
+		SSLTargets:          []SSLTarget{{Name: "cert", Target: certFile, Module: "file"}},
+		SSLConfig:           defaultSSLConfig(),
+		MaxConcurrentProbes: 1,
+		log:                 log.NewNopLogger(),
+	})
+	require.NoError(t, err)
+
+	h, err := e.Handler("/integrations/ssl_exporter/")
+	require.NoError(t, err)
+
+	get := func(name string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/integrations/ssl_exporter/api/v1/ssl/targets/"+name+"/certs", nil))
+		return rec
+	}
+
+	// Targets which weren't probed yet have no certificates.
+	rec := get("cert")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp targetCerts
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Empty(t, resp.Certificates)
+
+	e.probeTarget(context.Background(), e.options.SSLTargets[0])
+
+	rec = get("cert")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, certFile, resp.Target)
+	require.Len(t, resp.Certificates, 1)
+
+	cert := resp.Certificates[0]
+	require.Equal(t, map[string]string{"file": certFile}, cert.Source)
+	require.Equal(t, 0, cert.ChainPosition)
+	require.Equal(t, "CN=example.com", cert.Subject)
+	require.Equal(t, []string{"example.com"}, cert.DNSNames)
+	require.Len(t, cert.FingerprintSHA1, 40)
+	require.Len(t, cert.FingerprintSHA256, 64)
+
+	require.Equal(t, http.StatusNotFound, get("missing").Code)
+}
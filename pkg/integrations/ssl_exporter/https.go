@@ -0,0 +1,95 @@
+package ssl_exporter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"github.com/ribbybibby/ssl_exporter/v2/prober"
+)
+
+// newHTTPSProber returns a prober which behaves like the upstream https
+// prober, but applies opts to the connections it makes.
+func newHTTPSProber(opts proberOptions) prober.ProbeFn {
+	return func(ctx context.Context, logger log.Logger, target string, module ssl_config.Module, registry *prometheus.Registry) error {
+		tlsConfig, err := newTLSConfig("", registry, &module.TLSConfig)
+		if err != nil {
+			return err
+		}
+		if err := opts.configureTLS(ctx, tlsConfig, registry); err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(target, "http://") {
+			return fmt.Errorf("Target is using http scheme: %s", target)
+		}
+
+		if !strings.HasPrefix(target, "https://") {
+			target = "https://" + target
+		}
+
+		targetURL, err := url.Parse(target)
+		if err != nil {
+			return err
+		}
+
+		// Issue a GET request to the target
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL.String(), nil)
+		if err != nil {
+			return err
+		}
+
+		proxy := http.ProxyFromEnvironment
+		if module.HTTPS.ProxyURL.URL != nil {
+			proxy = http.ProxyURL(module.HTTPS.ProxyURL.URL)
+		}
+		proxyURL, err := proxy(request)
+		if err != nil {
+			return err
+		}
+		var dial dialFunc
+		if proxyURL == nil || opts.ip != "" || opts.ipProtocol != "" {
+			// Connections are dialed directly.
+			proxy = nil
+			dial = opts.dialer(registry)
+		}
+
+		client := &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+			Transport: &http.Transport{
+				TLSClientConfig:   tlsConfig,
+				Proxy:             proxy,
+				DialContext:       dial,
+				DisableKeepAlives: true,
+			},
+		}
+
+		resp, err := client.Do(request)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_, err := io.Copy(io.Discard, resp.Body)
+			if err != nil {
+				level.Error(logger).Log("msg", err)
+			}
+			resp.Body.Close()
+		}()
+
+		// Check if the response from the target is encrypted
+		if resp.TLS == nil {
+			return fmt.Errorf("The response from %s is unencrypted", targetURL.String())
+		}
+
+		return nil
+	}
+}
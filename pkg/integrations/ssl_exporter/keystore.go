@@ -0,0 +1,198 @@
+package ssl_exporter
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/bmatcuk/doublestar/v2"
+	"github.com/go-kit/log"
+	keystore "github.com/pavlo-v-chernykh/keystore-go/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"github.com/ribbybibby/ssl_exporter/v2/prober"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Types of keystores supported by the keystore prober, used as the value of
+// the store label.
+const (
+	storePKCS12 = "pkcs12"
+	storeJKS    = "jks"
+)
+
+// jksMagic is the magic number at the start of every Java keystore.
+var jksMagic = []byte{0xfe, 0xed, 0xfe, 0xed}
+
+// keystoreCert is a certificate found in a keystore. position is the index
+// of the certificate in the chain of its entry.
+type keystoreCert struct {
+	alias    string
+	position int
+	cert     *x509.Certificate
+}
+
+// newKeystoreProber returns a prober which collects certificate metrics from
+// PKCS#12 and Java keystore files matching the target glob. Keystores are
+// opened with password.
+func newKeystoreProber(password string) prober.ProbeFn {
+	return func(ctx context.Context, logger log.Logger, target string, module ssl_config.Module, registry *prometheus.Registry) error {
+		errCh := make(chan error, 1)
+
+		go func() {
+			files, err := doublestar.Glob(target)
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			if len(files) == 0 {
+				errCh <- fmt.Errorf("No files found")
+			} else {
+				errCh <- collectKeystoreMetrics(ctx, files, password, registry)
+			}
+		}()
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context timeout, ran out of time")
+		case err := <-errCh:
+			return err
+		}
+	}
+}
+
+func collectKeystoreMetrics(ctx context.Context, files []string, password string, registry *prometheus.Registry) error {
+	var (
+		totalCerts       int
+		keystoreNotAfter = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "keystore_cert_not_after"),
+				Help: "NotAfter expressed as a Unix Epoch Time for a certificate found in a keystore",
+			},
+			[]string{"file", "store", "alias", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		)
+		keystoreNotBefore = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "keystore_cert_not_before"),
+				Help: "NotBefore expressed as a Unix Epoch Time for a certificate found in a keystore",
+			},
+			[]string{"file", "store", "alias", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		)
+	)
+	registry.MustRegister(keystoreNotAfter, keystoreNotBefore)
+
+	for _, f := range files {
+		store, certs, err := readKeystore(f, password)
+		if err != nil {
+			return fmt.Errorf("failed to read keystore %s: %w", f, err)
+		}
+		totalCerts += len(certs)
+
+		for _, c := range certs {
+			labels := append([]string{f, store, c.alias}, labelValues(c.cert, c.position)...)
+			recordCert(ctx, map[string]string{"file": f, "store": store, "alias": c.alias}, c.position, c.cert)
+
+			if !c.cert.NotAfter.IsZero() {
+				keystoreNotAfter.WithLabelValues(labels...).Set(float64(c.cert.NotAfter.Unix()))
+			}
+
+			if !c.cert.NotBefore.IsZero() {
+				keystoreNotBefore.WithLabelValues(labels...).Set(float64(c.cert.NotBefore.Unix()))
+			}
+		}
+	}
+
+	if totalCerts == 0 {
+		return fmt.Errorf("No certificates found")
+	}
+
+	return nil
+}
+
+// readKeystore reads the certificates from the keystore at path, returning
+// the type of the keystore along with its certificates. Java keystores are
+// identified by their magic number; other files are read as PKCS#12.
+func readKeystore(path, password string) (string, []keystoreCert, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if bytes.HasPrefix(data, jksMagic) {
+		certs, err := readJKS(data, password)
+		return storeJKS, certs, err
+	}
+	certs, err := readPKCS12(data, password)
+	return storePKCS12, certs, err
+}
+
+// readPKCS12 reads the certificates from a PKCS#12 file. PKCS#12 files
+// holding a private key are read along with their chain; files without a
+// private key are read as trust stores.
+func readPKCS12(data []byte, password string) ([]keystoreCert, error) {
+	var certs []*x509.Certificate
+
+	_, cert, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err == nil {
+		certs = append([]*x509.Certificate{cert}, caCerts...)
+	} else {
+		var trustErr error
+		certs, trustErr = pkcs12.DecodeTrustStore(data, password)
+		if trustErr != nil {
+			// The error of DecodeChain is more useful for files which
+			// aren't trust stores.
+			return nil, err
+		}
+	}
+
+	res := make([]keystoreCert, 0, len(certs))
+	for i, cert := range uniq(certs) {
+		res = append(res, keystoreCert{position: i, cert: cert})
+	}
+	return res, nil
+}
+
+// readJKS reads the certificates of the private key and trusted certificate
+// entries of a Java keystore.
+func readJKS(data []byte, password string) ([]keystoreCert, error) {
+	ks := keystore.New()
+	if err := ks.Load(bytes.NewReader(data), []byte(password)); err != nil {
+		return nil, err
+	}
+
+	var res []keystoreCert
+	for _, alias := range ks.Aliases() {
+		var chain []keystore.Certificate
+
+		switch {
+		case ks.IsPrivateKeyEntry(alias):
+			entryChain, err := ks.GetPrivateKeyEntryCertificateChain(alias)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read entry %q: %w", alias, err)
+			}
+			chain = entryChain
+		case ks.IsTrustedCertificateEntry(alias):
+			entry, err := ks.GetTrustedCertificateEntry(alias)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read entry %q: %w", alias, err)
+			}
+			chain = []keystore.Certificate{entry.Certificate}
+		}
+
+		var certs []*x509.Certificate
+		for _, c := range chain {
+			cert, err := x509.ParseCertificate(c.Content)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse certificate of entry %q: %w", alias, err)
+			}
+			certs = append(certs, cert)
+		}
+		for i, cert := range uniq(certs) {
+			res = append(res, keystoreCert{alias: alias, position: i, cert: cert})
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,71 @@
+package ssl_exporter
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	keystore "github.com/pavlo-v-chernykh/keystore-go/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestKeystoreProber(t *testing.T) {
+	dir := t.TempDir()
+
+	certPEM, _ := generateKeyPair(t)
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	p12, err := pkcs12.EncodeTrustStore(rand.Reader, []*x509.Certificate{cert}, "changeit")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "truststore.p12"), p12, 0600))
+
+	ks := keystore.New()
+	err = ks.SetTrustedCertificateEntry("client", keystore.TrustedCertificateEntry{
+		CreationTime: time.Now(),
+		Certificate:  keystore.Certificate{Type: "X509", Content: cert.Raw},
+	})
+	require.NoError(t, err)
+	var jks bytes.Buffer
+	require.NoError(t, ks.Store(&jks, []byte("changeit")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "truststore.jks"), jks.Bytes(), 0600))
+
+	probe := newKeystoreProber("changeit")
+
+	reg := prometheus.NewRegistry()
+	err = probe(context.Background(), log.NewNopLogger(), filepath.Join(dir, "*"), defaultSSLConfig().Modules["keystore"], reg)
+	require.NoError(t, err)
+
+	fams, err := reg.Gather()
+	require.NoError(t, err)
+
+	stores := make(map[string]string)
+	for _, mf := range fams {
+		if mf.GetName() != "ssl_keystore_cert_not_after" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			require.Equal(t, float64(cert.NotAfter.Unix()), m.GetGauge().GetValue())
+			stores[labels["store"]] = labels["alias"]
+		}
+	}
+	require.Equal(t, map[string]string{"pkcs12": "", "jks": "client"}, stores)
+
+	// Keystores can't be opened with the wrong password.
+	err = newKeystoreProber("wrong")(context.Background(), log.NewNopLogger(), filepath.Join(dir, "*"), defaultSSLConfig().Modules["keystore"], prometheus.NewRegistry())
+	require.Error(t, err)
+}
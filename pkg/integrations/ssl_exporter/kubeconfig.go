@@ -0,0 +1,208 @@
+package ssl_exporter
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bmatcuk/doublestar/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"github.com/ribbybibby/ssl_exporter/v2/prober"
+	"gopkg.in/yaml.v3"
+)
+
+// probeKubeconfig collects certificate metrics from the kubeconfig file at
+// target. target may also be a directory or a glob, in which case every
+// kubeconfig file found in the directory or matching the glob is collected.
+// Files which aren't kubeconfigs are skipped.
+//
+// Copied from github.com/ribbybibby/ssl_exporter/v2/prober.
+func probeKubeconfig(ctx context.Context, logger log.Logger, target string, module ssl_config.Module, registry *prometheus.Registry) error {
+	if info, err := os.Stat(target); err == nil && !info.IsDir() {
+		k, err := parseKubeConfig(target)
+		if err != nil {
+			return err
+		}
+		return collectKubeconfigMetrics(ctx, logger, []prober.KubeConfig{*k}, registry)
+	}
+
+	files, err := kubeconfigFiles(target)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("kubeconfig not found: %s", target)
+	}
+
+	var kubeconfigs []prober.KubeConfig
+	for _, f := range files {
+		k, err := parseKubeConfig(f)
+		if err != nil || (len(k.Clusters) == 0 && len(k.Users) == 0) {
+			level.Debug(logger).Log("msg", fmt.Sprintf("Skipping %s, which isn't a kubeconfig", f), "err", err)
+			continue
+		}
+		kubeconfigs = append(kubeconfigs, *k)
+	}
+	if len(kubeconfigs) == 0 {
+		return fmt.Errorf("no kubeconfigs found in %s", target)
+	}
+	return collectKubeconfigMetrics(ctx, logger, kubeconfigs, registry)
+}
+
+// kubeconfigFiles returns the regular files in the directory target, or
+// matching the glob target. Directories matching the glob are scanned for
+// files too, but not recursively.
+func kubeconfigFiles(target string) ([]string, error) {
+	matches, err := doublestar.Glob(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, m := range matches {
+		info, err := os.Stat(m)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			files = append(files, m)
+			continue
+		}
+
+		entries, err := os.ReadDir(m)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.Type().IsRegular() {
+				files = append(files, filepath.Join(m, e.Name()))
+			}
+		}
+	}
+	return files, nil
+}
+
+// parseKubeConfig parses the kubeconfig at file. Relative certificate paths
+// are resolved against the directory of file.
+func parseKubeConfig(file string) (*prober.KubeConfig, error) {
+	k := &prober.KubeConfig{}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	err = yaml.Unmarshal(data, k)
+	if err != nil {
+		return nil, err
+	}
+	k.Path = file
+	clusters := []prober.KubeConfigCluster{}
+	users := []prober.KubeConfigUser{}
+	for _, c := range k.Clusters {
+		// Path is relative to kubeconfig path
+		if c.Cluster.CertificateAuthority != "" && !filepath.IsAbs(c.Cluster.CertificateAuthority) {
+			newPath := filepath.Join(filepath.Dir(k.Path), c.Cluster.CertificateAuthority)
+			c.Cluster.CertificateAuthority = newPath
+		}
+		clusters = append(clusters, c)
+	}
+	for _, u := range k.Users {
+		// Path is relative to kubeconfig path
+		if u.User.ClientCertificate != "" && !filepath.IsAbs(u.User.ClientCertificate) {
+			newPath := filepath.Join(filepath.Dir(k.Path), u.User.ClientCertificate)
+			u.User.ClientCertificate = newPath
+		}
+		users = append(users, u)
+	}
+	k.Clusters = clusters
+	k.Users = users
+	return k, nil
+}
+
+func collectKubeconfigMetrics(ctx context.Context, logger log.Logger, kubeconfigs []prober.KubeConfig, registry *prometheus.Registry) error {
+	var (
+		totalCerts         int
+		kubeconfigNotAfter = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "kubeconfig", "cert_not_after"),
+				Help: "NotAfter expressed as a Unix Epoch Time for a certificate found in a kubeconfig",
+			},
+			[]string{"kubeconfig", "name", "type", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		)
+		kubeconfigNotBefore = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "kubeconfig", "cert_not_before"),
+				Help: "NotBefore expressed as a Unix Epoch Time for a certificate found in a kubeconfig",
+			},
+			[]string{"kubeconfig", "name", "type", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		)
+	)
+	registry.MustRegister(kubeconfigNotAfter, kubeconfigNotBefore)
+
+	// collect reads the certificates of an entry from either its inline
+	// base64-encoded data or the file at path.
+	collect := func(kubeconfig prober.KubeConfig, name, entryType, inline, path string) error {
+		var (
+			data []byte
+			err  error
+		)
+		if inline != "" {
+			data, err = base64.StdEncoding.DecodeString(inline)
+			if err != nil {
+				return err
+			}
+		} else if path != "" {
+			data, err = os.ReadFile(path)
+			if err != nil {
+				level.Debug(logger).Log("msg", fmt.Sprintf("Error reading file %s: %s", path, err))
+				return err
+			}
+		}
+		if data == nil {
+			return nil
+		}
+		certs, err := decodeCertificates(data)
+		if err != nil {
+			return err
+		}
+		totalCerts += len(certs)
+		for i, cert := range certs {
+			labels := append([]string{kubeconfig.Path, name, entryType}, labelValues(cert, i)...)
+			recordCert(ctx, map[string]string{"kubeconfig": kubeconfig.Path, "name": name, "type": entryType}, i, cert)
+
+			if !cert.NotAfter.IsZero() {
+				kubeconfigNotAfter.WithLabelValues(labels...).Set(float64(cert.NotAfter.Unix()))
+			}
+
+			if !cert.NotBefore.IsZero() {
+				kubeconfigNotBefore.WithLabelValues(labels...).Set(float64(cert.NotBefore.Unix()))
+			}
+		}
+		return nil
+	}
+
+	for _, kubeconfig := range kubeconfigs {
+		for _, c := range kubeconfig.Clusters {
+			if err := collect(kubeconfig, c.Name, "cluster", c.Cluster.CertificateAuthorityData, c.Cluster.CertificateAuthority); err != nil {
+				return err
+			}
+		}
+		for _, u := range kubeconfig.Users {
+			if err := collect(kubeconfig, u.Name, "user", u.User.ClientCertificateData, u.User.ClientCertificate); err != nil {
+				return err
+			}
+		}
+	}
+
+	if totalCerts == 0 {
+		return fmt.Errorf("No certificates found")
+	}
+
+	return nil
+}
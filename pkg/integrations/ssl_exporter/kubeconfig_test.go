@@ -0,0 +1,78 @@
+package ssl_exporter
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeKubeconfig_Directory(t *testing.T) {
+	certPEM, _ := generateKeyPair(t)
+	dir := t.TempDir()
+
+	writeKubeconfig := func(name string) string {
+		path := filepath.Join(dir, name)
+		kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: %[1]s
+  cluster:
+    server: https://%[1]s:6443
+    certificate-authority-data: %[2]s
+`, name, base64.StdEncoding.EncodeToString(certPEM))
+		require.NoError(t, os.WriteFile(path, []byte(kubeconfig), 0600))
+		return path
+	}
+	admin := writeKubeconfig("admin.conf")
+	kubelet := writeKubeconfig("kubelet.conf")
+
+	// Files which aren't kubeconfigs are skipped.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.crt"), certPEM, 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "manifests"), 0700))
+
+	tt := []struct {
+		target string
+		expect []string
+	}{
+		{admin, []string{admin}},
+		{dir, []string{admin, kubelet}},
+		{filepath.Join(dir, "*.conf"), []string{admin, kubelet}},
+		{filepath.Join(dir, "kubelet*"), []string{kubelet}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.target, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			require.NoError(t, probeKubeconfig(context.Background(), log.NewNopLogger(), tc.target, defaultSSLConfig().Modules["kubeconfig"], reg))
+
+			fams, err := reg.Gather()
+			require.NoError(t, err)
+
+			var kubeconfigs []string
+			for _, mf := range fams {
+				if mf.GetName() != "ssl_kubeconfig_cert_not_after" {
+					continue
+				}
+				for _, m := range mf.GetMetric() {
+					for _, l := range m.GetLabel() {
+						if l.GetName() == "kubeconfig" {
+							kubeconfigs = append(kubeconfigs, l.GetValue())
+						}
+					}
+				}
+			}
+			sort.Strings(kubeconfigs)
+			require.Equal(t, tc.expect, kubeconfigs)
+		})
+	}
+
+	err := probeKubeconfig(context.Background(), log.NewNopLogger(), filepath.Join(dir, "*.yaml"), defaultSSLConfig().Modules["kubeconfig"], prometheus.NewRegistry())
+	require.EqualError(t, err, "kubeconfig not found: "+filepath.Join(dir, "*.yaml"))
+}
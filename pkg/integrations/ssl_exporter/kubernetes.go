@@ -0,0 +1,152 @@
+package ssl_exporter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v2"
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"github.com/ribbybibby/ssl_exporter/v2/prober"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// KubernetesSecretsConfig selects the secrets collected by the kubernetes
+// prober, in addition to matching the target.
+type KubernetesSecretsConfig struct {
+	// LabelSelector and FieldSelector are Kubernetes label and field
+	// selectors which secrets must match.
+	LabelSelector string `yaml:"label_selector,omitempty"`
+	FieldSelector string `yaml:"field_selector,omitempty"`
+}
+
+// Validate validates the selectors.
+func (c *KubernetesSecretsConfig) Validate() error {
+	if _, err := labels.Parse(c.LabelSelector); err != nil {
+		return fmt.Errorf("invalid label_selector: %w", err)
+	}
+	if _, err := fields.ParseSelector(c.FieldSelector); err != nil {
+		return fmt.Errorf("invalid field_selector: %w", err)
+	}
+	return nil
+}
+
+// listOptions returns the options for listing the kubernetes.io/tls secrets
+// selected by c. c may be nil.
+func (c *KubernetesSecretsConfig) listOptions() metav1.ListOptions {
+	opts := metav1.ListOptions{FieldSelector: "type=kubernetes.io/tls"}
+	if c == nil {
+		return opts
+	}
+	if c.FieldSelector != "" {
+		opts.FieldSelector += "," + c.FieldSelector
+	}
+	opts.LabelSelector = c.LabelSelector
+	return opts
+}
+
+// newKubernetesProber returns a prober which collects certificate metrics
+// from kubernetes.io/tls Secrets matching the target, which is given as
+// <namespace>/<name> globs, and the selectors of cfg, which may be nil.
+//
+// Copied from github.com/ribbybibby/ssl_exporter/v2/prober.
+func newKubernetesProber(cfg *KubernetesSecretsConfig) prober.ProbeFn {
+	return func(ctx context.Context, logger log.Logger, target string, module ssl_config.Module, registry *prometheus.Registry) error {
+		client, err := newKubeClient(module.Kubernetes.Kubeconfig)
+		if err != nil {
+			return err
+		}
+
+		return probeKubernetesSecrets(ctx, target, cfg, registry, client)
+	}
+}
+
+func probeKubernetesSecrets(ctx context.Context, target string, cfg *KubernetesSecretsConfig, registry *prometheus.Registry, client kubernetes.Interface) error {
+	parts := strings.Split(target, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return prober.ErrKubeBadTarget
+	}
+
+	ns := parts[0]
+	name := parts[1]
+
+	var tlsSecrets []v1.Secret
+	secrets, err := client.CoreV1().Secrets("").List(ctx, cfg.listOptions())
+	if err != nil {
+		return err
+	}
+	for _, secret := range secrets.Items {
+		nMatch, err := doublestar.Match(ns, secret.Namespace)
+		if err != nil {
+			return err
+		}
+		sMatch, err := doublestar.Match(name, secret.Name)
+		if err != nil {
+			return err
+		}
+		if nMatch && sMatch {
+			tlsSecrets = append(tlsSecrets, secret)
+		}
+	}
+
+	return collectKubernetesSecretMetrics(ctx, tlsSecrets, registry)
+}
+
+func collectKubernetesSecretMetrics(ctx context.Context, secrets []v1.Secret, registry *prometheus.Registry) error {
+	var (
+		totalCerts         int
+		kubernetesNotAfter = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "kubernetes_cert_not_after"),
+				Help: "NotAfter expressed as a Unix Epoch Time for a certificate found in a kubernetes secret",
+			},
+			[]string{"namespace", "secret", "key", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		)
+		kubernetesNotBefore = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "kubernetes_cert_not_before"),
+				Help: "NotBefore expressed as a Unix Epoch Time for a certificate found in a kubernetes secret",
+			},
+			[]string{"namespace", "secret", "key", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		)
+	)
+	registry.MustRegister(kubernetesNotAfter, kubernetesNotBefore)
+
+	for _, secret := range secrets {
+		for _, key := range []string{"tls.crt", "ca.crt"} {
+			data := secret.Data[key]
+			if len(data) == 0 {
+				continue
+			}
+			certs, err := decodeCertificates(data)
+			if err != nil {
+				return err
+			}
+			totalCerts += len(certs)
+			for i, cert := range certs {
+				labels := append([]string{secret.Namespace, secret.Name, key}, labelValues(cert, i)...)
+				recordCert(ctx, map[string]string{"namespace": secret.Namespace, "secret": secret.Name, "key": key}, i, cert)
+
+				if !cert.NotAfter.IsZero() {
+					kubernetesNotAfter.WithLabelValues(labels...).Set(float64(cert.NotAfter.Unix()))
+				}
+
+				if !cert.NotBefore.IsZero() {
+					kubernetesNotBefore.WithLabelValues(labels...).Set(float64(cert.NotBefore.Unix()))
+				}
+			}
+		}
+	}
+
+	if totalCerts == 0 {
+		return fmt.Errorf("No certificates found")
+	}
+
+	return nil
+}
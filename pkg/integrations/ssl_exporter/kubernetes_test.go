@@ -0,0 +1,70 @@
+package ssl_exporter
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestProbeKubernetesSecrets_Selectors(t *testing.T) {
+	certPEM, keyPEM := generateKeyPair(t)
+
+	secret := func(namespace, name string, labels map[string]string) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+			Type:       v1.SecretTypeTLS,
+			Data:       map[string][]byte{"tls.crt": certPEM, "tls.key": keyPEM},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		secret("default", "frontend", map[string]string{"app": "frontend"}),
+		secret("payments", "api", map[string]string{"app": "api", "probe": "true"}),
+		secret("monitoring", "grafana", map[string]string{"app": "grafana", "probe": "true"}),
+	)
+
+	cfg := &KubernetesSecretsConfig{LabelSelector: "probe=true"}
+	require.NoError(t, cfg.Validate())
+
+	reg := prometheus.NewRegistry()
+	require.NoError(t, probeKubernetesSecrets(context.Background(), "*/*", cfg, reg, client))
+
+	fams, err := reg.Gather()
+	require.NoError(t, err)
+
+	var secrets []string
+	for _, mf := range fams {
+		if mf.GetName() != "ssl_kubernetes_cert_not_after" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			secrets = append(secrets, labels["namespace"]+"/"+labels["secret"])
+		}
+	}
+	sort.Strings(secrets)
+	require.Equal(t, []string{"monitoring/grafana", "payments/api"}, secrets)
+}
+
+func TestKubernetesSecretsConfig(t *testing.T) {
+	cfg := &KubernetesSecretsConfig{LabelSelector: "app in (api, grafana)", FieldSelector: "metadata.namespace!=kube-system"}
+	require.NoError(t, cfg.Validate())
+
+	opts := cfg.listOptions()
+	require.Equal(t, "app in (api, grafana)", opts.LabelSelector)
+	require.Equal(t, "type=kubernetes.io/tls,metadata.namespace!=kube-system", opts.FieldSelector)
+
+	var nilCfg *KubernetesSecretsConfig
+	require.Equal(t, "type=kubernetes.io/tls", nilCfg.listOptions().FieldSelector)
+
+	require.Error(t, (&KubernetesSecretsConfig{LabelSelector: "app in ("}).Validate())
+	require.Error(t, (&KubernetesSecretsConfig{FieldSelector: "metadata.name"}).Validate())
+}
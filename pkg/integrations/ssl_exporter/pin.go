@@ -0,0 +1,62 @@
+package ssl_exporter
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+)
+
+// PinConfig pins the certificates expected to be collected when probing a
+// target. A probe matches the pins if any collected certificate, like the
+// leaf or one of its issuers, matches any pin.
+type PinConfig struct {
+	// SPKISHA256 are base64-encoded SHA-256 hashes of the DER-encoded
+	// SubjectPublicKeyInfo of certificates, like the pins of HPKP and curl's
+	// --pinnedpubkey. They keep matching when a certificate is renewed with
+	// the same key.
+	SPKISHA256 []string `yaml:"spki_sha256,omitempty"`
+
+	// SerialNumbers are the decimal serial numbers of certificates, as in
+	// the serial_no label of certificate metrics.
+	SerialNumbers []string `yaml:"serial_numbers,omitempty"`
+}
+
+// Validate validates the pins.
+func (c *PinConfig) Validate() error {
+	if len(c.SPKISHA256) == 0 && len(c.SerialNumbers) == 0 {
+		return fmt.Errorf("one of spki_sha256 or serial_numbers must be set")
+	}
+	for _, pin := range c.SPKISHA256 {
+		hash, err := base64.StdEncoding.DecodeString(pin)
+		if err != nil || len(hash) != sha256.Size {
+			return fmt.Errorf("spki_sha256 pin %q isn't a base64-encoded SHA-256 hash", pin)
+		}
+	}
+	for _, serial := range c.SerialNumbers {
+		if _, ok := new(big.Int).SetString(serial, 10); !ok {
+			return fmt.Errorf("serial number %q isn't a decimal number", serial)
+		}
+	}
+	return nil
+}
+
+// matches returns true if any of certs matches any pin.
+func (c *PinConfig) matches(certs []recordedCert) bool {
+	for _, rc := range certs {
+		spki := sha256.Sum256(rc.cert.RawSubjectPublicKeyInfo)
+		hash := base64.StdEncoding.EncodeToString(spki[:])
+		for _, pin := range c.SPKISHA256 {
+			if pin == hash {
+				return true
+			}
+		}
+
+		for _, serial := range c.SerialNumbers {
+			if n, ok := new(big.Int).SetString(serial, 10); ok && n.Cmp(rc.cert.SerialNumber) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package ssl_exporter
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExporter_Pin(t *testing.T) {
+	certPEM, _ := generateKeyPair(t)
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	spki := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	require.NoError(t, os.WriteFile(path, certPEM, 0600))
+
+	tt := []struct {
+		name   string
+		pin    PinConfig
+		expect string
+	}{
+		{"spki", PinConfig{SPKISHA256: []string{base64.StdEncoding.EncodeToString(spki[:])}}, "1"},
+		{"serial", PinConfig{SerialNumbers: []string{"2", "1"}}, "1"},
+		{"swapped", PinConfig{SerialNumbers: []string{"2"}}, "0"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			pin := tc.pin
+			require.NoError(t, pin.Validate())
+
+			target := SSLTarget{Name: "example", Target: path, Module: "file", Pin: &pin}
+			e, err := NewSSLExporter(Options{
+				Namespace:           "ssl_exporter",
+				SSLTargets:          []SSLTarget{target},
+				SSLConfig:           ssl_config.DefaultConfig,
+				MaxConcurrentProbes: 1,
+				log:                 log.NewNopLogger(),
+			})
+			require.NoError(t, err)
+
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(probeResults(e.probeTarget(context.Background(), target)))
+			expect := `
+# HELP ssl_cert_pin_match If a certificate collected by the probe matches a pin of the target
+# TYPE ssl_cert_pin_match gauge
+ssl_cert_pin_match{ssl_target="example"} ` + tc.expect + "\n"
+			require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expect), "ssl_cert_pin_match"))
+
+			// The certificates are still recorded for the status page.
+			require.Len(t, e.targetStatuses()[0].certs, 1)
+		})
+	}
+}
+
+func TestPinConfig_Validate(t *testing.T) {
+	require.Error(t, (&PinConfig{}).Validate())
+	require.Error(t, (&PinConfig{SPKISHA256: []string{"not a hash"}}).Validate())
+	require.Error(t, (&PinConfig{SPKISHA256: []string{base64.StdEncoding.EncodeToString([]byte("short"))}}).Validate())
+	require.Error(t, (&PinConfig{SerialNumbers: []string{"0x01"}}).Validate())
+}
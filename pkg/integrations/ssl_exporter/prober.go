@@ -0,0 +1,192 @@
+package ssl_exporter
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"github.com/ribbybibby/ssl_exporter/v2/prober"
+)
+
+// localProbers are the probers implemented by the integration in addition
+// to the upstream probers, keyed by name.
+var localProbers = map[string]prober.ProbeFn{
+	"keystore":        newKeystoreProber(""),
+	vaultPKIProber:    newVaultProber(vaultPKIProber, nil),
+	vaultSecretProber: newVaultProber(vaultSecretProber, nil),
+	sftpProber:        newSFTPProber(nil),
+}
+
+// dialFunc dials a connection to addr.
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// proberOptions customizes the connections made by the tcp and https probers
+// of the integration.
+type proberOptions struct {
+	// dial dials connections to targets, like through a proxy. Defaults to a
+	// net.Dialer.
+	dial dialFunc
+
+	// ip is the address connected to instead of resolving the host of
+	// targets.
+	ip string
+
+	// ipProtocol is the IP protocol used for resolving the host of targets.
+	ipProtocol string
+
+	// clientCert is the client certificate presented to targets. If nil,
+	// the client certificate of the module is used.
+	clientCert *ClientCertConfig
+
+	// tlsRefs are the settings of the TLS config of the module which
+	// reference secrets. They're resolved with secrets on every probe.
+	tlsRefs *tlsRefs
+	secrets *secretResolver
+
+	// crls is used for checking the certificates of targets against their
+	// CRLs. If nil, CRLs aren't checked.
+	crls *crlCache
+
+	// checkSessionResumption makes the tcp prober reconnect to targets to
+	// check whether sessions are resumed.
+	checkSessionResumption bool
+
+	// alpnProtocols are offered to targets through ALPN by the tcp prober,
+	// which fails unless one of them is negotiated.
+	alpnProtocols []string
+
+	// ehloName is sent with the EHLO command when upgrading connections
+	// to targets through the smtp STARTTLS protocol.
+	ehloName string
+}
+
+// dialer returns the dialFunc used for connecting to targets. Unless
+// connections are made to ip or through a proxy which resolves the host of
+// targets itself, hosts are resolved by the integration and the metrics of
+// resolving them are registered to registry. dialer must only be called once
+// per probe.
+func (opts proberOptions) dialer(registry *prometheus.Registry) dialFunc {
+	switch {
+	case opts.ip != "":
+		return dialIP(opts.dial, opts.ip)
+	case opts.dial != nil && opts.ipProtocol == "":
+		return opts.dial
+	default:
+		return newResolvingDialer(opts.dial, opts.ipProtocol, registry)
+	}
+}
+
+// configureTLS applies opts to cfg. Metrics collected while verifying
+// connections are registered to registry, and the certificates presented by
+// targets are recorded with the certRecorder of ctx.
+func (opts proberOptions) configureTLS(ctx context.Context, cfg *tls.Config, registry *prometheus.Registry) error {
+	secrets := opts.secrets
+	if secrets == nil {
+		secrets = newSecretResolver(nil)
+	}
+	if opts.tlsRefs != nil {
+		if err := opts.tlsRefs.apply(ctx, secrets, cfg); err != nil {
+			return err
+		}
+	}
+	if opts.clientCert != nil {
+		cert, err := opts.clientCert.load(ctx, secrets)
+		if err != nil {
+			return fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	verify := cfg.VerifyConnection
+	cfg.VerifyConnection = func(state tls.ConnectionState) error {
+		if err := verify(state); err != nil {
+			return err
+		}
+		for i, cert := range state.PeerCertificates {
+			recordCert(ctx, nil, i, cert)
+		}
+		if opts.crls == nil {
+			return nil
+		}
+		return opts.crls.collectMetrics(ctx, state, registry)
+	}
+	return nil
+}
+
+// targetProber returns the module and prober used for probing target. The
+// upstream probers are replaced by the probers of the integration, which
+// support the per-target settings of SSLTarget and add the
+// fingerprint_sha256 and chain_position labels to certificate metrics. If ip
+// is set, the tcp and https probers connect to ip instead of resolving the
+// host of the target.
+func (e *Exporter) targetProber(target SSLTarget, moduleName string, module ssl_config.Module, probeFunc prober.ProbeFn, ip string) (ssl_config.Module, prober.ProbeFn, error) {
+	if target.ServerName != "" {
+		module.TLSConfig.ServerName = target.ServerName
+	}
+	if target.StartTLS != nil {
+		module.TCP.StartTLS = target.StartTLS.Protocol
+	}
+
+	proxyURL, err := e.proxyURL(target, module)
+	if err != nil {
+		return module, nil, err
+	}
+
+	moduleOpts := e.options.ModuleOptions[moduleName]
+	ipProtocol := e.ipProtocol(target, moduleName)
+
+	var refs *tlsRefs
+	module.TLSConfig, refs = splitTLSRefs(module.TLSConfig)
+
+	opts := proberOptions{
+		ip:                     ip,
+		ipProtocol:             ipProtocol,
+		clientCert:             target.ClientCert,
+		tlsRefs:                refs,
+		secrets:                e.secrets,
+		checkSessionResumption: moduleOpts.CheckSessionResumption,
+		alpnProtocols:          moduleOpts.ALPNProtocols,
+	}
+	if target.StartTLS != nil {
+		opts.ehloName = target.StartTLS.EHLOName
+	}
+	if moduleOpts.CheckCRL {
+		opts.crls = e.crls
+	}
+
+	switch module.Prober {
+	case "tcp":
+		if proxyURL != nil {
+			opts.dial, err = newProxyDialer(proxyURL)
+			if err != nil {
+				return module, nil, err
+			}
+		}
+		return module, newTCPProber(opts), nil
+	case "https":
+		if proxyURL != nil {
+			if ip != "" || ipProtocol != "" {
+				return module, nil, fmt.Errorf("probe_all_ips and ip_protocol aren't supported by the https prober when probing through a proxy")
+			}
+			module.HTTPS.ProxyURL = ssl_config.URL{URL: proxyURL}
+		}
+		return module, newHTTPSProber(opts), nil
+	case "file":
+		return module, probeFile, nil
+	case "kubernetes":
+		return module, newKubernetesProber(target.KubernetesSecrets), nil
+	case "kubeconfig":
+		return module, probeKubeconfig, nil
+	case "keystore":
+		return module, newKeystoreProber(string(target.KeystorePassword)), nil
+	case vaultPKIProber, vaultSecretProber:
+		return module, newVaultProber(module.Prober, e.vault), nil
+	case sftpProber:
+		return module, newSFTPProber(target.SSH), nil
+	default:
+		return module, probeFunc, nil
+	}
+}
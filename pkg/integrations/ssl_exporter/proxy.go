@@ -0,0 +1,162 @@
+package ssl_exporter
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"golang.org/x/net/proxy"
+)
+
+// proxyURL returns the URL of the proxy used for probing target with module,
+// or nil if no proxy is used. The proxy of the target takes precedence over
+// the https.proxy_url of the module, which takes precedence over
+// Options.ProxyURL.
+func (e *Exporter) proxyURL(target SSLTarget, module ssl_config.Module) (*url.URL, error) {
+	rawURL := target.ProxyURL
+	if rawURL == "" {
+		if module.Prober == "https" && module.HTTPS.ProxyURL.URL != nil {
+			return module.HTTPS.ProxyURL.URL, nil
+		}
+		rawURL = e.options.ProxyURL
+	}
+	if rawURL == "" {
+		return nil, nil
+	}
+
+	proxyURL, err := parseProxyURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy_url: %w", err)
+	}
+	return proxyURL, nil
+}
+
+// parseProxyURL parses and validates the URL of a proxy. HTTP CONNECT
+// proxies are supported through the http and https schemes, and SOCKS5
+// proxies through the socks5 scheme.
+func parseProxyURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, must be http, https or socks5", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy URL %q is missing a host", s)
+	}
+	return u, nil
+}
+
+// newProxyDialer returns a dialFunc which dials addresses through the proxy
+// at proxyURL.
+func newProxyDialer(proxyURL *url.URL) (dialFunc, error) {
+	forward := &net.Dialer{}
+
+	switch proxyURL.Scheme {
+	case "socks5":
+		d, err := proxy.FromURL(proxyURL, forward)
+		if err != nil {
+			return nil, err
+		}
+		cd, ok := d.(proxy.ContextDialer)
+		if !ok {
+			return nil, fmt.Errorf("socks5 dialer does not support contexts")
+		}
+		return cd.DialContext, nil
+	case "http", "https":
+		return func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialConnect(ctx, forward, proxyURL, addr)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+}
+
+// dialConnect opens a tunnel to addr through the HTTP proxy at proxyURL by
+// issuing a CONNECT request.
+func dialConnect(ctx context.Context, forward *net.Dialer, proxyURL *url.URL, addr string) (net.Conn, error) {
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+
+	conn, err := forward.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to proxy: %w", err)
+	}
+	if proxyURL.Scheme == "https" {
+		conn = tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+	}
+
+	// Abort the CONNECT request if ctx is canceled before the tunnel is
+	// established.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u := proxyURL.User; u != nil {
+		password, _ := u.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send CONNECT request to proxy: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read CONNECT response from proxy: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused CONNECT to %s: %s", addr, resp.Status)
+	}
+	// The body of a successful CONNECT response is the tunnel, so it must not
+	// be read or closed.
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	// The target may send data before the client does, like the greeting of
+	// STARTTLS protocols, which could already be buffered by br.
+	return &bufferedConn{Conn: conn, r: br}, nil
+}
+
+// bufferedConn is a net.Conn which reads through a bufio.Reader.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,141 @@
+package ssl_exporter
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Default backoffs of quarantined targets.
+const (
+	defaultQuarantineInitialBackoff = time.Minute
+	defaultQuarantineMaxBackoff     = time.Hour
+)
+
+// QuarantineConfig configures quarantining targets which fail consecutive
+// probes. Quarantined targets aren't probed until their backoff expires, and
+// the results of their last probe are served instead.
+type QuarantineConfig struct {
+	// FailureThreshold is the number of consecutive failed probes after
+	// which a target is quarantined. Zero disables quarantining.
+	FailureThreshold int `yaml:"failure_threshold,omitempty"`
+
+	// InitialBackoff is how long a target is quarantined for the first time.
+	// The backoff doubles every time the target fails again after its
+	// quarantine, up to MaxBackoff.
+	InitialBackoff time.Duration `yaml:"initial_backoff,omitempty"`
+	MaxBackoff     time.Duration `yaml:"max_backoff,omitempty"`
+}
+
+// Enabled returns true if targets are quarantined.
+func (c QuarantineConfig) Enabled() bool {
+	return c.FailureThreshold > 0
+}
+
+// Validate validates the quarantine config.
+func (c QuarantineConfig) Validate() error {
+	switch {
+	case c.FailureThreshold < 0:
+		return fmt.Errorf("failure_threshold must not be negative")
+	case c.InitialBackoff < 0 || c.MaxBackoff < 0:
+		return fmt.Errorf("initial_backoff and max_backoff must not be negative")
+	case c.MaxBackoff > 0 && c.MaxBackoff < c.InitialBackoff:
+		return fmt.Errorf("max_backoff must not be less than initial_backoff")
+	}
+	return nil
+}
+
+// quarantine tracks the consecutive failures of targets and quarantines them
+// once they fail too often.
+type quarantine struct {
+	cfg QuarantineConfig
+	now func() time.Time
+
+	mut     sync.Mutex
+	targets map[string]*quarantineState // target name -> state
+}
+
+// quarantineState is the state of a target which failed its most recent
+// probes.
+type quarantineState struct {
+	failures int
+	backoff  time.Duration
+	until    time.Time
+
+	// metrics are the results of the probe which quarantined the target.
+	metrics []prometheus.Metric
+}
+
+func newQuarantine(cfg QuarantineConfig) *quarantine {
+	if cfg.InitialBackoff == 0 {
+		cfg.InitialBackoff = defaultQuarantineInitialBackoff
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = defaultQuarantineMaxBackoff
+		if cfg.MaxBackoff < cfg.InitialBackoff {
+			cfg.MaxBackoff = cfg.InitialBackoff
+		}
+	}
+
+	return &quarantine{
+		cfg:     cfg,
+		now:     time.Now,
+		targets: make(map[string]*quarantineState),
+	}
+}
+
+// check returns the results of the last probe of the target named name and
+// true if the target is quarantined and shouldn't be probed.
+func (q *quarantine) check(name string) ([]prometheus.Metric, bool) {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	st, ok := q.targets[name]
+	if !ok || !q.now().Before(st.until) {
+		return nil, false
+	}
+	return st.metrics, true
+}
+
+// update records the result of a probe of the target named name, which
+// collected metrics and failed if err is non-nil.
+func (q *quarantine) update(name string, metrics []prometheus.Metric, err error) {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	if err == nil {
+		delete(q.targets, name)
+		return
+	}
+
+	st, ok := q.targets[name]
+	if !ok {
+		st = &quarantineState{}
+		q.targets[name] = st
+	}
+	st.failures++
+	if st.failures < q.cfg.FailureThreshold {
+		return
+	}
+
+	if st.backoff == 0 {
+		st.backoff = q.cfg.InitialBackoff
+	} else {
+		st.backoff *= 2
+		if st.backoff > q.cfg.MaxBackoff {
+			st.backoff = q.cfg.MaxBackoff
+		}
+	}
+	st.until = q.now().Add(st.backoff)
+	st.metrics = metrics
+}
+
+// forget removes the state of a target which is no longer probed.
+func (q *quarantine) forget(name string) {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	delete(q.targets, name)
+}
@@ -0,0 +1,72 @@
+package ssl_exporter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuarantine(t *testing.T) {
+	now := time.Now()
+	q := newQuarantine(QuarantineConfig{FailureThreshold: 2, InitialBackoff: time.Minute, MaxBackoff: 3 * time.Minute})
+	q.now = func() time.Time { return now }
+
+	fail := fmt.Errorf("probe failed")
+
+	q.update("example", nil, fail)
+	_, quarantined := q.check("example")
+	require.False(t, quarantined, "target shouldn't be quarantined before reaching the threshold")
+
+	// Reaching the threshold quarantines the target for the initial backoff.
+	q.update("example", nil, fail)
+	_, quarantined = q.check("example")
+	require.True(t, quarantined)
+
+	// The backoff doubles every time the target fails after its quarantine,
+	// up to the max backoff.
+	for _, backoff := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		now = now.Add(backoff - time.Second)
+		_, quarantined = q.check("example")
+		require.True(t, quarantined)
+
+		now = now.Add(time.Second)
+		_, quarantined = q.check("example")
+		require.False(t, quarantined, "target should be probed once its backoff expires")
+		q.update("example", nil, fail)
+	}
+
+	// A successful probe ends the quarantine.
+	now = now.Add(3 * time.Minute)
+	q.update("example", nil, nil)
+	q.update("example", nil, fail)
+	_, quarantined = q.check("example")
+	require.False(t, quarantined)
+}
+
+func TestExporter_Quarantine(t *testing.T) {
+	target := SSLTarget{Name: "missing", Target: "/does/not/exist.pem", Module: "file"}
+
+	e, err := NewSSLExporter(Options{
+		Namespace:           "ssl_exporter",
+		SSLTargets:          []SSLTarget{target},
+		SSLConfig:           ssl_config.DefaultConfig,
+		MaxConcurrentProbes: 1,
+		Quarantine:          QuarantineConfig{FailureThreshold: 1},
+		log:                 log.NewNopLogger(),
+	})
+	require.NoError(t, err)
+
+	first := e.probeTarget(context.Background(), target)
+	require.NotEmpty(t, first)
+
+	// The quarantined target isn't probed again, and the results of its last
+	// probe are returned instead.
+	require.Equal(t, first, e.probeTarget(context.Background(), target))
+	require.Equal(t, float64(1), counterValue(t, e, "ssl_probes_total", target.Name))
+	require.Equal(t, float64(1), counterValue(t, e, "ssl_target_quarantined", target.Name))
+}
@@ -0,0 +1,195 @@
+package ssl_exporter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// IP protocols which can be used for probing targets.
+const (
+	ipProtocolIP4       = "ip4"
+	ipProtocolIP6       = "ip6"
+	ipProtocolPreferIP4 = "prefer-ip4"
+	ipProtocolPreferIP6 = "prefer-ip6"
+)
+
+// validateIPProtocol returns an error if protocol isn't a supported IP
+// protocol. An empty protocol is valid and uses any address family.
+func validateIPProtocol(protocol string) error {
+	switch protocol {
+	case "", ipProtocolIP4, ipProtocolIP6, ipProtocolPreferIP4, ipProtocolPreferIP6:
+		return nil
+	default:
+		return fmt.Errorf("invalid ip_protocol %q, must be one of %s, %s, %s, or %s", protocol, ipProtocolIP4, ipProtocolIP6, ipProtocolPreferIP4, ipProtocolPreferIP6)
+	}
+}
+
+// resolveTargetIPs returns every IP address of the host of target, which is
+// probed by the prober named proberName, using protocol. Only the tcp and
+// https probers are supported.
+func resolveTargetIPs(ctx context.Context, proberName, target, protocol string) ([]string, error) {
+	host, err := targetHost(proberName, target)
+	if err != nil {
+		return nil, err
+	}
+	return resolveIPs(ctx, host, protocol)
+}
+
+// resolveIPs returns the IP addresses of host which can be used with
+// protocol. With the prefer-ip4 and prefer-ip6 protocols, addresses of the
+// other family are only returned if host has no address of the preferred
+// family.
+func resolveIPs(ctx context.Context, host, protocol string) ([]string, error) {
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		network := "ip"
+		if protocol == ipProtocolIP4 || protocol == ipProtocolIP6 {
+			network = protocol
+		}
+		var err error
+		ips, err = net.DefaultResolver.LookupIP(ctx, network, host)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var want, other []string
+	for _, ip := range ips {
+		isIP4 := ip.To4() != nil
+		switch protocol {
+		case ipProtocolIP4, ipProtocolPreferIP4:
+			if isIP4 {
+				want = append(want, ip.String())
+			} else {
+				other = append(other, ip.String())
+			}
+		case ipProtocolIP6, ipProtocolPreferIP6:
+			if !isIP4 {
+				want = append(want, ip.String())
+			} else {
+				other = append(other, ip.String())
+			}
+		default:
+			want = append(want, ip.String())
+		}
+	}
+
+	if len(want) == 0 && (protocol == ipProtocolPreferIP4 || protocol == ipProtocolPreferIP6) {
+		want = other
+	}
+	if len(want) == 0 {
+		return nil, &net.DNSError{Err: "no suitable address found", Name: host, IsNotFound: true}
+	}
+	return want, nil
+}
+
+// targetHost returns the host of target, which is probed by the prober named
+// proberName.
+func targetHost(proberName, target string) (string, error) {
+	switch proberName {
+	case "tcp":
+		host, _, err := net.SplitHostPort(target)
+		return host, err
+	case "https":
+		if !strings.HasPrefix(target, "https://") {
+			target = "https://" + target
+		}
+		u, err := url.Parse(target)
+		if err != nil {
+			return "", err
+		}
+		return u.Hostname(), nil
+	default:
+		return "", fmt.Errorf("probe_all_ips isn't supported by the %s prober", proberName)
+	}
+}
+
+// newResolvingDialer returns a dialFunc which resolves the host of the
+// dialed address using protocol and connects to its addresses in turn until a
+// connection succeeds. Connections are dialed with dial, which defaults to a
+// net.Dialer. How long resolving took and whether the connection fell back
+// from IPv6 to IPv4 are registered to registry.
+func newResolvingDialer(dial dialFunc, protocol string, registry *prometheus.Registry) dialFunc {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	var (
+		dnsLookup = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "probe_dns_lookup_seconds"),
+				Help: "Time taken to resolve the host of the target",
+			},
+		)
+		ipFallback = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "probe_ip_fallback"),
+				Help: "If the probe fell back to IPv4 after failing to connect over IPv6",
+			},
+		)
+	)
+	registry.MustRegister(dnsLookup, ipFallback)
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		ips, err := resolveIPs(ctx, host, protocol)
+		dnsLookup.Set(time.Since(start).Seconds())
+		if err != nil {
+			return nil, err
+		}
+
+		var (
+			firstErr  error
+			failedIP6 bool
+		)
+		for _, ip := range ips {
+			isIP4 := net.ParseIP(ip).To4() != nil
+			conn, err := dial(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				if isIP4 && failedIP6 {
+					ipFallback.Set(1)
+				}
+				return conn, nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			if !isIP4 {
+				failedIP6 = true
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, firstErr
+	}
+}
+
+// dialIP returns a dialFunc which connects to ip instead of the host of the
+// dialed address, keeping its port. Connections are dialed with dial, which
+// defaults to a net.Dialer.
+func dialIP(dial dialFunc, ip string) dialFunc {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		return dial(ctx, network, net.JoinHostPort(ip, port))
+	}
+}
@@ -0,0 +1,178 @@
+package ssl_exporter
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetHost(t *testing.T) {
+	tt := []struct {
+		prober, target, expect string
+	}{
+		{"tcp", "example.com:443", "example.com"},
+		{"tcp", "[::1]:443", "::1"},
+		{"https", "example.com", "example.com"},
+		{"https", "https://example.com:8443/path", "example.com"},
+	}
+	for _, tc := range tt {
+		host, err := targetHost(tc.prober, tc.target)
+		require.NoError(t, err)
+		require.Equal(t, tc.expect, host)
+	}
+
+	_, err := targetHost("file", "/etc/ssl/cert.pem")
+	require.EqualError(t, err, "probe_all_ips isn't supported by the file prober")
+}
+
+func TestExporter_ProbeAllIPs(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	target := SSLTarget{Name: "server", Target: srv.Listener.Addr().String(), ProbeAllIPs: true}
+	e, err := NewSSLExporter(Options{
+		Namespace:  "ssl_exporter",
+		SSLTargets: []SSLTarget{target},
+		SSLConfig: &ssl_config.Config{
+			Modules: map[string]ssl_config.Module{
+				"tcp": {Prober: "tcp", TLSConfig: ssl_config.TLSConfig{InsecureSkipVerify: true}},
+			},
+		},
+		DefaultModule:       "tcp",
+		MaxConcurrentProbes: 1,
+		log:                 log.NewNopLogger(),
+	})
+	require.NoError(t, err)
+
+	metrics, err := e.probe(context.Background(), target)
+	require.NoError(t, err)
+	require.NotEmpty(t, metrics)
+
+	for _, m := range metrics {
+		labels := metricLabels(t, m)
+		require.Equal(t, "127.0.0.1", labels[ipLabel], m.Desc().String())
+	}
+}
+
+func TestExporter_ProbeAllIPs_Discovered(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	static := SSLTarget{Name: "static", Target: srv.Listener.Addr().String()}
+	e, err := NewSSLExporter(Options{
+		Namespace:  "ssl_exporter",
+		SSLTargets: []SSLTarget{static},
+		SSLConfig: &ssl_config.Config{
+			Modules: map[string]ssl_config.Module{
+				"tcp": {Prober: "tcp", TLSConfig: ssl_config.TLSConfig{InsecureSkipVerify: true}},
+			},
+		},
+		DefaultModule:       "tcp",
+		MaxConcurrentProbes: 1,
+		log:                 log.NewNopLogger(),
+	})
+	require.NoError(t, err)
+
+	discovered := SSLTarget{Name: "discovered", Target: srv.Listener.Addr().String(), ProbeAllIPs: true}
+	e.setDiscoveredTargets([]SSLTarget{discovered})
+
+	// Once a discovered target probes all IPs, the metrics of every target
+	// have the ip label, which is empty for other targets.
+	for _, tc := range []struct {
+		target SSLTarget
+		ip     string
+	}{
+		{static, ""},
+		{discovered, "127.0.0.1"},
+	} {
+		metrics, err := e.probe(context.Background(), tc.target)
+		require.NoError(t, err)
+		require.NotEmpty(t, metrics)
+
+		for _, m := range metrics {
+			labels := metricLabels(t, m)
+			require.Contains(t, labels, ipLabel, m.Desc().String())
+			require.Equal(t, tc.ip, labels[ipLabel], m.Desc().String())
+		}
+	}
+}
+
+// metricLabels returns the labels of m.
+func metricLabels(t *testing.T, m prometheus.Metric) map[string]string {
+	t.Helper()
+
+	var pb dto.Metric
+	require.NoError(t, m.Write(&pb))
+
+	labels := make(map[string]string)
+	for _, l := range pb.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	return labels
+}
+
+func TestResolveIPs(t *testing.T) {
+	tt := []struct {
+		host, protocol string
+		expect         []string
+		err            bool
+	}{
+		{"127.0.0.1", "", []string{"127.0.0.1"}, false},
+		{"127.0.0.1", ipProtocolIP4, []string{"127.0.0.1"}, false},
+		{"127.0.0.1", ipProtocolIP6, nil, true},
+		{"127.0.0.1", ipProtocolPreferIP6, []string{"127.0.0.1"}, false},
+		{"::1", ipProtocolPreferIP4, []string{"::1"}, false},
+		{"::1", ipProtocolIP4, nil, true},
+	}
+	for _, tc := range tt {
+		ips, err := resolveIPs(context.Background(), tc.host, tc.protocol)
+		if tc.err {
+			require.Error(t, err)
+			require.Equal(t, reasonDNSError, classifyProbeError(err))
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, tc.expect, ips)
+	}
+
+	require.Error(t, validateIPProtocol("ipv4"))
+	require.NoError(t, validateIPProtocol(ipProtocolPreferIP6))
+}
+
+func TestResolvingDialer(t *testing.T) {
+	var dialed []string
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+
+	reg := prometheus.NewRegistry()
+	conn, err := newResolvingDialer(dial, ipProtocolIP4, reg)(context.Background(), "tcp", "127.0.0.1:443")
+	require.NoError(t, err)
+	conn.Close()
+	require.Equal(t, []string{"127.0.0.1:443"}, dialed)
+
+	fams, err := reg.Gather()
+	require.NoError(t, err)
+	values := make(map[string]float64)
+	for _, mf := range fams {
+		values[mf.GetName()] = mf.GetMetric()[0].GetGauge().GetValue()
+	}
+	require.Contains(t, values, "ssl_probe_dns_lookup_seconds")
+	require.Equal(t, float64(0), values["ssl_probe_ip_fallback"])
+
+	// Resolving errors are returned without dialing.
+	_, err = newResolvingDialer(dial, ipProtocolIP6, prometheus.NewRegistry())(context.Background(), "tcp", "127.0.0.1:443")
+	require.Error(t, err)
+	require.Len(t, dialed, 1)
+}
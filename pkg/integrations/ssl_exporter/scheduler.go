@@ -0,0 +1,134 @@
+package ssl_exporter
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// scheduler probes targets in the background, each on its own interval, and
+// caches the results of the most recent probe of every target.
+type scheduler struct {
+	e *Exporter
+
+	mut     sync.RWMutex
+	results map[string][]prometheus.Metric // target name -> last results
+}
+
+func newScheduler(e *Exporter) *scheduler {
+	return &scheduler{
+		e:       e,
+		results: make(map[string][]prometheus.Metric),
+	}
+}
+
+// Run probes all targets until ctx is canceled. Probing of targets is
+// started and stopped as the set of targets changes.
+func (s *scheduler) Run(ctx context.Context) error {
+	var (
+		wg      sync.WaitGroup
+		running = make(map[string]*scheduledTarget)
+	)
+	defer wg.Wait()
+
+	for {
+		s.reconcile(ctx, &wg, running)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.e.targetsUpdated:
+		}
+	}
+}
+
+// scheduledTarget is a target which is being probed in the background.
+type scheduledTarget struct {
+	target SSLTarget
+	cancel context.CancelFunc
+}
+
+// reconcile stops probing targets which were removed or changed and starts
+// probing new targets.
+func (s *scheduler) reconcile(ctx context.Context, wg *sync.WaitGroup, running map[string]*scheduledTarget) {
+	desired := make(map[string]SSLTarget)
+	for _, target := range s.e.targets() {
+		desired[target.Name] = target
+	}
+
+	for name, st := range running {
+		if target, ok := desired[name]; ok && reflect.DeepEqual(target, st.target) {
+			continue
+		}
+		st.cancel()
+		delete(running, name)
+		if _, ok := desired[name]; !ok {
+			s.e.forgetTarget(st.target)
+		}
+
+		s.mut.Lock()
+		delete(s.results, name)
+		s.mut.Unlock()
+	}
+
+	for name, target := range desired {
+		if _, ok := running[name]; ok {
+			continue
+		}
+
+		interval := s.e.options.ProbeInterval
+		if target.ProbeInterval > 0 {
+			interval = target.ProbeInterval
+		}
+
+		targetCtx, cancel := context.WithCancel(ctx)
+		running[name] = &scheduledTarget{target: target, cancel: cancel}
+
+		wg.Add(1)
+		go func(target SSLTarget) {
+			defer wg.Done()
+			s.runTarget(targetCtx, target, interval)
+		}(target)
+	}
+}
+
+func (s *scheduler) runTarget(ctx context.Context, target SSLTarget, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		metrics := s.e.probeTarget(ctx, target)
+
+		// Don't cache results from a probe that was aborted because the
+		// target was removed or we're shutting down. ctx is checked while
+		// holding the lock so results of a removed target can't be written
+		// after reconcile deleted them.
+		s.mut.Lock()
+		if ctx.Err() == nil {
+			s.results[target.Name] = metrics
+		}
+		s.mut.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect writes the cached results of every target to ch. Targets which
+// haven't been probed yet are omitted.
+func (s *scheduler) Collect(ch chan<- prometheus.Metric) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	for _, metrics := range s.results {
+		for _, m := range metrics {
+			ch <- m
+		}
+	}
+}
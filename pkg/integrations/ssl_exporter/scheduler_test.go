@@ -0,0 +1,96 @@
+package ssl_exporter
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler_CachesResults(t *testing.T) {
+	certFile := writeTestCert(t, t.TempDir())
+
+	e, err := NewSSLExporter(Options{
+		Namespace: "ssl_exporter",
+		SSLTargets: []SSLTarget{
+			{Name: "cert", Target: certFile, Module: "file"},
+		},
+		SSLConfig:           ssl_config.DefaultConfig,
+		MaxConcurrentProbes: 1,
+		ProbeInterval:       time.Hour,
+		log:                 log.NewNopLogger(),
+	})
+	require.NoError(t, err)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(e)
+
+	// Nothing should be returned before the first probe runs.
+	require.Empty(t, gatherGauges(t, reg))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = e.Run(ctx) }()
+
+	require.Eventually(t, func() bool {
+		return gatherGauges(t, reg)["ssl_probe_success"] == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	fams := gatherGauges(t, reg)
+	require.Contains(t, fams, "ssl_file_cert_not_after")
+	require.Contains(t, fams, "ssl_probe_duration_seconds")
+}
+
+// gatherGauges gathers metrics from g and returns the value of the first
+// gauge of every metric family, keyed by family name.
+func gatherGauges(t *testing.T, g prometheus.Gatherer) map[string]float64 {
+	t.Helper()
+
+	fams, err := g.Gather()
+	require.NoError(t, err)
+
+	res := make(map[string]float64, len(fams))
+	for _, mf := range fams {
+		if len(mf.GetMetric()) > 0 {
+			res[mf.GetName()] = mf.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	return res
+}
+
+// writeTestCert writes a self-signed PEM certificate to dir and returns its
+// path.
+func writeTestCert(t *testing.T, dir string) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "cert.pem")
+	bb := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	require.NoError(t, os.WriteFile(path, bb, 0600))
+	return path
+}
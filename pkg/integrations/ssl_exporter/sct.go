@@ -0,0 +1,116 @@
+package ssl_exporter
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// oidSCTList is the OID of the extension holding the Signed Certificate
+// Timestamps embedded in a certificate, defined in RFC 6962.
+var oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// sct is a Signed Certificate Timestamp issued by a Certificate Transparency
+// log.
+type sct struct {
+	logID     [32]byte
+	timestamp time.Time
+}
+
+func collectSCTMetrics(certs []*x509.Certificate, registry *prometheus.Registry) error {
+	var (
+		sctCount = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "cert_sct_count"),
+				Help: "The number of Signed Certificate Timestamps embedded in a certificate",
+			},
+			[]string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		)
+		sctTimestamp = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "cert_sct_timestamp"),
+				Help: "The timestamp of a Signed Certificate Timestamp embedded in a certificate, expressed as a Unix Epoch Time",
+			},
+			[]string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position", "log_id"},
+		)
+	)
+	registry.MustRegister(sctCount, sctTimestamp)
+
+	for i, cert := range uniq(certs) {
+		labels := labelValues(cert, i)
+
+		scts, err := embeddedSCTs(cert)
+		if err != nil {
+			return err
+		}
+		sctCount.WithLabelValues(labels...).Set(float64(len(scts)))
+		for _, s := range scts {
+			logID := base64.StdEncoding.EncodeToString(s.logID[:])
+			sctTimestamp.WithLabelValues(append(labels, logID)...).Set(float64(s.timestamp.Unix()))
+		}
+	}
+
+	return nil
+}
+
+// embeddedSCTs returns the Signed Certificate Timestamps embedded in cert.
+func embeddedSCTs(cert *x509.Certificate) ([]sct, error) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidSCTList) {
+			continue
+		}
+		var list []byte
+		if _, err := asn1.Unmarshal(ext.Value, &list); err != nil {
+			return nil, fmt.Errorf("failed to parse SCT list: %w", err)
+		}
+		return parseSCTList(list)
+	}
+	return nil, nil
+}
+
+// parseSCTList parses a TLS-encoded SignedCertificateTimestampList.
+func parseSCTList(data []byte) ([]sct, error) {
+	list, rest, ok := readOpaque16(data)
+	if !ok || len(rest) != 0 {
+		return nil, fmt.Errorf("malformed SCT list")
+	}
+
+	var res []sct
+	for len(list) > 0 {
+		var entry []byte
+		entry, list, ok = readOpaque16(list)
+		if !ok {
+			return nil, fmt.Errorf("malformed SCT list")
+		}
+		// Every SCT starts with a one byte version, the 32 byte ID of the
+		// log, and an eight byte timestamp in milliseconds. Only version 1
+		// is defined.
+		if len(entry) < 41 || entry[0] != 0 {
+			continue
+		}
+		var s sct
+		copy(s.logID[:], entry[1:33])
+		ms := int64(binary.BigEndian.Uint64(entry[33:41]))
+		s.timestamp = time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+// readOpaque16 reads a TLS opaque value with a two byte length prefix from
+// data, returning the value and the remaining data.
+func readOpaque16(data []byte) (value, rest []byte, ok bool) {
+	if len(data) < 2 {
+		return nil, nil, false
+	}
+	n := int(binary.BigEndian.Uint16(data))
+	if len(data) < 2+n {
+		return nil, nil, false
+	}
+	return data[2 : 2+n], data[2+n:], true
+}
@@ -0,0 +1,99 @@
+package ssl_exporter
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectSCTMetrics(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	var logA, logB [32]byte
+	logA[0], logB[0] = 0xa, 0xb
+	tsA := time.Unix(1650000000, 0)
+	tsB := time.Unix(1650000100, 0)
+
+	ext, err := asn1.Marshal(encodeSCTList(t, []sct{{logA, tsA}, {logB, tsB}}))
+	require.NoError(t, err)
+
+	withSCTs := selfSignedCert(t, key, &x509.Certificate{
+		ExtraExtensions: []pkix.Extension{{Id: oidSCTList, Value: ext}},
+	})
+
+	reg := prometheus.NewRegistry()
+	require.NoError(t, collectSCTMetrics([]*x509.Certificate{withSCTs}, reg))
+
+	fams, err := reg.Gather()
+	require.NoError(t, err)
+
+	var count float64
+	timestamps := make(map[string]float64)
+	for _, mf := range fams {
+		for _, m := range mf.GetMetric() {
+			switch mf.GetName() {
+			case "ssl_cert_sct_count":
+				count = m.GetGauge().GetValue()
+			case "ssl_cert_sct_timestamp":
+				for _, l := range m.GetLabel() {
+					if l.GetName() == "log_id" {
+						timestamps[l.GetValue()] = m.GetGauge().GetValue()
+					}
+				}
+			}
+		}
+	}
+	require.Equal(t, float64(2), count)
+	require.Equal(t, map[string]float64{
+		base64.StdEncoding.EncodeToString(logA[:]): float64(tsA.Unix()),
+		base64.StdEncoding.EncodeToString(logB[:]): float64(tsB.Unix()),
+	}, timestamps)
+}
+
+func TestParseSCTList(t *testing.T) {
+	var logID [32]byte
+	ts := time.UnixMilli(1650000000123)
+
+	scts, err := parseSCTList(encodeSCTList(t, []sct{{logID, ts}}))
+	require.NoError(t, err)
+	require.Len(t, scts, 1)
+	require.Equal(t, ts.UnixMilli(), scts[0].timestamp.UnixMilli())
+
+	_, err = parseSCTList([]byte{0x00, 0x10, 0x00})
+	require.EqualError(t, err, "malformed SCT list")
+}
+
+// encodeSCTList encodes scts as a SignedCertificateTimestampList with empty
+// extensions and signatures.
+func encodeSCTList(t *testing.T, scts []sct) []byte {
+	t.Helper()
+
+	var list []byte
+	for _, s := range scts {
+		entry := []byte{0}
+		entry = append(entry, s.logID[:]...)
+		var ts [8]byte
+		binary.BigEndian.PutUint64(ts[:], uint64(s.timestamp.UnixMilli()))
+		entry = append(entry, ts[:]...)
+		// Empty extensions, hash and signature algorithm, empty signature.
+		entry = append(entry, 0, 0, 4, 3, 0, 0)
+		list = appendOpaque16(list, entry)
+	}
+	return appendOpaque16(nil, list)
+}
+
+func appendOpaque16(b, value []byte) []byte {
+	b = append(b, byte(len(value)>>8), byte(len(value)))
+	return append(b, value...)
+}
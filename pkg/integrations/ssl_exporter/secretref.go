@@ -0,0 +1,261 @@
+package ssl_exporter
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Schemes of secret references.
+const (
+	secretRefEnv        = "env"
+	secretRefFile       = "file"
+	secretRefVault      = "vault"
+	secretRefKubernetes = "kubernetes_secret"
+)
+
+// secretRef references key material which is resolved at probe time instead
+// of being read from a file when the config is loaded. References have one
+// of the forms:
+//
+//	env:<variable>
+//	file:<path>
+//	vault:<path>#<field>
+//	kubernetes_secret:<namespace>/<name>#<key>
+type secretRef struct {
+	scheme string
+
+	// path is the variable, file, Vault secret, or <namespace>/<name> of the
+	// Kubernetes secret holding the value.
+	path string
+
+	// key is the field of a Vault secret or the key of a Kubernetes secret
+	// holding the value.
+	key string
+}
+
+// parseSecretRef parses s as a secret reference. It returns false if s isn't
+// a reference, in which case it's the path of a file.
+func parseSecretRef(s string) (secretRef, bool, error) {
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return secretRef{}, false, nil
+	}
+
+	ref := secretRef{scheme: s[:i], path: s[i+1:]}
+	switch ref.scheme {
+	case secretRefEnv, secretRefFile:
+		if ref.path == "" {
+			return ref, true, fmt.Errorf("%s reference %q must not be empty", ref.scheme, s)
+		}
+	case secretRefVault, secretRefKubernetes:
+		j := strings.LastIndex(ref.path, "#")
+		if j < 0 || j == len(ref.path)-1 {
+			return ref, true, fmt.Errorf("%s reference %q must end with #<key>", ref.scheme, s)
+		}
+		ref.path, ref.key = ref.path[:j], ref.path[j+1:]
+		if ref.path == "" {
+			return ref, true, fmt.Errorf("%s reference %q must have a path", ref.scheme, s)
+		}
+		if ref.scheme == secretRefKubernetes && strings.Count(ref.path, "/") != 1 {
+			return ref, true, fmt.Errorf("%s reference %q must have the form %s:<namespace>/<name>#<key>", ref.scheme, s, secretRefKubernetes)
+		}
+	default:
+		return secretRef{}, false, nil
+	}
+	return ref, true, nil
+}
+
+// validateSecretRef returns an error if s is an invalid secret reference, or
+// references Vault when Vault isn't configured.
+func validateSecretRef(s string, vaultConfigured bool) error {
+	ref, ok, err := parseSecretRef(s)
+	if !ok || err != nil {
+		return err
+	}
+	if ref.scheme == secretRefVault && !vaultConfigured {
+		return fmt.Errorf("%q references vault, but vault is not configured", s)
+	}
+	return nil
+}
+
+// secretResolver resolves secret references.
+type secretResolver struct {
+	// vault is used for resolving vault references. If nil, vault
+	// references can't be resolved.
+	vault *vaultClient
+
+	// kubeClient returns the client used for resolving kubernetes_secret
+	// references.
+	kubeClient func() (kubernetes.Interface, error)
+}
+
+func newSecretResolver(vault *vaultClient) *secretResolver {
+	return &secretResolver{
+		vault: vault,
+		kubeClient: func() (kubernetes.Interface, error) {
+			return newKubeClient("")
+		},
+	}
+}
+
+// resolve returns the value referenced by s. If s isn't a reference, the
+// contents of the file at path s are returned.
+func (r *secretResolver) resolve(ctx context.Context, s string) ([]byte, error) {
+	ref, ok, err := parseSecretRef(s)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return os.ReadFile(s)
+	}
+
+	switch ref.scheme {
+	case secretRefEnv:
+		value, ok := os.LookupEnv(ref.path)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", ref.path)
+		}
+		return []byte(value), nil
+
+	case secretRefFile:
+		return os.ReadFile(ref.path)
+
+	case secretRefVault:
+		if r.vault == nil {
+			return nil, fmt.Errorf("vault must be configured to resolve %q", s)
+		}
+		client, err := r.vault.get()
+		if err != nil {
+			return nil, err
+		}
+		data, err := readVaultSecretData(client, ref.path)
+		if err != nil {
+			return nil, err
+		}
+		value, ok := data[ref.key].(string)
+		if !ok {
+			return nil, fmt.Errorf("vault secret %s has no field %s", ref.path, ref.key)
+		}
+		return []byte(value), nil
+
+	case secretRefKubernetes:
+		client, err := r.kubeClient()
+		if err != nil {
+			return nil, err
+		}
+		namespace, name := splitSecretName(ref.path)
+		secret, err := client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		value, ok := secret.Data[ref.key]
+		if !ok {
+			return nil, fmt.Errorf("kubernetes secret %s has no key %s", ref.path, ref.key)
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("unsupported secret reference %q", s)
+}
+
+// splitSecretName splits the <namespace>/<name> of a Kubernetes secret.
+func splitSecretName(s string) (string, string) {
+	parts := strings.SplitN(s, "/", 2)
+	return parts[0], parts[1]
+}
+
+// tlsRefs holds the settings of the TLS config of a module which reference
+// secrets. They're resolved on every probe so that rotated key material is
+// picked up.
+type tlsRefs struct {
+	caFile   string
+	certFile string
+	keyFile  string
+}
+
+// splitTLSRefs returns cfg without the settings which reference secrets,
+// which are returned as tlsRefs. The certificate and key are always resolved
+// together, so a plain path is moved along with a reference. It returns nil
+// tlsRefs if cfg doesn't reference any secrets.
+func splitTLSRefs(cfg ssl_config.TLSConfig) (ssl_config.TLSConfig, *tlsRefs) {
+	isRef := func(s string) bool {
+		_, ok, _ := parseSecretRef(s)
+		return ok
+	}
+
+	var refs tlsRefs
+	if isRef(cfg.CAFile) {
+		refs.caFile, cfg.CAFile = cfg.CAFile, ""
+	}
+	if isRef(cfg.CertFile) || isRef(cfg.KeyFile) {
+		refs.certFile, cfg.CertFile = cfg.CertFile, ""
+		refs.keyFile, cfg.KeyFile = cfg.KeyFile, ""
+	}
+	if refs == (tlsRefs{}) {
+		return cfg, nil
+	}
+	return cfg, &refs
+}
+
+// validateTLSRefs returns an error if any setting of cfg is an invalid
+// secret reference.
+func validateTLSRefs(cfg ssl_config.TLSConfig, vaultConfigured bool) error {
+	for _, setting := range []struct{ name, value string }{
+		{"ca_file", cfg.CAFile},
+		{"cert_file", cfg.CertFile},
+		{"key_file", cfg.KeyFile},
+	} {
+		if err := validateSecretRef(setting.value, vaultConfigured); err != nil {
+			return fmt.Errorf("invalid %s: %w", setting.name, err)
+		}
+	}
+	return nil
+}
+
+// apply resolves the referenced secrets with r and applies them to cfg.
+func (refs *tlsRefs) apply(ctx context.Context, r *secretResolver, cfg *tls.Config) error {
+	if refs.caFile != "" {
+		caPEM, err := r.resolve(ctx, refs.caFile)
+		if err != nil {
+			return fmt.Errorf("failed to resolve ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return fmt.Errorf("no certificates found in ca_file %q", refs.caFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if refs.certFile != "" || refs.keyFile != "" {
+		cert, err := loadKeyPair(ctx, r, refs.certFile, refs.keyFile)
+		if err != nil {
+			return err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return nil
+}
+
+// loadKeyPair resolves the certificate and key referenced by certFile and
+// keyFile with r.
+func loadKeyPair(ctx context.Context, r *secretResolver, certFile, keyFile string) (tls.Certificate, error) {
+	if certFile == "" || keyFile == "" {
+		return tls.Certificate{}, fmt.Errorf("cert_file and key_file must be set together")
+	}
+	certPEM, err := r.resolve(ctx, certFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to resolve cert_file: %w", err)
+	}
+	keyPEM, err := r.resolve(ctx, keyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to resolve key_file: %w", err)
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
@@ -0,0 +1,140 @@
+package ssl_exporter
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseSecretRef(t *testing.T) {
+	tt := []struct {
+		input  string
+		expect secretRef
+		isRef  bool
+		err    string
+	}{
+		{input: "/etc/ssl/ca.pem"},
+		{input: `C:\certs\ca.pem`},
+		{input: "env:CA_PEM", expect: secretRef{scheme: "env", path: "CA_PEM"}, isRef: true},
+		{input: "file:/etc/ssl/ca.pem", expect: secretRef{scheme: "file", path: "/etc/ssl/ca.pem"}, isRef: true},
+		{input: "vault:secret/data/app#tls.key", expect: secretRef{scheme: "vault", path: "secret/data/app", key: "tls.key"}, isRef: true},
+		{input: "kubernetes_secret:default/app-tls#tls.crt", expect: secretRef{scheme: "kubernetes_secret", path: "default/app-tls", key: "tls.crt"}, isRef: true},
+		{input: "env:", isRef: true, err: `env reference "env:" must not be empty`},
+		{input: "vault:secret/data/app", isRef: true, err: `vault reference "vault:secret/data/app" must end with #<key>`},
+		{input: "kubernetes_secret:app-tls#tls.crt", isRef: true, err: `kubernetes_secret reference "kubernetes_secret:app-tls#tls.crt" must have the form kubernetes_secret:<namespace>/<name>#<key>`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.input, func(t *testing.T) {
+			ref, isRef, err := parseSecretRef(tc.input)
+			require.Equal(t, tc.isRef, isRef)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, ref)
+		})
+	}
+
+	require.NoError(t, validateSecretRef("vault:secret/data/app#tls.key", true))
+	require.Error(t, validateSecretRef("vault:secret/data/app#tls.key", false))
+}
+
+func TestSecretResolver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" || r.URL.Path != "/v1/secret/data/app" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{
+			"data":     map[string]interface{}{"tls.key": "from vault"},
+			"metadata": map[string]interface{}{"version": 1},
+		}})
+	}))
+	defer srv.Close()
+
+	vc, err := newVaultClient(VaultConfig{Address: srv.URL, Token: "s.token"})
+	require.NoError(t, err)
+
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app-tls"},
+		Data:       map[string][]byte{"tls.crt": []byte("from kubernetes")},
+	})
+	r := newSecretResolver(vc)
+	r.kubeClient = func() (kubernetes.Interface, error) { return client, nil }
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(path, []byte("from file"), 0600))
+	t.Setenv("SSL_TEST_SECRET", "from env")
+
+	for ref, expect := range map[string]string{
+		path:                            "from file",
+		"file:" + path:                  "from file",
+		"env:SSL_TEST_SECRET":           "from env",
+		"vault:secret/data/app#tls.key": "from vault",
+		"kubernetes_secret:default/app-tls#tls.crt": "from kubernetes",
+	} {
+		value, err := r.resolve(context.Background(), ref)
+		require.NoError(t, err, ref)
+		require.Equal(t, expect, string(value), ref)
+	}
+
+	_, err = r.resolve(context.Background(), "env:SSL_TEST_MISSING")
+	require.EqualError(t, err, "environment variable SSL_TEST_MISSING is not set")
+	_, err = r.resolve(context.Background(), "vault:secret/data/app#tls.crt")
+	require.EqualError(t, err, "vault secret secret/data/app has no field tls.crt")
+	_, err = r.resolve(context.Background(), "kubernetes_secret:default/app-tls#tls.key")
+	require.EqualError(t, err, "kubernetes secret default/app-tls has no key tls.key")
+}
+
+func TestSplitTLSRefs(t *testing.T) {
+	cfg, refs := splitTLSRefs(ssl_config.TLSConfig{CAFile: "/etc/ssl/ca.pem", ServerName: "example.com"})
+	require.Nil(t, refs)
+	require.Equal(t, "/etc/ssl/ca.pem", cfg.CAFile)
+
+	cfg, refs = splitTLSRefs(ssl_config.TLSConfig{
+		CAFile:     "/etc/ssl/ca.pem",
+		CertFile:   "/etc/ssl/client.pem",
+		KeyFile:    "vault:secret/data/app#tls.key",
+		ServerName: "example.com",
+	})
+	require.Equal(t, ssl_config.TLSConfig{CAFile: "/etc/ssl/ca.pem", ServerName: "example.com"}, cfg)
+	require.Equal(t, &tlsRefs{certFile: "/etc/ssl/client.pem", keyFile: "vault:secret/data/app#tls.key"}, refs)
+}
+
+func TestTCPProber_TLSRefs(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	t.Setenv("SSL_TEST_CA", string(caPEM))
+
+	module := ssl_config.Module{Prober: "tcp"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The certificate of the server is only trusted through the referenced
+	// CA.
+	probe := newTCPProber(proberOptions{})
+	require.Error(t, probe(ctx, log.NewNopLogger(), srv.Listener.Addr().String(), module, prometheus.NewRegistry()))
+
+	probe = newTCPProber(proberOptions{tlsRefs: &tlsRefs{caFile: "env:SSL_TEST_CA"}})
+	require.NoError(t, probe(ctx, log.NewNopLogger(), srv.Listener.Addr().String(), module, prometheus.NewRegistry()))
+}
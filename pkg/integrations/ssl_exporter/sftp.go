@@ -0,0 +1,182 @@
+package ssl_exporter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"github.com/go-kit/log"
+	"github.com/pkg/sftp"
+	"github.com/prometheus/client_golang/prometheus"
+	config_util "github.com/prometheus/common/config"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"github.com/ribbybibby/ssl_exporter/v2/prober"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sftpProber is the name of the prober which reads certificate files from
+// remote hosts.
+const sftpProber = "sftp"
+
+// SSHConfig configures how the sftp prober connects to a remote host and
+// which certificate files it reads.
+type SSHConfig struct {
+	User string `yaml:"user"`
+
+	// Password and PrivateKeyFile authenticate the user. If both are set,
+	// the private key is tried first.
+	Password             config_util.Secret `yaml:"password,omitempty"`
+	PrivateKeyFile       string             `yaml:"private_key_file,omitempty"`
+	PrivateKeyPassphrase config_util.Secret `yaml:"private_key_passphrase,omitempty"`
+
+	// KnownHostsFile is used to verify the key of the host. Verification can
+	// be disabled with InsecureIgnoreHostKey instead.
+	KnownHostsFile        string `yaml:"known_hosts_file,omitempty"`
+	InsecureIgnoreHostKey bool   `yaml:"insecure_ignore_host_key,omitempty"`
+
+	// Paths are globs of the certificate files to read on the remote host.
+	Paths []string `yaml:"paths"`
+}
+
+// Validate validates the SSH config.
+func (c *SSHConfig) Validate() error {
+	switch {
+	case c.User == "":
+		return fmt.Errorf("user must be set")
+	case c.Password == "" && c.PrivateKeyFile == "":
+		return fmt.Errorf("one of password or private_key_file must be set")
+	case c.KnownHostsFile == "" && !c.InsecureIgnoreHostKey:
+		return fmt.Errorf("known_hosts_file must be set unless insecure_ignore_host_key is enabled")
+	case len(c.Paths) == 0:
+		return fmt.Errorf("at least one path must be set")
+	}
+	return nil
+}
+
+func (c *SSHConfig) clientConfig() (*ssh.ClientConfig, error) {
+	var auth []ssh.AuthMethod
+	if c.PrivateKeyFile != "" {
+		key, err := os.ReadFile(c.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+		var signer ssh.Signer
+		if c.PrivateKeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(c.PrivateKeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if c.Password != "" {
+		auth = append(auth, ssh.Password(string(c.Password)))
+	}
+
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if !c.InsecureIgnoreHostKey {
+		var err error
+		hostKeyCallback, err = knownhosts.New(c.KnownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read known hosts: %w", err)
+		}
+	}
+
+	return &ssh.ClientConfig{
+		User:            c.User,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+	}, nil
+}
+
+// newSFTPProber returns a prober which connects to the target host over SSH
+// and collects certificate metrics from the files matching the paths of cfg
+// through SFTP. The target is the address of the host, with port 22 used if
+// no port is given.
+func newSFTPProber(cfg *SSHConfig) prober.ProbeFn {
+	return func(ctx context.Context, logger log.Logger, target string, module ssl_config.Module, registry *prometheus.Registry) error {
+		if cfg == nil {
+			return fmt.Errorf("ssh must be configured to use the %s prober", sftpProber)
+		}
+
+		clientConfig, err := cfg.clientConfig()
+		if err != nil {
+			return err
+		}
+
+		addr := target
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "22")
+		}
+
+		errCh := make(chan error, 1)
+
+		go func() {
+			errCh <- probeSFTP(ctx, logger, addr, clientConfig, cfg.Paths, registry)
+		}()
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context timeout, ran out of time")
+		case err := <-errCh:
+			return err
+		}
+	}
+}
+
+func probeSFTP(ctx context.Context, logger log.Logger, addr string, clientConfig *ssh.ClientConfig, paths []string, registry *prometheus.Registry) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return fmt.Errorf("error setting deadline: %w", err)
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, clientConfig)
+	if err != nil {
+		return err
+	}
+	sshClient := ssh.NewClient(sshConn, chans, reqs)
+	defer sshClient.Close()
+
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		return fmt.Errorf("failed to start sftp session: %w", err)
+	}
+	defer client.Close()
+
+	var files []string
+	for _, p := range paths {
+		matches, err := client.Glob(p)
+		if err != nil {
+			return err
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("No files found")
+	}
+
+	host, _, _ := net.SplitHostPort(addr)
+	return collectFileMetrics(ctx, logger, host, files, func(path string) ([]byte, error) {
+		return readRemoteFile(client, path)
+	}, registry)
+}
+
+func readRemoteFile(client *sftp.Client, path string) ([]byte, error) {
+	f, err := client.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
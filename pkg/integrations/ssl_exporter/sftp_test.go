@@ -0,0 +1,142 @@
+package ssl_exporter
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/pkg/sftp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSFTPProber(t *testing.T) {
+	certFile := writeTestCert(t, t.TempDir())
+	addr := startSFTPServer(t, "agent", "secret")
+
+	cfg := &SSHConfig{
+		User:                  "agent",
+		Password:              "secret",
+		InsecureIgnoreHostKey: true,
+		Paths:                 []string{filepath.Join(filepath.Dir(certFile), "*.pem")},
+	}
+	require.NoError(t, cfg.Validate())
+
+	reg := prometheus.NewRegistry()
+	probe := newSFTPProber(cfg)
+	require.NoError(t, probe(context.Background(), log.NewNopLogger(), addr, defaultSSLConfig().Modules[sftpProber], reg))
+
+	fams, err := reg.Gather()
+	require.NoError(t, err)
+
+	var found bool
+	for _, mf := range fams {
+		if mf.GetName() != "ssl_file_cert_not_after" {
+			continue
+		}
+		require.Len(t, mf.GetMetric(), 1)
+		labels := make(map[string]string)
+		for _, l := range mf.GetMetric()[0].GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		require.Equal(t, "127.0.0.1", labels["host"])
+		require.Equal(t, certFile, labels["file"])
+		found = true
+	}
+	require.True(t, found, "expected ssl_file_cert_not_after to be collected")
+
+	// Probing should fail with the wrong password.
+	badCfg := *cfg
+	badCfg.Password = "wrong"
+	err = newSFTPProber(&badCfg)(context.Background(), log.NewNopLogger(), addr, defaultSSLConfig().Modules[sftpProber], prometheus.NewRegistry())
+	require.Error(t, err)
+}
+
+func TestSSHConfig_Validate(t *testing.T) {
+	cfg := SSHConfig{User: "agent", Password: "secret", Paths: []string{"/etc/ssl/*.pem"}}
+	require.EqualError(t, cfg.Validate(), "known_hosts_file must be set unless insecure_ignore_host_key is enabled")
+
+	cfg.KnownHostsFile = "/etc/ssh/ssh_known_hosts"
+	require.NoError(t, cfg.Validate())
+
+	cfg.Password = ""
+	require.EqualError(t, cfg.Validate(), "one of password or private_key_file must be set")
+}
+
+// startSFTPServer starts an SSH server which serves the local filesystem over
+// SFTP to the given user, returning its address.
+func startSFTPServer(t *testing.T, user, password string) string {
+	t.Helper()
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	require.NoError(t, err)
+
+	serverConfig := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == user && string(pass) == password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("invalid credentials")
+		},
+	}
+	serverConfig.AddHostKey(signer)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { lis.Close() })
+
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTP(conn, serverConfig)
+		}
+	}()
+
+	return lis.Addr().String()
+}
+
+func serveSFTP(conn net.Conn, cfg *ssh.ServerConfig) {
+	defer conn.Close()
+
+	_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+
+		go func(in <-chan *ssh.Request) {
+			for req := range in {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(ok, nil)
+			}
+		}(requests)
+
+		server, err := sftp.NewServer(channel)
+		if err != nil {
+			return
+		}
+		_ = server.Serve()
+		server.Close()
+	}
+}
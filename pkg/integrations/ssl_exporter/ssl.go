@@ -4,259 +4,1158 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prometheus/model/relabel"
 	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
 	"github.com/ribbybibby/ssl_exporter/v2/prober"
+	"golang.org/x/time/rate"
 )
 
+// targetLabel is added to every metric and holds the name of the probed
+// SSLTarget.
+const targetLabel = "ssl_target"
+
+// ipLabel is added to every metric when any configured or discovered target
+// sets SSLTarget.ProbeAllIPs. It holds the IP address which was probed, and is
+// empty for other targets.
+const ipLabel = "ip"
+
 var (
-	namespace  = "ssl"
-	labelOrder = map[string]int{
-		"prober":     0,
-		"version":    0,
-		"chain_no":   0,
-		"file":       0,
-		"namespace":  0,
-		"kubeconfig": 0,
-		"secret":     1,
-		"name":       1,
-		"key":        2,
-		"type":       2,
-		"serial_no":  3,
-		"issuer_cn":  4,
-		"cn":         5,
-		"dnsnames":   6,
-		"ips":        7,
-		"emails":     8,
-		"ou":         9,
-	}
-	descs = map[string]*prometheus.Desc{
-		"ssl_exporter_probe_success": prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "probe_success"),
-			"If the probe was a success",
-			nil, nil,
-		),
-		"ssl_exporter_prober": prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "prober"),
-			"The prober used by the exporter to connect to the target",
-			[]string{"prober"}, nil,
-		),
-		"ssl_tls_version_info": prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "tls_version_info"),
-			"The TLS version used",
-			[]string{"version"}, nil,
-		),
-		"ssl_cert_not_after": prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "cert_not_after"),
-			"NotAfter expressed as a Unix Epoch Time",
-			[]string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou"}, nil,
-		),
-		"ssl_cert_not_before": prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "cert_not_before"),
-			"NotBefore expressed as a Unix Epoch Time",
-			[]string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou"}, nil,
-		),
-		"ssl_verified_cert_not_after": prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "verified_cert_not_after"),
-			"NotAfter expressed as a Unix Epoch Time",
-			[]string{"chain_no", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou"}, nil,
-		),
-		"ssl_verified_cert_not_before": prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "verified_cert_not_before"),
-			"NotBefore expressed as a Unix Epoch Time",
-			nil, nil,
-		),
-		"ssl_ocsp_response_stapled": prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "ocsp_response_stapled"),
-			"If the connection state contains a stapled OCSP response",
-			nil, nil,
-		),
-		"ssl_ocsp_response_status": prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "ocsp_response_status"),
-			"The status in the OCSP response 0=Good 1=Revoked 2=Unknown",
-			nil, nil,
-		),
-		"ssl_ocsp_response_produced_at": prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "ocsp_response_produced_at"),
-			"The producedAt value in the OCSP response, expressed as a Unix Epoch Time",
-			nil, nil,
-		),
-		"ssl_ocsp_response_this_update": prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "ocsp_response_this_update"),
-			"The thisUpdate value in the OCSP response, expressed as a Unix Epoch Time",
-			nil, nil,
-		),
-		"ssl_ocsp_response_next_update": prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "ocsp_response_next_update"),
-			"The nextUpdate value in the OCSP response, expressed as a Unix Epoch Time",
-			nil, nil,
-		),
-		"ssl_ocsp_response_revoked_at": prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "ocsp_response_revoked_at"),
-			"The revocationTime value in the OCSP response, expressed as a Unix Epoch Time",
-			nil, nil,
-		),
-		"ssl_file_cert_not_after": prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "file_cert_not_after"),
-			"NotAfter expressed as a Unix Epoch Time for a certificate found in a file",
-			[]string{"file", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou"}, nil,
-		),
-		"ssl_file_cert_not_before": prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "file_cert_not_before"),
-			"NotBefore expressed as a Unix Epoch Time for a certificate found in a file",
-			[]string{"file", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou"}, nil,
-		),
-		"ssl_kubernetes_cert_not_after": prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "kubernetes_cert_not_after"),
-			"NotAfter expressed as a Unix Epoch Time for a certificate found in a kubernetes secret",
-			[]string{"namespace", "secret", "key", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou"}, nil,
-		),
-		"ssl_kubernetes_cert_not_before": prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "kubernetes_cert_not_before"),
-			"NotBefore expressed as a Unix Epoch Time for a certificate found in a kubernetes secret",
-			[]string{"namespace", "secret", "key", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou"}, nil,
-		),
-		"ssl_kubeconfig_cert_not_after": prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "kubeconfig", "cert_not_after"),
-			"NotAfter expressed as a Unix Epoch Time for a certificate found in a kubeconfig",
-			[]string{"kubeconfig", "name", "type", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou"}, nil,
-		),
-		"ssl_kubeconfig_cert_not_before": prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "kubeconfig", "cert_not_before"),
-			"NotBefore expressed as a Unix Epoch Time for a certificate found in a kubeconfig",
-			[]string{"kubeconfig", "name", "type", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou"}, nil,
-		),
+	namespace = "ssl"
+	// metricOpts describes the metrics exposed by the integration, keyed by
+	// the name used by the upstream prober. Every metric additionally has
+	// targetLabel and the custom labels of the targets as labels.
+	metricOpts = map[string]metricOpt{
+		"ssl_exporter_probe_success": {
+			fqName: prometheus.BuildFQName(namespace, "", "probe_success"),
+			help:   "If the probe was a success",
+			labels: nil,
+		},
+		"ssl_probe_duration_seconds": {
+			fqName: prometheus.BuildFQName(namespace, "", "probe_duration_seconds"),
+			help:   "How long the probe took to complete in seconds",
+			labels: nil,
+		},
+		"ssl_probe_error_info": {
+			fqName: prometheus.BuildFQName(namespace, "", "probe_error_info"),
+			help:   "The reason the probe failed",
+			labels: []string{"reason"},
+		},
+		"ssl_probe_dns_lookup_seconds": {
+			fqName: prometheus.BuildFQName(namespace, "", "probe_dns_lookup_seconds"),
+			help:   "Time taken to resolve the host of the target",
+			labels: nil,
+		},
+		"ssl_probe_ip_fallback": {
+			fqName: prometheus.BuildFQName(namespace, "", "probe_ip_fallback"),
+			help:   "If the probe fell back to IPv4 after failing to connect over IPv6",
+			labels: nil,
+		},
+		"ssl_exporter_prober": {
+			fqName: prometheus.BuildFQName(namespace, "", "prober"),
+			help:   "The prober used by the exporter to connect to the target",
+			labels: []string{"prober"},
+		},
+		"ssl_tls_version_info": {
+			fqName: prometheus.BuildFQName(namespace, "", "tls_version_info"),
+			help:   "The TLS version used",
+			labels: []string{"version"},
+		},
+		"ssl_tls_cipher_info": {
+			fqName: prometheus.BuildFQName(namespace, "", "tls_cipher_info"),
+			help:   "The cipher suite and key exchange negotiated for the connection",
+			labels: []string{"cipher", "kex"},
+		},
+		"ssl_tls_alpn_protocol_info": {
+			fqName: prometheus.BuildFQName(namespace, "", "tls_alpn_protocol_info"),
+			help:   "The application protocol negotiated through ALPN",
+			labels: []string{"protocol"},
+		},
+		"ssl_cert_expiring_within": {
+			fqName: prometheus.BuildFQName(namespace, "", "cert_expiring_within"),
+			help:   "If the certificate expires within the threshold, as of the probe",
+			labels: append(append([]string{}, expiringCertLabels...), "threshold"),
+		},
+		"ssl_cert_pin_match": {
+			fqName: prometheus.BuildFQName(namespace, "", "cert_pin_match"),
+			help:   "If a certificate collected by the probe matches a pin of the target",
+			labels: nil,
+		},
+		"ssl_tls_session_resumed": {
+			fqName: prometheus.BuildFQName(namespace, "", "tls_session_resumed"),
+			help:   "If the TLS session of a previous connection was resumed when reconnecting to the target",
+			labels: nil,
+		},
+		"ssl_cert_not_after": {
+			fqName: prometheus.BuildFQName(namespace, "", "cert_not_after"),
+			help:   "NotAfter expressed as a Unix Epoch Time",
+			labels: []string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		},
+		"ssl_cert_not_before": {
+			fqName: prometheus.BuildFQName(namespace, "", "cert_not_before"),
+			help:   "NotBefore expressed as a Unix Epoch Time",
+			labels: []string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		},
+		"ssl_cert_public_key_info": {
+			fqName: prometheus.BuildFQName(namespace, "", "cert_public_key_info"),
+			help:   "The algorithm and size in bits of the public key of a certificate",
+			labels: []string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position", "algorithm", "bits"},
+		},
+		"ssl_cert_signature_algorithm_info": {
+			fqName: prometheus.BuildFQName(namespace, "", "cert_signature_algorithm_info"),
+			help:   "The algorithm used to sign a certificate",
+			labels: []string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position", "signature_algorithm"},
+		},
+		"ssl_cert_insecure_signature": {
+			fqName: prometheus.BuildFQName(namespace, "", "cert_insecure_signature"),
+			help:   "If a certificate is signed with an insecure algorithm, like MD5 or SHA-1",
+			labels: []string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		},
+		"ssl_cert_sct_count": {
+			fqName: prometheus.BuildFQName(namespace, "", "cert_sct_count"),
+			help:   "The number of Signed Certificate Timestamps embedded in a certificate",
+			labels: []string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		},
+		"ssl_cert_sct_timestamp": {
+			fqName: prometheus.BuildFQName(namespace, "", "cert_sct_timestamp"),
+			help:   "The timestamp of a Signed Certificate Timestamp embedded in a certificate, expressed as a Unix Epoch Time",
+			labels: []string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position", "log_id"},
+		},
+		"ssl_cert_expires_in_seconds": {
+			fqName: prometheus.BuildFQName(namespace, "", "cert_expires_in_seconds"),
+			help:   "The number of seconds until a certificate expires, as of the probe. Negative once expired",
+			labels: []string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		},
+		"ssl_verified_cert_not_after": {
+			fqName: prometheus.BuildFQName(namespace, "", "verified_cert_not_after"),
+			help:   "NotAfter expressed as a Unix Epoch Time",
+			labels: []string{"chain_no", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		},
+		"ssl_verified_cert_not_before": {
+			fqName: prometheus.BuildFQName(namespace, "", "verified_cert_not_before"),
+			help:   "NotBefore expressed as a Unix Epoch Time",
+			labels: []string{"chain_no", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		},
+		"ssl_verified_cert_expires_in_seconds": {
+			fqName: prometheus.BuildFQName(namespace, "", "verified_cert_expires_in_seconds"),
+			help:   "The number of seconds until a certificate expires, as of the probe. Negative once expired",
+			labels: []string{"chain_no", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		},
+		"ssl_verified_chain_expires_in_seconds": {
+			fqName: prometheus.BuildFQName(namespace, "", "verified_chain_expires_in_seconds"),
+			help:   "The number of seconds until the first certificate of a verified chain expires, as of the probe. Negative once expired",
+			labels: []string{"chain_no"},
+		},
+		"ssl_ocsp_response_stapled": {
+			fqName: prometheus.BuildFQName(namespace, "", "ocsp_response_stapled"),
+			help:   "If the connection state contains a stapled OCSP response",
+			labels: nil,
+		},
+		"ssl_ocsp_response_status": {
+			fqName: prometheus.BuildFQName(namespace, "", "ocsp_response_status"),
+			help:   "The status in the OCSP response 0=Good 1=Revoked 2=Unknown",
+			labels: nil,
+		},
+		"ssl_ocsp_response_produced_at": {
+			fqName: prometheus.BuildFQName(namespace, "", "ocsp_response_produced_at"),
+			help:   "The producedAt value in the OCSP response, expressed as a Unix Epoch Time",
+			labels: nil,
+		},
+		"ssl_ocsp_response_this_update": {
+			fqName: prometheus.BuildFQName(namespace, "", "ocsp_response_this_update"),
+			help:   "The thisUpdate value in the OCSP response, expressed as a Unix Epoch Time",
+			labels: nil,
+		},
+		"ssl_ocsp_response_next_update": {
+			fqName: prometheus.BuildFQName(namespace, "", "ocsp_response_next_update"),
+			help:   "The nextUpdate value in the OCSP response, expressed as a Unix Epoch Time",
+			labels: nil,
+		},
+		"ssl_ocsp_response_revoked_at": {
+			fqName: prometheus.BuildFQName(namespace, "", "ocsp_response_revoked_at"),
+			help:   "The revocationTime value in the OCSP response, expressed as a Unix Epoch Time",
+			labels: nil,
+		},
+		"ssl_ocsp_must_staple": {
+			fqName: prometheus.BuildFQName(namespace, "", "ocsp_must_staple"),
+			help:   "If the leaf certificate advertises the OCSP must-staple extension",
+			labels: nil,
+		},
+		"ssl_ocsp_must_staple_missing": {
+			fqName: prometheus.BuildFQName(namespace, "", "ocsp_must_staple_missing"),
+			help:   "If the leaf certificate advertises the OCSP must-staple extension but no OCSP response was stapled",
+			labels: nil,
+		},
+		"ssl_cert_revoked": {
+			fqName: prometheus.BuildFQName(namespace, "", "cert_revoked"),
+			help:   "If the certificate is listed in the CRL of its issuer",
+			labels: []string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		},
+		"ssl_crl_next_update": {
+			fqName: prometheus.BuildFQName(namespace, "", "crl_next_update"),
+			help:   "The nextUpdate value in the CRL, expressed as a Unix Epoch Time",
+			labels: []string{"url"},
+		},
+		"ssl_crl_fetch_success": {
+			fqName: prometheus.BuildFQName(namespace, "", "crl_fetch_success"),
+			help:   "If the CRL was fetched and its signature is valid",
+			labels: []string{"url"},
+		},
+		"ssl_file_cert_not_after": {
+			fqName: prometheus.BuildFQName(namespace, "", "file_cert_not_after"),
+			help:   "NotAfter expressed as a Unix Epoch Time for a certificate found in a file",
+			labels: []string{"host", "file", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		},
+		"ssl_file_cert_not_before": {
+			fqName: prometheus.BuildFQName(namespace, "", "file_cert_not_before"),
+			help:   "NotBefore expressed as a Unix Epoch Time for a certificate found in a file",
+			labels: []string{"host", "file", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		},
+		"ssl_keystore_cert_not_after": {
+			fqName: prometheus.BuildFQName(namespace, "", "keystore_cert_not_after"),
+			help:   "NotAfter expressed as a Unix Epoch Time for a certificate found in a keystore",
+			labels: []string{"file", "store", "alias", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		},
+		"ssl_keystore_cert_not_before": {
+			fqName: prometheus.BuildFQName(namespace, "", "keystore_cert_not_before"),
+			help:   "NotBefore expressed as a Unix Epoch Time for a certificate found in a keystore",
+			labels: []string{"file", "store", "alias", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		},
+		"ssl_vault_cert_not_after": {
+			fqName: prometheus.BuildFQName(namespace, "", "vault_cert_not_after"),
+			help:   "NotAfter expressed as a Unix Epoch Time for a certificate found in Vault",
+			labels: []string{"path", "key", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		},
+		"ssl_vault_cert_not_before": {
+			fqName: prometheus.BuildFQName(namespace, "", "vault_cert_not_before"),
+			help:   "NotBefore expressed as a Unix Epoch Time for a certificate found in Vault",
+			labels: []string{"path", "key", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		},
+		"ssl_kubernetes_cert_not_after": {
+			fqName: prometheus.BuildFQName(namespace, "", "kubernetes_cert_not_after"),
+			help:   "NotAfter expressed as a Unix Epoch Time for a certificate found in a kubernetes secret",
+			labels: []string{"namespace", "secret", "key", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		},
+		"ssl_kubernetes_cert_not_before": {
+			fqName: prometheus.BuildFQName(namespace, "", "kubernetes_cert_not_before"),
+			help:   "NotBefore expressed as a Unix Epoch Time for a certificate found in a kubernetes secret",
+			labels: []string{"namespace", "secret", "key", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		},
+		"ssl_kubeconfig_cert_not_after": {
+			fqName: prometheus.BuildFQName(namespace, "kubeconfig", "cert_not_after"),
+			help:   "NotAfter expressed as a Unix Epoch Time for a certificate found in a kubeconfig",
+			labels: []string{"kubeconfig", "name", "type", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		},
+		"ssl_kubeconfig_cert_not_before": {
+			fqName: prometheus.BuildFQName(namespace, "kubeconfig", "cert_not_before"),
+			help:   "NotBefore expressed as a Unix Epoch Time for a certificate found in a kubeconfig",
+			labels: []string{"kubeconfig", "name", "type", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		},
 	}
 )
 
+// metricOpt holds the options used to build the descriptor of a metric.
+type metricOpt struct {
+	fqName string
+	help   string
+	labels []string
+}
+
+// Exporter collects metrics by probing a set of SSL targets.
 type Exporter struct {
 	sync.Mutex
-	probeSuccess prometheus.Gauge
-	proberType   *prometheus.GaugeVec
 
 	options   Options
 	namespace string
+
+	// labelSet holds the labels added to every metric, which are rebuilt
+	// whenever the set of targets changes.
+	labelsMut sync.RWMutex
+	labelSet  *labelSet
+
+	// counts holds the number of probes of every configured target, keyed
+	// by target name.
+	countsMut sync.Mutex
+	counts    map[string]*probeCounts
+
+	// statuses holds the result of the most recent probe of every
+	// configured target, keyed by target name, for the status page.
+	statusMut sync.RWMutex
+	statuses  map[string]targetStatus
+
+	// probeSem limits the number of concurrently running probes across
+	// scrapes and the background scheduler.
+	probeSem chan struct{}
+
+	// probeLimiter limits the rate at which probes are started. It is nil
+	// when probes aren't rate limited.
+	probeLimiter *rate.Limiter
+
+	// quarantine is non-nil when targets which fail consecutive probes are
+	// quarantined.
+	quarantine *quarantine
+
+	// scheduler is non-nil when targets are probed in the background.
+	scheduler *scheduler
+
+	// sslConfigMut guards loadedSSLConfig, which may be reloaded from
+	// Options.ConfigFile at runtime.
+	sslConfigMut    sync.RWMutex
+	loadedSSLConfig *ssl_config.Config
+
+	// configHash is the hash of Options.ConfigFile when the exporter was
+	// created, which is when Options.SSLConfig was loaded from it.
+	configHash []byte
+
+	// expiryThresholds are the parsed Options.ExpiryThresholds.
+	expiryThresholds []expiryThreshold
+
+	// vault is non-nil when Options.Vault is set.
+	vault *vaultClient
+
+	// secrets resolves the secret references of client certificates and the
+	// TLS configs of modules.
+	secrets *secretResolver
+
+	// crls caches the CRLs fetched by modules which check CRLs.
+	crls *crlCache
+
+	// discoverer is non-nil when targets are discovered dynamically.
+	// Discovered targets are probed in addition to Options.SSLTargets.
+	discoverer     *discoverer
+	targetsMut     sync.RWMutex
+	discovered     []SSLTarget
+	targetsUpdated chan struct{}
 }
 
+// Options configures an Exporter.
 type Options struct {
 	Namespace   string
 	MetricsPath string
 	ProbePath   string
-	Registry    *prometheus.Registry
+	StatusPath  string
 	SSLTargets  []SSLTarget
 	SSLConfig   *ssl_config.Config
 	log         log.Logger
+
+	// ConfigFile is the file SSLConfig was loaded from, if any. When
+	// ConfigReloadInterval is non-zero, ConfigFile is checked for changes on
+	// that interval and SSLConfig is reloaded when it changes.
+	ConfigFile           string
+	ConfigReloadInterval time.Duration
+
+	// MaxConcurrentProbes limits how many targets are probed in parallel.
+	MaxConcurrentProbes int
+
+	// ProbeRateLimit limits how many probes are started per second across
+	// all targets, allowing bursts of up to ProbeRateBurst probes. Zero
+	// disables rate limiting.
+	ProbeRateLimit float64
+	ProbeRateBurst int
+
+	// Quarantine configures quarantining targets which fail consecutive
+	// probes.
+	Quarantine QuarantineConfig
+
+	// ProbeInterval enables background probing when non-zero. Targets are
+	// then probed on their own interval and Collect serves the most recent
+	// cached results.
+	ProbeInterval time.Duration
+
+	// MetricRelabelConfigs are applied to the metrics returned by the /probe
+	// endpoint. Relabeling of other metrics is done by the integration.
+	MetricRelabelConfigs []*relabel.Config
+
+	// AllowAdhocProbes allows the /probe endpoint to probe targets which
+	// aren't configured, using the network probers only.
+	AllowAdhocProbes bool
+
+	// DefaultModule is the module used for targets which don't set one. If
+	// empty, the default module of SSLConfig is used.
+	DefaultModule string
+
+	// ProbeTimeout is the default timeout for probes. It is used when
+	// neither the target nor its module set a timeout.
+	ProbeTimeout time.Duration
+
+	// Discovery configures the discovery of additional targets.
+	Discovery DiscoveryConfig
+
+	// ProxyURL is the URL of the proxy used for probing targets which don't
+	// set their own proxy.
+	ProxyURL string
+
+	// Vault configures the connection to Vault used by the vault_pki and
+	// vault_secret probers.
+	Vault *VaultConfig
+
+	// ModuleOptions holds the settings of the integration for SSL modules,
+	// keyed by module name.
+	ModuleOptions map[string]ModuleOptions
+
+	// ExpiryThresholds are the thresholds of the ssl_cert_expiring_within
+	// metric of every certificate collected by probes.
+	ExpiryThresholds []string
 }
 
+// NewSSLExporter creates a new Exporter.
 func NewSSLExporter(opts Options) (*Exporter, error) {
+	maxConcurrent := opts.MaxConcurrentProbes
+	if maxConcurrent <= 0 {
+		maxConcurrent = 1
+	}
+
 	e := &Exporter{
-		options:   opts,
-		namespace: opts.Namespace,
-		probeSuccess: prometheus.NewGauge(
-			prometheus.GaugeOpts{
-				Name: prometheus.BuildFQName(opts.Namespace, "", "probe_success"),
-				Help: "If the probe was a success",
-			},
-		),
-		proberType: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Name: prometheus.BuildFQName(opts.Namespace, "", "prober"),
-				Help: "The prober used by the exporter to connect to the target",
-			},
-			[]string{"prober"},
-		),
+		options:         opts,
+		namespace:       opts.Namespace,
+		loadedSSLConfig: opts.SSLConfig,
+		probeSem:        make(chan struct{}, maxConcurrent),
+		crls:            newCRLCache(),
+		statuses:        make(map[string]targetStatus),
+		counts:          make(map[string]*probeCounts),
+
+		targetsUpdated: make(chan struct{}, 1),
+	}
+	e.updateLabels()
+
+	if opts.ConfigFile != "" {
+		e.configHash, _ = hashFile(opts.ConfigFile)
+	}
+	if opts.ProbeRateLimit > 0 {
+		burst := opts.ProbeRateBurst
+		if burst <= 0 {
+			burst = 1
+		}
+		e.probeLimiter = rate.NewLimiter(rate.Limit(opts.ProbeRateLimit), burst)
+	}
+	thresholds, err := parseExpiryThresholds(opts.ExpiryThresholds)
+	if err != nil {
+		return nil, err
+	}
+	e.expiryThresholds = thresholds
+	if opts.Quarantine.Enabled() {
+		e.quarantine = newQuarantine(opts.Quarantine)
+	}
+	if opts.Vault != nil {
+		vc, err := newVaultClient(*opts.Vault)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create vault client: %w", err)
+		}
+		e.vault = vc
+	}
+	e.secrets = newSecretResolver(e.vault)
+	if opts.ProbeInterval > 0 {
+		e.scheduler = newScheduler(e)
+	}
+	if opts.Discovery.Enabled() {
+		e.discoverer = &discoverer{
+			log:      log.With(opts.log, "component", "discovery"),
+			cfg:      opts.Discovery,
+			onUpdate: e.setDiscoveredTargets,
+		}
 	}
 
 	return e, nil
 }
 
-func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
-	for _, desc := range descs {
-		ch <- desc
+// Run runs target discovery, reloading of the SSL config file, and the
+// background scheduler, if enabled, until ctx is canceled.
+func (e *Exporter) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	runBackground := func(name string, f func(context.Context) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f(ctx); err != nil {
+				level.Error(e.options.log).Log("msg", name+" failed", "err", err)
+			}
+		}()
 	}
+
+	if e.discoverer != nil {
+		runBackground("target discovery", e.discoverer.Run)
+	}
+	if e.options.ConfigFile != "" && e.options.ConfigReloadInterval > 0 {
+		runBackground("reloading the ssl config file", e.watchConfigFile)
+	}
+
+	if e.scheduler == nil {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return e.scheduler.Run(ctx)
 }
 
-func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+// targets returns the static and discovered targets. Discovered targets
+// which have the same name as a static target are ignored.
+func (e *Exporter) targets() []SSLTarget {
+	e.targetsMut.RLock()
+	defer e.targetsMut.RUnlock()
+
+	if len(e.discovered) == 0 {
+		return e.options.SSLTargets
+	}
+
+	names := make(map[string]struct{}, len(e.options.SSLTargets))
+	res := make([]SSLTarget, 0, len(e.options.SSLTargets)+len(e.discovered))
+	for _, t := range e.options.SSLTargets {
+		names[t.Name] = struct{}{}
+		res = append(res, t)
+	}
+	for _, t := range e.discovered {
+		if _, exist := names[t.Name]; !exist {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+func (e *Exporter) setDiscoveredTargets(targets []SSLTarget) {
+	previous := e.targets()
+
+	e.targetsMut.Lock()
+	e.discovered = targets
+	e.targetsMut.Unlock()
+
+	e.forgetRemovedTargets(previous)
+
+	// The custom labels and probe_all_ips of the discovered targets may
+	// change the labels of every metric.
+	e.updateLabels()
+
+	// Notify the scheduler without blocking if a notification is already
+	// pending.
+	select {
+	case e.targetsUpdated <- struct{}{}:
+	default:
+	}
+}
+
+// forgetRemovedTargets forgets the targets of previous which are no longer
+// probed. The scheduler forgets the targets it stops probing as well, in case
+// one of their probes finished in the meantime.
+func (e *Exporter) forgetRemovedTargets(previous []SSLTarget) {
+	current := make(map[string]struct{})
+	for _, t := range e.targets() {
+		current[t.Name] = struct{}{}
+	}
+
+	// Wait for running scrapes, which may still be probing the removed
+	// targets.
 	e.Lock()
 	defer e.Unlock()
 
-	logger := e.options.log
+	for _, t := range previous {
+		if _, ok := current[t.Name]; !ok {
+			e.forgetTarget(t)
+		}
+	}
+}
 
-	for _, target := range e.options.SSLTargets {
-		ctx := context.Background()
+// Describe implements prometheus.Collector. The Exporter is an unchecked
+// collector, since descriptors of metrics which aren't in metricOpts are only
+// known once a prober collects them.
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {}
 
-		var moduleName string
-		if target.Module != "" {
-			moduleName = e.options.SSLConfig.DefaultModule
-			if moduleName == "" {
-				level.Error(logger).Log("msg", "Module parameter must be set")
-				continue
-			}
-		}
+// collectCounters collects the probe counters of the targets, and whether
+// they're quarantined if quarantining is enabled. Targets which weren't
+// probed yet are skipped.
+func (e *Exporter) collectCounters(ch chan<- prometheus.Metric) {
+	labels := e.labels()
 
-		module, ok := e.options.SSLConfig.Modules[target.Module]
+	e.countsMut.Lock()
+	defer e.countsMut.Unlock()
+
+	for _, target := range e.targets() {
+		counts, ok := e.counts[target.Name]
 		if !ok {
-			level.Error(logger).Log("msg", fmt.Sprintf("Unknown module '%s'", target.Module))
 			continue
 		}
 
-		probeFunc, ok := prober.Probers[module.Prober]
-		if !ok {
-			level.Error(logger).Log("msg", fmt.Sprintf("Unknown prober %q", module.Prober))
-			continue
+		labelValues := append([]string{target.Name}, labels.customLabelValues(target)...)
+		ch <- prometheus.MustNewConstMetric(labels.probesTotal, prometheus.CounterValue, counts.probes, labelValues...)
+		ch <- prometheus.MustNewConstMetric(labels.probeFailuresTotal, prometheus.CounterValue, counts.failures, labelValues...)
+
+		if e.quarantine != nil {
+			var quarantined float64
+			if _, ok := e.quarantine.check(target.Name); ok {
+				quarantined = 1
+			}
+			ch <- prometheus.MustNewConstMetric(labels.targetQuarantined, prometheus.GaugeValue, quarantined, labelValues...)
 		}
+	}
+}
+
+// Collect implements prometheus.Collector. When background probing is
+// enabled, the cached results of the most recent probes are returned.
+// Otherwise, targets are probed concurrently, with at most
+// Options.MaxConcurrentProbes probes running at once.
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	if e.scheduler != nil {
+		e.scheduler.Collect(ch)
+		e.collectCounters(ch)
+		return
+	}
 
-		e.options.Registry = prometheus.NewRegistry()
-		e.options.Registry.MustRegister(e.probeSuccess, e.proberType)
-		e.proberType.WithLabelValues(module.Prober).Set(1)
+	e.Lock()
+	defer e.Unlock()
 
-		// set high-level metric not collected in the prober
-		err := probeFunc(ctx, logger, target.Target, module, e.options.Registry)
-		if err != nil {
-			level.Error(logger).Log("msg", err)
-			e.probeSuccess.Set(0)
-		} else {
-			e.probeSuccess.Set(1)
+	var wg sync.WaitGroup
+	for _, target := range e.targets() {
+		wg.Add(1)
+
+		go func(target SSLTarget) {
+			defer wg.Done()
+			for _, m := range e.probeTarget(context.Background(), target) {
+				ch <- m
+			}
+		}(target)
+	}
+	wg.Wait()
+
+	e.collectCounters(ch)
+}
+
+// probe runs a single probe against target and returns the resulting
+// metrics, along with the error of the probe if it failed. probe is safe to
+// call concurrently; it blocks until the probe rate limit allows another
+// probe and the number of running probes is below
+// Options.MaxConcurrentProbes.
+func (e *Exporter) probe(ctx context.Context, target SSLTarget) ([]prometheus.Metric, error) {
+	if e.probeLimiter != nil {
+		if err := e.probeLimiter.Wait(ctx); err != nil {
+			return nil, err
 		}
+	}
+
+	e.probeSem <- struct{}{}
+	defer func() { <-e.probeSem }()
+
+	logger := log.With(e.options.log, "target", target.Name)
+
+	sslConfig := e.sslConfig()
+	module, probeFunc, err := resolveModule(target.Module, e.options.DefaultModule, sslConfig)
+	if err != nil {
+		level.Error(logger).Log("msg", err)
+		return nil, err
+	}
+	name := moduleName(target.Module, e.options.DefaultModule, sslConfig)
 
-		// gather all the metrics we've collected in the prober
-		metricFams, err := e.options.Registry.Gather()
+	if timeout := e.probeTimeout(target, module); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if !target.ProbeAllIPs {
+		module, probeFunc, err := e.targetProber(target, name, module, probeFunc, "")
 		if err != nil {
 			level.Error(logger).Log("msg", err)
-			continue
+			return nil, err
 		}
-		for _, mf := range metricFams {
-			for _, m := range mf.Metric {
-				// get desc from name
-				desc, ok := descs[*mf.Name]
-				if !ok {
-					level.Error(logger).Log("msg", fmt.Sprintf("Unknown metric %q", *mf.Name))
-					continue
-				}
+		return e.probeOnce(ctx, logger, target, module, probeFunc, "")
+	}
 
-				// ensure label order
-				sort.Slice(m.Label, func(i, j int) bool {
-					iPrec := labelOrder[*m.Label[i].Name]
-					jPrec := labelOrder[*m.Label[j].Name]
-					return iPrec < jPrec
-				})
-				labelValues := []string{}
-				for _, l := range m.Label {
-					labelValues = append(labelValues, *l.Value)
-				}
+	ips, err := resolveTargetIPs(ctx, module.Prober, target.Target, e.ipProtocol(target, name))
+	if err != nil {
+		level.Error(logger).Log("msg", err)
+		return nil, err
+	}
+
+	// Every address is probed concurrently. The probe fails if probing any
+	// of the addresses fails.
+	var (
+		wg       sync.WaitGroup
+		mut      sync.Mutex
+		metrics  []prometheus.Metric
+		probeErr error
+	)
+	for _, ip := range ips {
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
 
-				// create prometheus metric
-				metric, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, *m.Gauge.Value, labelValues...)
+			ipLogger := log.With(logger, "ip", ip)
+			ipMetrics, err := func() ([]prometheus.Metric, error) {
+				module, probeFunc, err := e.targetProber(target, name, module, probeFunc, ip)
 				if err != nil {
-					level.Error(logger).Log("msg", err)
-					continue
+					level.Error(ipLogger).Log("msg", err)
+					return nil, err
 				}
-				ch <- metric
+				return e.probeOnce(ctx, ipLogger, target, module, probeFunc, ip)
+			}()
+
+			mut.Lock()
+			defer mut.Unlock()
+			metrics = append(metrics, ipMetrics...)
+			if err != nil && probeErr == nil {
+				probeErr = fmt.Errorf("probing %s failed: %w", ip, err)
+			}
+		}(ip)
+	}
+	wg.Wait()
+
+	return metrics, probeErr
+}
+
+// probeOnce probes target with probeFunc and returns the collected metrics.
+// ip is the IP address which is probed, if the target is probed on all of
+// its addresses.
+func (e *Exporter) probeOnce(ctx context.Context, logger log.Logger, target SSLTarget, module ssl_config.Module, probeFunc prober.ProbeFn, ip string) ([]prometheus.Metric, error) {
+	// Every probe uses its own registry and high-level metrics so concurrent
+	// probes don't overwrite each other's results.
+	var (
+		registry     = prometheus.NewRegistry()
+		probeSuccess = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(e.namespace, "", "probe_success"),
+				Help: "If the probe was a success",
+			},
+		)
+		proberType = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(e.namespace, "", "prober"),
+				Help: "The prober used by the exporter to connect to the target",
+			},
+			[]string{"prober"},
+		)
+	)
+	registry.MustRegister(probeSuccess, proberType)
+	proberType.WithLabelValues(module.Prober).Set(1)
+
+	if r := certRecorderFrom(ctx); r != nil && ip != "" {
+		ctx = withCertRecorder(ctx, r.withSource(ipLabel, ip))
+	}
+
+	// The certificates of targets which pin certificates are recorded for
+	// checking the pins.
+	var pinned *certRecorder
+	if target.Pin != nil {
+		pinned = newCertRecorder()
+		ctx = withCertRecorder(ctx, certRecorderFrom(ctx).tee(pinned))
+	}
+
+	// set high-level metric not collected in the prober
+	start := time.Now()
+	probeErr := probeFunc(ctx, logger, target.Target, module, registry)
+	duration := time.Since(start)
+	if probeErr != nil {
+		level.Error(logger).Log("msg", probeErr)
+		probeSuccess.Set(0)
+	} else {
+		probeSuccess.Set(1)
+	}
+
+	// gather all the metrics we've collected in the prober
+	metricFams, err := registry.Gather()
+	if err != nil {
+		level.Error(logger).Log("msg", err)
+		return nil, err
+	}
+
+	var (
+		labels       = e.labels()
+		customValues = labels.customLabelValues(target)
+	)
+
+	// newMetric creates a metric which isn't collected by the prober.
+	newMetric := func(key string, value float64, labelValues ...string) prometheus.Metric {
+		labelValues = append(labels.baseLabelValues(target, ip), labelValues...)
+		labelValues = append(labelValues, customValues...)
+		return prometheus.MustNewConstMetric(labels.descs[key], prometheus.GaugeValue, value, labelValues...)
+	}
+
+	metrics := []prometheus.Metric{
+		newMetric("ssl_probe_duration_seconds", duration.Seconds()),
+	}
+	if probeErr != nil {
+		metrics = append(metrics, newMetric("ssl_probe_error_info", 1, classifyProbeError(probeErr)))
+	}
+	for _, mf := range metricFams {
+		for _, m := range mf.Metric {
+			desc, labelNames := labels.metricDesc(mf, m)
+
+			// Labels are looked up by name, since probers may omit labels
+			// of a metric which don't apply to them.
+			values := make(map[string]string, len(m.Label))
+			for _, l := range m.Label {
+				values[l.GetName()] = l.GetValue()
+			}
+			labelValues := labels.baseLabelValues(target, ip)
+			for _, name := range labelNames {
+				labelValues = append(labelValues, values[name])
+			}
+			labelValues = append(labelValues, customValues...)
+
+			valueType, value, ok := metricValue(mf.GetType(), m)
+			if !ok {
+				level.Error(logger).Log("msg", fmt.Sprintf("Unsupported type %s of metric %q", mf.GetType(), mf.GetName()))
+				continue
+			}
+
+			// create prometheus metric
+			metric, err := prometheus.NewConstMetric(desc, valueType, value, labelValues...)
+			if err != nil {
+				level.Error(logger).Log("msg", err)
+				continue
 			}
+			metrics = append(metrics, metric)
 		}
 	}
+	metrics = append(metrics, e.expiryMetrics(metricFams, time.Now(), newMetric)...)
+	if certs := pinned.certificates(); len(certs) > 0 {
+		var match float64
+		if target.Pin.matches(certs) {
+			match = 1
+		}
+		metrics = append(metrics, newMetric("ssl_cert_pin_match", match))
+	}
+	return metrics, probeErr
+}
+
+// dynamicDesc is the descriptor of a metric which isn't in metricOpts, along
+// with the names of the labels of the metric, in the order of the descriptor.
+type dynamicDesc struct {
+	desc       *prometheus.Desc
+	labelNames []string
+}
+
+// metricDesc returns the descriptor of m, which belongs to mf, along with the
+// names of the labels of m in the order of the descriptor, excluding the
+// labels added by the Exporter. Metrics in metricOpts use their predefined
+// descriptor. Descriptors of other metrics, like metrics added by new
+// versions of the upstream probers, are built from mf and m.
+func (l *labelSet) metricDesc(mf *dto.MetricFamily, m *dto.Metric) (*prometheus.Desc, []string) {
+	if desc, ok := l.descs[mf.GetName()]; ok {
+		return desc, metricOpts[mf.GetName()].labels
+	}
+
+	labelNames := make([]string, 0, len(m.Label))
+	for _, l := range m.Label {
+		labelNames = append(labelNames, l.GetName())
+	}
+	key := mf.GetName() + "\xff" + strings.Join(labelNames, "\xff")
+
+	l.dynamicDescsMut.Lock()
+	defer l.dynamicDescsMut.Unlock()
+
+	if dd, ok := l.dynamicDescs[key]; ok {
+		return dd.desc, dd.labelNames
+	}
+
+	dd := dynamicDesc{
+		desc:       prometheus.NewDesc(mf.GetName(), mf.GetHelp(), l.labelNames(labelNames), nil),
+		labelNames: labelNames,
+	}
+	l.dynamicDescs[key] = dd
+	return dd.desc, dd.labelNames
+}
+
+// metricValue returns the value of m, which has type typ. ok is false for
+// types which can't be exposed as a single value, like histograms.
+func metricValue(typ dto.MetricType, m *dto.Metric) (valueType prometheus.ValueType, value float64, ok bool) {
+	switch typ {
+	case dto.MetricType_GAUGE:
+		return prometheus.GaugeValue, m.GetGauge().GetValue(), true
+	case dto.MetricType_COUNTER:
+		return prometheus.CounterValue, m.GetCounter().GetValue(), true
+	case dto.MetricType_UNTYPED:
+		return prometheus.UntypedValue, m.GetUntyped().GetValue(), true
+	default:
+		return 0, 0, false
+	}
+}
+
+// probeTarget probes a configured target and records the result of the
+// probe in the probe counters and on the status page.
+func (e *Exporter) probeTarget(ctx context.Context, target SSLTarget) []prometheus.Metric {
+	// Quarantined targets aren't probed. The results of their last probe are
+	// returned instead.
+	if e.quarantine != nil {
+		if metrics, quarantined := e.quarantine.check(target.Name); quarantined {
+			return metrics
+		}
+	}
+
+	start := time.Now()
+	certs := newCertRecorder()
+	metrics, err := e.probe(withCertRecorder(ctx, certs), target)
+
+	// Probes which were aborted by shutting down or removing the target
+	// aren't counted.
+	if ctx.Err() == context.Canceled {
+		return metrics
+	}
+
+	e.countProbe(target, err)
+	e.recordStatus(target, start, certs.certificates(), err)
+	if e.quarantine != nil {
+		e.quarantine.update(target.Name, metrics, err)
+	}
+	return metrics
+}
+
+// probeCounts counts the probes of a target.
+type probeCounts struct {
+	probes, failures float64
+}
+
+// countProbe records a probe of target in the probe counters. The probe
+// failed if err is non-nil.
+func (e *Exporter) countProbe(target SSLTarget, err error) {
+	e.countsMut.Lock()
+	defer e.countsMut.Unlock()
+
+	counts, ok := e.counts[target.Name]
+	if !ok {
+		counts = &probeCounts{}
+		e.counts[target.Name] = counts
+	}
+	counts.probes++
+	if err != nil {
+		counts.failures++
+	}
+}
+
+// forgetTarget removes the probe counters, status, and quarantine of a target
+// which is no longer probed.
+func (e *Exporter) forgetTarget(target SSLTarget) {
+	e.countsMut.Lock()
+	delete(e.counts, target.Name)
+	e.countsMut.Unlock()
+
+	e.forgetStatus(target)
+	if e.quarantine != nil {
+		e.quarantine.forget(target.Name)
+	}
+}
+
+// ipProtocol returns the IP protocol used for probing target with the module
+// named moduleName. The protocol of the target takes precedence over the
+// protocol of the module.
+func (e *Exporter) ipProtocol(target SSLTarget, moduleName string) string {
+	if target.IPProtocol != "" {
+		return target.IPProtocol
+	}
+	return e.options.ModuleOptions[moduleName].IPProtocol
+}
+
+// labelSet holds the labels added to every metric of the Exporter, along with
+// the descriptors of the metrics using them. The labels depend on all
+// targets, so the labelSet is rebuilt when discovery changes the targets.
+type labelSet struct {
+	// customLabels is the sorted set of custom label names across all
+	// targets, which is appended to the labels of every descriptor.
+	customLabels []string
+
+	// labelIPs is true when ipLabel is added to every descriptor.
+	labelIPs bool
+
+	// descs holds the descriptors of all metrics, keyed like metricOpts.
+	descs map[string]*prometheus.Desc
+
+	// Descriptors of the probe counters and the quarantine gauge, which are
+	// labeled by target and custom labels.
+	probesTotal        *prometheus.Desc
+	probeFailuresTotal *prometheus.Desc
+	targetQuarantined  *prometheus.Desc
+
+	// dynamicDescs caches the descriptors of metrics collected by probers
+	// which aren't in metricOpts, keyed by name and label names.
+	dynamicDescsMut sync.Mutex
+	dynamicDescs    map[string]dynamicDesc
+}
+
+func newLabelSet(customLabels []string, labelIPs bool) *labelSet {
+	l := &labelSet{
+		customLabels: customLabels,
+		labelIPs:     labelIPs,
+		descs:        make(map[string]*prometheus.Desc, len(metricOpts)),
+		dynamicDescs: make(map[string]dynamicDesc),
+	}
+	for key, opt := range metricOpts {
+		l.descs[key] = prometheus.NewDesc(opt.fqName, opt.help, l.labelNames(opt.labels), nil)
+	}
+
+	counterLabels := append([]string{targetLabel}, customLabels...)
+	l.probesTotal = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "probes_total"),
+		"Total number of probes of a target",
+		counterLabels, nil,
+	)
+	l.probeFailuresTotal = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "probe_failures_total"),
+		"Total number of failed probes of a target",
+		counterLabels, nil,
+	)
+	l.targetQuarantined = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "target_quarantined"),
+		"If the target is quarantined after failing consecutive probes",
+		counterLabels, nil,
+	)
+	return l
+}
+
+// labelNames returns the label names of the descriptor of a metric labeled by
+// names, which are preceded by the target and IP labels and followed by the
+// custom labels.
+func (l *labelSet) labelNames(names []string) []string {
+	res := []string{targetLabel}
+	if l.labelIPs {
+		res = append(res, ipLabel)
+	}
+	res = append(res, names...)
+	return append(res, l.customLabels...)
+}
+
+// baseLabelValues returns the values of the labels which precede the labels
+// of every metric of target. ip is the IP address which was probed, if any.
+func (l *labelSet) baseLabelValues(target SSLTarget, ip string) []string {
+	if l.labelIPs {
+		return []string{target.Name, ip}
+	}
+	return []string{target.Name}
+}
+
+// customLabelValues returns the values of the custom labels for target.
+func (l *labelSet) customLabelValues(target SSLTarget) []string {
+	values := make([]string, 0, len(l.customLabels))
+	for _, name := range l.customLabels {
+		values = append(values, target.Labels[name])
+	}
+	return values
+}
+
+// labels returns the current labels of the metrics of the Exporter.
+func (e *Exporter) labels() *labelSet {
+	e.labelsMut.RLock()
+	defer e.labelsMut.RUnlock()
+	return e.labelSet
+}
+
+// updateLabels rebuilds the labels of the metrics from the current targets.
+// The labels are kept if their names didn't change, so the cached
+// descriptors are reused.
+func (e *Exporter) updateLabels() {
+	var (
+		targets      = e.targets()
+		customLabels = customLabelNames(targets)
+		labelIPs     = anyProbesAllIPs(targets)
+	)
+
+	e.labelsMut.Lock()
+	defer e.labelsMut.Unlock()
+
+	if l := e.labelSet; l != nil && l.labelIPs == labelIPs && equalStrings(l.customLabels, customLabels) {
+		return
+	}
+	e.labelSet = newLabelSet(customLabels, labelIPs)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// probeTimeout returns the timeout to use when probing target with module.
+// The timeout of the target takes precedence over the timeout of the module,
+// which takes precedence over Options.ProbeTimeout. Zero means no timeout.
+func (e *Exporter) probeTimeout(target SSLTarget, module ssl_config.Module) time.Duration {
+	switch {
+	case target.Timeout > 0:
+		return target.Timeout
+	case module.Timeout > 0:
+		return module.Timeout
+	default:
+		return e.options.ProbeTimeout
+	}
+}
+
+// customLabelNames returns the sorted set of custom label names used across
+// targets. Targets which don't set one of the labels get an empty value for
+// it.
+func customLabelNames(targets []SSLTarget) []string {
+	set := make(map[string]struct{})
+	for _, t := range targets {
+		for name := range t.Labels {
+			set[name] = struct{}{}
+		}
+	}
+
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// anyProbesAllIPs returns whether any of targets sets ProbeAllIPs.
+func anyProbesAllIPs(targets []SSLTarget) bool {
+	for _, t := range targets {
+		if t.ProbeAllIPs {
+			return true
+		}
+	}
+	return false
+}
+
+// moduleName returns the name of the module used for probing a target, or
+// an empty string if no module is set.
+func moduleName(targetModule, integrationDefault string, sslConfig *ssl_config.Config) string {
+	switch {
+	case targetModule != "":
+		return targetModule
+	case integrationDefault != "":
+		return integrationDefault
+	default:
+		return sslConfig.DefaultModule
+	}
+}
+
+// resolveModule returns the module used for probing a target, along with the
+// function of its prober. The module of the target takes precedence over
+// integrationDefault, which takes precedence over the default module of
+// sslConfig.
+func resolveModule(targetModule, integrationDefault string, sslConfig *ssl_config.Config) (ssl_config.Module, prober.ProbeFn, error) {
+	name := moduleName(targetModule, integrationDefault, sslConfig)
+	if name == "" {
+		return ssl_config.Module{}, nil, fmt.Errorf("module must be set as no default module is configured")
+	}
+
+	module, ok := sslConfig.Modules[name]
+	if !ok {
+		return ssl_config.Module{}, nil, fmt.Errorf("unknown module %q", name)
+	}
+	probeFunc, ok := proberFunc(module.Prober)
+	if !ok {
+		return ssl_config.Module{}, nil, fmt.Errorf("unknown prober %q for module %q", module.Prober, name)
+	}
+	return module, probeFunc, nil
+}
+
+// proberFunc returns the function of the prober name, which is either an
+// upstream prober or a prober implemented by the integration.
+func proberFunc(name string) (prober.ProbeFn, bool) {
+	if probeFunc, ok := prober.Probers[name]; ok {
+		return probeFunc, true
+	}
+	probeFunc, ok := localProbers[name]
+	return probeFunc, ok
 }
@@ -3,19 +3,27 @@ package ssl_exporter
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/grafana/agent/pkg/integrations"
 	integrations_v2 "github.com/grafana/agent/pkg/integrations/v2"
 	"github.com/grafana/agent/pkg/integrations/v2/metricsutils"
+	config_util "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/relabel"
 	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
 )
 
 // DefaultConfig holds the default settings for the ssl_exporter integration.
 var DefaultConfig = Config{
-	ConfigFile: "",
-	SSLTargets: []SSLTarget{},
+	ConfigFile:           "",
+	SSLTargets:           []SSLTarget{},
+	MaxConcurrentProbes:  10,
+	ProbeTimeout:         10 * time.Second,
+	ConfigReloadInterval: time.Minute,
 }
 
 // SSLTarget represents a target to scrape.
@@ -23,6 +31,59 @@ type SSLTarget struct {
 	Name   string `yaml:"name"`
 	Target string `yaml:"target"`
 	Module string `yaml:"module"`
+
+	// ProbeInterval overrides Config.ProbeInterval for this target.
+	ProbeInterval time.Duration `yaml:"probe_interval,omitempty"`
+
+	// Timeout overrides Config.ProbeTimeout and the timeout of the module
+	// for this target.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// Labels are added to every metric emitted for this target.
+	Labels map[string]string `yaml:"labels,omitempty"`
+
+	// ServerName overrides the server name of the module for this target.
+	// It is sent through SNI and used for verifying the certificate of the
+	// target, which allows probing a host by IP address.
+	ServerName string `yaml:"server_name,omitempty"`
+
+	// KeystorePassword is used to open the keystores of this target when
+	// probing with the keystore prober.
+	KeystorePassword config_util.Secret `yaml:"keystore_password,omitempty"`
+
+	// SSH configures how the sftp prober connects to this target.
+	SSH *SSHConfig `yaml:"ssh,omitempty"`
+
+	// KubernetesSecrets selects the secrets collected by the kubernetes
+	// prober for this target.
+	KubernetesSecrets *KubernetesSecretsConfig `yaml:"kubernetes_secrets,omitempty"`
+
+	// ClientCert is the client certificate presented to this target, for
+	// targets which require mutual TLS. It overrides the client certificate
+	// of the module.
+	ClientCert *ClientCertConfig `yaml:"client_cert,omitempty"`
+
+	// ProxyURL is the URL of an HTTP CONNECT or SOCKS5 proxy used for probing
+	// this target. It overrides Config.ProxyURL and the proxy of the module.
+	ProxyURL string `yaml:"proxy_url,omitempty"`
+
+	// ProbeAllIPs probes every IP address the host of this target resolves
+	// to, instead of a single one. Metrics of every address are labeled with
+	// the address.
+	ProbeAllIPs bool `yaml:"probe_all_ips,omitempty"`
+
+	// IPProtocol is the address family used for probing this target. It
+	// overrides the IP protocol of the module.
+	IPProtocol string `yaml:"ip_protocol,omitempty"`
+
+	// StartTLS upgrades the connections to this target to TLS through
+	// STARTTLS. It overrides the STARTTLS protocol of the module. Only
+	// supported by the tcp prober.
+	StartTLS *StartTLSConfig `yaml:"starttls,omitempty"`
+
+	// Pin holds the certificates expected to be collected when probing this
+	// target, which are checked by the ssl_cert_pin_match metric.
+	Pin *PinConfig `yaml:"pin,omitempty"`
 }
 
 // Config controls the ssl_exporter integration.
@@ -30,11 +91,91 @@ type Config struct {
 	IncludeExporterMetrics bool        `yaml:"include_exporter_metrics"`
 	ConfigFile             string      `yaml:"config_file,omitempty"`
 	SSLTargets             []SSLTarget `yaml:"ssl_targets"`
+	MaxConcurrentProbes    int         `yaml:"max_concurrent_probes,omitempty"`
+
+	// ProbeRateLimit limits how many probes are started per second, with
+	// bursts of up to ProbeRateBurst probes.
+	ProbeRateLimit float64 `yaml:"probe_rate_limit,omitempty"`
+	ProbeRateBurst int     `yaml:"probe_rate_burst,omitempty"`
+
+	// Quarantine configures quarantining targets which fail consecutive
+	// probes, so they aren't probed every cycle.
+	Quarantine QuarantineConfig `yaml:"quarantine,omitempty"`
+
+	// ProbeInterval enables probing targets in the background. When set,
+	// scrapes return the results of the most recent probes instead of
+	// probing targets on every scrape.
+	ProbeInterval time.Duration `yaml:"probe_interval,omitempty"`
+
+	// ProbeTimeout is the default timeout for probing a target.
+	ProbeTimeout time.Duration `yaml:"probe_timeout,omitempty"`
+
+	// DefaultModule is used for targets which don't set a module. If empty,
+	// the default module of the SSL config is used.
+	DefaultModule string `yaml:"default_module,omitempty"`
+
+	// ConfigReloadInterval is how often ConfigFile is checked for changes.
+	// Changes are applied without restarting the integration. Zero disables
+	// reloading.
+	ConfigReloadInterval time.Duration `yaml:"config_file_reload_interval,omitempty"`
+
+	// MetricRelabelConfigs are applied to all metrics exposed by the
+	// integration, including metrics from the /probe endpoint.
+	MetricRelabelConfigs []*relabel.Config `yaml:"exporter_metric_relabel_configs,omitempty"`
+
+	// AllowAdhocProbes allows the /probe endpoint to probe targets which
+	// aren't configured. Only modules using the tcp and https probers may be
+	// used for them.
+	AllowAdhocProbes bool `yaml:"allow_adhoc_probes,omitempty"`
+
+	// Discovery configures discovering targets in addition to SSLTargets.
+	Discovery DiscoveryConfig `yaml:"discovery,omitempty"`
+
+	// ProxyURL is the URL of an HTTP CONNECT or SOCKS5 proxy used for probing
+	// targets with the tcp and https probers.
+	ProxyURL string `yaml:"proxy_url,omitempty"`
+
+	// Vault configures the connection to Vault used by the vault_pki and
+	// vault_secret probers.
+	Vault *VaultConfig `yaml:"vault,omitempty"`
+
+	// ModuleOptions holds settings of the integration for SSL modules, keyed
+	// by module name.
+	ModuleOptions map[string]ModuleOptions `yaml:"module_options,omitempty"`
+
+	// ExpiryThresholds are the thresholds of the ssl_cert_expiring_within
+	// metric, which is 1 for every certificate which expires within a
+	// threshold, like 7d or 30d.
+	ExpiryThresholds []string `yaml:"expiry_thresholds,omitempty"`
+}
+
+// ModuleOptions holds settings for an SSL module which extend the settings
+// of the module in the SSL config.
+type ModuleOptions struct {
+	// CheckCRL enables checking whether the certificates of targets are
+	// revoked through the CRLs of their distribution points. Only supported
+	// by the tcp and https probers.
+	CheckCRL bool `yaml:"check_crl,omitempty"`
+
+	// CheckSessionResumption enables checking whether targets support
+	// session resumption by connecting to them a second time. Only supported
+	// by the tcp prober.
+	CheckSessionResumption bool `yaml:"check_session_resumption,omitempty"`
+
+	// ALPNProtocols are the application protocols offered through ALPN
+	// during the TLS handshake, like h2 or http/1.1. Probes fail unless the
+	// target negotiates one of them. Only supported by the tcp prober.
+	ALPNProtocols []string `yaml:"alpn_protocols,omitempty"`
+
+	// IPProtocol is the address family used for probing targets: ip4, ip6,
+	// prefer-ip4, or prefer-ip6. Any family is used if empty. Only supported
+	// by the tcp and https probers.
+	IPProtocol string `yaml:"ip_protocol,omitempty"`
 }
 
 func (c Config) GetExporterOptions(log log.Logger) (*Options, error) {
 	var err error
-	conf := ssl_config.DefaultConfig
+	conf := defaultSSLConfig()
 
 	if c.ConfigFile != "" {
 		conf, err = ssl_config.LoadConfig(c.ConfigFile)
@@ -44,13 +185,48 @@ func (c Config) GetExporterOptions(log log.Logger) (*Options, error) {
 	}
 
 	return &Options{
-		Namespace:  c.Name(),
-		SSLTargets: c.SSLTargets,
-		SSLConfig:  conf,
-		log:        log,
+		Namespace:            c.Name(),
+		ProbePath:            "/probe",
+		StatusPath:           "/status",
+		SSLTargets:           c.SSLTargets,
+		SSLConfig:            conf,
+		MaxConcurrentProbes:  c.MaxConcurrentProbes,
+		ProbeRateLimit:       c.ProbeRateLimit,
+		ProbeRateBurst:       c.ProbeRateBurst,
+		Quarantine:           c.Quarantine,
+		ProbeInterval:        c.ProbeInterval,
+		ProbeTimeout:         c.ProbeTimeout,
+		Discovery:            c.Discovery,
+		DefaultModule:        c.DefaultModule,
+		MetricRelabelConfigs: c.MetricRelabelConfigs,
+		AllowAdhocProbes:     c.AllowAdhocProbes,
+		ConfigFile:           c.ConfigFile,
+		ConfigReloadInterval: c.ConfigReloadInterval,
+		ProxyURL:             c.ProxyURL,
+		Vault:                c.Vault,
+		ModuleOptions:        c.ModuleOptions,
+		ExpiryThresholds:     c.ExpiryThresholds,
+		log:                  log,
 	}, nil
 }
 
+// defaultSSLConfig returns the default config of ssl_exporter, which is used
+// when no config file is given, along with a module for every prober
+// implemented by the integration.
+func defaultSSLConfig() *ssl_config.Config {
+	conf := &ssl_config.Config{
+		DefaultModule: ssl_config.DefaultConfig.DefaultModule,
+		Modules:       make(map[string]ssl_config.Module, len(ssl_config.DefaultConfig.Modules)+len(localProbers)),
+	}
+	for name, module := range ssl_config.DefaultConfig.Modules {
+		conf.Modules[name] = module
+	}
+	for name := range localProbers {
+		conf.Modules[name] = ssl_config.Module{Prober: name}
+	}
+	return conf
+}
+
 // UnmarshalYAML implements yaml.Unmarshaler for Config.
 func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	*c = DefaultConfig
@@ -74,6 +250,154 @@ func (c *Config) NewIntegration(l log.Logger) (integrations.Integration, error)
 	return New(l, c)
 }
 
+// Validate returns an error if c is invalid, like when a target uses an
+// unknown module or a module uses an unknown prober.
+func (c *Config) Validate() error {
+	exporterConfig, err := c.GetExporterOptions(log.NewNopLogger())
+	if err != nil {
+		return fmt.Errorf("failed to get exporter config: %w", err)
+	}
+	return c.validate(exporterConfig.SSLConfig)
+}
+
+// validate validates c against sslConfig, the SSL config it's used with.
+func (c *Config) validate(sslConfig *ssl_config.Config) error {
+	names := make(map[string]struct{}, len(c.SSLTargets))
+	for _, target := range c.SSLTargets {
+		if target.Name == "" || target.Target == "" {
+			return fmt.Errorf("failed to load ssl_targets; the `name` and `target` fields are mandatory")
+		}
+		if _, exist := names[target.Name]; exist {
+			return fmt.Errorf("failed to load ssl_targets; found multiple targets with name %q", target.Name)
+		}
+		names[target.Name] = struct{}{}
+
+		if target.ProbeInterval > 0 && c.ProbeInterval <= 0 {
+			return fmt.Errorf("ssl_target %q sets probe_interval, but probe_interval is not set for the integration", target.Name)
+		}
+		if err := validateTargetLabels(target.Labels); err != nil {
+			return fmt.Errorf("invalid labels for ssl_target %q: %w", target.Name, err)
+		}
+		module, _, err := resolveModule(target.Module, c.DefaultModule, sslConfig)
+		if err != nil {
+			return fmt.Errorf("invalid module for ssl_target %q: %w", target.Name, err)
+		}
+		if (module.Prober == vaultPKIProber || module.Prober == vaultSecretProber) && c.Vault == nil {
+			return fmt.Errorf("ssl_target %q uses the %s prober, but vault is not configured", target.Name, module.Prober)
+		}
+		if module.Prober == sftpProber && target.SSH == nil {
+			return fmt.Errorf("ssl_target %q uses the %s prober, but ssh is not configured", target.Name, module.Prober)
+		}
+		if target.ProbeAllIPs && module.Prober != "tcp" && module.Prober != "https" {
+			return fmt.Errorf("ssl_target %q sets probe_all_ips, which isn't supported by the %s prober", target.Name, module.Prober)
+		}
+		if target.IPProtocol != "" {
+			if err := validateIPProtocol(target.IPProtocol); err != nil {
+				return fmt.Errorf("invalid ip_protocol for ssl_target %q: %w", target.Name, err)
+			}
+			if module.Prober != "tcp" && module.Prober != "https" {
+				return fmt.Errorf("ssl_target %q sets ip_protocol, which isn't supported by the %s prober", target.Name, module.Prober)
+			}
+		}
+		if target.StartTLS != nil {
+			if module.Prober != "tcp" {
+				return fmt.Errorf("ssl_target %q sets starttls, which isn't supported by the %s prober", target.Name, module.Prober)
+			}
+			if err := target.StartTLS.Validate(); err != nil {
+				return fmt.Errorf("invalid starttls for ssl_target %q: %w", target.Name, err)
+			}
+		}
+		if target.Pin != nil {
+			if err := target.Pin.Validate(); err != nil {
+				return fmt.Errorf("invalid pin for ssl_target %q: %w", target.Name, err)
+			}
+		}
+		if target.SSH != nil {
+			if err := target.SSH.Validate(); err != nil {
+				return fmt.Errorf("invalid ssh config for ssl_target %q: %w", target.Name, err)
+			}
+		}
+		if target.KubernetesSecrets != nil {
+			if module.Prober != "kubernetes" {
+				return fmt.Errorf("ssl_target %q sets kubernetes_secrets, which isn't supported by the %s prober", target.Name, module.Prober)
+			}
+			if err := target.KubernetesSecrets.Validate(); err != nil {
+				return fmt.Errorf("invalid kubernetes_secrets for ssl_target %q: %w", target.Name, err)
+			}
+		}
+		if target.ClientCert != nil {
+			if err := target.ClientCert.Validate(); err != nil {
+				return fmt.Errorf("invalid client_cert for ssl_target %q: %w", target.Name, err)
+			}
+			for _, file := range []string{target.ClientCert.CertFile, target.ClientCert.KeyFile} {
+				if err := validateSecretRef(file, c.Vault != nil); err != nil {
+					return fmt.Errorf("invalid client_cert for ssl_target %q: %w", target.Name, err)
+				}
+			}
+		}
+		if target.ProxyURL != "" {
+			if _, err := parseProxyURL(target.ProxyURL); err != nil {
+				return fmt.Errorf("invalid proxy_url for ssl_target %q: %w", target.Name, err)
+			}
+		}
+	}
+	if c.Discovery.Enabled() {
+		if _, _, err := resolveModule(c.Discovery.Module, c.DefaultModule, sslConfig); err != nil {
+			return fmt.Errorf("invalid module for discovered targets: %w", err)
+		}
+	}
+
+	if c.ProxyURL != "" {
+		if _, err := parseProxyURL(c.ProxyURL); err != nil {
+			return fmt.Errorf("invalid proxy_url: %w", err)
+		}
+	}
+
+	if c.Vault != nil {
+		if err := c.Vault.Validate(); err != nil {
+			return fmt.Errorf("invalid vault config: %w", err)
+		}
+	}
+
+	for name, module := range sslConfig.Modules {
+		if _, ok := proberFunc(module.Prober); !ok {
+			return fmt.Errorf("unknown prober %q for module %q", module.Prober, name)
+		}
+		if err := validateTLSRefs(module.TLSConfig, c.Vault != nil); err != nil {
+			return fmt.Errorf("invalid tls_config for module %q: %w", name, err)
+		}
+	}
+
+	for name, opts := range c.ModuleOptions {
+		if err := validateIPProtocol(opts.IPProtocol); err != nil {
+			return fmt.Errorf("invalid module_options for module %q: %w", name, err)
+		}
+		if module, ok := sslConfig.Modules[name]; ok && len(opts.ALPNProtocols) > 0 && module.Prober != "tcp" {
+			return fmt.Errorf("module_options for module %q set alpn_protocols, which isn't supported by the %s prober", name, module.Prober)
+		}
+	}
+
+	if c.MaxConcurrentProbes < 0 {
+		return fmt.Errorf("max_concurrent_probes must not be negative")
+	}
+	if c.ProbeRateLimit < 0 || c.ProbeRateBurst < 0 {
+		return fmt.Errorf("probe_rate_limit and probe_rate_burst must not be negative")
+	}
+	if _, err := parseExpiryThresholds(c.ExpiryThresholds); err != nil {
+		return err
+	}
+	if err := c.Quarantine.Validate(); err != nil {
+		return fmt.Errorf("invalid quarantine config: %w", err)
+	}
+	if c.ConfigReloadInterval < 0 {
+		return fmt.Errorf("config_file_reload_interval must not be negative")
+	}
+	if c.ProbeTimeout < 0 {
+		return fmt.Errorf("probe_timeout must not be negative")
+	}
+	return nil
+}
+
 func init() {
 	integrations.RegisterIntegration(&Config{})
 	integrations_v2.RegisterLegacy(&Config{}, integrations_v2.TypeMultiplex, metricsutils.NewNamedShim("ssl"))
@@ -90,10 +414,8 @@ func New(log log.Logger, c *Config) (integrations.Integration, error) {
 		return nil, fmt.Errorf("failed to get exporter config: %w", err)
 	}
 
-	for _, target := range c.SSLTargets {
-		if target.Name == "" || target.Target == "" {
-			return nil, fmt.Errorf("failed to load ssl_targets; the `name` and `target` fields are mandatory")
-		}
+	if err := c.validate(exporterConfig.SSLConfig); err != nil {
+		return nil, err
 	}
 
 	exporter, err := NewSSLExporter(*exporterConfig)
@@ -104,6 +426,30 @@ func New(log log.Logger, c *Config) (integrations.Integration, error) {
 	return integrations.NewCollectorIntegration(
 		c.Name(),
 		integrations.WithCollectors(exporter),
+		integrations.WithRunner(exporter.Run),
+		integrations.WithHTTPHandler(exporter.Handler),
+		integrations.WithMetricRelabelConfigs(c.MetricRelabelConfigs),
 		integrations.WithExporterMetricsIncluded(c.IncludeExporterMetrics),
 	), nil
 }
+
+// validateTargetLabels ensures that custom target labels are valid label
+// names which don't collide with the labels set by the integration.
+func validateTargetLabels(labels map[string]string) error {
+	reserved := map[string]struct{}{targetLabel: {}, ipLabel: {}}
+	for _, opt := range metricOpts {
+		for _, l := range opt.labels {
+			reserved[l] = struct{}{}
+		}
+	}
+
+	for name := range labels {
+		if !model.LabelName(name).IsValid() || strings.HasPrefix(name, model.ReservedLabelPrefix) {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+		if _, ok := reserved[name]; ok {
+			return fmt.Errorf("label %q is reserved by the integration", name)
+		}
+	}
+	return nil
+}
@@ -8,12 +8,19 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/gorilla/mux"
+	integrations_v2 "github.com/grafana/agent/pkg/integrations/v2"
+	"github.com/grafana/agent/pkg/integrations/v2/common"
+	"github.com/grafana/agent/pkg/integrations/v2/metricsutils"
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/textparse"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
 )
 
 func TestSSLCases(t *testing.T) {
@@ -115,3 +122,43 @@ func matchMetricNames(names map[string]bool, p textparse.Parser) {
 		}
 	}
 }
+
+func TestSSLAutoscrape(t *testing.T) {
+	baseURL, err := url.Parse("http://testagent/")
+	require.NoError(t, err)
+	globals := integrations_v2.Globals{
+		AgentIdentifier: "testagent",
+		AgentBaseURL:    baseURL,
+		SubsystemOpts:   integrations_v2.DefaultSubsystemOptions,
+	}
+
+	var mc common.MetricsConfig
+	err = yaml.Unmarshal([]byte(`
+autoscrape:
+  metrics_instance: ssl
+  scrape_interval: 5m
+  scrape_timeout: 1m
+  metric_relabel_configs:
+  - source_labels: [__name__]
+    regex: ssl_ocsp_.*
+    action: drop
+`), &mc)
+	require.NoError(t, err)
+
+	cfg := DefaultConfig
+	upgraded := metricsutils.NewNamedShim("ssl")(&cfg, mc)
+	require.NoError(t, upgraded.ApplyDefaults(globals))
+
+	i, err := upgraded.NewIntegration(log.NewNopLogger(), globals)
+	require.NoError(t, err)
+	mi, ok := i.(integrations_v2.MetricsIntegration)
+	require.True(t, ok, "expected the integration to be scraped")
+
+	scs := mi.ScrapeConfigs(nil)
+	require.Len(t, scs, 1)
+	require.Equal(t, "ssl", scs[0].Instance)
+	require.Equal(t, "ssl/testagent", scs[0].Config.JobName)
+	require.Equal(t, model.Duration(5*time.Minute), scs[0].Config.ScrapeInterval)
+	require.Equal(t, model.Duration(time.Minute), scs[0].Config.ScrapeTimeout)
+	require.Len(t, scs[0].Config.MetricRelabelConfigs, 1)
+}
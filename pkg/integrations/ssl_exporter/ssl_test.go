@@ -0,0 +1,372 @@
+package ssl_exporter
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExporter_ProbeTimeout(t *testing.T) {
+	e := &Exporter{options: Options{ProbeTimeout: 10 * time.Second}}
+
+	tt := []struct {
+		name   string
+		target SSLTarget
+		module ssl_config.Module
+		expect time.Duration
+	}{
+		{"default", SSLTarget{}, ssl_config.Module{}, 10 * time.Second},
+		{"module", SSLTarget{}, ssl_config.Module{Timeout: 5 * time.Second}, 5 * time.Second},
+		{"target", SSLTarget{Timeout: time.Second}, ssl_config.Module{Timeout: 5 * time.Second}, time.Second},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expect, e.probeTimeout(tc.target, tc.module))
+		})
+	}
+}
+
+func TestExporter_CustomLabels(t *testing.T) {
+	certFile := writeTestCert(t, t.TempDir())
+
+	e, err := NewSSLExporter(Options{
+		Namespace: "ssl_exporter",
+		SSLTargets: []SSLTarget{
+			{Name: "a", Target: certFile, Module: "file", Labels: map[string]string{"env": "prod"}},
+			{Name: "b", Target: certFile, Module: "file", Labels: map[string]string{"team": "payments"}},
+		},
+		SSLConfig:           ssl_config.DefaultConfig,
+		MaxConcurrentProbes: 1,
+		log:                 log.NewNopLogger(),
+	})
+	require.NoError(t, err)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(e)
+
+	gather := func(name string) map[string]map[string]string {
+		fams, err := reg.Gather()
+		require.NoError(t, err)
+
+		found := make(map[string]map[string]string)
+		for _, mf := range fams {
+			if mf.GetName() != name {
+				continue
+			}
+			for _, m := range mf.GetMetric() {
+				labels := make(map[string]string)
+				for _, l := range m.GetLabel() {
+					labels[l.GetName()] = l.GetValue()
+				}
+				found[labels[targetLabel]] = labels
+			}
+		}
+		return found
+	}
+
+	require.Equal(t, map[string]map[string]string{
+		"a": {targetLabel: "a", "env": "prod", "team": ""},
+		"b": {targetLabel: "b", "env": "", "team": "payments"},
+	}, gather("ssl_probe_success"))
+
+	// Custom labels of discovered targets are added to the metrics of every
+	// target.
+	e.setDiscoveredTargets([]SSLTarget{
+		{Name: "c", Target: certFile, Module: "file", Labels: map[string]string{"region": "eu"}},
+	})
+	expect := map[string]map[string]string{
+		"a": {targetLabel: "a", "env": "prod", "region": "", "team": ""},
+		"b": {targetLabel: "b", "env": "", "region": "", "team": "payments"},
+		"c": {targetLabel: "c", "env": "", "region": "eu", "team": ""},
+	}
+	require.Equal(t, expect, gather("ssl_probe_success"))
+	require.Equal(t, expect, gather("ssl_probes_total"))
+}
+
+func TestValidateTargetLabels(t *testing.T) {
+	require.NoError(t, validateTargetLabels(map[string]string{"env": "prod"}))
+	require.Error(t, validateTargetLabels(map[string]string{"cn": "example"}))
+	require.Error(t, validateTargetLabels(map[string]string{targetLabel: "example"}))
+	require.Error(t, validateTargetLabels(map[string]string{"__name__": "example"}))
+	require.Error(t, validateTargetLabels(map[string]string{"not-valid": "example"}))
+}
+
+func TestResolveModule(t *testing.T) {
+	sslConfig := &ssl_config.Config{
+		DefaultModule: "tcp",
+		Modules: map[string]ssl_config.Module{
+			"tcp":    {Prober: "tcp"},
+			"https":  {Prober: "https"},
+			"file":   {Prober: "file"},
+			"broken": {Prober: "fake"},
+		},
+	}
+
+	tt := []struct {
+		name               string
+		targetModule       string
+		integrationDefault string
+		sslConfig          *ssl_config.Config
+		expectProber       string
+		expectErr          string
+	}{
+		{name: "target module", targetModule: "file", integrationDefault: "https", sslConfig: sslConfig, expectProber: "file"},
+		{name: "integration default", integrationDefault: "https", sslConfig: sslConfig, expectProber: "https"},
+		{name: "exporter default", sslConfig: sslConfig, expectProber: "tcp"},
+		{name: "no default", sslConfig: &ssl_config.Config{}, expectErr: "module must be set as no default module is configured"},
+		{name: "unknown module", targetModule: "fake", sslConfig: sslConfig, expectErr: `unknown module "fake"`},
+		{name: "unknown prober", targetModule: "broken", sslConfig: sslConfig, expectErr: `unknown prober "fake" for module "broken"`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			module, _, err := resolveModule(tc.targetModule, tc.integrationDefault, tc.sslConfig)
+			if tc.expectErr != "" {
+				require.EqualError(t, err, tc.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectProber, module.Prober)
+		})
+	}
+}
+
+func TestExporter_ProbeCounters(t *testing.T) {
+	certFile := writeTestCert(t, t.TempDir())
+
+	e, err := NewSSLExporter(Options{
+		Namespace: "ssl_exporter",
+		SSLTargets: []SSLTarget{
+			{Name: "good", Target: certFile, Module: "file"},
+			{Name: "bad", Target: certFile + ".missing", Module: "file"},
+		},
+		SSLConfig:           ssl_config.DefaultConfig,
+		MaxConcurrentProbes: 1,
+		log:                 log.NewNopLogger(),
+	})
+	require.NoError(t, err)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(e)
+	for i := 0; i < 2; i++ {
+		_, err := reg.Gather()
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, 2.0, counterValue(t, e, "ssl_probes_total", "good"))
+	require.Equal(t, 0.0, counterValue(t, e, "ssl_probe_failures_total", "good"))
+	require.Equal(t, 2.0, counterValue(t, e, "ssl_probes_total", "bad"))
+	require.Equal(t, 2.0, counterValue(t, e, "ssl_probe_failures_total", "bad"))
+}
+
+// counterValue returns the value of the probe counter or quarantine gauge
+// name of target.
+func counterValue(t *testing.T, e *Exporter, name, target string) float64 {
+	t.Helper()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(countersCollector{e})
+	fams, err := reg.Gather()
+	require.NoError(t, err)
+
+	for _, mf := range fams {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			if !hasLabel(m, targetLabel, target) {
+				continue
+			}
+			if c := m.GetCounter(); c != nil {
+				return c.GetValue()
+			}
+			return m.GetGauge().GetValue()
+		}
+	}
+	require.FailNow(t, "metric not found", "%s of target %s", name, target)
+	return 0
+}
+
+func hasLabel(m *dto.Metric, name, value string) bool {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name && l.GetValue() == value {
+			return true
+		}
+	}
+	return false
+}
+
+// countersCollector collects the probe counters of an Exporter without
+// probing its targets.
+type countersCollector struct{ e *Exporter }
+
+func (countersCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c countersCollector) Collect(ch chan<- prometheus.Metric) { c.e.collectCounters(ch) }
+
+func TestExporter_ProbeRateLimit(t *testing.T) {
+	certFile := writeTestCert(t, t.TempDir())
+
+	var targets []SSLTarget
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		targets = append(targets, SSLTarget{Name: name, Target: certFile, Module: "file"})
+	}
+
+	e, err := NewSSLExporter(Options{
+		Namespace:           "ssl_exporter",
+		SSLTargets:          targets,
+		SSLConfig:           ssl_config.DefaultConfig,
+		MaxConcurrentProbes: len(targets),
+		ProbeRateLimit:      20,
+		ProbeRateBurst:      1,
+		log:                 log.NewNopLogger(),
+	})
+	require.NoError(t, err)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(e)
+
+	// With a burst of 1, the 4 probes after the first one each wait 50ms.
+	start := time.Now()
+	_, err = reg.Gather()
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+}
+
+func TestExporter_ServerName(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// The certificate of the test server is valid for example.com and
+	// 127.0.0.1.
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, ca, 0600))
+
+	e, err := NewSSLExporter(Options{
+		Namespace: "ssl_exporter",
+		SSLConfig: &ssl_config.Config{
+			Modules: map[string]ssl_config.Module{
+				"tcp": {Prober: "tcp", TLSConfig: ssl_config.TLSConfig{CAFile: caFile}},
+			},
+		},
+		DefaultModule:       "tcp",
+		MaxConcurrentProbes: 1,
+		log:                 log.NewNopLogger(),
+	})
+	require.NoError(t, err)
+
+	addr := srv.Listener.Addr().String()
+
+	_, err = e.probe(context.Background(), SSLTarget{Name: "server", Target: addr, ServerName: "example.com"})
+	require.NoError(t, err)
+
+	_, err = e.probe(context.Background(), SSLTarget{Name: "server", Target: addr, ServerName: "grafana.com"})
+	require.Error(t, err)
+	require.Equal(t, reasonHostnameMismatch, classifyProbeError(err))
+}
+
+func TestExporter_UnknownMetrics(t *testing.T) {
+	e, err := NewSSLExporter(Options{
+		Namespace:           "ssl_exporter",
+		SSLConfig:           ssl_config.DefaultConfig,
+		MaxConcurrentProbes: 1,
+		log:                 log.NewNopLogger(),
+	})
+	require.NoError(t, err)
+
+	// probeFunc collects a metric which isn't in metricOpts, like a metric
+	// added by a newer version of the upstream probers.
+	probeFunc := func(ctx context.Context, logger log.Logger, target string, module ssl_config.Module, registry *prometheus.Registry) error {
+		newMetric := prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ssl_new_metric_total",
+			Help: "A metric unknown to the integration",
+		}, []string{"kind"})
+		registry.MustRegister(newMetric)
+		newMetric.WithLabelValues("example").Add(3)
+		return nil
+	}
+
+	target := SSLTarget{Name: "example", Target: "example.com:443"}
+	for i := 0; i < 2; i++ {
+		metrics, err := e.probeOnce(context.Background(), log.NewNopLogger(), target, ssl_config.Module{Prober: "tcp"}, probeFunc, "")
+		require.NoError(t, err)
+
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(probeResults(metrics))
+		expect := `
+# HELP ssl_new_metric_total A metric unknown to the integration
+# TYPE ssl_new_metric_total counter
+ssl_new_metric_total{kind="example",ssl_target="example"} 3
+`
+		require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expect), "ssl_new_metric_total"))
+	}
+
+	// The descriptor is built once and reused by later probes.
+	require.Len(t, e.labels().dynamicDescs, 1)
+}
+
+func TestExporter_ExpiryThresholds(t *testing.T) {
+	e, err := NewSSLExporter(Options{
+		Namespace:           "ssl_exporter",
+		SSLConfig:           ssl_config.DefaultConfig,
+		MaxConcurrentProbes: 1,
+		ExpiryThresholds:    []string{"7d", "30d"},
+		log:                 log.NewNopLogger(),
+	})
+	require.NoError(t, err)
+
+	// probeFunc finds the same certificate, which expires in 10 days, in
+	// two files.
+	probeFunc := func(ctx context.Context, logger log.Logger, target string, module ssl_config.Module, registry *prometheus.Registry) error {
+		notAfter := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "ssl_file_cert_not_after",
+			Help: "NotAfter expressed as a Unix Epoch Time for a certificate found in a file",
+		}, append([]string{"file"}, expiringCertLabels...))
+		registry.MustRegister(notAfter)
+		expiry := float64(time.Now().Add(10 * 24 * time.Hour).Unix())
+		for _, file := range []string{"a.pem", "b.pem"} {
+			notAfter.WithLabelValues(file, "1", "Example CA", "example.com", ",example.com,", "", "", "", "ab", "0").Set(expiry)
+		}
+		return nil
+	}
+
+	target := SSLTarget{Name: "example", Target: "/etc/ssl/*.pem"}
+	metrics, err := e.probeOnce(context.Background(), log.NewNopLogger(), target, ssl_config.Module{Prober: "file"}, probeFunc, "")
+	require.NoError(t, err)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(probeResults(metrics))
+	expect := `
+# HELP ssl_cert_expiring_within If the certificate expires within the threshold, as of the probe
+# TYPE ssl_cert_expiring_within gauge
+ssl_cert_expiring_within{chain_position="0",cn="example.com",dnsnames=",example.com,",emails="",fingerprint_sha256="ab",ips="",issuer_cn="Example CA",ou="",serial_no="1",ssl_target="example",threshold="30d"} 1
+ssl_cert_expiring_within{chain_position="0",cn="example.com",dnsnames=",example.com,",emails="",fingerprint_sha256="ab",ips="",issuer_cn="Example CA",ou="",serial_no="1",ssl_target="example",threshold="7d"} 0
+`
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expect), "ssl_cert_expiring_within"))
+}
+
+func TestParseExpiryThresholds(t *testing.T) {
+	thresholds, err := parseExpiryThresholds([]string{"7d", "4w"})
+	require.NoError(t, err)
+	require.Equal(t, []expiryThreshold{{"7d", 7 * 24 * time.Hour}, {"4w", 28 * 24 * time.Hour}}, thresholds)
+
+	_, err = parseExpiryThresholds([]string{"0d"})
+	require.EqualError(t, err, `expiry threshold "0d" must be positive`)
+	_, err = parseExpiryThresholds([]string{"1w", "7d"})
+	require.EqualError(t, err, `expiry threshold "7d" is set more than once`)
+	_, err = parseExpiryThresholds([]string{"soon"})
+	require.Error(t, err)
+}
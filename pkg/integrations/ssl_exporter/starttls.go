@@ -0,0 +1,261 @@
+package ssl_exporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// StartTLSConfig configures upgrading the connections made to a target to
+// TLS through STARTTLS, so that the certificates of mail, directory, and
+// database servers can be probed. STARTTLS precedes authentication for every
+// supported protocol, so no credentials are needed.
+type StartTLSConfig struct {
+	// Protocol is the protocol spoken by the target before upgrading the
+	// connection: smtp, ftp, imap, pop3, postgres, mysql, or ldap.
+	Protocol string `yaml:"protocol"`
+
+	// EHLOName is the hostname sent with the EHLO command of the smtp
+	// protocol. Defaults to "prober".
+	EHLOName string `yaml:"ehlo_name,omitempty"`
+}
+
+// Validate validates the STARTTLS config.
+func (c *StartTLSConfig) Validate() error {
+	if !supportedStartTLSProtocol(c.Protocol) {
+		return fmt.Errorf("unsupported protocol %q, must be one of %v", c.Protocol, startTLSProtocols())
+	}
+	if c.EHLOName != "" && c.Protocol != "smtp" {
+		return fmt.Errorf("ehlo_name is only supported by the smtp protocol")
+	}
+	return nil
+}
+
+// startTLSHandshakes upgrade connections to TLS for the protocols which use
+// binary messages that can't be expressed as startTLSqueryResponses.
+var startTLSHandshakes = map[string]func(logger log.Logger, conn net.Conn) error{
+	"mysql": startTLSMySQL,
+	"ldap":  startTLSLDAP,
+}
+
+// supportedStartTLSProtocol returns true if connections can be upgraded to
+// TLS for protocol.
+func supportedStartTLSProtocol(protocol string) bool {
+	_, ok := startTLSqueryResponses[protocol]
+	if !ok {
+		_, ok = startTLSHandshakes[protocol]
+	}
+	return ok
+}
+
+// startTLSProtocols returns the sorted names of the supported protocols.
+func startTLSProtocols() []string {
+	var protocols []string
+	for protocol := range startTLSqueryResponses {
+		protocols = append(protocols, protocol)
+	}
+	for protocol := range startTLSHandshakes {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+	return protocols
+}
+
+// withEHLOName returns the smtp conversation qr with the EHLO command sending
+// name instead of the default hostname.
+func withEHLOName(qr []queryResponse, name string) []queryResponse {
+	res := make([]queryResponse, len(qr))
+	copy(res, qr)
+	for i := range res {
+		if res[i].send == "EHLO prober" {
+			res[i].send = "EHLO " + name
+		}
+	}
+	return res
+}
+
+// MySQL capability flags used for requesting TLS.
+const (
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientSSL              = 0x00000800
+	mysqlClientSecureConnection = 0x00008000
+)
+
+// startTLSMySQL reads the initial handshake of a MySQL server and requests
+// TLS with an SSLRequest packet.
+func startTLSMySQL(logger log.Logger, conn net.Conn) error {
+	payload, seq, err := readMySQLPacket(conn)
+	if err != nil {
+		return fmt.Errorf("failed to read mysql handshake: %w", err)
+	}
+	if len(payload) > 0 && payload[0] == 0xff {
+		return fmt.Errorf("mysql server returned an error: %s", mysqlErrorMessage(payload))
+	}
+	if len(payload) == 0 || payload[0] != 0x0a {
+		return fmt.Errorf("unsupported mysql handshake")
+	}
+	level.Debug(logger).Log("msg", "read mysql handshake", "bytes", len(payload))
+
+	// The lower capability flags follow the protocol version, the
+	// null-terminated server version, the connection id, the first part of
+	// the auth plugin data and a filler byte.
+	i := 1
+	for i < len(payload) && payload[i] != 0 {
+		i++
+	}
+	i += 1 + 4 + 8 + 1
+	if i+2 > len(payload) {
+		return fmt.Errorf("mysql handshake is too short")
+	}
+	if binary.LittleEndian.Uint16(payload[i:])&mysqlClientSSL == 0 {
+		return fmt.Errorf("mysql server doesn't support TLS")
+	}
+
+	// The SSLRequest packet holds the capability flags, the max packet
+	// size, the character set (utf8) and 23 reserved bytes.
+	request := make([]byte, 4+32)
+	request[0] = 32
+	request[3] = seq + 1
+	binary.LittleEndian.PutUint32(request[4:], mysqlClientProtocol41|mysqlClientSSL|mysqlClientSecureConnection)
+	binary.LittleEndian.PutUint32(request[8:], 1<<24)
+	request[12] = 0x21
+	level.Debug(logger).Log("msg", "sending mysql SSLRequest")
+	_, err = conn.Write(request)
+	return err
+}
+
+// readMySQLPacket reads a packet of the MySQL protocol, returning its
+// payload and sequence id.
+func readMySQLPacket(r io.Reader) ([]byte, byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, 0, err
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, err
+	}
+	return payload, header[3], nil
+}
+
+// mysqlErrorMessage returns the message of a MySQL ERR packet. The message
+// follows the header byte and the error code. Errors sent before the
+// handshake have no SQL state.
+func mysqlErrorMessage(payload []byte) string {
+	if len(payload) < 3 {
+		return "unknown error"
+	}
+	return string(payload[3:])
+}
+
+// ldapStartTLSRequest is an LDAPMessage with message id 1 holding an
+// ExtendedRequest for the StartTLS operation (1.3.6.1.4.1.1466.20037),
+// defined in RFC 4511.
+var ldapStartTLSRequest = append([]byte{
+	0x30, 0x1d, // LDAPMessage
+	0x02, 0x01, 0x01, // messageID
+	0x77, 0x18, // ExtendedRequest
+	0x80, 0x16, // requestName
+}, "1.3.6.1.4.1.1466.20037"...)
+
+// startTLSLDAP sends the StartTLS extended operation to an LDAP server and
+// checks that it succeeded.
+func startTLSLDAP(logger log.Logger, conn net.Conn) error {
+	level.Debug(logger).Log("msg", "sending ldap StartTLS request")
+	if _, err := conn.Write(ldapStartTLSRequest); err != nil {
+		return err
+	}
+
+	tag, message, err := readBERElement(conn)
+	if err != nil {
+		return fmt.Errorf("failed to read ldap response: %w", err)
+	}
+	if tag != 0x30 {
+		return fmt.Errorf("unexpected ldap response tag %#x", tag)
+	}
+
+	// Skip the messageID to get to the ExtendedResponse, which starts with
+	// the result code.
+	_, _, message, err = parseBERElement(message)
+	if err != nil {
+		return fmt.Errorf("invalid ldap response: %w", err)
+	}
+	tag, response, _, err := parseBERElement(message)
+	if err != nil {
+		return fmt.Errorf("invalid ldap response: %w", err)
+	}
+	if tag != 0x78 {
+		return fmt.Errorf("unexpected ldap response tag %#x", tag)
+	}
+	tag, resultCode, _, err := parseBERElement(response)
+	if err != nil {
+		return fmt.Errorf("invalid ldap response: %w", err)
+	}
+	if tag != 0x0a || len(resultCode) == 0 || len(resultCode) > 4 {
+		return fmt.Errorf("invalid ldap result code")
+	}
+	var code int
+	for _, b := range resultCode {
+		code = code<<8 | int(b)
+	}
+	if code != 0 {
+		return fmt.Errorf("ldap server refused StartTLS with result code %d", code)
+	}
+	return nil
+}
+
+// maxBERElementLength is the size of the largest BER element read from
+// servers, which is far more than any StartTLS response needs.
+const maxBERElementLength = 64 * 1024
+
+// readBERElement reads a BER encoded element with a definite length from r,
+// returning its tag and contents.
+func readBERElement(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	length := int(header[1])
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 4 {
+			return 0, nil, fmt.Errorf("unsupported BER length")
+		}
+		lengthBytes := make([]byte, n)
+		if _, err := io.ReadFull(r, lengthBytes); err != nil {
+			return 0, nil, err
+		}
+		length = 0
+		for _, b := range lengthBytes {
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxBERElementLength {
+		return 0, nil, fmt.Errorf("BER element of %d bytes is too large", length)
+	}
+
+	contents := make([]byte, length)
+	if _, err := io.ReadFull(r, contents); err != nil {
+		return 0, nil, err
+	}
+	return header[0], contents, nil
+}
+
+// parseBERElement parses the first BER encoded element of data, returning
+// its tag, its contents and the rest of data.
+func parseBERElement(data []byte) (byte, []byte, []byte, error) {
+	r := bytes.NewReader(data)
+	tag, contents, err := readBERElement(r)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return tag, contents, data[len(data)-r.Len():], nil
+}
@@ -0,0 +1,131 @@
+package ssl_exporter
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+// startTLSServer runs serve on the server side of a connection, returning
+// the client side along with a channel receiving the result of serve.
+func startTLSServer(t *testing.T, serve func(conn net.Conn) error) (net.Conn, <-chan error) {
+	t.Helper()
+
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- serve(server) }()
+	return client, done
+}
+
+func TestStartTLS_SMTPEHLOName(t *testing.T) {
+	conn, done := startTLSServer(t, func(conn net.Conn) error {
+		r := bufio.NewReader(conn)
+		if _, err := fmt.Fprint(conn, "220 mail.example.com ESMTP\r\n"); err != nil {
+			return err
+		}
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if line != "EHLO probe.example.com\r\n" {
+			return fmt.Errorf("unexpected command %q", line)
+		}
+		if _, err := fmt.Fprint(conn, "250-mail.example.com\r\n250-STARTTLS\r\n250 OK\r\n"); err != nil {
+			return err
+		}
+		if _, err := r.ReadString('\n'); err != nil {
+			return err
+		}
+		_, err = fmt.Fprint(conn, "220 Ready to start TLS\r\n")
+		return err
+	})
+
+	require.NoError(t, startTLS(log.NewNopLogger(), conn, "smtp", "probe.example.com"))
+	require.NoError(t, <-done)
+}
+
+func TestStartTLS_MySQL(t *testing.T) {
+	handshake := func(capabilities uint16) []byte {
+		payload := []byte{0x0a}
+		payload = append(payload, "8.0.30\x00"...)
+		payload = append(payload, 1, 0, 0, 0)         // connection id
+		payload = append(payload, make([]byte, 8)...) // auth plugin data
+		payload = append(payload, 0)                  // filler
+		payload = append(payload, byte(capabilities), byte(capabilities>>8))
+		return append([]byte{byte(len(payload)), 0, 0, 0}, payload...)
+	}
+
+	conn, done := startTLSServer(t, func(conn net.Conn) error {
+		if _, err := conn.Write(handshake(mysqlClientSSL | mysqlClientProtocol41)); err != nil {
+			return err
+		}
+		request := make([]byte, 36)
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return err
+		}
+		if request[3] != 1 || binary.LittleEndian.Uint32(request[4:])&mysqlClientSSL == 0 {
+			return fmt.Errorf("unexpected SSLRequest %x", request)
+		}
+		return nil
+	})
+	require.NoError(t, startTLS(log.NewNopLogger(), conn, "mysql", ""))
+	require.NoError(t, <-done)
+
+	conn, _ = startTLSServer(t, func(conn net.Conn) error {
+		_, err := conn.Write(handshake(mysqlClientProtocol41))
+		return err
+	})
+	require.EqualError(t, startTLS(log.NewNopLogger(), conn, "mysql", ""), "mysql server doesn't support TLS")
+}
+
+func TestStartTLS_LDAP(t *testing.T) {
+	// Responses use the long form of lengths, like Active Directory does.
+	response := func(resultCode byte) []byte {
+		return []byte{
+			0x30, 0x84, 0x00, 0x00, 0x00, 0x0c,
+			0x02, 0x01, 0x01,
+			0x78, 0x07,
+			0x0a, 0x01, resultCode,
+			0x04, 0x00,
+			0x04, 0x00,
+		}
+	}
+	serve := func(resultCode byte) func(conn net.Conn) error {
+		return func(conn net.Conn) error {
+			request := make([]byte, len(ldapStartTLSRequest))
+			if _, err := io.ReadFull(conn, request); err != nil {
+				return err
+			}
+			_, err := conn.Write(response(resultCode))
+			return err
+		}
+	}
+
+	conn, done := startTLSServer(t, serve(0))
+	require.NoError(t, startTLS(log.NewNopLogger(), conn, "ldap", ""))
+	require.NoError(t, <-done)
+
+	conn, _ = startTLSServer(t, serve(2))
+	require.EqualError(t, startTLS(log.NewNopLogger(), conn, "ldap", ""), "ldap server refused StartTLS with result code 2")
+}
+
+func TestStartTLSConfig_Validate(t *testing.T) {
+	for _, protocol := range []string{"smtp", "ftp", "imap", "pop3", "postgres", "mysql", "ldap"} {
+		require.NoError(t, (&StartTLSConfig{Protocol: protocol}).Validate(), protocol)
+	}
+	require.NoError(t, (&StartTLSConfig{Protocol: "smtp", EHLOName: "probe.example.com"}).Validate())
+
+	require.EqualError(t, (&StartTLSConfig{Protocol: "xmpp"}).Validate(), `unsupported protocol "xmpp", must be one of [ftp imap ldap mysql pop3 postgres smtp]`)
+	require.Error(t, (&StartTLSConfig{Protocol: "imap", EHLOName: "probe.example.com"}).Validate())
+}
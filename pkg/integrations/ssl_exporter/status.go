@@ -0,0 +1,179 @@
+package ssl_exporter
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// targetStatus is the result of the most recent probe of a configured
+// target, shown on the status page.
+type targetStatus struct {
+	Name   string `json:"name"`
+	Target string `json:"target"`
+	Module string `json:"module"`
+
+	// LastProbe is zero if the target hasn't been probed yet.
+	LastProbe       time.Time `json:"last_probe"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Success         bool      `json:"success"`
+	Error           string    `json:"error,omitempty"`
+	Reason          string    `json:"reason,omitempty"`
+
+	// LeafCert is the certificate at the start of a chain which expires
+	// first, if any certificates were collected.
+	LeafCert *certSummary `json:"leaf_cert,omitempty"`
+
+	// certs are the certificates collected by the probe, served by the
+	// certificates API.
+	certs []recordedCert
+}
+
+// certSummary describes a certificate collected by a probe.
+type certSummary struct {
+	CommonName        string    `json:"cn"`
+	IssuerCommonName  string    `json:"issuer_cn"`
+	DNSNames          []string  `json:"dnsnames"`
+	SerialNo          string    `json:"serial_no"`
+	FingerprintSHA256 string    `json:"fingerprint_sha256"`
+	NotAfter          time.Time `json:"not_after"`
+}
+
+// recordStatus records the result of a probe of target which started at
+// start and collected certs.
+func (e *Exporter) recordStatus(target SSLTarget, start time.Time, certs []recordedCert, err error) {
+	status := targetStatus{
+		Name:            target.Name,
+		Target:          target.Target,
+		Module:          moduleName(target.Module, e.options.DefaultModule, e.sslConfig()),
+		LastProbe:       start,
+		DurationSeconds: time.Since(start).Seconds(),
+		Success:         err == nil,
+		LeafCert:        leafCertSummary(certs),
+		certs:           certs,
+	}
+	if err != nil {
+		status.Error = err.Error()
+		status.Reason = classifyProbeError(err)
+	}
+
+	e.statusMut.Lock()
+	e.statuses[target.Name] = status
+	e.statusMut.Unlock()
+}
+
+// forgetStatus removes the status of a target which is no longer probed.
+func (e *Exporter) forgetStatus(target SSLTarget) {
+	e.statusMut.Lock()
+	delete(e.statuses, target.Name)
+	e.statusMut.Unlock()
+}
+
+// targetStatuses returns the status of every configured target, sorted by
+// name. Targets which haven't been probed yet have an empty status.
+func (e *Exporter) targetStatuses() []targetStatus {
+	e.statusMut.RLock()
+	defer e.statusMut.RUnlock()
+
+	targets := e.targets()
+	res := make([]targetStatus, 0, len(targets))
+	for _, target := range targets {
+		status, ok := e.statuses[target.Name]
+		if !ok {
+			status = targetStatus{
+				Name:   target.Name,
+				Target: target.Target,
+				Module: moduleName(target.Module, e.options.DefaultModule, e.sslConfig()),
+			}
+		}
+		res = append(res, status)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// leafCertSummary returns the summary of the certificate at the start of a
+// chain in certs which expires first. It returns nil if certs has no such
+// certificate.
+func leafCertSummary(certs []recordedCert) *certSummary {
+	var leaf *x509.Certificate
+	for _, rc := range certs {
+		if rc.position == 0 && (leaf == nil || rc.cert.NotAfter.Before(leaf.NotAfter)) {
+			leaf = rc.cert
+		}
+	}
+	if leaf == nil {
+		return nil
+	}
+	return &certSummary{
+		CommonName:        leaf.Subject.CommonName,
+		IssuerCommonName:  leaf.Issuer.CommonName,
+		DNSNames:          leaf.DNSNames,
+		SerialNo:          leaf.SerialNumber.String(),
+		FingerprintSHA256: fingerprintSHA256(leaf),
+		NotAfter:          leaf.NotAfter.UTC(),
+	}
+}
+
+var statusTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"probeURL": func(probePath string, s targetStatus) string {
+		return probePath + "?" + url.Values{"target": {s.Target}, "module": {s.Module}}.Encode()
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><title>SSL targets</title></head>
+<body>
+<h1>SSL targets</h1>
+<table border="1" cellpadding="4">
+<tr>
+<th>Name</th><th>Target</th><th>Module</th><th>Last probe</th><th>Duration</th><th>Result</th><th>Leaf certificate</th><th>Expires</th><th></th>
+</tr>
+{{- range .Statuses }}
+<tr>
+<td>{{ .Name }}</td>
+<td>{{ .Target }}</td>
+<td>{{ .Module }}</td>
+{{- if .LastProbe.IsZero }}
+<td colspan="4">Not probed yet</td>
+{{- else }}
+<td>{{ .LastProbe.UTC.Format "2006-01-02T15:04:05Z07:00" }}</td>
+<td>{{ printf "%.3fs" .DurationSeconds }}</td>
+<td>{{ if .Success }}Success{{ else }}Failure ({{ .Reason }}): {{ .Error }}{{ end }}</td>
+<td>{{ with .LeafCert }}{{ .CommonName }}, issued by {{ .IssuerCommonName }}{{ end }}</td>
+{{- end }}
+<td>{{ with .LeafCert }}{{ .NotAfter.Format "2006-01-02T15:04:05Z07:00" }}{{ end }}</td>
+<td><a href="{{ probeURL $.ProbePath . }}">Probe</a></td>
+</tr>
+{{- end }}
+</table>
+</body>
+</html>
+`))
+
+// statusHandler shows the configured targets along with the result of their
+// most recent probe. The page is rendered as HTML, or as JSON when the format
+// query parameter is set to json.
+func (e *Exporter) statusHandler(probePath string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		statuses := e.targetStatuses()
+
+		if r.URL.Query().Get("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(statuses)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := statusTemplate.Execute(w, struct {
+			ProbePath string
+			Statuses  []targetStatus
+		}{probePath, statuses})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,272 @@
+package ssl_exporter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"github.com/ribbybibby/ssl_exporter/v2/prober"
+)
+
+// newTCPProber returns a prober which behaves like the upstream tcp prober,
+// but applies opts to the connections it makes.
+func newTCPProber(opts proberOptions) prober.ProbeFn {
+	return func(ctx context.Context, logger log.Logger, target string, module ssl_config.Module, registry *prometheus.Registry) error {
+		dial := opts.dialer(registry)
+
+		tlsConfig, err := newTLSConfig(target, registry, &module.TLSConfig)
+		if err != nil {
+			return err
+		}
+		if err := opts.configureTLS(ctx, tlsConfig, registry); err != nil {
+			return err
+		}
+		if opts.checkSessionResumption {
+			tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(1)
+		}
+		if len(opts.alpnProtocols) > 0 {
+			tlsConfig.NextProtos = opts.alpnProtocols
+		}
+
+		conn, err := dial(ctx, "tcp", target)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		deadline, _ := ctx.Deadline()
+		if err := conn.SetDeadline(deadline); err != nil {
+			return fmt.Errorf("error setting deadline: %w", err)
+		}
+
+		if module.TCP.StartTLS != "" {
+			err = startTLS(logger, conn, module.TCP.StartTLS, opts.ehloName)
+			if err != nil {
+				return err
+			}
+		}
+
+		tlsConn := tls.Client(conn, tlsConfig)
+		defer tlsConn.Close()
+
+		if err := tlsConn.Handshake(); err != nil {
+			return err
+		}
+		if len(opts.alpnProtocols) > 0 {
+			if err := collectALPNMetrics(tlsConn.ConnectionState(), opts.alpnProtocols, registry); err != nil {
+				return err
+			}
+		}
+		if !opts.checkSessionResumption {
+			return nil
+		}
+		return probeSessionResumption(ctx, logger, dial, target, module, opts.ehloName, tlsConn, tlsConfig, registry)
+	}
+}
+
+// collectALPNMetrics collects the application protocol negotiated through
+// ALPN for the connection, and returns an error unless it's one of the
+// expected protocols.
+func collectALPNMetrics(state tls.ConnectionState, expected []string, registry *prometheus.Registry) error {
+	alpnProtocol := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName(namespace, "", "tls_alpn_protocol_info"),
+			Help: "The application protocol negotiated through ALPN",
+		},
+		[]string{"protocol"},
+	)
+	registry.MustRegister(alpnProtocol)
+
+	if state.NegotiatedProtocol == "" {
+		return fmt.Errorf("target didn't negotiate any of the ALPN protocols %v", expected)
+	}
+	alpnProtocol.WithLabelValues(state.NegotiatedProtocol).Set(1)
+
+	for _, protocol := range expected {
+		if protocol == state.NegotiatedProtocol {
+			return nil
+		}
+	}
+	return fmt.Errorf("target negotiated unexpected ALPN protocol %q", state.NegotiatedProtocol)
+}
+
+// sessionTicketTimeout is how long to wait for the session tickets which
+// TLS 1.3 servers send after the handshake.
+const sessionTicketTimeout = time.Second
+
+// probeSessionResumption connects to target a second time and collects
+// whether the session of the established connection tlsConn was resumed.
+func probeSessionResumption(ctx context.Context, logger log.Logger, dial dialFunc, target string, module ssl_config.Module, ehloName string, tlsConn *tls.Conn, tlsConfig *tls.Config, registry *prometheus.Registry) error {
+	sessionResumed := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName(namespace, "", "tls_session_resumed"),
+			Help: "If the TLS session of a previous connection was resumed when reconnecting to the target",
+		},
+	)
+	registry.MustRegister(sessionResumed)
+
+	// TLS 1.3 session tickets are only processed while reading from the
+	// connection. The read usually times out, as most servers wait for the
+	// client to send data.
+	readDeadline := time.Now().Add(sessionTicketTimeout)
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(readDeadline) {
+		readDeadline = deadline
+	}
+	if err := tlsConn.SetReadDeadline(readDeadline); err != nil {
+		return fmt.Errorf("error setting deadline: %w", err)
+	}
+	_, _ = tlsConn.Read(make([]byte, 1))
+
+	conn, err := dial(ctx, "tcp", target)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return fmt.Errorf("error setting deadline: %w", err)
+	}
+
+	if module.TCP.StartTLS != "" {
+		if err := startTLS(logger, conn, module.TCP.StartTLS, ehloName); err != nil {
+			return err
+		}
+	}
+
+	// Metrics were already collected for the first connection.
+	resumeConfig := tlsConfig.Clone()
+	resumeConfig.VerifyConnection = nil
+
+	resumeConn := tls.Client(conn, resumeConfig)
+	defer resumeConn.Close()
+
+	if err := resumeConn.Handshake(); err != nil {
+		return fmt.Errorf("failed to reconnect for resuming the session: %w", err)
+	}
+	if resumeConn.ConnectionState().DidResume {
+		sessionResumed.Set(1)
+	}
+	return nil
+}
+
+type queryResponse struct {
+	expect      string
+	send        string
+	sendBytes   []byte
+	expectBytes []byte
+}
+
+// startTLSqueryResponses holds the conversation needed to upgrade a
+// connection to TLS for every supported protocol.
+//
+// Copied from github.com/ribbybibby/ssl_exporter/v2/prober.
+var startTLSqueryResponses = map[string][]queryResponse{
+	"smtp": {
+		{expect: "^220"},
+		{send: "EHLO prober"},
+		{expect: "^250-STARTTLS"},
+		{send: "STARTTLS"},
+		{expect: "^220"},
+	},
+	"ftp": {
+		{expect: "^220"},
+		{send: "AUTH TLS"},
+		{expect: "^234"},
+	},
+	"imap": {
+		{expect: "OK"},
+		{send: ". CAPABILITY"},
+		{expect: "STARTTLS"},
+		{expect: "OK"},
+		{send: ". STARTTLS"},
+		{expect: "OK"},
+	},
+	"postgres": {
+		{sendBytes: []byte{0x00, 0x00, 0x00, 0x08, 0x04, 0xd2, 0x16, 0x2f}},
+		{expectBytes: []byte{0x53}},
+	},
+	"pop3": {
+		{expect: "OK"},
+		{send: "STLS"},
+		{expect: "OK"},
+	},
+}
+
+// startTLS will send the STARTTLS command for the given protocol. If
+// ehloName is set, it's sent with the EHLO command of the smtp protocol.
+//
+// Based on github.com/ribbybibby/ssl_exporter/v2/prober.
+func startTLS(logger log.Logger, conn net.Conn, proto string, ehloName string) error {
+	var err error
+
+	if handshake, ok := startTLSHandshakes[proto]; ok {
+		return handshake(logger, conn)
+	}
+
+	qr, ok := startTLSqueryResponses[proto]
+	if !ok {
+		return fmt.Errorf("STARTTLS is not supported for %s", proto)
+	}
+	if proto == "smtp" && ehloName != "" {
+		qr = withEHLOName(qr, ehloName)
+	}
+
+	scanner := bufio.NewScanner(conn)
+	for _, qr := range qr {
+		if qr.expect != "" {
+			var match bool
+			for scanner.Scan() {
+				level.Debug(logger).Log("msg", fmt.Sprintf("read line: %s", scanner.Text()))
+				match, err = regexp.Match(qr.expect, scanner.Bytes())
+				if err != nil {
+					return err
+				}
+				if match {
+					level.Debug(logger).Log("msg", fmt.Sprintf("regex: %s matched: %s", qr.expect, scanner.Text()))
+					break
+				}
+			}
+			if scanner.Err() != nil {
+				return scanner.Err()
+			}
+			if !match {
+				return fmt.Errorf("regex: %s didn't match: %s", qr.expect, scanner.Text())
+			}
+		}
+		if len(qr.expectBytes) > 0 {
+			buffer := make([]byte, len(qr.expectBytes))
+			_, err = io.ReadFull(conn, buffer)
+			if err != nil {
+				return err
+			}
+			level.Debug(logger).Log("msg", fmt.Sprintf("read bytes: %x", buffer))
+			if !bytes.Equal(buffer, qr.expectBytes) {
+				return fmt.Errorf("read bytes %x didn't match with expected bytes %x", buffer, qr.expectBytes)
+			}
+		}
+		if qr.send != "" {
+			level.Debug(logger).Log("msg", fmt.Sprintf("sending line: %s", qr.send))
+			if _, err := fmt.Fprintf(conn, "%s\r\n", qr.send); err != nil {
+				return err
+			}
+		}
+		if len(qr.sendBytes) > 0 {
+			level.Debug(logger).Log("msg", fmt.Sprintf("sending bytes: %x", qr.sendBytes))
+			if _, err = conn.Write(qr.sendBytes); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
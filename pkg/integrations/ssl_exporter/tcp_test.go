@@ -0,0 +1,79 @@
+package ssl_exporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTCPProber_SessionResumption(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	module := ssl_config.Module{
+		Prober:    "tcp",
+		TLSConfig: ssl_config.TLSConfig{InsecureSkipVerify: true},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	reg := prometheus.NewRegistry()
+	probe := newTCPProber(proberOptions{checkSessionResumption: true})
+	require.NoError(t, probe(ctx, log.NewNopLogger(), srv.Listener.Addr().String(), module, reg))
+
+	fams, err := reg.Gather()
+	require.NoError(t, err)
+
+	var found bool
+	for _, mf := range fams {
+		if mf.GetName() != "ssl_tls_session_resumed" {
+			continue
+		}
+		require.Equal(t, float64(1), mf.GetMetric()[0].GetGauge().GetValue())
+		found = true
+	}
+	require.True(t, found, "expected ssl_tls_session_resumed to be collected")
+}
+
+func TestTCPProber_ALPN(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	module := ssl_config.Module{
+		Prober:    "tcp",
+		TLSConfig: ssl_config.TLSConfig{InsecureSkipVerify: true},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	reg := prometheus.NewRegistry()
+	probe := newTCPProber(proberOptions{alpnProtocols: []string{"h2"}})
+	require.NoError(t, probe(ctx, log.NewNopLogger(), srv.Listener.Addr().String(), module, reg))
+
+	fams, err := reg.Gather()
+	require.NoError(t, err)
+
+	var protocol string
+	for _, mf := range fams {
+		if mf.GetName() != "ssl_tls_alpn_protocol_info" {
+			continue
+		}
+		protocol = mf.GetMetric()[0].GetLabel()[0].GetValue()
+	}
+	require.Equal(t, "h2", protocol)
+
+	// The handshake fails when the server supports none of the protocols.
+	probe = newTCPProber(proberOptions{alpnProtocols: []string{"grpc-exp"}})
+	require.Error(t, probe(ctx, log.NewNopLogger(), srv.Listener.Addr().String(), module, prometheus.NewRegistry()))
+}
@@ -0,0 +1,526 @@
+package ssl_exporter
+
+import (
+	"crypto/dsa" //nolint:staticcheck // DSA keys are only inspected, not used.
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"golang.org/x/crypto/ocsp"
+)
+
+// The functions in this file collect the same metrics about the state of a
+// TLS connection as the upstream probers, which don't export them. They're
+// used by the probers of the integration.
+//
+// Copied from github.com/ribbybibby/ssl_exporter/v2/prober.
+
+// newTLSConfig sets up TLS config and instruments it with a function that
+// collects metrics for the verified chain
+func newTLSConfig(target string, registry *prometheus.Registry, cfg *ssl_config.TLSConfig) (*tls.Config, error) {
+	tlsConfig, err := ssl_config.NewTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConfig.ServerName == "" && target != "" {
+		targetAddress, _, err := net.SplitHostPort(target)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ServerName = targetAddress
+	}
+
+	tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		return collectConnectionStateMetrics(state, registry)
+	}
+
+	return tlsConfig, nil
+}
+
+func collectConnectionStateMetrics(state tls.ConnectionState, registry *prometheus.Registry) error {
+	if err := collectTLSVersionMetrics(state.Version, registry); err != nil {
+		return err
+	}
+
+	if err := collectCipherMetrics(state, registry); err != nil {
+		return err
+	}
+
+	if err := collectCertificateMetrics(state.PeerCertificates, registry); err != nil {
+		return err
+	}
+
+	if err := collectVerifiedChainMetrics(state.VerifiedChains, registry); err != nil {
+		return err
+	}
+
+	if err := collectSCTMetrics(state.PeerCertificates, registry); err != nil {
+		return err
+	}
+
+	if err := collectOCSPMetrics(state.OCSPResponse, registry); err != nil {
+		return err
+	}
+
+	return collectMustStapleMetrics(state, registry)
+}
+
+func collectTLSVersionMetrics(version uint16, registry *prometheus.Registry) error {
+	tlsVersion := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName(namespace, "", "tls_version_info"),
+			Help: "The TLS version used",
+		},
+		[]string{"version"},
+	)
+	registry.MustRegister(tlsVersion)
+
+	var v string
+	switch version {
+	case tls.VersionTLS10:
+		v = "TLS 1.0"
+	case tls.VersionTLS11:
+		v = "TLS 1.1"
+	case tls.VersionTLS12:
+		v = "TLS 1.2"
+	case tls.VersionTLS13:
+		v = "TLS 1.3"
+	default:
+		v = "unknown"
+	}
+
+	tlsVersion.WithLabelValues(v).Set(1)
+	return nil
+}
+
+func collectCipherMetrics(state tls.ConnectionState, registry *prometheus.Registry) error {
+	cipherInfo := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName(namespace, "", "tls_cipher_info"),
+			Help: "The cipher suite and key exchange negotiated for the connection",
+		},
+		[]string{"cipher", "kex"},
+	)
+	registry.MustRegister(cipherInfo)
+
+	cipherInfo.WithLabelValues(tls.CipherSuiteName(state.CipherSuite), keyExchange(state)).Set(1)
+	return nil
+}
+
+// keyExchange returns the key exchange method negotiated for the connection,
+// like ECDHE or RSA.
+func keyExchange(state tls.ConnectionState) string {
+	// Every TLS 1.3 cipher suite supported by Go uses ECDHE.
+	if state.Version == tls.VersionTLS13 {
+		return "ECDHE"
+	}
+
+	// TLS 1.2 and earlier cipher suites are named after their key exchange,
+	// like TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256.
+	name := strings.TrimPrefix(tls.CipherSuiteName(state.CipherSuite), "TLS_")
+	i := strings.Index(name, "_WITH_")
+	if i < 0 {
+		return "unknown"
+	}
+	return strings.SplitN(name[:i], "_", 2)[0]
+}
+
+func collectCertificateMetrics(certs []*x509.Certificate, registry *prometheus.Registry) error {
+	var (
+		notAfter = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "cert_not_after"),
+				Help: "NotAfter expressed as a Unix Epoch Time",
+			},
+			[]string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		)
+		notBefore = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "cert_not_before"),
+				Help: "NotBefore expressed as a Unix Epoch Time",
+			},
+			[]string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		)
+		publicKeyInfo = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "cert_public_key_info"),
+				Help: "The algorithm and size in bits of the public key of a certificate",
+			},
+			[]string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position", "algorithm", "bits"},
+		)
+		signatureAlgorithmInfo = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "cert_signature_algorithm_info"),
+				Help: "The algorithm used to sign a certificate",
+			},
+			[]string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position", "signature_algorithm"},
+		)
+		insecureSignature = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "cert_insecure_signature"),
+				Help: "If a certificate is signed with an insecure algorithm, like MD5 or SHA-1",
+			},
+			[]string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		)
+		expiresIn = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "cert_expires_in_seconds"),
+				Help: "The number of seconds until a certificate expires, as of the probe. Negative once expired",
+			},
+			[]string{"serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		)
+	)
+	registry.MustRegister(notAfter, notBefore, publicKeyInfo, signatureAlgorithmInfo, insecureSignature, expiresIn)
+
+	certs = uniq(certs)
+
+	if len(certs) == 0 {
+		return fmt.Errorf("No certificates found")
+	}
+
+	for i, cert := range certs {
+		labels := labelValues(cert, i)
+
+		if !cert.NotAfter.IsZero() {
+			notAfter.WithLabelValues(labels...).Set(float64(cert.NotAfter.Unix()))
+			expiresIn.WithLabelValues(labels...).Set(time.Until(cert.NotAfter).Seconds())
+		}
+
+		if !cert.NotBefore.IsZero() {
+			notBefore.WithLabelValues(labels...).Set(float64(cert.NotBefore.Unix()))
+		}
+
+		algorithm, bits := publicKeyInfoValues(cert)
+		publicKeyInfo.WithLabelValues(append(labels, algorithm, strconv.Itoa(bits))...).Set(1)
+
+		signatureAlgorithmInfo.WithLabelValues(append(labels, cert.SignatureAlgorithm.String())...).Set(1)
+		if insecureSignatureAlgorithm(cert.SignatureAlgorithm) {
+			insecureSignature.WithLabelValues(labels...).Set(1)
+		} else {
+			insecureSignature.WithLabelValues(labels...).Set(0)
+		}
+	}
+
+	return nil
+}
+
+// insecureSignatureAlgorithm returns whether signatures made with algo can be
+// forged, which is the case for every algorithm based on MD2, MD5, or SHA-1.
+func insecureSignatureAlgorithm(algo x509.SignatureAlgorithm) bool {
+	switch algo {
+	case x509.MD2WithRSA, x509.MD5WithRSA, x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+		return true
+	default:
+		return false
+	}
+}
+
+// publicKeyInfoValues returns the name of the algorithm of the public key of
+// cert along with its size in bits. The size is 0 for unknown algorithms.
+func publicKeyInfoValues(cert *x509.Certificate) (string, int) {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return "rsa", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return "ecdsa", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return "ed25519", len(key) * 8
+	case *dsa.PublicKey:
+		return "dsa", key.P.BitLen()
+	default:
+		return "unknown", 0
+	}
+}
+
+func collectVerifiedChainMetrics(verifiedChains [][]*x509.Certificate, registry *prometheus.Registry) error {
+	var (
+		verifiedNotAfter = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "verified_cert_not_after"),
+				Help: "NotAfter expressed as a Unix Epoch Time",
+			},
+			[]string{"chain_no", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		)
+		verifiedNotBefore = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "verified_cert_not_before"),
+				Help: "NotBefore expressed as a Unix Epoch Time",
+			},
+			[]string{"chain_no", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		)
+		verifiedExpiresIn = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "verified_cert_expires_in_seconds"),
+				Help: "The number of seconds until a certificate expires, as of the probe. Negative once expired",
+			},
+			[]string{"chain_no", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		)
+		chainExpiresIn = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "verified_chain_expires_in_seconds"),
+				Help: "The number of seconds until the first certificate of a verified chain expires, as of the probe. Negative once expired",
+			},
+			[]string{"chain_no"},
+		)
+	)
+	registry.MustRegister(verifiedNotAfter, verifiedNotBefore, verifiedExpiresIn, chainExpiresIn)
+
+	sort.Slice(verifiedChains, func(i, j int) bool {
+		return chainExpiry(verifiedChains[i]).After(chainExpiry(verifiedChains[j]))
+	})
+
+	for i, chain := range verifiedChains {
+		chain = uniq(chain)
+		if expiry := chainExpiry(chain); !expiry.IsZero() {
+			chainExpiresIn.WithLabelValues(strconv.Itoa(i)).Set(time.Until(expiry).Seconds())
+		}
+		for j, cert := range chain {
+			chainNo := strconv.Itoa(i)
+			labels := append([]string{chainNo}, labelValues(cert, j)...)
+
+			if !cert.NotAfter.IsZero() {
+				verifiedNotAfter.WithLabelValues(labels...).Set(float64(cert.NotAfter.Unix()))
+				verifiedExpiresIn.WithLabelValues(labels...).Set(time.Until(cert.NotAfter).Seconds())
+			}
+
+			if !cert.NotBefore.IsZero() {
+				verifiedNotBefore.WithLabelValues(labels...).Set(float64(cert.NotBefore.Unix()))
+			}
+		}
+	}
+
+	return nil
+}
+
+// chainExpiry returns the earliest NotAfter of the certificates in chain.
+func chainExpiry(chain []*x509.Certificate) time.Time {
+	expiry := time.Time{}
+	for _, cert := range chain {
+		if (expiry.IsZero() || cert.NotAfter.Before(expiry)) && !cert.NotAfter.IsZero() {
+			expiry = cert.NotAfter
+		}
+	}
+	return expiry
+}
+
+func collectOCSPMetrics(ocspResponse []byte, registry *prometheus.Registry) error {
+	var (
+		ocspStapled = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "ocsp_response_stapled"),
+				Help: "If the connection state contains a stapled OCSP response",
+			},
+		)
+		ocspStatus = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "ocsp_response_status"),
+				Help: "The status in the OCSP response 0=Good 1=Revoked 2=Unknown",
+			},
+		)
+		ocspProducedAt = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "ocsp_response_produced_at"),
+				Help: "The producedAt value in the OCSP response, expressed as a Unix Epoch Time",
+			},
+		)
+		ocspThisUpdate = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "ocsp_response_this_update"),
+				Help: "The thisUpdate value in the OCSP response, expressed as a Unix Epoch Time",
+			},
+		)
+		ocspNextUpdate = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "ocsp_response_next_update"),
+				Help: "The nextUpdate value in the OCSP response, expressed as a Unix Epoch Time",
+			},
+		)
+		ocspRevokedAt = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "ocsp_response_revoked_at"),
+				Help: "The revocationTime value in the OCSP response, expressed as a Unix Epoch Time",
+			},
+		)
+	)
+	registry.MustRegister(
+		ocspStapled,
+		ocspStatus,
+		ocspProducedAt,
+		ocspThisUpdate,
+		ocspNextUpdate,
+		ocspRevokedAt,
+	)
+
+	if len(ocspResponse) == 0 {
+		return nil
+	}
+
+	resp, err := ocsp.ParseResponse(ocspResponse, nil)
+	if err != nil {
+		return err
+	}
+
+	ocspStapled.Set(1)
+	ocspStatus.Set(float64(resp.Status))
+	ocspProducedAt.Set(float64(resp.ProducedAt.Unix()))
+	ocspThisUpdate.Set(float64(resp.ThisUpdate.Unix()))
+	ocspNextUpdate.Set(float64(resp.NextUpdate.Unix()))
+	ocspRevokedAt.Set(float64(resp.RevokedAt.Unix()))
+
+	return nil
+}
+
+// oidTLSFeature is the OID of the TLS Feature extension defined in RFC 7633.
+var oidTLSFeature = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
+
+// tlsFeatureStatusRequest is the TLS feature which requires the server to
+// staple an OCSP response.
+const tlsFeatureStatusRequest = 5
+
+// collectMustStapleMetrics collects whether the leaf certificate of the
+// connection advertises the OCSP must-staple extension, and whether that
+// requirement was violated because the server didn't staple a response.
+func collectMustStapleMetrics(state tls.ConnectionState, registry *prometheus.Registry) error {
+	var (
+		mustStaple = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "ocsp_must_staple"),
+				Help: "If the leaf certificate advertises the OCSP must-staple extension",
+			},
+		)
+		mustStapleMissing = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "ocsp_must_staple_missing"),
+				Help: "If the leaf certificate advertises the OCSP must-staple extension but no OCSP response was stapled",
+			},
+		)
+	)
+	registry.MustRegister(mustStaple, mustStapleMissing)
+
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	if hasMustStaple(state.PeerCertificates[0]) {
+		mustStaple.Set(1)
+		if len(state.OCSPResponse) == 0 {
+			mustStapleMissing.Set(1)
+		}
+	}
+
+	return nil
+}
+
+// hasMustStaple returns whether cert has a TLS Feature extension which
+// requires an OCSP response to be stapled.
+func hasMustStaple(cert *x509.Certificate) bool {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidTLSFeature) {
+			continue
+		}
+		var features []int
+		if _, err := asn1.Unmarshal(ext.Value, &features); err != nil {
+			return false
+		}
+		for _, f := range features {
+			if f == tlsFeatureStatusRequest {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func uniq(certs []*x509.Certificate) []*x509.Certificate {
+	r := []*x509.Certificate{}
+
+	for _, c := range certs {
+		if !contains(r, c) {
+			r = append(r, c)
+		}
+	}
+
+	return r
+}
+
+func contains(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if (c.SerialNumber.String() == cert.SerialNumber.String()) && (c.Issuer.CommonName == cert.Issuer.CommonName) {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return certs, err
+			}
+			if !contains(certs, cert) {
+				certs = append(certs, cert)
+			}
+		}
+	}
+
+	return certs, nil
+}
+
+// labelValues returns the values of the certificate labels of cert. position
+// is the index of cert in its chain or file, where 0 is the first
+// certificate, which is usually the leaf.
+func labelValues(cert *x509.Certificate, position int) []string {
+	return []string{
+		cert.SerialNumber.String(),
+		cert.Issuer.CommonName,
+		cert.Subject.CommonName,
+		joinLabelValues(cert.DNSNames),
+		ipAddresses(cert),
+		joinLabelValues(cert.EmailAddresses),
+		joinLabelValues(cert.Subject.OrganizationalUnit),
+		fingerprintSHA256(cert),
+		strconv.Itoa(position),
+	}
+}
+
+// fingerprintSHA256 returns the hex-encoded SHA-256 fingerprint of the DER
+// encoding of cert.
+func fingerprintSHA256(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// joinLabelValues joins values into a single label value which is wrapped
+// in commas, so that individual values can be matched with a regex.
+func joinLabelValues(values []string) string {
+	if len(values) > 0 {
+		return "," + strings.Join(values, ",") + ","
+	}
+	return ""
+}
+
+func ipAddresses(cert *x509.Certificate) string {
+	ips := make([]string, 0, len(cert.IPAddresses))
+	for _, ip := range cert.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+	return joinLabelValues(ips)
+}
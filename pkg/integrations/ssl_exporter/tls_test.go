@@ -0,0 +1,230 @@
+package ssl_exporter
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectCertificateMetrics_PublicKeyInfo(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tt := []struct {
+		name      string
+		key       crypto.Signer
+		algorithm string
+		bits      string
+	}{
+		{"rsa", rsaKey, "rsa", "1024"},
+		{"ecdsa", ecKey, "ecdsa", "384"},
+		{"ed25519", edKey, "ed25519", "256"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cert := selfSignedCert(t, tc.key, &x509.Certificate{})
+
+			reg := prometheus.NewRegistry()
+			require.NoError(t, collectCertificateMetrics([]*x509.Certificate{cert}, reg))
+
+			labels := gatherLabels(t, reg, "ssl_cert_public_key_info")
+			require.Len(t, labels, 1)
+			require.Equal(t, tc.algorithm, labels[0]["algorithm"])
+			require.Equal(t, tc.bits, labels[0]["bits"])
+		})
+	}
+}
+
+// selfSignedCert returns a certificate based on tmpl which is self-signed by
+// key. The serial number and validity of tmpl are filled in if unset.
+func selfSignedCert(t *testing.T, key crypto.Signer, tmpl *x509.Certificate) *x509.Certificate {
+	t.Helper()
+
+	if tmpl.SerialNumber == nil {
+		tmpl.SerialNumber = big.NewInt(1)
+	}
+	if tmpl.Subject.CommonName == "" {
+		tmpl.Subject = pkix.Name{CommonName: "example.com"}
+	}
+	if tmpl.NotAfter.IsZero() {
+		tmpl.NotBefore = time.Now().Add(-time.Hour)
+		tmpl.NotAfter = time.Now().Add(24 * time.Hour)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+// gatherLabels returns the labels of every metric of the family name in reg.
+func gatherLabels(t *testing.T, reg *prometheus.Registry, name string) []map[string]string {
+	t.Helper()
+
+	fams, err := reg.Gather()
+	require.NoError(t, err)
+
+	var res []map[string]string
+	for _, mf := range fams {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			res = append(res, labels)
+		}
+	}
+	return res
+}
+
+func TestCollectCertificateMetrics_SignatureAlgorithm(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	tt := []struct {
+		algo     x509.SignatureAlgorithm
+		name     string
+		insecure float64
+	}{
+		{x509.SHA256WithRSA, "SHA256-RSA", 0},
+		{x509.SHA1WithRSA, "SHA1-RSA", 1},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			// Go refuses to create SHA-1 signatures, so the signature
+			// algorithm is set after signing.
+			cert := selfSignedCert(t, key, &x509.Certificate{})
+			cert.SignatureAlgorithm = tc.algo
+
+			reg := prometheus.NewRegistry()
+			require.NoError(t, collectCertificateMetrics([]*x509.Certificate{cert}, reg))
+
+			labels := gatherLabels(t, reg, "ssl_cert_signature_algorithm_info")
+			require.Len(t, labels, 1)
+			require.Equal(t, tc.name, labels[0]["signature_algorithm"])
+
+			fams, err := reg.Gather()
+			require.NoError(t, err)
+			for _, mf := range fams {
+				if mf.GetName() == "ssl_cert_insecure_signature" {
+					require.Equal(t, tc.insecure, mf.GetMetric()[0].GetGauge().GetValue())
+				}
+			}
+		})
+	}
+}
+
+func TestCollectMustStapleMetrics(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	mustStapleExt, err := asn1.Marshal([]int{tlsFeatureStatusRequest})
+	require.NoError(t, err)
+
+	plain := selfSignedCert(t, key, &x509.Certificate{})
+	mustStaple := selfSignedCert(t, key, &x509.Certificate{
+		ExtraExtensions: []pkix.Extension{{Id: oidTLSFeature, Value: mustStapleExt}},
+	})
+
+	tt := []struct {
+		name                string
+		cert                *x509.Certificate
+		ocspResponse        []byte
+		mustStaple, missing float64
+	}{
+		{"no must-staple", plain, nil, 0, 0},
+		{"must-staple without response", mustStaple, nil, 1, 1},
+		{"must-staple with response", mustStaple, []byte("response"), 1, 0},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			state := tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{tc.cert},
+				OCSPResponse:     tc.ocspResponse,
+			}
+			require.NoError(t, collectMustStapleMetrics(state, reg))
+
+			fams, err := reg.Gather()
+			require.NoError(t, err)
+			values := make(map[string]float64)
+			for _, mf := range fams {
+				values[mf.GetName()] = mf.GetMetric()[0].GetGauge().GetValue()
+			}
+			require.Equal(t, tc.mustStaple, values["ssl_ocsp_must_staple"])
+			require.Equal(t, tc.missing, values["ssl_ocsp_must_staple_missing"])
+		})
+	}
+}
+
+func TestCollectVerifiedChainMetrics_ExpiresIn(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	now := time.Now()
+	leaf := selfSignedCert(t, key, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+	})
+	root := selfSignedCert(t, key, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "root"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(48 * time.Hour),
+	})
+
+	reg := prometheus.NewRegistry()
+	require.NoError(t, collectVerifiedChainMetrics([][]*x509.Certificate{{leaf, root}}, reg))
+
+	fams, err := reg.Gather()
+	require.NoError(t, err)
+
+	var found bool
+	for _, mf := range fams {
+		if mf.GetName() != "ssl_verified_chain_expires_in_seconds" {
+			continue
+		}
+		// The chain expires with its leaf.
+		require.InDelta(t, time.Hour.Seconds(), mf.GetMetric()[0].GetGauge().GetValue(), 60)
+		found = true
+	}
+	require.True(t, found, "expected ssl_verified_chain_expires_in_seconds to be collected")
+}
+
+func TestKeyExchange(t *testing.T) {
+	tt := []struct {
+		version, suite uint16
+		expect         string
+	}{
+		{tls.VersionTLS13, tls.TLS_AES_128_GCM_SHA256, "ECDHE"},
+		{tls.VersionTLS12, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, "ECDHE"},
+		{tls.VersionTLS12, tls.TLS_RSA_WITH_AES_128_CBC_SHA, "RSA"},
+		{tls.VersionTLS12, 0xffff, "unknown"},
+	}
+	for _, tc := range tt {
+		state := tls.ConnectionState{Version: tc.version, CipherSuite: tc.suite}
+		require.Equal(t, tc.expect, keyExchange(state), tls.CipherSuiteName(tc.suite))
+	}
+}
@@ -0,0 +1,364 @@
+package ssl_exporter
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	vault "github.com/hashicorp/vault/api"
+	"github.com/prometheus/client_golang/prometheus"
+	config_util "github.com/prometheus/common/config"
+	ssl_config "github.com/ribbybibby/ssl_exporter/v2/config"
+	"github.com/ribbybibby/ssl_exporter/v2/prober"
+)
+
+// Names of the probers which collect certificates from Vault.
+const (
+	vaultPKIProber    = "vault_pki"
+	vaultSecretProber = "vault_secret"
+)
+
+const defaultServiceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// VaultConfig configures the connection to HashiCorp Vault used by the
+// vault_pki and vault_secret probers. Settings which aren't set are read
+// from the standard Vault environment variables, like VAULT_ADDR and
+// VAULT_CACERT.
+type VaultConfig struct {
+	Address   string `yaml:"address,omitempty"`
+	Namespace string `yaml:"namespace,omitempty"`
+
+	// At most one authentication method may be set. If none is set, the
+	// token from the VAULT_TOKEN environment variable is used.
+	Token      config_util.Secret   `yaml:"token,omitempty"`
+	AppRole    *VaultAppRoleAuth    `yaml:"approle,omitempty"`
+	Kubernetes *VaultKubernetesAuth `yaml:"kubernetes,omitempty"`
+}
+
+// VaultAppRoleAuth authenticates to Vault with the AppRole auth method.
+type VaultAppRoleAuth struct {
+	MountPath string             `yaml:"mount_path,omitempty"`
+	RoleID    string             `yaml:"role_id"`
+	SecretID  config_util.Secret `yaml:"secret_id"`
+}
+
+// VaultKubernetesAuth authenticates to Vault with the Kubernetes auth method,
+// using the token of the service account of the agent.
+type VaultKubernetesAuth struct {
+	MountPath               string `yaml:"mount_path,omitempty"`
+	Role                    string `yaml:"role"`
+	ServiceAccountTokenFile string `yaml:"service_account_token_file,omitempty"`
+}
+
+// Validate validates the Vault config.
+func (c *VaultConfig) Validate() error {
+	methods := 0
+	if c.Token != "" {
+		methods++
+	}
+	if c.AppRole != nil {
+		methods++
+		if c.AppRole.RoleID == "" || c.AppRole.SecretID == "" {
+			return fmt.Errorf("approle auth requires role_id and secret_id to be set")
+		}
+	}
+	if c.Kubernetes != nil {
+		methods++
+		if c.Kubernetes.Role == "" {
+			return fmt.Errorf("kubernetes auth requires role to be set")
+		}
+	}
+	if methods > 1 {
+		return fmt.Errorf("only one of token, approle, or kubernetes may be set")
+	}
+	return nil
+}
+
+// vaultClient is a Vault client which logs in on demand and logs in again
+// once its token expires.
+type vaultClient struct {
+	cfg VaultConfig
+
+	mut     sync.Mutex
+	client  *vault.Client
+	expires time.Time // zero if the token doesn't expire
+}
+
+func newVaultClient(cfg VaultConfig) (*vaultClient, error) {
+	clientConfig := vault.DefaultConfig()
+	if clientConfig.Error != nil {
+		return nil, clientConfig.Error
+	}
+	if cfg.Address != "" {
+		clientConfig.Address = cfg.Address
+	}
+
+	client, err := vault.NewClient(clientConfig)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Namespace != "" {
+		client.SetNamespace(cfg.Namespace)
+	}
+	switch {
+	case cfg.Token != "":
+		client.SetToken(string(cfg.Token))
+	case cfg.AppRole != nil || cfg.Kubernetes != nil:
+		// Log in with the auth method instead of using the token from the
+		// environment.
+		client.ClearToken()
+	}
+	return &vaultClient{cfg: cfg, client: client}, nil
+}
+
+// get returns the client, logging in first if needed.
+func (c *vaultClient) get() (*vault.Client, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	// Tokens which were set directly aren't renewed.
+	if c.cfg.AppRole == nil && c.cfg.Kubernetes == nil {
+		return c.client, nil
+	}
+	if c.client.Token() != "" && (c.expires.IsZero() || time.Now().Before(c.expires)) {
+		return c.client, nil
+	}
+
+	var (
+		loginPath string
+		data      map[string]interface{}
+	)
+	if c.cfg.AppRole != nil {
+		loginPath = path.Join("auth", stringOrDefault(c.cfg.AppRole.MountPath, "approle"), "login")
+		data = map[string]interface{}{
+			"role_id":   c.cfg.AppRole.RoleID,
+			"secret_id": string(c.cfg.AppRole.SecretID),
+		}
+	} else {
+		jwt, err := os.ReadFile(stringOrDefault(c.cfg.Kubernetes.ServiceAccountTokenFile, defaultServiceAccountTokenFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read service account token: %w", err)
+		}
+		loginPath = path.Join("auth", stringOrDefault(c.cfg.Kubernetes.MountPath, "kubernetes"), "login")
+		data = map[string]interface{}{
+			"role": c.cfg.Kubernetes.Role,
+			"jwt":  strings.TrimSpace(string(jwt)),
+		}
+	}
+
+	c.client.ClearToken()
+	secret, err := c.client.Logical().Write(loginPath, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to log in to vault: %w", err)
+	}
+	if secret == nil || secret.Auth == nil {
+		return nil, fmt.Errorf("failed to log in to vault: no token returned")
+	}
+
+	c.client.SetToken(secret.Auth.ClientToken)
+	c.expires = time.Time{}
+	if secret.Auth.LeaseDuration > 0 {
+		// Log in again a little before the token expires.
+		lease := time.Duration(secret.Auth.LeaseDuration) * time.Second
+		c.expires = time.Now().Add(lease * 9 / 10)
+	}
+	return c.client, nil
+}
+
+func stringOrDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// newVaultProber returns a prober which collects certificate metrics from
+// Vault with client. With the vault_pki prober, the target is the mount path
+// of a PKI secrets engine and every certificate issued by it is collected.
+// With the vault_secret prober, the target is the path of a secret and every
+// field of the secret which holds PEM-encoded certificates is collected.
+func newVaultProber(name string, client *vaultClient) prober.ProbeFn {
+	return func(ctx context.Context, logger log.Logger, target string, module ssl_config.Module, registry *prometheus.Registry) error {
+		if client == nil {
+			return fmt.Errorf("vault must be configured to use the %s prober", name)
+		}
+
+		errCh := make(chan error, 1)
+
+		go func() {
+			c, err := client.get()
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			var certs []vaultCert
+			if name == vaultPKIProber {
+				certs, err = readVaultPKICerts(ctx, c, target)
+			} else {
+				certs, err = readVaultSecretCerts(c, target)
+			}
+			if err != nil {
+				errCh <- err
+				return
+			}
+			errCh <- collectVaultMetrics(ctx, certs, registry)
+		}()
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context timeout, ran out of time")
+		case err := <-errCh:
+			return err
+		}
+	}
+}
+
+// vaultCert is a certificate found in Vault. position is the index of the
+// certificate in the PEM data of its field.
+type vaultCert struct {
+	path, key string
+	position  int
+	cert      *x509.Certificate
+}
+
+// readVaultPKICerts reads every certificate issued by the PKI secrets engine
+// mounted at mount.
+func readVaultPKICerts(ctx context.Context, client *vault.Client, mount string) ([]vaultCert, error) {
+	mount = strings.Trim(mount, "/")
+
+	list, err := client.Logical().List(path.Join(mount, "certs"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list certificates: %w", err)
+	}
+	if list == nil {
+		return nil, nil
+	}
+	keys, _ := list.Data["keys"].([]interface{})
+
+	var res []vaultCert
+	for _, k := range keys {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		serial, ok := k.(string)
+		if !ok {
+			continue
+		}
+		certPath := path.Join(mount, "cert", serial)
+		secret, err := client.Logical().Read(certPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read certificate %s: %w", serial, err)
+		}
+		if secret == nil {
+			continue
+		}
+		pemData, _ := secret.Data["certificate"].(string)
+		certs, err := decodeCertificates([]byte(pemData))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate %s: %w", serial, err)
+		}
+		for i, cert := range certs {
+			res = append(res, vaultCert{path: certPath, key: "certificate", position: i, cert: cert})
+		}
+	}
+	return res, nil
+}
+
+// readVaultSecretCerts reads the certificates from every field of the secret
+// at secretPath which holds PEM-encoded certificates.
+func readVaultSecretCerts(client *vault.Client, secretPath string) ([]vaultCert, error) {
+	data, err := readVaultSecretData(client, secretPath)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var res []vaultCert
+	for _, k := range keys {
+		value, ok := data[k].(string)
+		if !ok {
+			continue
+		}
+		certs, err := decodeCertificates([]byte(value))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate in field %s: %w", k, err)
+		}
+		for i, cert := range certs {
+			res = append(res, vaultCert{path: secretPath, key: k, position: i, cert: cert})
+		}
+	}
+	return res, nil
+}
+
+// readVaultSecretData reads the fields of the secret at secretPath. The data
+// of KV version 2 secrets is read from their data field.
+func readVaultSecretData(client *vault.Client, secretPath string) (map[string]interface{}, error) {
+	secret, err := client.Logical().Read(secretPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret: %w", err)
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("secret %s not found", secretPath)
+	}
+
+	data := secret.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = nested
+		}
+	}
+	return data, nil
+}
+
+func collectVaultMetrics(ctx context.Context, certs []vaultCert, registry *prometheus.Registry) error {
+	var (
+		vaultNotAfter = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "vault_cert_not_after"),
+				Help: "NotAfter expressed as a Unix Epoch Time for a certificate found in Vault",
+			},
+			[]string{"path", "key", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		)
+		vaultNotBefore = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: prometheus.BuildFQName(namespace, "", "vault_cert_not_before"),
+				Help: "NotBefore expressed as a Unix Epoch Time for a certificate found in Vault",
+			},
+			[]string{"path", "key", "serial_no", "issuer_cn", "cn", "dnsnames", "ips", "emails", "ou", "fingerprint_sha256", "chain_position"},
+		)
+	)
+	registry.MustRegister(vaultNotAfter, vaultNotBefore)
+
+	if len(certs) == 0 {
+		return fmt.Errorf("No certificates found")
+	}
+
+	for _, c := range certs {
+		labels := append([]string{c.path, c.key}, labelValues(c.cert, c.position)...)
+		recordCert(ctx, map[string]string{"path": c.path, "key": c.key}, c.position, c.cert)
+
+		if !c.cert.NotAfter.IsZero() {
+			vaultNotAfter.WithLabelValues(labels...).Set(float64(c.cert.NotAfter.Unix()))
+		}
+
+		if !c.cert.NotBefore.IsZero() {
+			vaultNotBefore.WithLabelValues(labels...).Set(float64(c.cert.NotBefore.Unix()))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package ssl_exporter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultProbers(t *testing.T) {
+	certPEM, _ := generateKeyPair(t)
+
+	var logins int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond := func(v interface{}) {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(v)
+		}
+
+		if r.URL.Path == "/v1/auth/approle/login" {
+			atomic.AddInt32(&logins, 1)
+			respond(map[string]interface{}{
+				"auth": map[string]interface{}{"client_token": "s.token", "lease_duration": 3600},
+			})
+			return
+		}
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/v1/pki/certs" && (r.Method == "LIST" || r.URL.Query().Get("list") == "true"):
+			respond(map[string]interface{}{"data": map[string]interface{}{"keys": []string{"01"}}})
+		case r.URL.Path == "/v1/pki/cert/01":
+			respond(map[string]interface{}{"data": map[string]interface{}{"certificate": string(certPEM)}})
+		case r.URL.Path == "/v1/secret/data/app":
+			respond(map[string]interface{}{"data": map[string]interface{}{
+				"data":     map[string]interface{}{"tls.crt": string(certPEM), "username": "app"},
+				"metadata": map[string]interface{}{"version": 1},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := VaultConfig{
+		Address: srv.URL,
+		AppRole: &VaultAppRoleAuth{RoleID: "role", SecretID: "secret"},
+	}
+	require.NoError(t, cfg.Validate())
+	client, err := newVaultClient(cfg)
+	require.NoError(t, err)
+
+	tt := []struct {
+		prober, target, path, key string
+	}{
+		{vaultPKIProber, "pki", "pki/cert/01", "certificate"},
+		{vaultSecretProber, "secret/data/app", "secret/data/app", "tls.crt"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.prober, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			probe := newVaultProber(tc.prober, client)
+			require.NoError(t, probe(context.Background(), log.NewNopLogger(), tc.target, defaultSSLConfig().Modules[tc.prober], reg))
+
+			fams, err := reg.Gather()
+			require.NoError(t, err)
+
+			var found bool
+			for _, mf := range fams {
+				if mf.GetName() != "ssl_vault_cert_not_after" {
+					continue
+				}
+				require.Len(t, mf.GetMetric(), 1)
+				labels := make(map[string]string)
+				for _, l := range mf.GetMetric()[0].GetLabel() {
+					labels[l.GetName()] = l.GetValue()
+				}
+				require.Equal(t, tc.path, labels["path"])
+				require.Equal(t, tc.key, labels["key"])
+				found = true
+			}
+			require.True(t, found, "expected ssl_vault_cert_not_after to be collected")
+		})
+	}
+
+	// The token should be reused until it expires.
+	require.Equal(t, int32(1), atomic.LoadInt32(&logins))
+}
+
+func TestVaultProber_NotConfigured(t *testing.T) {
+	probe := newVaultProber(vaultPKIProber, nil)
+	err := probe(context.Background(), log.NewNopLogger(), "pki", defaultSSLConfig().Modules[vaultPKIProber], prometheus.NewRegistry())
+	require.EqualError(t, err, "vault must be configured to use the vault_pki prober")
+}
+
+func TestVaultConfig_Validate(t *testing.T) {
+	cfg := VaultConfig{Token: "token", Kubernetes: &VaultKubernetesAuth{Role: "agent"}}
+	require.EqualError(t, cfg.Validate(), "only one of token, approle, or kubernetes may be set")
+
+	cfg = VaultConfig{AppRole: &VaultAppRoleAuth{RoleID: "role"}}
+	require.EqualError(t, cfg.Validate(), "approle auth requires role_id and secret_id to be set")
+
+	cfg = VaultConfig{Kubernetes: &VaultKubernetesAuth{}}
+	require.EqualError(t, cfg.Validate(), "kubernetes auth requires role to be set")
+}
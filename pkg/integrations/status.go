@@ -0,0 +1,216 @@
+package integrations
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/pkg/metrics/cluster/configapi"
+	"github.com/prometheus/prometheus/scrape"
+)
+
+// States of integrations reported by Manager.Status.
+const (
+	// StateRunning is the state of integrations which are running.
+	StateRunning = "running"
+
+	// StateRestarting is the state of integrations which exited with an error
+	// and are waiting to be restarted.
+	StateRestarting = "restarting"
+
+	// StateStopped is the state of integrations which exited without an
+	// error and won't be restarted.
+	StateStopped = "stopped"
+
+	// StateFailed is the state of integrations which couldn't be created from
+	// their config. They're retried when the config is reloaded.
+	StateFailed = "failed"
+
+	// StateDisabled is the state of integrations which were disabled at
+	// runtime through the API.
+	StateDisabled = "disabled"
+)
+
+// IntegrationStatus is the status of an integration.
+type IntegrationStatus struct {
+	Name string `json:"name"`
+
+	// InstanceName is the name of the instance of integrations configured
+	// with multiple instances.
+	InstanceName string `json:"instance_name,omitempty"`
+
+	// Instance is the instance label of the integration, and MetricsPath the
+	// path of the endpoint exposing its metrics. Both are unset for
+	// integrations which failed or were disabled.
+	Instance    string `json:"instance,omitempty"`
+	MetricsPath string `json:"metrics_path,omitempty"`
+
+	State string `json:"state"`
+
+	// Healthy is false if the integration isn't running or its last scrape
+	// failed.
+	Healthy bool `json:"healthy"`
+
+	// UptimeSeconds is how long the integration has been running since it was
+	// last started.
+	UptimeSeconds float64 `json:"uptime_seconds"`
+
+	// LastError is the error the integration last exited with.
+	LastError string `json:"last_error,omitempty"`
+
+	// LastScrape is the time the integration was last scraped, and
+	// LastScrapeSuccess whether that scrape succeeded. Both are unset if the
+	// integration isn't scraped or hasn't been scraped yet.
+	LastScrape        *time.Time `json:"last_scrape,omitempty"`
+	LastScrapeSuccess *bool      `json:"last_scrape_success,omitempty"`
+	LastScrapeError   string     `json:"last_scrape_error,omitempty"`
+}
+
+// processStatus tracks the state of an integrationProcess.
+type processStatus struct {
+	mut       sync.Mutex
+	running   bool
+	stopped   bool
+	startedAt time.Time
+	lastErr   error
+}
+
+// started records that the integration was (re)started at now.
+func (s *processStatus) started(now time.Time) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.running, s.startedAt = true, now
+}
+
+// exited records that the integration exited. The integration is restarted
+// if err is non-nil.
+func (s *processStatus) exited(err error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.running = false
+	if err != nil {
+		s.lastErr = err
+	} else {
+		s.stopped = true
+	}
+}
+
+// Status returns the status of every integration enabled in the config,
+// sorted by name and instance name.
+func (m *Manager) Status() []IntegrationStatus {
+	m.cfgMut.RLock()
+	defer m.cfgMut.RUnlock()
+
+	m.integrationsMut.RLock()
+	defer m.integrationsMut.RUnlock()
+
+	now := time.Now()
+
+	var res []IntegrationStatus
+	for _, ic := range m.integrationConfigs(m.cfg) {
+		if !ic.Common.Enabled {
+			continue
+		}
+		key := integrationKey(ic.Name(), ic.Common.Name)
+
+		if _, disabled := m.disabled[key]; disabled {
+			res = append(res, IntegrationStatus{Name: ic.Name(), InstanceName: ic.Common.Name, State: StateDisabled, Healthy: true})
+			continue
+		}
+		p, ok := m.integrations[key]
+		if !ok {
+			// The integration failed to be created, which was logged when the
+			// config was applied.
+			res = append(res, IntegrationStatus{Name: ic.Name(), InstanceName: ic.Common.Name, State: StateFailed})
+			continue
+		}
+
+		status := p.status(now)
+		if inst, err := m.im.GetInstance(key); err == nil {
+			status.setScrapeStatus(inst.TargetsActive())
+		}
+		status.Healthy = status.State == StateRunning &&
+			(status.LastScrapeSuccess == nil || *status.LastScrapeSuccess)
+		res = append(res, status)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Name != res[j].Name {
+			return res[i].Name < res[j].Name
+		}
+		return res[i].InstanceName < res[j].InstanceName
+	})
+	return res
+}
+
+// Ready returns true if no integration failed to be created or is waiting to
+// be restarted. Failed scrapes don't affect readiness, since a single failed
+// scrape of an otherwise running integration is often transient; they're
+// reported by Status instead.
+func (m *Manager) Ready() bool {
+	for _, s := range m.Status() {
+		if s.State == StateFailed || s.State == StateRestarting {
+			return false
+		}
+	}
+	return true
+}
+
+// status returns the status of the integration run by p.
+func (p *integrationProcess) status(now time.Time) IntegrationStatus {
+	p.ps.mut.Lock()
+	defer p.ps.mut.Unlock()
+
+	status := IntegrationStatus{
+		Name:         p.cfg.Name(),
+		InstanceName: p.cfg.Common.Name,
+		Instance:     p.instanceKey,
+		MetricsPath:  instanceMetricsPath(p.cfg.Name(), p.instanceKey),
+		State:        StateRestarting,
+	}
+	switch {
+	case p.ps.running:
+		status.State = StateRunning
+		status.UptimeSeconds = now.Sub(p.ps.startedAt).Seconds()
+	case p.ps.stopped:
+		status.State = StateStopped
+	}
+	if p.ps.lastErr != nil {
+		status.LastError = p.ps.lastErr.Error()
+	}
+	return status
+}
+
+// setScrapeStatus sets the scrape status of s from the targets scraping the
+// integration. The scrape is successful if no target failed its last scrape.
+func (s *IntegrationStatus) setScrapeStatus(targets map[string][]*scrape.Target) {
+	for _, tgts := range targets {
+		for _, tgt := range tgts {
+			if tgt.Health() == scrape.HealthUnknown {
+				continue
+			}
+
+			lastScrape := tgt.LastScrape()
+			if s.LastScrape == nil || lastScrape.After(*s.LastScrape) {
+				s.LastScrape = &lastScrape
+			}
+
+			success := tgt.Health() == scrape.HealthGood
+			if s.LastScrapeSuccess == nil || !success {
+				s.LastScrapeSuccess = &success
+			}
+			if err := tgt.LastError(); err != nil && s.LastScrapeError == "" {
+				s.LastScrapeError = err.Error()
+			}
+		}
+	}
+}
+
+// statusHandler returns the status of all integrations.
+func (m *Manager) statusHandler(rw http.ResponseWriter, _ *http.Request) {
+	if err := configapi.WriteResponse(rw, http.StatusOK, m.Status()); err != nil {
+		level.Error(m.logger).Log("msg", "failed to write response", "err", err)
+	}
+}
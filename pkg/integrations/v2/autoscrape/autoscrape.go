@@ -7,6 +7,7 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/pkg/integrations/config"
 	"github.com/grafana/agent/pkg/metrics"
 	"github.com/grafana/agent/pkg/metrics/instance"
 	"github.com/grafana/agent/pkg/server"
@@ -51,6 +52,15 @@ type Config struct {
 
 	RelabelConfigs       []*relabel.Config `yaml:"relabel_configs,omitempty"`        // Relabel the autoscrape job
 	MetricRelabelConfigs []*relabel.Config `yaml:"metric_relabel_configs,omitempty"` // Relabel individual autoscrape metrics
+	MetricAllowlist      []relabel.Regexp  `yaml:"metric_allowlist,omitempty"`       // Only keep metrics whose names match
+	MetricDenylist       []relabel.Regexp  `yaml:"metric_denylist,omitempty"`        // Drop metrics whose names match
+}
+
+// AllMetricRelabelConfigs returns the relabel configs applied to the metrics
+// of the autoscrape job: the metric_allowlist and metric_denylist filters,
+// followed by MetricRelabelConfigs.
+func (c *Config) AllMetricRelabelConfigs() []*relabel.Config {
+	return append(config.FilterRelabelConfigs(c.MetricAllowlist, c.MetricDenylist), c.MetricRelabelConfigs...)
 }
 
 // InstanceStore is used to find instances to send metrics to. It is a subset
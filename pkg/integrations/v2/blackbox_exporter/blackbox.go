@@ -0,0 +1,97 @@
+package blackbox_exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/gorilla/mux"
+	v1 "github.com/grafana/agent/pkg/integrations/blackbox_exporter"
+	"github.com/grafana/agent/pkg/integrations/v2"
+	"github.com/grafana/agent/pkg/integrations/v2/autoscrape"
+	"github.com/grafana/agent/pkg/integrations/v2/metricsutils"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+)
+
+type blackboxHandler struct {
+	cfg     *Config
+	handler http.Handler
+}
+
+func (bh *blackboxHandler) Targets(ep integrations.Endpoint) []*targetgroup.Group {
+	integrationNameValue := model.LabelValue("integrations/" + bh.cfg.Name())
+	key, _ := bh.cfg.InstanceKey("")
+
+	group := &targetgroup.Group{
+		Labels: model.LabelSet{
+			model.InstanceLabel: model.LabelValue(key),
+			model.JobLabel:      integrationNameValue,
+			"agent_hostname":    model.LabelValue(bh.cfg.globals.AgentIdentifier),
+
+			// Meta labels that can be used during SD.
+			"__meta_agent_integration_name":       model.LabelValue(bh.cfg.Name()),
+			"__meta_agent_integration_instance":   model.LabelValue(bh.cfg.Name()),
+			"__meta_agent_integration_autoscrape": model.LabelValue(metricsutils.BoolToString(*bh.cfg.Common.Autoscrape.Enable)),
+		},
+		Source: fmt.Sprintf("%s/%s", bh.cfg.Name(), bh.cfg.Name()),
+	}
+
+	for _, lbl := range bh.cfg.Common.ExtraLabels {
+		group.Labels[model.LabelName(lbl.Name)] = model.LabelValue(lbl.Value)
+	}
+
+	for _, t := range bh.cfg.BlackboxTargets {
+		params := v1.TargetParams(t)
+		group.Targets = append(group.Targets, model.LabelSet{
+			model.AddressLabel:     model.LabelValue(ep.Host),
+			model.MetricsPathLabel: model.LabelValue(path.Join(ep.Prefix, "metrics")),
+			"blackbox_target":      model.LabelValue(t.Name),
+			"__param_target":       model.LabelValue(params.Get("target")),
+			"__param_module":       model.LabelValue(params.Get("module")),
+		})
+	}
+
+	return []*targetgroup.Group{group}
+}
+
+func (bh *blackboxHandler) ScrapeConfigs(sd discovery.Configs) []*autoscrape.ScrapeConfig {
+	if !*bh.cfg.Common.Autoscrape.Enable {
+		return nil
+	}
+	name := bh.cfg.Name()
+	cfg := config.DefaultScrapeConfig
+	cfg.JobName = fmt.Sprintf("%s/%s", name, name)
+	cfg.Scheme = bh.cfg.globals.AgentBaseURL.Scheme
+	cfg.ServiceDiscoveryConfigs = sd
+	cfg.ScrapeInterval = bh.cfg.Common.Autoscrape.ScrapeInterval
+	cfg.ScrapeTimeout = bh.cfg.Common.Autoscrape.ScrapeTimeout
+	cfg.RelabelConfigs = bh.cfg.Common.Autoscrape.RelabelConfigs
+	cfg.MetricRelabelConfigs = bh.cfg.Common.Autoscrape.AllMetricRelabelConfigs()
+
+	return []*autoscrape.ScrapeConfig{{
+		Instance: bh.cfg.Common.Autoscrape.MetricsInstance,
+		Config:   cfg,
+	}}
+}
+
+func (bh *blackboxHandler) Handler(prefix string) (http.Handler, error) {
+	r := mux.NewRouter()
+	r.Handle(path.Join(prefix, "metrics"), bh.handler)
+	return r, nil
+}
+
+// Static typecheck tests
+var (
+	_ integrations.Integration        = (*blackboxHandler)(nil)
+	_ integrations.HTTPIntegration    = (*blackboxHandler)(nil)
+	_ integrations.MetricsIntegration = (*blackboxHandler)(nil)
+)
+
+func (bh *blackboxHandler) RunIntegration(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
@@ -0,0 +1,83 @@
+// Package blackbox_exporter embeds https://github.com/prometheus/blackbox_exporter
+package blackbox_exporter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log"
+	v1 "github.com/grafana/agent/pkg/integrations/blackbox_exporter"
+	integrations_v2 "github.com/grafana/agent/pkg/integrations/v2"
+	"github.com/grafana/agent/pkg/integrations/v2/common"
+	"github.com/grafana/agent/pkg/util"
+)
+
+// DefaultConfig holds the default settings for the blackbox_exporter
+// integration.
+var DefaultConfig = Config{
+	ProbeTimeoutOffset: v1.DefaultConfig.ProbeTimeoutOffset,
+}
+
+// Config configures the blackbox integration.
+type Config struct {
+	BlackboxConfigFile string               `yaml:"config_file,omitempty"`
+	BlackboxConfig     util.RawYAML         `yaml:"blackbox_config,omitempty"`
+	BlackboxTargets    []v1.BlackboxTarget  `yaml:"blackbox_targets"`
+	ProbeTimeoutOffset time.Duration        `yaml:"probe_timeout_offset,omitempty"`
+	Common             common.MetricsConfig `yaml:",inline"`
+
+	globals integrations_v2.Globals
+}
+
+// ApplyDefaults applies the integration's default configuration.
+func (c *Config) ApplyDefaults(globals integrations_v2.Globals) error {
+	c.Common.ApplyDefaults(globals.SubsystemOpts.Metrics.Autoscrape)
+	return nil
+}
+
+// Identifier returns a string that identifies the integration.
+func (c *Config) Identifier(globals integrations_v2.Globals) (string, error) {
+	return c.Name(), nil
+}
+
+// NewIntegration creates a new blackbox integration.
+func (c *Config) NewIntegration(log log.Logger, globals integrations_v2.Globals) (integrations_v2.Integration, error) {
+	modules, err := v1.LoadBlackboxConfig(c.BlackboxConfigFile, c.BlackboxConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := v1.ValidateTargets(c.BlackboxTargets, modules); err != nil {
+		return nil, err
+	}
+	if c.ProbeTimeoutOffset < 0 {
+		return nil, fmt.Errorf("probe_timeout_offset must not be negative")
+	}
+
+	c.globals = globals
+	return &blackboxHandler{
+		cfg:     c,
+		handler: v1.NewHandler(log, modules, c.BlackboxTargets, c.ProbeTimeoutOffset),
+	}, nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for Config.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultConfig
+
+	type plain Config
+	return unmarshal((*plain)(c))
+}
+
+// Name returns the name of the integration.
+func (c *Config) Name() string {
+	return "blackbox"
+}
+
+// InstanceKey returns the hostname:port of the agent.
+func (c *Config) InstanceKey(agentKey string) (string, error) {
+	return agentKey, nil
+}
+
+func init() {
+	integrations_v2.Register(&Config{}, integrations_v2.TypeSingleton)
+}
@@ -52,6 +52,10 @@ type metricsHandlerIntegration struct {
 	targets []handlerTarget
 
 	runFunc func(ctx context.Context) error
+
+	// httpHandler optionally exposes additional endpoints under the prefix
+	// (used from integrationShim).
+	httpHandler func(prefix string) (http.Handler, error)
 }
 
 type handlerTarget struct {
@@ -85,6 +89,15 @@ func (i *metricsHandlerIntegration) RunIntegration(ctx context.Context) error {
 func (i *metricsHandlerIntegration) Handler(prefix string) (http.Handler, error) {
 	r := mux.NewRouter()
 	r.Handle(path.Join(prefix, "metrics"), i.handler)
+
+	if i.httpHandler != nil {
+		h, err := i.httpHandler(prefix)
+		if err != nil {
+			return nil, err
+		} else if h != nil {
+			r.PathPrefix(prefix).Handler(h)
+		}
+	}
 	return r, nil
 }
 
@@ -143,7 +156,7 @@ func (i *metricsHandlerIntegration) ScrapeConfigs(sd discovery.Configs) []*autos
 	cfg.ScrapeInterval = i.common.Autoscrape.ScrapeInterval
 	cfg.ScrapeTimeout = i.common.Autoscrape.ScrapeTimeout
 	cfg.RelabelConfigs = i.common.Autoscrape.RelabelConfigs
-	cfg.MetricRelabelConfigs = i.common.Autoscrape.MetricRelabelConfigs
+	cfg.MetricRelabelConfigs = i.common.Autoscrape.AllMetricRelabelConfigs()
 
 	return []*autoscrape.ScrapeConfig{{
 		Instance: i.common.Autoscrape.MetricsInstance,
@@ -132,6 +132,12 @@ func (s *configShim) NewIntegration(l log.Logger, g v2.Globals) (v2.Integration,
 		}
 	}
 
+	// Integrations may expose HTTP endpoints other than metrics.
+	var httpHandler func(prefix string) (http.Handler, error)
+	if hi, ok := v1Integration.(v1.HTTPIntegration); ok {
+		httpHandler = hi.Handler
+	}
+
 	// Aggregate our converted settings into a v2 integration.
 	return &metricsHandlerIntegration{
 		integrationName: s.Name(),
@@ -142,6 +148,7 @@ func (s *configShim) NewIntegration(l log.Logger, g v2.Globals) (v2.Integration,
 		handler: handler,
 		targets: targets,
 
-		runFunc: runFunc,
+		runFunc:     runFunc,
+		httpHandler: httpHandler,
 	}, nil
 }
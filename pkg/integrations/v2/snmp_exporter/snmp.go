@@ -53,12 +53,19 @@ func (sh *snmpHandler) Targets(ep integrations.Endpoint) []*targetgroup.Group {
 	}
 
 	for _, t := range sh.cfg.SnmpTargets {
-		group.Targets = append(group.Targets, model.LabelSet{
+		labelSet := model.LabelSet{
 			model.AddressLabel:     model.LabelValue(ep.Host),
 			model.MetricsPathLabel: model.LabelValue(path.Join(ep.Prefix, "metrics")),
 			"snmp_target":          model.LabelValue(t.Target),
 			"__param_target":       model.LabelValue(t.Target),
-		})
+		}
+		if t.Module != "" {
+			labelSet["__param_module"] = model.LabelValue(t.Module)
+		}
+		if t.WalkParams != "" {
+			labelSet["__param_walk_params"] = model.LabelValue(t.WalkParams)
+		}
+		group.Targets = append(group.Targets, labelSet)
 	}
 
 	return []*targetgroup.Group{group}
@@ -76,7 +83,7 @@ func (sh *snmpHandler) ScrapeConfigs(sd discovery.Configs) []*autoscrape.ScrapeC
 	cfg.ScrapeInterval = sh.cfg.Common.Autoscrape.ScrapeInterval
 	cfg.ScrapeTimeout = sh.cfg.Common.Autoscrape.ScrapeTimeout
 	cfg.RelabelConfigs = sh.cfg.Common.Autoscrape.RelabelConfigs
-	cfg.MetricRelabelConfigs = sh.cfg.Common.Autoscrape.MetricRelabelConfigs
+	cfg.MetricRelabelConfigs = sh.cfg.Common.Autoscrape.AllMetricRelabelConfigs()
 
 	return []*autoscrape.ScrapeConfig{{
 		Instance: sh.cfg.Common.Autoscrape.MetricsInstance,
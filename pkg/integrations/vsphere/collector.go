@@ -0,0 +1,296 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+const namespace = "vsphere"
+
+var (
+	upDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "up"),
+		"Whether the last scrape of vCenter succeeded.",
+		nil, nil,
+	)
+
+	hostLabels               = []string{"host"}
+	hostConnectedDesc        = hostDesc("connected", "Whether the host is connected to vCenter.")
+	hostPoweredOnDesc        = hostDesc("powered_on", "Whether the host is powered on.")
+	hostCPUUsageDesc         = hostDesc("cpu_usage_mhz", "CPU usage of the host in MHz.")
+	hostCPUCapacityDesc      = hostDesc("cpu_capacity_mhz", "CPU capacity of the host in MHz.")
+	hostMemoryUsageDesc      = hostDesc("memory_usage_bytes", "Memory usage of the host in bytes.")
+	hostMemoryCapacityDesc   = hostDesc("memory_capacity_bytes", "Memory capacity of the host in bytes.")
+	hostUptimeDesc           = hostDesc("uptime_seconds", "Uptime of the host in seconds.")
+	vmLabels                 = []string{"vm", "host"}
+	vmPoweredOnDesc          = vmDesc("powered_on", "Whether the virtual machine is powered on.")
+	vmCPUCountDesc           = vmDesc("cpu_count", "Number of virtual CPUs of the virtual machine.")
+	vmCPUUsageDesc           = vmDesc("cpu_usage_mhz", "CPU usage of the virtual machine in MHz.")
+	vmMemorySizeDesc         = vmDesc("memory_size_bytes", "Configured memory of the virtual machine in bytes.")
+	vmMemoryUsageDesc        = vmDesc("memory_usage_bytes", "Guest memory usage of the virtual machine in bytes.")
+	vmStorageCommittedDesc   = vmDesc("storage_committed_bytes", "Storage committed to the virtual machine in bytes.")
+	vmStorageUncommittedDesc = vmDesc("storage_uncommitted_bytes", "Additional storage which may be used by the virtual machine in bytes.")
+	vmUptimeDesc             = vmDesc("uptime_seconds", "Uptime of the virtual machine in seconds.")
+	datastoreLabels          = []string{"datastore"}
+	datastoreAccessibleDesc  = datastoreDesc("accessible", "Whether the datastore is accessible.")
+	datastoreCapacityDesc    = datastoreDesc("capacity_bytes", "Capacity of the datastore in bytes.")
+	datastoreFreeDesc        = datastoreDesc("free_bytes", "Free space of the datastore in bytes.")
+	datastoreUncommittedDesc = datastoreDesc("uncommitted_bytes", "Additional storage which may be used by the virtual machines on the datastore in bytes.")
+)
+
+func hostDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "host", name), help, hostLabels, nil)
+}
+
+func vmDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "vm", name), help, vmLabels, nil)
+}
+
+func datastoreDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "datastore", name), help, datastoreLabels, nil)
+}
+
+// collector retrieves the hosts, virtual machines and datastores from
+// vCenter on every scrape.
+type collector struct {
+	log      log.Logger
+	url      *url.URL
+	insecure bool
+	timeout  time.Duration
+	filters  filters
+
+	mut    sync.Mutex
+	client *govmomi.Client
+}
+
+func newCollector(l log.Logger, u *url.URL, insecure bool, timeout time.Duration, f filters) *collector {
+	return &collector{
+		log:      l,
+		url:      u,
+		insecure: insecure,
+		timeout:  timeout,
+		filters:  f,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		upDesc,
+		hostConnectedDesc, hostPoweredOnDesc, hostCPUUsageDesc, hostCPUCapacityDesc,
+		hostMemoryUsageDesc, hostMemoryCapacityDesc, hostUptimeDesc,
+		vmPoweredOnDesc, vmCPUCountDesc, vmCPUUsageDesc, vmMemorySizeDesc,
+		vmMemoryUsageDesc, vmStorageCommittedDesc, vmStorageUncommittedDesc, vmUptimeDesc,
+		datastoreAccessibleDesc, datastoreCapacityDesc, datastoreFreeDesc, datastoreUncommittedDesc,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	metrics, err := c.scrape(ctx)
+	if err != nil {
+		level.Error(c.log).Log("msg", "failed to scrape vCenter", "err", err)
+		ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 0)
+
+		// Reconnect on the next scrape in case the connection broke.
+		c.logoutLocked(ctx)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 1)
+	for _, m := range metrics {
+		ch <- m
+	}
+}
+
+// connect returns the client connected to vCenter, logging in again if the
+// session expired. It must be called with c.mut held.
+func (c *collector) connect(ctx context.Context) (*govmomi.Client, error) {
+	if c.client != nil {
+		session, err := c.client.SessionManager.UserSession(ctx)
+		if err == nil && session != nil {
+			return c.client, nil
+		}
+		c.logoutLocked(ctx)
+	}
+
+	client, err := govmomi.NewClient(ctx, c.url, c.insecure)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to vCenter: %w", err)
+	}
+	c.client = client
+	return client, nil
+}
+
+// logout ends the session with vCenter.
+func (c *collector) logout() {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	c.logoutLocked(ctx)
+}
+
+// logoutLocked ends the session with vCenter. It must be called with c.mut
+// held.
+func (c *collector) logoutLocked(ctx context.Context) {
+	if c.client == nil {
+		return
+	}
+	if err := c.client.Logout(ctx); err != nil {
+		level.Debug(c.log).Log("msg", "failed to log out of vCenter", "err", err)
+	}
+	c.client = nil
+}
+
+// scrape retrieves the objects selected by the filters and returns their
+// metrics.
+func (c *collector) scrape(ctx context.Context) ([]prometheus.Metric, error) {
+	client, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m := view.NewManager(client.Client)
+	v, err := m.CreateContainerView(ctx, client.ServiceContent.RootFolder, []string{"HostSystem", "VirtualMachine", "Datastore"}, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create view: %w", err)
+	}
+	defer func() { _ = v.Destroy(ctx) }()
+
+	var res []prometheus.Metric
+
+	// Hosts are also retrieved for the host label of virtual machines.
+	hostNames := make(map[types.ManagedObjectReference]string)
+	if !c.filters.hosts.disabled || !c.filters.vms.disabled {
+		var hosts []mo.HostSystem
+		if err := v.Retrieve(ctx, []string{"HostSystem"}, []string{"name", "summary"}, &hosts); err != nil {
+			return nil, fmt.Errorf("failed to retrieve hosts: %w", err)
+		}
+		for _, host := range hosts {
+			hostNames[host.Reference()] = host.Name
+			if c.filters.hosts.match(host.Name) {
+				res = append(res, hostMetrics(host)...)
+			}
+		}
+	}
+
+	if !c.filters.vms.disabled {
+		var vms []mo.VirtualMachine
+		if err := v.Retrieve(ctx, []string{"VirtualMachine"}, []string{"name", "summary"}, &vms); err != nil {
+			return nil, fmt.Errorf("failed to retrieve virtual machines: %w", err)
+		}
+		for _, vm := range vms {
+			if vm.Summary.Config.Template || !c.filters.vms.match(vm.Name) {
+				continue
+			}
+			var host string
+			if ref := vm.Summary.Runtime.Host; ref != nil {
+				host = hostNames[*ref]
+			}
+			res = append(res, vmMetrics(vm, host)...)
+		}
+	}
+
+	if !c.filters.datastores.disabled {
+		var datastores []mo.Datastore
+		if err := v.Retrieve(ctx, []string{"Datastore"}, []string{"name", "summary"}, &datastores); err != nil {
+			return nil, fmt.Errorf("failed to retrieve datastores: %w", err)
+		}
+		for _, ds := range datastores {
+			if c.filters.datastores.match(ds.Name) {
+				res = append(res, datastoreMetrics(ds)...)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func hostMetrics(host mo.HostSystem) []prometheus.Metric {
+	var (
+		summary = host.Summary
+		res     []prometheus.Metric
+	)
+	gauge := func(desc *prometheus.Desc, value float64) {
+		res = append(res, prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, host.Name))
+	}
+
+	if summary.Runtime != nil {
+		gauge(hostConnectedDesc, boolValue(summary.Runtime.ConnectionState == types.HostSystemConnectionStateConnected))
+		gauge(hostPoweredOnDesc, boolValue(summary.Runtime.PowerState == types.HostSystemPowerStatePoweredOn))
+	}
+	if hw := summary.Hardware; hw != nil {
+		gauge(hostCPUCapacityDesc, float64(hw.CpuMhz)*float64(hw.NumCpuCores))
+		gauge(hostMemoryCapacityDesc, float64(hw.MemorySize))
+	}
+	gauge(hostCPUUsageDesc, float64(summary.QuickStats.OverallCpuUsage))
+	gauge(hostMemoryUsageDesc, float64(summary.QuickStats.OverallMemoryUsage)*1024*1024)
+	gauge(hostUptimeDesc, float64(summary.QuickStats.Uptime))
+	return res
+}
+
+func vmMetrics(vm mo.VirtualMachine, host string) []prometheus.Metric {
+	var (
+		summary = vm.Summary
+		res     []prometheus.Metric
+	)
+	gauge := func(desc *prometheus.Desc, value float64) {
+		res = append(res, prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, vm.Name, host))
+	}
+
+	gauge(vmPoweredOnDesc, boolValue(summary.Runtime.PowerState == types.VirtualMachinePowerStatePoweredOn))
+	gauge(vmCPUCountDesc, float64(summary.Config.NumCpu))
+	gauge(vmCPUUsageDesc, float64(summary.QuickStats.OverallCpuUsage))
+	gauge(vmMemorySizeDesc, float64(summary.Config.MemorySizeMB)*1024*1024)
+	gauge(vmMemoryUsageDesc, float64(summary.QuickStats.GuestMemoryUsage)*1024*1024)
+	gauge(vmUptimeDesc, float64(summary.QuickStats.UptimeSeconds))
+	if storage := summary.Storage; storage != nil {
+		gauge(vmStorageCommittedDesc, float64(storage.Committed))
+		gauge(vmStorageUncommittedDesc, float64(storage.Uncommitted))
+	}
+	return res
+}
+
+func datastoreMetrics(ds mo.Datastore) []prometheus.Metric {
+	var (
+		summary = ds.Summary
+		res     []prometheus.Metric
+	)
+	gauge := func(desc *prometheus.Desc, value float64) {
+		res = append(res, prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, ds.Name))
+	}
+
+	gauge(datastoreAccessibleDesc, boolValue(summary.Accessible))
+	gauge(datastoreCapacityDesc, float64(summary.Capacity))
+	gauge(datastoreFreeDesc, float64(summary.FreeSpace))
+	gauge(datastoreUncommittedDesc, float64(summary.Uncommitted))
+	return res
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,210 @@
+// Package vsphere collects metrics of the hosts, virtual machines and
+// datastores managed by a vCenter server.
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/integrations"
+	integrations_v2 "github.com/grafana/agent/pkg/integrations/v2"
+	"github.com/grafana/agent/pkg/integrations/v2/metricsutils"
+	config_util "github.com/prometheus/common/config"
+)
+
+// DefaultConfig holds the default settings for the vsphere integration.
+var DefaultConfig = Config{
+	RequestTimeout: 30 * time.Second,
+}
+
+// Config controls the vsphere integration.
+type Config struct {
+	// VCenterURL is the URL of the SDK endpoint of vCenter, like
+	// https://vcenter.example.com/sdk.
+	VCenterURL string `yaml:"vcenter_url"`
+
+	Username string             `yaml:"username,omitempty"`
+	Password config_util.Secret `yaml:"password,omitempty"`
+
+	// PasswordFile is a file holding the password. If set, it takes
+	// precedence over Password.
+	PasswordFile string `yaml:"password_file,omitempty"`
+
+	// InsecureSkipVerify disables verifying the certificate of vCenter.
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify,omitempty"`
+
+	// RequestTimeout is the timeout of connecting to vCenter and retrieving
+	// the objects during a scrape.
+	RequestTimeout time.Duration `yaml:"request_timeout,omitempty"`
+
+	Hosts      ObjectFilter `yaml:"hosts,omitempty"`
+	VMs        ObjectFilter `yaml:"vms,omitempty"`
+	Datastores ObjectFilter `yaml:"datastores,omitempty"`
+}
+
+// ObjectFilter selects the objects of a type whose metrics are collected.
+type ObjectFilter struct {
+	// Disabled stops collecting metrics of objects of the type.
+	Disabled bool `yaml:"disabled,omitempty"`
+
+	// Include and Exclude are anchored regexes matched against the names of
+	// objects. Objects are collected if they match any regex of Include, or
+	// Include is empty, and don't match any regex of Exclude.
+	Include []string `yaml:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for Config.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultConfig
+
+	type plain Config
+	return unmarshal((*plain)(c))
+}
+
+// Name returns the name of the integration that this config represents.
+func (c *Config) Name() string {
+	return "vsphere"
+}
+
+// InstanceKey returns the hostname:port of vCenter.
+func (c *Config) InstanceKey(_ string) (string, error) {
+	u, err := url.Parse(c.VCenterURL)
+	if err != nil {
+		return "", fmt.Errorf("could not parse url: %w", err)
+	}
+	return u.Host, nil
+}
+
+// NewIntegration creates a new vsphere integration.
+func (c *Config) NewIntegration(l log.Logger) (integrations.Integration, error) {
+	return New(l, c)
+}
+
+func init() {
+	integrations.RegisterIntegration(&Config{})
+	integrations_v2.RegisterLegacy(&Config{}, integrations_v2.TypeMultiplex, metricsutils.NewNamedShim("vsphere"))
+}
+
+// vcenterURL returns the URL of vCenter with the credentials of the config.
+func (c *Config) vcenterURL() (*url.URL, error) {
+	if c.VCenterURL == "" {
+		return nil, fmt.Errorf("vcenter_url must be set")
+	}
+	u, err := url.Parse(c.VCenterURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse vcenter_url: %w", err)
+	}
+
+	password := string(c.Password)
+	if c.PasswordFile != "" {
+		bb, err := os.ReadFile(c.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read password_file: %w", err)
+		}
+		password = strings.TrimSpace(string(bb))
+	}
+	if c.Username != "" {
+		u.User = url.UserPassword(c.Username, password)
+	}
+	return u, nil
+}
+
+// New creates a new vsphere integration.
+func New(l log.Logger, c *Config) (integrations.Integration, error) {
+	u, err := c.vcenterURL()
+	if err != nil {
+		return nil, err
+	}
+	if c.RequestTimeout <= 0 {
+		return nil, fmt.Errorf("request_timeout must be greater than zero")
+	}
+
+	var f filters
+	if f.hosts, err = compileObjectFilter(c.Hosts); err != nil {
+		return nil, fmt.Errorf("invalid hosts filter: %w", err)
+	}
+	if f.vms, err = compileObjectFilter(c.VMs); err != nil {
+		return nil, fmt.Errorf("invalid vms filter: %w", err)
+	}
+	if f.datastores, err = compileObjectFilter(c.Datastores); err != nil {
+		return nil, fmt.Errorf("invalid datastores filter: %w", err)
+	}
+
+	col := newCollector(l, u, c.InsecureSkipVerify, c.RequestTimeout, f)
+	return integrations.NewCollectorIntegration(
+		c.Name(),
+		integrations.WithCollectors(col),
+		integrations.WithRunner(func(ctx context.Context) error {
+			<-ctx.Done()
+			col.logout()
+			return ctx.Err()
+		}),
+	), nil
+}
+
+// filters are the filters of every type of object.
+type filters struct {
+	hosts, vms, datastores *objectFilter
+}
+
+// objectFilter is a compiled ObjectFilter.
+type objectFilter struct {
+	disabled         bool
+	include, exclude []*regexp.Regexp
+}
+
+func compileObjectFilter(f ObjectFilter) (*objectFilter, error) {
+	var (
+		res = &objectFilter{disabled: f.Disabled}
+		err error
+	)
+	if res.include, err = compileAnchored(f.Include); err != nil {
+		return nil, err
+	}
+	if res.exclude, err = compileAnchored(f.Exclude); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// compileAnchored compiles exprs as regexes which must match the whole
+// string, like the regexes of relabel configs.
+func compileAnchored(exprs []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// match returns true if the metrics of the object called name are collected.
+func (f *objectFilter) match(name string) bool {
+	if f.disabled {
+		return false
+	}
+	for _, re := range f.exclude {
+		if re.MatchString(name) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, re := range f.include {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
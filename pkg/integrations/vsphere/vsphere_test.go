@@ -0,0 +1,103 @@
+package vsphere
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/simulator"
+)
+
+func TestConfig_SecretPassword(t *testing.T) {
+	stringCfg := `
+prometheus:
+  wal_directory: /tmp/agent
+integrations:
+  vsphere:
+    enabled: true
+    vcenter_url: https://vcenter.example.com/sdk
+    username: agent
+    password: secret_password
+`
+	config.CheckSecret(t, stringCfg, "secret_password")
+}
+
+func TestConfig_PasswordFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(file, []byte("from_file\n"), 0600))
+
+	c := DefaultConfig
+	c.VCenterURL = "https://vcenter.example.com/sdk"
+	c.Username = "agent"
+	c.Password = "from_config"
+	c.PasswordFile = file
+
+	u, err := c.vcenterURL()
+	require.NoError(t, err)
+	password, _ := u.User.Password()
+	require.Equal(t, "from_file", password)
+}
+
+func TestObjectFilter(t *testing.T) {
+	f, err := compileObjectFilter(ObjectFilter{
+		Include: []string{"prod-.*"},
+		Exclude: []string{"prod-test"},
+	})
+	require.NoError(t, err)
+	require.True(t, f.match("prod-db"))
+	require.False(t, f.match("prod-test"))
+	require.False(t, f.match("dev-prod-db"))
+
+	f, err = compileObjectFilter(ObjectFilter{})
+	require.NoError(t, err)
+	require.True(t, f.match("anything"))
+
+	f, err = compileObjectFilter(ObjectFilter{Disabled: true})
+	require.NoError(t, err)
+	require.False(t, f.match("anything"))
+}
+
+func TestCollector(t *testing.T) {
+	model := simulator.VPX()
+	defer model.Remove()
+	require.NoError(t, model.Create())
+
+	s := model.Service.NewServer()
+	defer s.Close()
+
+	datastores, err := compileObjectFilter(ObjectFilter{Disabled: true})
+	require.NoError(t, err)
+	vms, err := compileObjectFilter(ObjectFilter{Include: []string{".*_VM0"}})
+	require.NoError(t, err)
+	all, err := compileObjectFilter(ObjectFilter{})
+	require.NoError(t, err)
+
+	col := newCollector(log.NewNopLogger(), s.URL, true, DefaultConfig.RequestTimeout, filters{
+		hosts:      all,
+		vms:        vms,
+		datastores: datastores,
+	})
+	defer col.logout()
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(col)
+
+	fams, err := reg.Gather()
+	require.NoError(t, err)
+
+	series := make(map[string]int)
+	for _, fam := range fams {
+		series[fam.GetName()] = len(fam.GetMetric())
+		if fam.GetName() == "vsphere_up" {
+			require.Equal(t, 1.0, fam.GetMetric()[0].GetGauge().GetValue())
+		}
+	}
+	require.Equal(t, model.Host+model.Cluster*model.ClusterHost, series["vsphere_host_connected"])
+	require.NotZero(t, series["vsphere_vm_powered_on"])
+	require.Less(t, series["vsphere_vm_powered_on"], model.Machine*(model.Host+model.Cluster))
+	require.NotContains(t, series, "vsphere_datastore_capacity_bytes")
+}
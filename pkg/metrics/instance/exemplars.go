@@ -0,0 +1,83 @@
+package instance
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"go.uber.org/atomic"
+)
+
+// exemplarLimiter drops exemplars appended to an instance when scraping
+// exemplars is disabled, or past the maximum number of exemplars of a
+// single scrape. Its limits may be changed while appenders are in use.
+type exemplarLimiter struct {
+	enabled atomic.Bool
+	max     atomic.Int64
+
+	dropped prometheus.Counter
+}
+
+func newExemplarLimiter(cfg Config) *exemplarLimiter {
+	l := &exemplarLimiter{
+		dropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "agent_metrics_exemplars_dropped_total",
+			Help: "Total number of exemplars dropped because of scrape_exemplars or max_exemplars_per_scrape.",
+		}),
+	}
+	l.ApplyConfig(cfg)
+	return l
+}
+
+// ApplyConfig updates the limits from cfg. Appenders which are already in use
+// keep the limits they were created with.
+func (l *exemplarLimiter) ApplyConfig(cfg Config) {
+	l.enabled.Store(cfg.ScrapeExemplars == nil || *cfg.ScrapeExemplars)
+	l.max.Store(int64(cfg.MaxExemplarsPerScrape))
+}
+
+// Wrap returns app with the limits applied to the exemplars appended to it.
+func (l *exemplarLimiter) Wrap(app storage.Appender) storage.Appender {
+	enabled, max := l.enabled.Load(), l.max.Load()
+	if enabled && max == 0 {
+		return app
+	}
+	return &exemplarLimitAppender{Appender: app, enabled: enabled, max: max, dropped: l.dropped}
+}
+
+// Appendable returns app with the limits applied to its appenders.
+func (l *exemplarLimiter) Appendable(app storage.Appendable) storage.Appendable {
+	return exemplarLimitAppendable{app: app, limiter: l}
+}
+
+type exemplarLimitAppendable struct {
+	app     storage.Appendable
+	limiter *exemplarLimiter
+}
+
+func (a exemplarLimitAppendable) Appender(ctx context.Context) storage.Appender {
+	return a.limiter.Wrap(a.app.Appender(ctx))
+}
+
+// exemplarLimitAppender drops exemplars past the limits it was created with.
+// Every appender is used for a single scrape, so its count of exemplars is the
+// count of the scrape.
+type exemplarLimitAppender struct {
+	storage.Appender
+
+	enabled bool
+	max     int64
+	count   int64
+	dropped prometheus.Counter
+}
+
+func (a *exemplarLimitAppender) AppendExemplar(ref storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	if !a.enabled || (a.max > 0 && a.count >= a.max) {
+		a.dropped.Inc()
+		return ref, nil
+	}
+	a.count++
+	return a.Appender.AppendExemplar(ref, l, e)
+}
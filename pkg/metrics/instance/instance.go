@@ -15,6 +15,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/alecthomas/units"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/grafana/agent/pkg/build"
@@ -62,6 +63,7 @@ type Config struct {
 	HostFilterRelabelConfigs []*relabel.Config           `yaml:"host_filter_relabel_configs,omitempty"`
 	ScrapeConfigs            []*config.ScrapeConfig      `yaml:"scrape_configs,omitempty"`
 	RemoteWrite              []*config.RemoteWriteConfig `yaml:"remote_write,omitempty"`
+	TenantRouting            *TenantRoutingConfig        `yaml:"tenant_routing,omitempty"`
 
 	// How frequently the WAL should be truncated.
 	WALTruncateFrequency time.Duration `yaml:"wal_truncate_frequency,omitempty"`
@@ -70,9 +72,31 @@ type Config struct {
 	MinWALTime time.Duration `yaml:"min_wal_time,omitempty"`
 	MaxWALTime time.Duration `yaml:"max_wal_time,omitempty"`
 
+	// Maximum size of the WAL on disk. The WAL is truncated when it grows
+	// larger, even if remote_write hasn't sent its data yet. 0 is unlimited.
+	MaxWALSize units.Base2Bytes `yaml:"max_wal_size,omitempty"`
+
 	RemoteFlushDeadline  time.Duration `yaml:"remote_flush_deadline,omitempty"`
 	WriteStaleOnShutdown bool          `yaml:"write_stale_on_shutdown,omitempty"`
 
+	// Whether exemplars are kept when scraping targets, and the maximum
+	// number of exemplars kept from a single scrape. 0 is unlimited. Unset
+	// ScrapeExemplars keeps exemplars.
+	ScrapeExemplars       *bool `yaml:"scrape_exemplars,omitempty"`
+	MaxExemplarsPerScrape int   `yaml:"max_exemplars_per_scrape,omitempty"`
+
+	// Limits applied to scrape_configs which don't set their own. 0 is
+	// unlimited.
+	SampleLimit uint `yaml:"sample_limit,omitempty"`
+	TargetLimit uint `yaml:"target_limit,omitempty"`
+
+	// Limits of the series of a single scrape of a target. Series exceeding
+	// them are dropped, keeping the rest of the scrape. 0 is unlimited.
+	MaxSeriesPerTarget  int `yaml:"max_series_per_target,omitempty"`
+	MaxLabelsPerSeries  int `yaml:"max_labels_per_series,omitempty"`
+	MaxLabelNameLength  int `yaml:"max_label_name_length,omitempty"`
+	MaxLabelValueLength int `yaml:"max_label_value_length,omitempty"`
+
 	global GlobalConfig `yaml:"-"`
 }
 
@@ -120,6 +144,18 @@ func (c *Config) ApplyDefaults(global GlobalConfig) error {
 		return errors.New("remote_flush_deadline must be greater than 0s")
 	case c.MinWALTime > c.MaxWALTime:
 		return errors.New("min_wal_time must be less than max_wal_time")
+	case c.MaxWALSize < 0:
+		return errors.New("max_wal_size must not be negative")
+	case c.MaxExemplarsPerScrape < 0:
+		return errors.New("max_exemplars_per_scrape must not be negative")
+	case c.MaxSeriesPerTarget < 0:
+		return errors.New("max_series_per_target must not be negative")
+	case c.MaxLabelsPerSeries < 0:
+		return errors.New("max_labels_per_series must not be negative")
+	case c.MaxLabelNameLength < 0:
+		return errors.New("max_label_name_length must not be negative")
+	case c.MaxLabelValueLength < 0:
+		return errors.New("max_label_value_length must not be negative")
 	}
 
 	jobNames := map[string]struct{}{}
@@ -147,19 +183,57 @@ func (c *Config) ApplyDefaults(global GlobalConfig) error {
 			}
 		}
 
+		if sc.SampleLimit == 0 {
+			sc.SampleLimit = c.SampleLimit
+		}
+		if sc.TargetLimit == 0 {
+			sc.TargetLimit = c.TargetLimit
+		}
+
 		if _, exists := jobNames[sc.JobName]; exists {
 			return fmt.Errorf("found multiple scrape configs with job name %q", sc.JobName)
 		}
 		jobNames[sc.JobName] = struct{}{}
 	}
 
-	rwNames := map[string]struct{}{}
-
 	// If the instance remote write is not filled in, then apply the prometheus write config
 	if len(c.RemoteWrite) == 0 {
 		c.RemoteWrite = c.global.RemoteWrite
 	}
-	for _, cfg := range c.RemoteWrite {
+	if err := c.assignRemoteWriteNames(c.RemoteWrite); err != nil {
+		return err
+	}
+
+	if c.TenantRouting != nil {
+		if err := c.TenantRouting.Validate(); err != nil {
+			return err
+		}
+		for _, t := range c.TenantRouting.Tenants {
+			if err := c.assignRemoteWriteNames(t.RemoteWrite); err != nil {
+				return err
+			}
+		}
+
+		// Routes get their own copies of remote_write configs, which must have
+		// unique names as well.
+		rwNames := map[string]struct{}{}
+		for _, cfg := range c.remoteWriteConfigs() {
+			if _, exists := rwNames[cfg.Name]; exists {
+				return fmt.Errorf("found duplicate remote write configs with name %q after applying tenant_routing", cfg.Name)
+			}
+			rwNames[cfg.Name] = struct{}{}
+		}
+	}
+
+	return nil
+}
+
+// assignRemoteWriteNames assigns names to remote write configs without one
+// and checks that the names are unique.
+func (c *Config) assignRemoteWriteNames(rws []*config.RemoteWriteConfig) error {
+	rwNames := map[string]struct{}{}
+
+	for _, cfg := range rws {
 		if cfg == nil {
 			return fmt.Errorf("empty or null remote write config section")
 		}
@@ -239,6 +313,8 @@ type Instance struct {
 	ready atomic.Bool
 
 	hostFilter *HostFilter
+	exemplars  *exemplarLimiter
+	series     *seriesLimiter
 
 	logger log.Logger
 
@@ -270,6 +346,8 @@ func newInstance(cfg Config, reg prometheus.Registerer, logger log.Logger, newWa
 		cfg:        cfg,
 		logger:     logger,
 		hostFilter: NewHostFilter(hostname, cfg.HostFilterRelabelConfigs),
+		exemplars:  newExemplarLimiter(cfg),
+		series:     newSeriesLimiter(cfg),
 
 		reg:    reg,
 		newWal: newWal,
@@ -418,7 +496,7 @@ func (i *Instance) initialize(ctx context.Context, reg prometheus.Registerer, cf
 	i.remoteStore = remote.NewStorage(remoteLogger, reg, i.wal.StartTime, i.wal.Directory(), cfg.RemoteFlushDeadline, i.readyScrapeManager)
 	err = i.remoteStore.ApplyConfig(&config.Config{
 		GlobalConfig:       cfg.global.Prometheus,
-		RemoteWriteConfigs: cfg.RemoteWrite,
+		RemoteWriteConfigs: cfg.remoteWriteConfigs(),
 	})
 	if err != nil {
 		return fmt.Errorf("failed applying config to remote storage: %w", err)
@@ -426,10 +504,17 @@ func (i *Instance) initialize(ctx context.Context, reg prometheus.Registerer, cf
 
 	i.storage = storage.NewFanout(i.logger, i.wal, i.remoteStore)
 
+	if err := reg.Register(i.exemplars.dropped); err != nil {
+		return fmt.Errorf("failed to register exemplar metrics: %w", err)
+	}
+	if err := reg.Register(i.series.dropped); err != nil {
+		return fmt.Errorf("failed to register series limit metrics: %w", err)
+	}
+
 	opts := &scrape.Options{
 		ExtraMetrics: cfg.global.ExtraMetrics,
 	}
-	scrapeManager := newScrapeManager(opts, log.With(i.logger, "component", "scrape manager"), i.storage)
+	scrapeManager := newScrapeManager(opts, log.With(i.logger, "component", "scrape manager"), i.series.Appendable(i.exemplars.Appendable(i.storage)))
 	err = scrapeManager.ApplyConfig(&config.Config{
 		GlobalConfig:  cfg.global.Prometheus,
 		ScrapeConfigs: cfg.ScrapeConfigs,
@@ -502,6 +587,8 @@ func (i *Instance) Update(c Config) (err error) {
 	i.cfg = c
 
 	i.hostFilter.SetRelabels(c.HostFilterRelabelConfigs)
+	i.exemplars.ApplyConfig(c)
+	i.series.ApplyConfig(c)
 	if c.HostFilter {
 		// N.B.: only call PatchSD if HostFilter is enabled since it
 		// mutates what targets will be discovered.
@@ -510,7 +597,7 @@ func (i *Instance) Update(c Config) (err error) {
 
 	err = i.remoteStore.ApplyConfig(&config.Config{
 		GlobalConfig:       c.global.Prometheus,
-		RemoteWriteConfigs: c.RemoteWrite,
+		RemoteWriteConfigs: c.remoteWriteConfigs(),
 	})
 	if err != nil {
 		return fmt.Errorf("error applying new remote_write configs: %w", err)
@@ -568,7 +655,7 @@ func (i *Instance) StorageDirectory() string {
 
 // Appender returns a storage.Appender from the instance's WAL
 func (i *Instance) Appender(ctx context.Context) storage.Appender {
-	return i.wal.Appender(ctx)
+	return i.exemplars.Wrap(i.wal.Appender(ctx))
 }
 
 type discoveryService struct {
@@ -644,16 +731,27 @@ func (i *Instance) newDiscoveryManager(ctx context.Context, cfg *Config) (*disco
 	}, nil
 }
 
+// walSizeCheckInterval is how often the size of the WAL is compared to
+// max_wal_size.
+var walSizeCheckInterval = time.Minute
+
 func (i *Instance) truncateLoop(ctx context.Context, wal walStorage, cfg *Config) {
 	// Track the last timestamp we truncated for to prevent segments from getting
 	// deleted until at least some new data has been sent.
 	var lastTs int64 = math.MinInt64
 
+	truncateTicker := time.NewTicker(cfg.WALTruncateFrequency)
+	defer truncateTicker.Stop()
+	sizeTicker := time.NewTicker(walSizeCheckInterval)
+	defer sizeTicker.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-time.After(cfg.WALTruncateFrequency):
+		case <-sizeTicker.C:
+			i.truncateToMaxSize(wal)
+		case <-truncateTicker.C:
 			// The timestamp ts is used to determine which series are not receiving
 			// samples and may be deleted from the WAL. Their most recent append
 			// timestamp is compared to ts, and if that timestamp is older then ts,
@@ -691,6 +789,31 @@ func (i *Instance) truncateLoop(ctx context.Context, wal walStorage, cfg *Config
 	}
 }
 
+// truncateToMaxSize truncates the WAL if it's larger than max_wal_size. Data
+// older than min_wal_time is truncated even if remote_write hasn't sent it
+// yet.
+func (i *Instance) truncateToMaxSize(wal walStorage) {
+	i.mut.Lock()
+	maxSize, minWALTime := i.cfg.MaxWALSize, i.cfg.MinWALTime
+	i.mut.Unlock()
+	if maxSize <= 0 {
+		return
+	}
+
+	size, err := wal.Size()
+	if err != nil {
+		level.Warn(i.logger).Log("msg", "could not get WAL size", "err", err)
+		return
+	} else if size <= int64(maxSize) {
+		return
+	}
+
+	level.Warn(i.logger).Log("msg", "WAL is larger than max_wal_size, truncating it even if remote_write hasn't sent its data", "size", size, "max_wal_size", maxSize)
+	if err := wal.Truncate(timestamp.FromTime(time.Now().Add(-minWALTime))); err != nil {
+		level.Warn(i.logger).Log("msg", "could not truncate WAL", "err", err)
+	}
+}
+
 // getRemoteWriteTimestamp looks up the last successful remote write timestamp.
 // This is passed to wal.Storage for its truncation. If no remote write sections
 // are configured, getRemoteWriteTimestamp returns the current time.
@@ -698,7 +821,7 @@ func (i *Instance) getRemoteWriteTimestamp() int64 {
 	i.mut.Lock()
 	defer i.mut.Unlock()
 
-	if len(i.cfg.RemoteWrite) == 0 {
+	if len(i.cfg.remoteWriteConfigs()) == 0 {
 		return timestamp.FromTime(time.Now())
 	}
 
@@ -721,6 +844,7 @@ type walStorage interface {
 	WriteStalenessMarkers(remoteTsFunc func() int64) error
 	Appender(context.Context) storage.Appender
 	Truncate(mint int64) error
+	Size() (int64, error)
 
 	Close() error
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http/httptest"
 	"os"
 	"path"
@@ -12,15 +13,18 @@ import (
 	"testing"
 	"time"
 
+	"github.com/alecthomas/units"
 	"github.com/cortexproject/cortex/pkg/util/test"
 	"github.com/go-kit/log"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/config"
 	"github.com/prometheus/prometheus/discovery"
 	"github.com/prometheus/prometheus/model/exemplar"
 	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/stretchr/testify/require"
 )
@@ -47,6 +51,7 @@ remote_write:
 	require.Equal(t, DefaultConfig.WALTruncateFrequency, cfg.WALTruncateFrequency)
 	require.Equal(t, DefaultConfig.RemoteFlushDeadline, cfg.RemoteFlushDeadline)
 	require.Equal(t, DefaultConfig.WriteStaleOnShutdown, cfg.WriteStaleOnShutdown)
+	require.Nil(t, cfg.ScrapeExemplars)
 
 	for _, sc := range cfg.ScrapeConfigs {
 		require.Equal(t, sc.ScrapeInterval, global.Prometheus.ScrapeInterval)
@@ -96,6 +101,21 @@ func TestConfig_ApplyDefaults_Validations(t *testing.T) {
 			func(c *Config) { c.WALTruncateFrequency = 0 },
 			fmt.Errorf("wal_truncate_frequency must be greater than 0s"),
 		},
+		{
+			"negative max wal size",
+			func(c *Config) { c.MaxWALSize = -1 },
+			fmt.Errorf("max_wal_size must not be negative"),
+		},
+		{
+			"negative max exemplars per scrape",
+			func(c *Config) { c.MaxExemplarsPerScrape = -1 },
+			fmt.Errorf("max_exemplars_per_scrape must not be negative"),
+		},
+		{
+			"negative max series per target",
+			func(c *Config) { c.MaxSeriesPerTarget = -1 },
+			fmt.Errorf("max_series_per_target must not be negative"),
+		},
 		{
 			"missing remote flush deadline",
 			func(c *Config) { c.RemoteFlushDeadline = 0 },
@@ -184,6 +204,31 @@ remote_write:
 	require.NotEmpty(t, cfg.RemoteWrite[0].Name)
 }
 
+func TestConfig_ApplyDefaults_ScrapeLimits(t *testing.T) {
+	cfgText := `name: test
+sample_limit: 1000
+target_limit: 10
+scrape_configs:
+  - job_name: default
+    static_configs:
+      - targets: ['127.0.0.1:12345']
+  - job_name: own_limit
+    sample_limit: 50
+    static_configs:
+      - targets: ['127.0.0.1:12345']`
+
+	cfg, err := UnmarshalConfig(strings.NewReader(cfgText))
+	require.NoError(t, err)
+	require.NoError(t, cfg.ApplyDefaults(DefaultGlobalConfig))
+
+	require.Equal(t, uint(1000), cfg.ScrapeConfigs[0].SampleLimit)
+	require.Equal(t, uint(50), cfg.ScrapeConfigs[1].SampleLimit)
+	for _, sc := range cfg.ScrapeConfigs {
+		require.Equal(t, uint(10), sc.TargetLimit)
+		require.Zero(t, sc.LabelLimit)
+	}
+}
+
 func TestInstance_Path(t *testing.T) {
 	scrapeAddr, closeSrv := getTestServer(t)
 	defer closeSrv()
@@ -245,6 +290,135 @@ func TestInstance(t *testing.T) {
 	})
 }
 
+func TestInstance_TruncateToMaxSize(t *testing.T) {
+	tt := []struct {
+		name        string
+		maxSize     units.Base2Bytes
+		size        int64
+		expectTrunc bool
+	}{
+		{"unlimited", 0, 1 << 30, false},
+		{"below limit", units.MiB, 1024, false},
+		{"above limit", units.MiB, 2 << 20, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := DefaultConfig
+			cfg.MaxWALSize = tc.maxSize
+			inst := &Instance{cfg: cfg, logger: log.NewNopLogger()}
+
+			wal := &sizedWalStorage{size: tc.size}
+			inst.truncateToMaxSize(wal)
+			require.Equal(t, tc.expectTrunc, wal.truncated)
+		})
+	}
+}
+
+func TestExemplarLimiter(t *testing.T) {
+	appendExemplars := func(app storage.Appender, n int) {
+		for j := 0; j < n; j++ {
+			_, err := app.AppendExemplar(1, nil, exemplar.Exemplar{Value: float64(j)})
+			require.NoError(t, err)
+		}
+	}
+
+	cfg := DefaultConfig
+	l := newExemplarLimiter(cfg)
+	app := &countingAppender{}
+	require.Same(t, app, l.Wrap(app), "unlimited appenders shouldn't be wrapped")
+
+	cfg.MaxExemplarsPerScrape = 3
+	l.ApplyConfig(cfg)
+	appendExemplars(l.Wrap(app), 5)
+	require.Equal(t, 3, app.exemplars)
+	require.Equal(t, 2.0, testutil.ToFloat64(l.dropped))
+
+	// The limit applies to every scrape separately.
+	appendExemplars(l.Wrap(app), 5)
+	require.Equal(t, 6, app.exemplars)
+
+	scrapeExemplars := false
+	cfg.ScrapeExemplars = &scrapeExemplars
+	l.ApplyConfig(cfg)
+	appendExemplars(l.Wrap(app), 5)
+	require.Equal(t, 6, app.exemplars)
+	require.Equal(t, 9.0, testutil.ToFloat64(l.dropped))
+}
+
+func TestSeriesLimiter(t *testing.T) {
+	cfg := DefaultConfig
+	l := newSeriesLimiter(cfg)
+	app := &countingAppender{}
+	appendable := l.Appendable(appendableFunc(func(context.Context) storage.Appender { return app }))
+	require.Same(t, app, appendable.Appender(context.Background()), "unlimited appenders shouldn't be wrapped")
+
+	cfg.MaxSeriesPerTarget = 2
+	cfg.MaxLabelValueLength = 5
+	l.ApplyConfig(cfg)
+
+	scrape := appendable.Appender(context.Background())
+	for _, lbls := range []labels.Labels{
+		labels.FromStrings("__name__", "a"),
+		labels.FromStrings("__name__", "b", "long", "value_too_long"),
+		labels.FromStrings("__name__", "c"),
+		labels.FromStrings("__name__", "a"),
+		labels.FromStrings("__name__", "d"),
+	} {
+		_, err := scrape.Append(0, lbls, 0, 1)
+		require.NoError(t, err)
+		_, err = scrape.AppendExemplar(0, lbls, exemplar.Exemplar{Value: 1})
+		require.NoError(t, err)
+	}
+	require.Equal(t, 3, app.samples, "series a twice and series c should be kept")
+	require.Equal(t, 3, app.exemplars, "exemplars of dropped series should be dropped")
+	require.Equal(t, 1.0, testutil.ToFloat64(l.dropped.WithLabelValues(dropReasonLabelValueLength)))
+	require.Equal(t, 1.0, testutil.ToFloat64(l.dropped.WithLabelValues(dropReasonSeriesLimit)))
+
+	// Staleness markers don't count towards the limit.
+	_, err := scrape.Append(0, labels.FromStrings("__name__", "e"), 0, math.Float64frombits(value.StaleNaN))
+	require.NoError(t, err)
+	require.Equal(t, 4, app.samples)
+
+	// The limit applies to every scrape separately.
+	scrape = appendable.Appender(context.Background())
+	_, err = scrape.Append(0, labels.FromStrings("__name__", "d"), 0, 1)
+	require.NoError(t, err)
+	require.Equal(t, 5, app.samples)
+}
+
+type appendableFunc func(context.Context) storage.Appender
+
+func (f appendableFunc) Appender(ctx context.Context) storage.Appender { return f(ctx) }
+
+type countingAppender struct {
+	storage.Appender
+	samples   int
+	exemplars int
+}
+
+func (a *countingAppender) Append(ref storage.SeriesRef, _ labels.Labels, _ int64, _ float64) (storage.SeriesRef, error) {
+	a.samples++
+	return ref, nil
+}
+
+func (a *countingAppender) AppendExemplar(ref storage.SeriesRef, _ labels.Labels, _ exemplar.Exemplar) (storage.SeriesRef, error) {
+	a.exemplars++
+	return ref, nil
+}
+
+type sizedWalStorage struct {
+	mockWalStorage
+	size      int64
+	truncated bool
+}
+
+func (s *sizedWalStorage) Size() (int64, error) { return s.size, nil }
+func (s *sizedWalStorage) Truncate(int64) error {
+	s.truncated = true
+	return nil
+}
+
 // TestInstance_Recreate ensures that creating an instance with the same name twice
 // does not cause any duplicate metrics registration that leads to a panic.
 func TestInstance_Recreate(t *testing.T) {
@@ -357,6 +531,7 @@ func (s *mockWalStorage) StartTime() (int64, error)                  { return 0,
 func (s *mockWalStorage) WriteStalenessMarkers(f func() int64) error { return nil }
 func (s *mockWalStorage) Close() error                               { return nil }
 func (s *mockWalStorage) Truncate(mint int64) error                  { return nil }
+func (s *mockWalStorage) Size() (int64, error)                       { return 0, nil }
 
 func (s *mockWalStorage) Appender(context.Context) storage.Appender {
 	return &mockAppender{s: s}
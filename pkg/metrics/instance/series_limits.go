@@ -0,0 +1,155 @@
+package instance
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/storage"
+	"go.uber.org/atomic"
+)
+
+// Reasons of series dropped by a seriesLimiter.
+const (
+	dropReasonSeriesLimit      = "series_limit"
+	dropReasonLabelLimit       = "label_limit"
+	dropReasonLabelNameLength  = "label_name_length"
+	dropReasonLabelValueLength = "label_value_length"
+)
+
+// seriesLimits are the limits of the series of a single scrape. 0 is
+// unlimited.
+type seriesLimits struct {
+	maxSeries, maxLabels, maxLabelNameLength, maxLabelValueLength int64
+}
+
+func (l seriesLimits) unlimited() bool {
+	return l == seriesLimits{}
+}
+
+// seriesLimiter drops the series of a scrape which exceed the series limits
+// of an instance, keeping the other series of the scrape. Its limits may be
+// changed while appenders are in use.
+type seriesLimiter struct {
+	maxSeries           atomic.Int64
+	maxLabels           atomic.Int64
+	maxLabelNameLength  atomic.Int64
+	maxLabelValueLength atomic.Int64
+
+	dropped *prometheus.CounterVec
+}
+
+func newSeriesLimiter(cfg Config) *seriesLimiter {
+	l := &seriesLimiter{
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_metrics_series_dropped_total",
+			Help: "Total number of samples dropped from scrapes because their series exceeded the series limits of the instance.",
+		}, []string{"reason"}),
+	}
+	for _, reason := range []string{dropReasonSeriesLimit, dropReasonLabelLimit, dropReasonLabelNameLength, dropReasonLabelValueLength} {
+		l.dropped.WithLabelValues(reason)
+	}
+	l.ApplyConfig(cfg)
+	return l
+}
+
+// ApplyConfig updates the limits from cfg. Appenders which are already in use
+// keep the limits they were created with.
+func (l *seriesLimiter) ApplyConfig(cfg Config) {
+	l.maxSeries.Store(int64(cfg.MaxSeriesPerTarget))
+	l.maxLabels.Store(int64(cfg.MaxLabelsPerSeries))
+	l.maxLabelNameLength.Store(int64(cfg.MaxLabelNameLength))
+	l.maxLabelValueLength.Store(int64(cfg.MaxLabelValueLength))
+}
+
+// Appendable returns app with the limits applied to its appenders. Every
+// appender of app must be used for a single scrape of a target.
+func (l *seriesLimiter) Appendable(app storage.Appendable) storage.Appendable {
+	return seriesLimitAppendable{app: app, limiter: l}
+}
+
+type seriesLimitAppendable struct {
+	app     storage.Appendable
+	limiter *seriesLimiter
+}
+
+func (a seriesLimitAppendable) Appender(ctx context.Context) storage.Appender {
+	app := a.app.Appender(ctx)
+	limits := seriesLimits{
+		maxSeries:           a.limiter.maxSeries.Load(),
+		maxLabels:           a.limiter.maxLabels.Load(),
+		maxLabelNameLength:  a.limiter.maxLabelNameLength.Load(),
+		maxLabelValueLength: a.limiter.maxLabelValueLength.Load(),
+	}
+	if limits.unlimited() {
+		return app
+	}
+	return &seriesLimitAppender{
+		Appender: app,
+		limits:   limits,
+		dropped:  a.limiter.dropped,
+		kept:     make(map[uint64]struct{}),
+		skipped:  make(map[uint64]struct{}),
+	}
+}
+
+// seriesLimitAppender drops series past the limits it was created with. Since
+// every appender is used for a single scrape, its series are the series of
+// the scraped target.
+type seriesLimitAppender struct {
+	storage.Appender
+
+	limits  seriesLimits
+	dropped *prometheus.CounterVec
+
+	// kept and skipped hold the hashes of the labels of the series which were
+	// kept and dropped, so exemplars of dropped series are dropped as well.
+	kept, skipped map[uint64]struct{}
+}
+
+func (a *seriesLimitAppender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	hash := l.Hash()
+	if _, ok := a.kept[hash]; !ok {
+		// Staleness markers of series which disappeared from the target are
+		// always kept, so they don't count towards the limit.
+		if value.IsStaleNaN(v) {
+			return a.Appender.Append(ref, l, t, v)
+		}
+		if reason := a.exceeds(l); reason != "" {
+			a.skipped[hash] = struct{}{}
+			a.dropped.WithLabelValues(reason).Inc()
+			return 0, nil
+		}
+		a.kept[hash] = struct{}{}
+	}
+	return a.Appender.Append(ref, l, t, v)
+}
+
+// exceeds returns the reason for dropping the new series l, or an empty
+// string if l may be appended.
+func (a *seriesLimitAppender) exceeds(l labels.Labels) string {
+	if a.limits.maxSeries > 0 && int64(len(a.kept)) >= a.limits.maxSeries {
+		return dropReasonSeriesLimit
+	}
+	if a.limits.maxLabels > 0 && int64(len(l)) > a.limits.maxLabels {
+		return dropReasonLabelLimit
+	}
+	for _, lbl := range l {
+		if a.limits.maxLabelNameLength > 0 && int64(len(lbl.Name)) > a.limits.maxLabelNameLength {
+			return dropReasonLabelNameLength
+		}
+		if a.limits.maxLabelValueLength > 0 && int64(len(lbl.Value)) > a.limits.maxLabelValueLength {
+			return dropReasonLabelValueLength
+		}
+	}
+	return ""
+}
+
+func (a *seriesLimitAppender) AppendExemplar(ref storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	if _, ok := a.skipped[l.Hash()]; ok {
+		return 0, nil
+	}
+	return a.Appender.AppendExemplar(ref, l, e)
+}
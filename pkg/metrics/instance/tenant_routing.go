@@ -0,0 +1,142 @@
+package instance
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/model/relabel"
+)
+
+// orgIDHeader is the header identifying the tenant of remote_write requests.
+const orgIDHeader = "X-Scope-OrgID"
+
+// TenantRoutingConfig routes series to remote_write endpoints by the value of
+// a label identifying their tenant. The label is removed from routed series
+// before they are sent.
+type TenantRoutingConfig struct {
+	// Label holding the tenant of series.
+	Label string `yaml:"label,omitempty"`
+
+	// Tenants are the routes of the tenants. Series without a route are sent
+	// to the remote_write endpoints of the instance.
+	Tenants []TenantRoute `yaml:"tenants,omitempty"`
+}
+
+// TenantRoute sends the series of a tenant to its remote_write endpoints.
+type TenantRoute struct {
+	// Tenant is the value of the tenant label of the series of the route.
+	Tenant string `yaml:"tenant"`
+
+	// OrgID is sent as the X-Scope-OrgID header. Defaults to Tenant.
+	OrgID string `yaml:"org_id,omitempty"`
+
+	// RemoteWrite are the endpoints of the tenant. Defaults to the
+	// remote_write endpoints of the instance.
+	RemoteWrite []*config.RemoteWriteConfig `yaml:"remote_write,omitempty"`
+}
+
+// Validate returns an error if c is invalid.
+func (c *TenantRoutingConfig) Validate() error {
+	if len(c.Tenants) == 0 {
+		return nil
+	}
+	if !model.LabelName(c.Label).IsValid() {
+		return fmt.Errorf("tenant_routing label %q is not a valid label name", c.Label)
+	}
+
+	tenants := make(map[string]struct{}, len(c.Tenants))
+	for _, t := range c.Tenants {
+		if t.Tenant == "" {
+			return fmt.Errorf("tenant_routing tenants must not be empty")
+		}
+		if _, exists := tenants[t.Tenant]; exists {
+			return fmt.Errorf("found multiple tenant_routing routes for tenant %q", t.Tenant)
+		}
+		tenants[t.Tenant] = struct{}{}
+
+		for _, rw := range t.RemoteWrite {
+			if rw == nil {
+				return fmt.Errorf("empty or null remote write config section in tenant_routing route for tenant %q", t.Tenant)
+			}
+		}
+	}
+	return nil
+}
+
+// remoteWriteConfigs returns the remote_write configs of c with tenant
+// routing applied. Every route gets its own copy of its endpoints, which only
+// keeps the series of its tenant, while the endpoints of the instance drop
+// them.
+func (c *Config) remoteWriteConfigs() []*config.RemoteWriteConfig {
+	r := c.TenantRouting
+	if r == nil || len(r.Tenants) == 0 {
+		return c.RemoteWrite
+	}
+
+	var (
+		res     []*config.RemoteWriteConfig
+		tenants = make([]string, 0, len(r.Tenants))
+	)
+	for _, t := range r.Tenants {
+		tenants = append(tenants, regexp.QuoteMeta(t.Tenant))
+	}
+
+	dropRouted := tenantRelabelConfig(r.Label, strings.Join(tenants, "|"), relabel.Drop)
+	for _, rw := range c.RemoteWrite {
+		res = append(res, withWriteRelabelConfigs(rw, dropRouted))
+	}
+
+	dropLabel := &relabel.Config{
+		Action:    relabel.LabelDrop,
+		Regex:     relabel.MustNewRegexp(regexp.QuoteMeta(r.Label)),
+		Separator: ";",
+	}
+	for _, t := range r.Tenants {
+		endpoints := t.RemoteWrite
+		if len(endpoints) == 0 {
+			endpoints = c.RemoteWrite
+		}
+		orgID := t.OrgID
+		if orgID == "" {
+			orgID = t.Tenant
+		}
+
+		keepTenant := tenantRelabelConfig(r.Label, regexp.QuoteMeta(t.Tenant), relabel.Keep)
+		for _, rw := range endpoints {
+			routed := withWriteRelabelConfigs(rw, keepTenant, dropLabel)
+			routed.Name = rw.Name + "-" + t.Tenant
+
+			routed.Headers = make(map[string]string, len(rw.Headers)+1)
+			for k, v := range rw.Headers {
+				routed.Headers[k] = v
+			}
+			routed.Headers[orgIDHeader] = orgID
+
+			res = append(res, routed)
+		}
+	}
+	return res
+}
+
+// tenantRelabelConfig returns a relabel config applying action to series with
+// a value of label matching regex.
+func tenantRelabelConfig(label, regex string, action relabel.Action) *relabel.Config {
+	return &relabel.Config{
+		SourceLabels: model.LabelNames{model.LabelName(label)},
+		Separator:    ";",
+		Regex:        relabel.MustNewRegexp(regex),
+		Replacement:  "$1",
+		Action:       action,
+	}
+}
+
+// withWriteRelabelConfigs returns a copy of rw with rcs prepended to its
+// write_relabel_configs.
+func withWriteRelabelConfigs(rw *config.RemoteWriteConfig, rcs ...*relabel.Config) *config.RemoteWriteConfig {
+	cp := *rw
+	cp.WriteRelabelConfigs = append(append([]*relabel.Config{}, rcs...), rw.WriteRelabelConfigs...)
+	return &cp
+}
@@ -0,0 +1,128 @@
+package instance
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_TenantRouting(t *testing.T) {
+	cfgText := `name: test
+remote_write:
+  - name: default
+    url: http://localhost:9009/api/prom/push
+tenant_routing:
+  label: tenant
+  tenants:
+    - tenant: team-a
+    - tenant: team-b
+      org_id: b
+      remote_write:
+        - name: team-b
+          url: http://localhost:9010/api/prom/push
+          headers:
+            X-Custom: value`
+
+	cfg, err := UnmarshalConfig(strings.NewReader(cfgText))
+	require.NoError(t, err)
+	require.NoError(t, cfg.ApplyDefaults(DefaultGlobalConfig))
+
+	rws := cfg.remoteWriteConfigs()
+	require.Len(t, rws, 3)
+
+	type expect struct {
+		name    string
+		url     string
+		orgID   string
+		headers int
+	}
+	var actual []expect
+	for _, rw := range rws {
+		actual = append(actual, expect{rw.Name, rw.URL.String(), rw.Headers[orgIDHeader], len(rw.Headers)})
+	}
+	require.Equal(t, []expect{
+		{"default", "http://localhost:9009/api/prom/push", "", 0},
+		{"default-team-a", "http://localhost:9009/api/prom/push", "team-a", 1},
+		{"team-b-team-b", "http://localhost:9010/api/prom/push", "b", 2},
+	}, actual)
+
+	// The config of the instance must not be modified.
+	require.Empty(t, cfg.RemoteWrite[0].Headers)
+	require.Empty(t, cfg.RemoteWrite[0].WriteRelabelConfigs)
+
+	var (
+		unrouted = labels.FromStrings("__name__", "up", "tenant", "team-c")
+		teamA    = labels.FromStrings("__name__", "up", "tenant", "team-a")
+		teamB    = labels.FromStrings("__name__", "up", "tenant", "team-b")
+	)
+	process := func(idx int, lbls labels.Labels) labels.Labels {
+		return relabel.Process(lbls, rws[idx].WriteRelabelConfigs...)
+	}
+
+	require.Equal(t, unrouted, process(0, unrouted))
+	require.Nil(t, process(0, teamA))
+	require.Nil(t, process(0, teamB))
+
+	require.Nil(t, process(1, unrouted))
+	require.Equal(t, labels.FromStrings("__name__", "up"), process(1, teamA))
+	require.Nil(t, process(1, teamB))
+
+	require.Nil(t, process(2, teamA))
+	require.Equal(t, labels.FromStrings("__name__", "up"), process(2, teamB))
+}
+
+func TestConfig_TenantRouting_Validation(t *testing.T) {
+	tt := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name: "invalid label",
+			config: `
+  label: "invalid-label"
+  tenants: [{tenant: a}]`,
+			err: `tenant_routing label "invalid-label" is not a valid label name`,
+		},
+		{
+			name: "empty tenant",
+			config: `
+  label: tenant
+  tenants: [{org_id: a}]`,
+			err: "tenant_routing tenants must not be empty",
+		},
+		{
+			name: "duplicate tenant",
+			config: `
+  label: tenant
+  tenants: [{tenant: a}, {tenant: a}]`,
+			err: `found multiple tenant_routing routes for tenant "a"`,
+		},
+		{
+			name: "duplicate routed names",
+			config: `
+  label: tenant
+  tenants: [{tenant: a}]`,
+			err: `found duplicate remote write configs with name "default-a" after applying tenant_routing`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfgText := `name: test
+remote_write:
+  - name: default
+    url: http://localhost:9009/api/prom/push
+  - name: default-a
+    url: http://localhost:9011/api/prom/push
+tenant_routing:` + tc.config
+
+			cfg, err := UnmarshalConfig(strings.NewReader(cfgText))
+			require.NoError(t, err)
+			require.EqualError(t, cfg.ApplyDefaults(DefaultGlobalConfig), tc.err)
+		})
+	}
+}
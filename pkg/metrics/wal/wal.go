@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 	"unicode/utf8"
@@ -36,9 +38,10 @@ type storageMetrics struct {
 	totalRemovedSeries     prometheus.Counter
 	totalAppendedSamples   prometheus.Counter
 	totalAppendedExemplars prometheus.Counter
+	sizeBytes              prometheus.GaugeFunc
 }
 
-func newStorageMetrics(r prometheus.Registerer) *storageMetrics {
+func newStorageMetrics(r prometheus.Registerer, size func() (int64, error)) *storageMetrics {
 	m := storageMetrics{r: r}
 	m.numActiveSeries = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "agent_wal_storage_active_series",
@@ -70,6 +73,17 @@ func newStorageMetrics(r prometheus.Registerer) *storageMetrics {
 		Help: "Total number of exemplars appended to the WAL",
 	})
 
+	m.sizeBytes = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "agent_wal_storage_size_bytes",
+		Help: "Current size of the WAL on disk in bytes",
+	}, func() float64 {
+		n, err := size()
+		if err != nil {
+			return math.NaN()
+		}
+		return float64(n)
+	})
+
 	if r != nil {
 		r.MustRegister(
 			m.numActiveSeries,
@@ -78,6 +92,7 @@ func newStorageMetrics(r prometheus.Registerer) *storageMetrics {
 			m.totalRemovedSeries,
 			m.totalAppendedSamples,
 			m.totalAppendedExemplars,
+			m.sizeBytes,
 		)
 	}
 
@@ -95,6 +110,7 @@ func (m *storageMetrics) Unregister() {
 		m.totalRemovedSeries,
 		m.totalAppendedSamples,
 		m.totalAppendedExemplars,
+		m.sizeBytes,
 	}
 	for _, c := range cs {
 		m.r.Unregister(c)
@@ -143,9 +159,9 @@ func NewStorage(logger log.Logger, registerer prometheus.Registerer, path string
 		logger:  logger,
 		deleted: map[chunks.HeadSeriesRef]int{},
 		series:  newStripeSeries(),
-		metrics: newStorageMetrics(registerer),
 		ref:     atomic.NewUint64(0),
 	}
+	storage.metrics = newStorageMetrics(registerer, storage.Size)
 
 	storage.bufPool.New = func() interface{} {
 		b := make([]byte, 0, 1024)
@@ -369,6 +385,25 @@ func (w *Storage) Appender(_ context.Context) storage.Appender {
 	return w.appenderPool.Get().(storage.Appender)
 }
 
+// Size returns the size of the files of the WAL storage on disk in bytes.
+func (w *Storage) Size() (int64, error) {
+	var size int64
+	err := filepath.Walk(w.path, func(_ string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// Files may be removed by a concurrent truncation.
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !fi.IsDir() {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
 // StartTime always returns 0, nil. It is implemented for compatibility with
 // Prometheus, but is unused in the agent.
 func (*Storage) StartTime() (int64, error) {
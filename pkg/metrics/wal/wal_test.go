@@ -307,6 +307,29 @@ func TestStorage_Truncate(t *testing.T) {
 	require.Equal(t, expectedExemplars, actualExemplars)
 }
 
+func TestStorage_Size(t *testing.T) {
+	walDir := t.TempDir()
+
+	s, err := NewStorage(log.NewNopLogger(), nil, walDir)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, s.Close())
+	}()
+
+	before, err := s.Size()
+	require.NoError(t, err)
+
+	app := s.Appender(context.Background())
+	for _, metric := range buildSeries([]string{"foo", "bar", "baz", "blerg"}) {
+		metric.Write(t, app)
+	}
+	require.NoError(t, app.Commit())
+
+	after, err := s.Size()
+	require.NoError(t, err)
+	require.Greater(t, after, before)
+}
+
 func TestStorage_WriteStalenessMarkers(t *testing.T) {
 	walDir, err := ioutil.TempDir(os.TempDir(), "wal")
 	require.NoError(t, err)
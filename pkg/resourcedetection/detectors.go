@@ -0,0 +1,197 @@
+package resourcedetection
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+)
+
+type detectFunc func(ctx context.Context, client *http.Client) (Attributes, error)
+
+var detectors = map[string]detectFunc{
+	DetectorEnv:        detectEnv,
+	DetectorHost:       detectHost,
+	DetectorKubernetes: detectKubernetes,
+	DetectorEC2:        detectEC2,
+	DetectorGCP:        detectGCP,
+}
+
+// envResourceAttributes is the standard OpenTelemetry environment variable
+// used for passing resource attributes.
+const envResourceAttributes = "OTEL_RESOURCE_ATTRIBUTES"
+
+// detectEnv reads attributes from OTEL_RESOURCE_ATTRIBUTES, which holds a
+// comma-separated list of key=value pairs.
+func detectEnv(_ context.Context, _ *http.Client) (Attributes, error) {
+	return parseEnvAttributes(os.Getenv(envResourceAttributes))
+}
+
+func parseEnvAttributes(raw string) (Attributes, error) {
+	res := make(Attributes)
+	if strings.TrimSpace(raw) == "" {
+		return res, nil
+	}
+	for _, pair := range strings.Split(raw, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid %s entry %q", envResourceAttributes, pair)
+		}
+		res[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return res, nil
+}
+
+func detectHost(_ context.Context, _ *http.Client) (Attributes, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	return Attributes{
+		"host.name": hostname,
+		"host.arch": runtime.GOARCH,
+		"os.type":   runtime.GOOS,
+	}, nil
+}
+
+// serviceAccountNamespaceFile is where Kubernetes mounts the namespace of
+// the running pod.
+var serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// detectKubernetes detects attributes from the Kubernetes downward API. The
+// pod should expose POD_NAME, POD_NAMESPACE, and NODE_NAME environment
+// variables; otherwise, values are inferred where possible.
+func detectKubernetes(_ context.Context, _ *http.Client) (Attributes, error) {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return nil, fmt.Errorf("not running in Kubernetes")
+	}
+
+	res := Attributes{
+		"k8s.pod.name":       os.Getenv("POD_NAME"),
+		"k8s.namespace.name": os.Getenv("POD_NAMESPACE"),
+		"k8s.node.name":      os.Getenv("NODE_NAME"),
+	}
+	if res["k8s.pod.name"] == "" {
+		// Pod hostnames default to the pod name.
+		res["k8s.pod.name"] = os.Getenv("HOSTNAME")
+	}
+	if res["k8s.namespace.name"] == "" {
+		if bb, err := ioutil.ReadFile(serviceAccountNamespaceFile); err == nil {
+			res["k8s.namespace.name"] = strings.TrimSpace(string(bb))
+		}
+	}
+	return res, nil
+}
+
+var (
+	ec2MetadataEndpoint = "http://169.254.169.254"
+	gcpMetadataEndpoint = "http://metadata.google.internal"
+)
+
+// detectEC2 detects attributes from the EC2 instance metadata service using
+// IMDSv2.
+func detectEC2(ctx context.Context, client *http.Client) (Attributes, error) {
+	tokenReq, err := http.NewRequestWithContext(ctx, http.MethodPut, ec2MetadataEndpoint+"/latest/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	tokenReq.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	token, err := doMetadataRequest(client, tokenReq)
+	if err != nil {
+		return nil, fmt.Errorf("fetching IMDS token: %w", err)
+	}
+
+	docReq, err := http.NewRequestWithContext(ctx, http.MethodGet, ec2MetadataEndpoint+"/latest/dynamic/instance-identity/document", nil)
+	if err != nil {
+		return nil, err
+	}
+	docReq.Header.Set("X-aws-ec2-metadata-token", token)
+	rawDoc, err := doMetadataRequest(client, docReq)
+	if err != nil {
+		return nil, fmt.Errorf("fetching instance identity document: %w", err)
+	}
+
+	var doc struct {
+		AccountID        string `json:"accountId"`
+		AvailabilityZone string `json:"availabilityZone"`
+		Region           string `json:"region"`
+		InstanceID       string `json:"instanceId"`
+		InstanceType     string `json:"instanceType"`
+		ImageID          string `json:"imageId"`
+	}
+	if err := json.Unmarshal([]byte(rawDoc), &doc); err != nil {
+		return nil, fmt.Errorf("decoding instance identity document: %w", err)
+	}
+
+	return Attributes{
+		"cloud.provider":          "aws",
+		"cloud.platform":          "aws_ec2",
+		"cloud.account.id":        doc.AccountID,
+		"cloud.region":            doc.Region,
+		"cloud.availability_zone": doc.AvailabilityZone,
+		"host.id":                 doc.InstanceID,
+		"host.type":               doc.InstanceType,
+		"host.image.id":           doc.ImageID,
+	}, nil
+}
+
+// detectGCP detects attributes from the GCE metadata server.
+func detectGCP(ctx context.Context, client *http.Client) (Attributes, error) {
+	get := func(p string) (string, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpMetadataEndpoint+"/computeMetadata/v1/"+p, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+		return doMetadataRequest(client, req)
+	}
+
+	project, err := get("project/project-id")
+	if err != nil {
+		return nil, fmt.Errorf("fetching project id: %w", err)
+	}
+	res := Attributes{
+		"cloud.provider":   "gcp",
+		"cloud.platform":   "gcp_compute_engine",
+		"cloud.account.id": project,
+	}
+
+	if id, err := get("instance/id"); err == nil {
+		res["host.id"] = id
+	}
+	if name, err := get("instance/name"); err == nil {
+		res["host.name"] = name
+	}
+	// The zone is returned as projects/<number>/zones/<zone>.
+	if zone, err := get("instance/zone"); err == nil {
+		zone = path.Base(zone)
+		res["cloud.availability_zone"] = zone
+		if idx := strings.LastIndex(zone, "-"); idx > 0 {
+			res["cloud.region"] = zone[:idx]
+		}
+	}
+	return res, nil
+}
+
+func doMetadataRequest(client *http.Client, req *http.Request) (string, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	bb, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return strings.TrimSpace(string(bb)), nil
+}
@@ -0,0 +1,181 @@
+// Package resourcedetection discovers attributes about the environment the
+// agent is running in (cloud metadata, Kubernetes downward API, host facts) so
+// that they can be applied consistently to metrics, logs, and traces.
+package resourcedetection
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+)
+
+// Names of supported detectors.
+const (
+	DetectorEnv        = "env"
+	DetectorHost       = "host"
+	DetectorKubernetes = "kubernetes"
+	DetectorEC2        = "ec2"
+	DetectorGCP        = "gcp"
+)
+
+// DefaultConfig holds default settings for resource detection.
+var DefaultConfig = Config{
+	Enabled:   false,
+	Detectors: []string{DetectorEnv, DetectorHost},
+	Timeout:   5 * time.Second,
+	Override:  false,
+}
+
+// Config controls resource detection.
+type Config struct {
+	// Enabled enables resource detection. When disabled, no detectors are run
+	// and no attributes are applied to any pipeline.
+	Enabled bool `yaml:"enabled,omitempty"`
+
+	// Detectors is the ordered list of detectors to run. Attributes from later
+	// detectors take precedence over attributes from earlier detectors.
+	Detectors []string `yaml:"detectors,omitempty"`
+
+	// Timeout bounds how long all detectors may take combined.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// Override controls whether detected attributes replace values which were
+	// explicitly configured in a pipeline.
+	Override bool `yaml:"override,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultConfig
+
+	type plain Config
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+// Validate returns an error if c is invalid.
+func (c *Config) Validate() error {
+	if c.Timeout <= 0 {
+		return fmt.Errorf("resource detection timeout must be greater than 0")
+	}
+	for _, name := range c.Detectors {
+		if _, ok := detectors[name]; !ok {
+			return fmt.Errorf("unknown resource detector %q", name)
+		}
+	}
+	return nil
+}
+
+// Attributes is a set of detected resource attributes. Keys follow the
+// OpenTelemetry semantic conventions (e.g., cloud.region, k8s.pod.name).
+type Attributes map[string]string
+
+// Labels converts a to a set of Prometheus-compatible labels. Characters
+// which are not valid in label names are replaced by underscores.
+func (a Attributes) Labels() model.LabelSet {
+	ls := make(model.LabelSet, len(a))
+	for k, v := range a {
+		ls[model.LabelName(LabelName(k))] = model.LabelValue(v)
+	}
+	return ls
+}
+
+// Keys returns the sorted set of keys in a.
+func (a Attributes) Keys() []string {
+	keys := make([]string, 0, len(a))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// LabelName converts an attribute key into a valid Prometheus label name.
+func LabelName(key string) string {
+	var sb strings.Builder
+	for i, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			sb.WriteRune(r)
+		case r >= '0' && r <= '9' && i > 0:
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune('_')
+		}
+	}
+	return sb.String()
+}
+
+// Detect runs all detectors configured in cfg and merges their attributes.
+// Detectors which fail are logged to l and skipped, so the attributes of the
+// other detectors are still returned.
+func Detect(ctx context.Context, l log.Logger, cfg Config) Attributes {
+	res := make(Attributes)
+	if !cfg.Enabled {
+		return res
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	client := &http.Client{Timeout: cfg.Timeout}
+
+	for _, name := range cfg.Detectors {
+		detect, ok := detectors[name]
+		if !ok {
+			level.Warn(l).Log("msg", "skipping unknown resource detector", "detector", name)
+			continue
+		}
+		attrs, err := detect(ctx, client)
+		if err != nil {
+			level.Warn(l).Log("msg", "skipping failed resource detector", "detector", name, "err", err)
+			continue
+		}
+		for k, v := range attrs {
+			if v == "" {
+				continue
+			}
+			res[k] = v
+		}
+	}
+
+	return res
+}
+
+// Cache keeps the attributes found by Detect, so the detectors are run once
+// rather than whenever the config file is reloaded. The detectors are only
+// run again when the set of configured detectors changes.
+type Cache struct {
+	mut      sync.Mutex
+	detected bool
+	key      string
+	attrs    Attributes
+}
+
+// Detect returns the cached attributes of cfg, running the detectors of cfg
+// if they haven't been run yet.
+func (c *Cache) Detect(ctx context.Context, l log.Logger, cfg Config) Attributes {
+	if !cfg.Enabled {
+		return make(Attributes)
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	key := strings.Join(cfg.Detectors, ",")
+	if !c.detected || c.key != key {
+		c.attrs = Detect(ctx, l, cfg)
+		c.key, c.detected = key, true
+	}
+	return c.attrs
+}
@@ -0,0 +1,130 @@
+package resourcedetection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig_Unmarshal(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		var cfg Config
+		require.NoError(t, yaml.UnmarshalStrict([]byte(`enabled: true`), &cfg))
+		require.Equal(t, []string{DetectorEnv, DetectorHost}, cfg.Detectors)
+		require.Equal(t, DefaultConfig.Timeout, cfg.Timeout)
+	})
+
+	t.Run("unknown detector", func(t *testing.T) {
+		var cfg Config
+		err := yaml.UnmarshalStrict([]byte(`detectors: [azure]`), &cfg)
+		require.EqualError(t, err, `unknown resource detector "azure"`)
+	})
+}
+
+func TestParseEnvAttributes(t *testing.T) {
+	attrs, err := parseEnvAttributes("service.namespace=payments, deployment.environment=prod")
+	require.NoError(t, err)
+	require.Equal(t, Attributes{
+		"service.namespace":      "payments",
+		"deployment.environment": "prod",
+	}, attrs)
+
+	_, err = parseEnvAttributes("invalid")
+	require.Error(t, err)
+}
+
+func TestAttributes_Labels(t *testing.T) {
+	attrs := Attributes{
+		"k8s.pod.name": "agent-0",
+		"1st.key":      "value",
+	}
+	require.Equal(t, model.LabelSet{
+		"k8s_pod_name": "agent-0",
+		"_st_key":      "value",
+	}, attrs.Labels())
+}
+
+func TestDetect_GCP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing header", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/computeMetadata/v1/project/project-id":
+			_, _ = w.Write([]byte("my-project"))
+		case "/computeMetadata/v1/instance/zone":
+			_, _ = w.Write([]byte("projects/1234/zones/us-central1-a"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	oldEndpoint := gcpMetadataEndpoint
+	gcpMetadataEndpoint = srv.URL
+	defer func() { gcpMetadataEndpoint = oldEndpoint }()
+
+	cfg := DefaultConfig
+	cfg.Enabled = true
+	cfg.Detectors = []string{DetectorGCP}
+
+	attrs := Detect(context.Background(), log.NewNopLogger(), cfg)
+	require.Equal(t, Attributes{
+		"cloud.provider":          "gcp",
+		"cloud.platform":          "gcp_compute_engine",
+		"cloud.account.id":        "my-project",
+		"cloud.availability_zone": "us-central1-a",
+		"cloud.region":            "us-central1",
+	}, attrs)
+}
+
+func TestDetect_Disabled(t *testing.T) {
+	attrs := Detect(context.Background(), log.NewNopLogger(), DefaultConfig)
+	require.Empty(t, attrs)
+}
+
+func TestDetect_SkipsFailedDetectors(t *testing.T) {
+	t.Setenv(envResourceAttributes, "team=payments")
+
+	cfg := DefaultConfig
+	cfg.Enabled = true
+	cfg.Detectors = []string{DetectorEnv, "failing"}
+
+	detectors["failing"] = func(context.Context, *http.Client) (Attributes, error) {
+		return nil, errors.New("metadata endpoint unavailable")
+	}
+	defer delete(detectors, "failing")
+
+	attrs := Detect(context.Background(), log.NewNopLogger(), cfg)
+	require.Equal(t, Attributes{"team": "payments"}, attrs)
+}
+
+func TestCache(t *testing.T) {
+	var runs int
+	detectors["counting"] = func(context.Context, *http.Client) (Attributes, error) {
+		runs++
+		return Attributes{"run": fmt.Sprint(runs)}, nil
+	}
+	defer delete(detectors, "counting")
+
+	cfg := DefaultConfig
+	cfg.Enabled = true
+	cfg.Detectors = []string{"counting"}
+
+	var c Cache
+	require.Equal(t, Attributes{"run": "1"}, c.Detect(context.Background(), log.NewNopLogger(), cfg))
+	require.Equal(t, Attributes{"run": "1"}, c.Detect(context.Background(), log.NewNopLogger(), cfg))
+
+	// Changing the detectors runs them again.
+	cfg.Detectors = []string{DetectorHost, "counting"}
+	require.Equal(t, "2", c.Detect(context.Background(), log.NewNopLogger(), cfg)["run"])
+}
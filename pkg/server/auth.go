@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/common/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AuthConfig holds dynamic configuration options for authenticating requests
+// to the HTTP server. Requests are allowed without credentials if neither
+// users nor a bearer token are configured.
+type AuthConfig struct {
+	// BasicAuthUsers maps usernames to the bcrypt hashes of their passwords.
+	BasicAuthUsers map[string]config.Secret `yaml:"basic_auth_users,omitempty"`
+
+	// BearerTokenFile is the path to a file holding a token which requests
+	// may pass as a bearer token.
+	BearerTokenFile string `yaml:"bearer_token_file,omitempty"`
+}
+
+// Enabled returns whether requests must be authenticated.
+func (c AuthConfig) Enabled() bool {
+	return len(c.BasicAuthUsers) > 0 || c.BearerTokenFile != ""
+}
+
+// unauthenticatedPaths are the paths of the HTTP server which are served
+// without credentials, so that liveness and readiness probes such as the ones
+// of Kubernetes work when authentication is enabled.
+var unauthenticatedPaths = map[string]struct{}{
+	"/-/ready":   {},
+	"/-/healthy": {},
+}
+
+// authMiddleware rejects requests to the HTTP server which aren't
+// authenticated. Requests over in-memory connections and requests to
+// unauthenticatedPaths are always allowed. authMiddleware supports
+// dynamically updating its settings.
+type authMiddleware struct {
+	mut   sync.RWMutex
+	users map[string][]byte
+	token []byte
+
+	// verified caches the credentials of successful basic auth checks, since
+	// bcrypt is intentionally slow.
+	verifiedMut sync.Mutex
+	verified    map[[sha256.Size]byte]struct{}
+}
+
+// newAuthMiddleware creates and configures a new authMiddleware.
+func newAuthMiddleware(c AuthConfig) (*authMiddleware, error) {
+	var m authMiddleware
+	return &m, m.ApplyConfig(c)
+}
+
+// ApplyConfig updates the settings used to authenticate requests. The bearer
+// token file is read when ApplyConfig is called.
+func (m *authMiddleware) ApplyConfig(c AuthConfig) error {
+	users := make(map[string][]byte, len(c.BasicAuthUsers))
+	for user, hash := range c.BasicAuthUsers {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return fmt.Errorf("invalid password hash for user %q: %w", user, err)
+		}
+		users[user] = []byte(hash)
+	}
+
+	var token []byte
+	if c.BearerTokenFile != "" {
+		bb, err := os.ReadFile(c.BearerTokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read bearer token file: %w", err)
+		}
+		token = []byte(strings.TrimSpace(string(bb)))
+		if len(token) == 0 {
+			return fmt.Errorf("bearer token file %s is empty", c.BearerTokenFile)
+		}
+	}
+
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.users = users
+	m.token = token
+
+	m.verifiedMut.Lock()
+	defer m.verifiedMut.Unlock()
+	m.verified = make(map[[sha256.Size]byte]struct{})
+	return nil
+}
+
+// Wrap implements middleware.Interface.
+func (m *authMiddleware) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.mut.RLock()
+		users, token := m.users, m.token
+		m.mut.RUnlock()
+
+		_, unauthenticated := unauthenticatedPaths[r.URL.Path]
+		if unauthenticated || isInMemoryRequest(r) || m.authenticated(r, users, token) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(users) > 0 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Grafana Agent"`)
+		}
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	})
+}
+
+// authenticated returns whether r passes the credentials of one of users or
+// token, or if no credentials are required.
+func (m *authMiddleware) authenticated(r *http.Request, users map[string][]byte, token []byte) bool {
+	if len(users) == 0 && len(token) == 0 {
+		return true
+	}
+
+	if len(token) > 0 {
+		auth := r.Header.Get("Authorization")
+		if strings.HasPrefix(auth, "Bearer ") && subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), token) == 1 {
+			return true
+		}
+	}
+
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	hash, ok := users[user]
+	if !ok {
+		return false
+	}
+
+	key := sha256.Sum256(append(append([]byte(user+":"+password), 0), hash...))
+	m.verifiedMut.Lock()
+	_, verified := m.verified[key]
+	m.verifiedMut.Unlock()
+	if verified {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+		return false
+	}
+	m.verifiedMut.Lock()
+	m.verified[key] = struct{}{}
+	m.verifiedMut.Unlock()
+	return true
+}
+
+type inMemoryConnKey struct{}
+
+// inMemoryListener wraps the connections of an in-memory listener so they
+// can be told apart from network connections.
+type inMemoryListener struct{ net.Listener }
+
+type inMemoryConn struct{ net.Conn }
+
+func (l inMemoryListener) Accept() (net.Conn, error) {
+	nc, err := l.Listener.Accept()
+	if err != nil {
+		return nc, err
+	}
+	return inMemoryConn{nc}, nil
+}
+
+// connContext marks the context of requests over in-memory connections. It
+// is used as http.Server.ConnContext.
+func connContext(ctx context.Context, c net.Conn) context.Context {
+	if _, ok := c.(inMemoryConn); ok {
+		return context.WithValue(ctx, inMemoryConnKey{}, true)
+	}
+	return ctx
+}
+
+// isInMemoryRequest returns whether r was made over an in-memory connection.
+func isInMemoryRequest(r *http.Request) bool {
+	v, _ := r.Context().Value(inMemoryConnKey{}).(bool)
+	return v
+}
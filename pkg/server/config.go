@@ -32,7 +32,8 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 
 // HTTPConfig holds dynamic configuration options for the HTTP server.
 type HTTPConfig struct {
-	TLSConfig TLSConfig `yaml:"http_tls_config"`
+	TLSConfig  TLSConfig  `yaml:"http_tls_config"`
+	AuthConfig AuthConfig `yaml:"http_auth_config,omitempty"`
 }
 
 // GRPCConfig holds dynamic configuration options for the gRPC server.
@@ -1,6 +1,9 @@
 package server
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
 	"sync"
 
 	"github.com/go-kit/log"
@@ -18,6 +21,9 @@ type Logger struct {
 	mut sync.RWMutex
 	l   log.Logger
 
+	// cfg is the config l was made from.
+	cfg Config
+
 	// makeLogger will default to defaultLogger. It's a struct
 	// member to make testing work properly.
 	makeLogger func(*Config) (log.Logger, error)
@@ -34,8 +40,13 @@ func NewLoggerFromLevel(lvl logging.Level, fmt logging.Format) *Logger {
 	if err != nil {
 		panic(err)
 	}
+	cfg := DefaultConfig
+	cfg.LogLevel = lvl
+	cfg.LogFormat = fmt
 	return &Logger{
-		l: logger,
+		l:          logger,
+		cfg:        cfg,
+		makeLogger: defaultLogger,
 	}
 }
 
@@ -58,9 +69,77 @@ func (l *Logger) ApplyConfig(cfg *Config) error {
 	}
 
 	l.l = newLogger
+	l.cfg = *cfg
+	return nil
+}
+
+// Level returns the current log level and format of the logger.
+func (l *Logger) Level() (logging.Level, logging.Format) {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+	return l.cfg.LogLevel, l.cfg.LogFormat
+}
+
+// SetLevel changes the log level and format of the logger, until the next
+// call to ApplyConfig.
+func (l *Logger) SetLevel(lvl logging.Level, format logging.Format) error {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	cfg := l.cfg
+	cfg.LogLevel = lvl
+	cfg.LogFormat = format
+	newLogger, err := l.makeLogger(&cfg)
+	if err != nil {
+		return err
+	}
+
+	l.l = newLogger
+	l.cfg = cfg
 	return nil
 }
 
+// logLevelBody is the body of requests to and responses from
+// LogLevelHandler.
+type logLevelBody struct {
+	Level  string `json:"level,omitempty"`
+	Format string `json:"format,omitempty"`
+}
+
+// LogLevelHandler returns the log level and format of the logger for GET
+// requests, and changes them for PUT requests. The body of PUT requests is a
+// JSON object with optional level and format fields.
+func (l *Logger) LogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	lvl, format := l.Level()
+
+	if r.Method == http.MethodPut {
+		var body logLevelBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request body: %s", err), http.StatusBadRequest)
+			return
+		}
+		if body.Level != "" {
+			if err := lvl.Set(body.Level); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if body.Format != "" {
+			if err := format.Set(body.Format); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if err := l.SetLevel(lvl, format); err != nil {
+			http.Error(w, fmt.Sprintf("failed to update logger: %s", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(logLevelBody{Level: lvl.String(), Format: format.String()})
+}
+
 func defaultLogger(cfg *Config) (log.Logger, error) {
 	return makeDefaultLogger(cfg.LogLevel, cfg.LogFormat)
 }
@@ -2,6 +2,9 @@ package server
 
 import (
 	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/go-kit/log"
@@ -55,3 +58,48 @@ log_format: json`
 		"msg":"this should appear"
 	}`, buf.String())
 }
+
+func TestLogger_LogLevelHandler(t *testing.T) {
+	var buf bytes.Buffer
+	makeLogger := func(cfg *Config) (log.Logger, error) {
+		l := log.NewLogfmtLogger(log.NewSyncWriter(&buf))
+		if cfg.LogFormat.String() == "json" {
+			l = log.NewJSONLogger(log.NewSyncWriter(&buf))
+		}
+		return level.NewFilter(l, cfg.LogLevel.Gokit), nil
+	}
+	l := newLogger(&DefaultConfig, makeLogger)
+
+	do := func(method, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		l.LogLevelHandler(rec, httptest.NewRequest(method, "/-/loglevel", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := do(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"level":"info","format":"logfmt"}`, rec.Body.String())
+
+	level.Debug(l).Log("msg", "this should not appear")
+	require.Empty(t, buf.String())
+
+	rec = do(http.MethodPut, `{"level":"debug","format":"json"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"level":"debug","format":"json"}`, rec.Body.String())
+
+	level.Debug(l).Log("msg", "this should appear")
+	require.JSONEq(t, `{"level":"debug","msg":"this should appear"}`, buf.String())
+
+	// Fields which aren't set are kept.
+	rec = do(http.MethodPut, `{"level":"warn"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"level":"warn","format":"json"}`, rec.Body.String())
+
+	rec = do(http.MethodPut, `{"level":"verbose"}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// ApplyConfig resets the level and format to the config.
+	require.NoError(t, l.ApplyConfig(&DefaultConfig))
+	rec = do(http.MethodGet, "")
+	require.JSONEq(t, `{"level":"info","format":"logfmt"}`, rec.Body.String())
+}
@@ -54,6 +54,7 @@ type Server struct {
 
 	updateHTTPTLS func(TLSConfig) error
 	updateGRPCTLS func(TLSConfig) error
+	auth          *authMiddleware
 
 	HTTP       *mux.Router
 	HTTPServer *http.Server
@@ -198,9 +199,14 @@ func New(l log.Logger, r prometheus.Registerer, g prometheus.Gatherer, cfg Confi
 		"http_tls_enabled", opts.HTTP.UseTLS, "grpc_tls_enabled", opts.GRPC.UseTLS,
 	)
 
+	auth, err := newAuthMiddleware(cfg.HTTP.AuthConfig)
+	if err != nil {
+		return nil, fmt.Errorf("generating HTTP auth config: %w", err)
+	}
+
 	// Build servers
 	grpcServer := newGRPCServer(wrappedLogger, &opts.GRPC, m)
-	httpServer, router, err := newHTTPServer(wrappedLogger, g, &opts, m)
+	httpServer, router, err := newHTTPServer(wrappedLogger, g, &opts, m, auth)
 	if err != nil {
 		return nil, err
 	}
@@ -230,6 +236,7 @@ func New(l log.Logger, r prometheus.Registerer, g prometheus.Gatherer, cfg Confi
 
 		updateHTTPTLS: updateHTTPTLS,
 		updateGRPCTLS: updateGRPCTLS,
+		auth:          auth,
 
 		HTTP:        router,
 		HTTPServer:  httpServer,
@@ -310,7 +317,7 @@ func newGRPCServer(l logging.Interface, opts *GRPCFlags, m *metrics) *grpc.Serve
 	return grpc.NewServer(grpcOptions...)
 }
 
-func newHTTPServer(l logging.Interface, g prometheus.Gatherer, opts *Flags, m *metrics) (*http.Server, *mux.Router, error) {
+func newHTTPServer(l logging.Interface, g prometheus.Gatherer, opts *Flags, m *metrics, auth *authMiddleware) (*http.Server, *mux.Router, error) {
 	router := mux.NewRouter()
 	if opts.RegisterInstrumentation && g != nil {
 		router.Handle("/metrics", promhttp.HandlerFor(g, promhttp.HandlerOpts{
@@ -344,6 +351,7 @@ func newHTTPServer(l logging.Interface, g prometheus.Gatherer, opts *Flags, m *m
 			ResponseBodySize: m.sentMessageSize,
 			InflightRequests: m.inflightRequests,
 		},
+		auth,
 	}
 
 	httpServer := &http.Server{
@@ -351,6 +359,7 @@ func newHTTPServer(l logging.Interface, g prometheus.Gatherer, opts *Flags, m *m
 		WriteTimeout: opts.HTTP.WriteTimeout,
 		IdleTimeout:  opts.HTTP.IdleTimeout,
 		Handler:      middleware.Merge(httpMiddleware...).Wrap(router),
+		ConnContext:  connContext,
 	}
 
 	return httpServer, router, nil
@@ -384,6 +393,9 @@ func (s *Server) ApplyConfig(cfg Config) error {
 			return fmt.Errorf("updating gRPC TLS settings: %w", err)
 		}
 	}
+	if err := s.auth.ApplyConfig(cfg.HTTP.AuthConfig); err != nil {
+		return fmt.Errorf("updating HTTP auth settings: %w", err)
+	}
 
 	if !reflect.DeepEqual(s.opts, cfg.Flags) {
 		return fmt.Errorf("cannot dynamically update values for deprecated YAML fields")
@@ -408,7 +420,7 @@ func (s *Server) Run(ctx context.Context) error {
 
 	httpListeners := []net.Listener{
 		s.httpListener,
-		s.httpMemListener,
+		inMemoryListener{s.httpMemListener},
 	}
 	for i := range httpListeners {
 		listener := httpListeners[i]
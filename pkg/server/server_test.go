@@ -6,10 +6,15 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/go-kit/log"
+	"github.com/prometheus/common/config"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
@@ -121,6 +126,102 @@ func TestServer_TLS(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestServer_Auth(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("token\n"), 0600))
+
+	cfg := newTestConfig()
+	cfg.HTTP.AuthConfig = AuthConfig{
+		BasicAuthUsers:  map[string]config.Secret{"agent": config.Secret(hash)},
+		BearerTokenFile: tokenFile,
+	}
+	srv := runExampleServer(t, cfg)
+
+	get := func(t *testing.T, cli *http.Client, host string, setAuth func(r *http.Request)) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/testing", host), nil)
+		require.NoError(t, err)
+		if setAuth != nil {
+			setAuth(req)
+		}
+		resp, err := cli.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp
+	}
+	host := srv.HTTPAddress().String()
+
+	tt := []struct {
+		name    string
+		setAuth func(r *http.Request)
+		expect  int
+	}{
+		{"no credentials", nil, http.StatusUnauthorized},
+		{"basic auth", func(r *http.Request) { r.SetBasicAuth("agent", "secret") }, http.StatusOK},
+		{"wrong password", func(r *http.Request) { r.SetBasicAuth("agent", "wrong") }, http.StatusUnauthorized},
+		{"unknown user", func(r *http.Request) { r.SetBasicAuth("other", "secret") }, http.StatusUnauthorized},
+		{"bearer token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer token") }, http.StatusOK},
+		{"wrong bearer token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }, http.StatusUnauthorized},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := get(t, http.DefaultClient, host, tc.setAuth)
+			require.Equal(t, tc.expect, resp.StatusCode)
+			if tc.expect == http.StatusUnauthorized {
+				require.Equal(t, `Basic realm="Grafana Agent"`, resp.Header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	t.Run("in-memory", func(t *testing.T) {
+		cli := &http.Client{Transport: &http.Transport{DialContext: srv.DialContext}}
+		resp := get(t, cli, cfg.Flags.HTTP.InMemoryAddr, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		cfg := cfg
+		cfg.HTTP.AuthConfig = AuthConfig{}
+		require.NoError(t, srv.ApplyConfig(cfg))
+
+		resp := get(t, http.DefaultClient, host, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("invalid hash", func(t *testing.T) {
+		cfg := cfg
+		cfg.HTTP.AuthConfig = AuthConfig{
+			BasicAuthUsers: map[string]config.Secret{"agent": "secret"},
+		}
+		require.Error(t, srv.ApplyConfig(cfg))
+	})
+}
+
+// TestAuthMiddleware_HealthEndpoints ensures that the readiness and health
+// endpoints don't require credentials.
+func TestAuthMiddleware_HealthEndpoints(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	auth, err := newAuthMiddleware(AuthConfig{
+		BasicAuthUsers: map[string]config.Secret{"agent": config.Secret(hash)},
+	})
+	require.NoError(t, err)
+
+	handler := auth.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for path, expect := range map[string]int{
+		"/-/ready":   http.StatusOK,
+		"/-/healthy": http.StatusOK,
+		"/-/reload":  http.StatusUnauthorized,
+		"/metrics":   http.StatusUnauthorized,
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, expect, rec.Code, path)
+	}
+}
+
 // TestRunReturnsError validates that Run exits with an error when the
 // HTTP/GRPC servers stop unexpectedly.
 func TestRunReturnsError(t *testing.T) {
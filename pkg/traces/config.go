@@ -40,6 +40,7 @@ import (
 	"github.com/grafana/agent/pkg/traces/promsdprocessor"
 	"github.com/grafana/agent/pkg/traces/pushreceiver"
 	"github.com/grafana/agent/pkg/traces/remotewriteexporter"
+	"github.com/grafana/agent/pkg/traces/resourceattributesprocessor"
 	"github.com/grafana/agent/pkg/traces/servicegraphprocessor"
 	"github.com/grafana/agent/pkg/util"
 )
@@ -115,6 +116,12 @@ type InstanceConfig struct {
 	// Attributes: https://github.com/open-telemetry/opentelemetry-collector/blob/7d7ae2eb34b5d387627875c498d7f43619f37ee3/processor/attributesprocessor/config.go#L30
 	Attributes map[string]interface{} `yaml:"attributes,omitempty"`
 
+	// ResourceAttributes are added to the resource of every span unless the
+	// span's resource already sets them and OverrideResourceAttributes is
+	// false.
+	ResourceAttributes         map[string]string `yaml:"resource_attributes,omitempty"`
+	OverrideResourceAttributes bool              `yaml:"override_resource_attributes,omitempty"`
+
 	// prom service discovery config
 	ScrapeConfigs   []interface{} `yaml:"scrape_configs,omitempty"`
 	OperationType   string        `yaml:"prom_sd_operation_type,omitempty"`
@@ -562,6 +569,14 @@ func (c *InstanceConfig) otelConfig() (*config.Config, error) {
 		}
 	}
 
+	if len(c.ResourceAttributes) > 0 {
+		processors[resourceattributesprocessor.TypeStr] = map[string]interface{}{
+			"attributes": c.ResourceAttributes,
+			"override":   c.OverrideResourceAttributes,
+		}
+		processorNames = append(processorNames, resourceattributesprocessor.TypeStr)
+	}
+
 	if c.Attributes != nil {
 		processors["attributes"] = c.Attributes
 		processorNames = append(processorNames, "attributes")
@@ -769,6 +784,7 @@ func tracingFactories() (component.Factories, error) {
 		batchprocessor.NewFactory(),
 		attributesprocessor.NewFactory(),
 		promsdprocessor.NewFactory(),
+		resourceattributesprocessor.NewFactory(),
 		spanmetricsprocessor.NewFactory(),
 		automaticloggingprocessor.NewFactory(),
 		tailsamplingprocessor.NewFactory(),
@@ -791,12 +807,13 @@ func tracingFactories() (component.Factories, error) {
 // sets: before and after load balancing
 func orderProcessors(processors []string, splitPipelines bool) [][]string {
 	order := map[string]int{
-		"attributes":        0,
-		"spanmetrics":       1,
-		"service_graphs":    2,
-		"tail_sampling":     3,
-		"automatic_logging": 4,
-		"batch":             5,
+		"resource_attributes": 0,
+		"attributes":          1,
+		"spanmetrics":         2,
+		"service_graphs":      3,
+		"tail_sampling":       4,
+		"automatic_logging":   5,
+		"batch":               6,
 	}
 
 	sort.Slice(processors, func(i, j int) bool {
@@ -0,0 +1,48 @@
+package resourceattributesprocessor
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer"
+)
+
+// TypeStr is the unique identifier for the resource attributes processor.
+const TypeStr = "resource_attributes"
+
+// Config holds the configuration for the resource attributes processor.
+type Config struct {
+	config.ProcessorSettings `mapstructure:",squash"`
+
+	// Attributes to add to the resource of every span. Attributes which are
+	// already set on a resource are left unchanged unless Override is set.
+	Attributes map[string]string `mapstructure:"attributes"`
+	Override   bool              `mapstructure:"override"`
+}
+
+// NewFactory returns a new factory for the resource attributes processor.
+func NewFactory() component.ProcessorFactory {
+	return component.NewProcessorFactory(
+		TypeStr,
+		createDefaultConfig,
+		component.WithTracesProcessor(createTraceProcessor),
+	)
+}
+
+func createDefaultConfig() config.Processor {
+	return &Config{
+		ProcessorSettings: config.NewProcessorSettings(config.NewComponentIDWithName(TypeStr, TypeStr)),
+	}
+}
+
+func createTraceProcessor(
+	_ context.Context,
+	cp component.ProcessorCreateSettings,
+	cfg config.Processor,
+	nextConsumer consumer.Traces,
+) (component.TracesProcessor, error) {
+
+	oCfg := cfg.(*Config)
+	return newTraceProcessor(nextConsumer, oCfg.Attributes, oCfg.Override)
+}
@@ -0,0 +1,54 @@
+// Package resourceattributesprocessor adds a static set of attributes to the
+// resource of every span passing through a traces pipeline.
+package resourceattributesprocessor
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenterror"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+type processor struct {
+	nextConsumer consumer.Traces
+	attributes   map[string]string
+	override     bool
+}
+
+func newTraceProcessor(nextConsumer consumer.Traces, attributes map[string]string, override bool) (component.TracesProcessor, error) {
+	if nextConsumer == nil {
+		return nil, componenterror.ErrNilNextConsumer
+	}
+	return &processor{
+		nextConsumer: nextConsumer,
+		attributes:   attributes,
+		override:     override,
+	}, nil
+}
+
+func (p *processor) ConsumeTraces(ctx context.Context, td pdata.Traces) error {
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		attrs := rss.At(i).Resource().Attributes()
+		for k, v := range p.attributes {
+			if p.override {
+				attrs.UpsertString(k, v)
+			} else {
+				attrs.InsertString(k, v)
+			}
+		}
+	}
+	return p.nextConsumer.ConsumeTraces(ctx, td)
+}
+
+func (p *processor) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: true}
+}
+
+// Start is invoked during service startup.
+func (p *processor) Start(_ context.Context, _ component.Host) error { return nil }
+
+// Shutdown is invoked during service shutdown.
+func (p *processor) Shutdown(context.Context) error { return nil }
@@ -0,0 +1,52 @@
+package resourceattributesprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+func TestConsumeTraces(t *testing.T) {
+	tests := []struct {
+		name     string
+		override bool
+		expected map[string]string
+	}{
+		{
+			name:     "keep existing",
+			override: false,
+			expected: map[string]string{"service.name": "app", "team": "observability", "env": "prod"},
+		},
+		{
+			name:     "override",
+			override: true,
+			expected: map[string]string{"service.name": "app", "team": "payments", "env": "prod"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sink := new(consumertest.TracesSink)
+			p, err := newTraceProcessor(sink, map[string]string{"team": "payments", "env": "prod"}, tc.override)
+			require.NoError(t, err)
+
+			td := pdata.NewTraces()
+			attrs := td.ResourceSpans().AppendEmpty().Resource().Attributes()
+			attrs.InsertString("service.name", "app")
+			attrs.InsertString("team", "observability")
+
+			require.NoError(t, p.ConsumeTraces(context.Background(), td))
+			require.Len(t, sink.AllTraces(), 1)
+
+			actual := map[string]string{}
+			sink.AllTraces()[0].ResourceSpans().At(0).Resource().Attributes().Range(func(k string, v pdata.AttributeValue) bool {
+				actual[k] = v.StringVal()
+				return true
+			})
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+// Package textmetrics converts metrics in the Prometheus text exposition
+// format into metrics that can be sent by a prometheus.Collector.
+package textmetrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Parse parses r in the Prometheus text exposition format. The returned
+// metrics are sorted by name.
+func Parse(r io.Reader) ([]prometheus.Metric, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort the families so the metrics are collected in a stable order.
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var res []prometheus.Metric
+	for _, name := range names {
+		mf := families[name]
+		for _, m := range mf.GetMetric() {
+			metric, err := constMetric(mf, m)
+			if err != nil {
+				return nil, fmt.Errorf("invalid metric %s: %w", name, err)
+			}
+			res = append(res, metric)
+		}
+	}
+	return res, nil
+}
+
+// constMetric converts m of the family mf into a prometheus.Metric.
+func constMetric(mf *dto.MetricFamily, m *dto.Metric) (prometheus.Metric, error) {
+	var (
+		labelNames  = make([]string, 0, len(m.GetLabel()))
+		labelValues = make([]string, 0, len(m.GetLabel()))
+	)
+	for _, l := range m.GetLabel() {
+		labelNames = append(labelNames, l.GetName())
+		labelValues = append(labelValues, l.GetValue())
+	}
+	desc := prometheus.NewDesc(mf.GetName(), mf.GetHelp(), labelNames, nil)
+
+	var (
+		metric prometheus.Metric
+		err    error
+	)
+	switch mf.GetType() {
+	case dto.MetricType_COUNTER:
+		metric, err = prometheus.NewConstMetric(desc, prometheus.CounterValue, m.GetCounter().GetValue(), labelValues...)
+	case dto.MetricType_GAUGE:
+		metric, err = prometheus.NewConstMetric(desc, prometheus.GaugeValue, m.GetGauge().GetValue(), labelValues...)
+	case dto.MetricType_SUMMARY:
+		quantiles := make(map[float64]float64, len(m.GetSummary().GetQuantile()))
+		for _, q := range m.GetSummary().GetQuantile() {
+			quantiles[q.GetQuantile()] = q.GetValue()
+		}
+		metric, err = prometheus.NewConstSummary(desc, m.GetSummary().GetSampleCount(), m.GetSummary().GetSampleSum(), quantiles, labelValues...)
+	case dto.MetricType_HISTOGRAM:
+		buckets := make(map[float64]uint64, len(m.GetHistogram().GetBucket()))
+		for _, b := range m.GetHistogram().GetBucket() {
+			// The +Inf bucket is implied by the sample count.
+			if !math.IsInf(b.GetUpperBound(), +1) {
+				buckets[b.GetUpperBound()] = b.GetCumulativeCount()
+			}
+		}
+		metric, err = prometheus.NewConstHistogram(desc, m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum(), buckets, labelValues...)
+	default:
+		metric, err = prometheus.NewConstMetric(desc, prometheus.UntypedValue, m.GetUntyped().GetValue(), labelValues...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if m.TimestampMs != nil {
+		metric = prometheus.NewMetricWithTimestamp(time.Unix(0, m.GetTimestampMs()*int64(time.Millisecond)), metric)
+	}
+	return metric, nil
+}
+
+// Collector is a prometheus.Collector that collects a fixed set of metrics.
+// It's an unchecked collector.
+type Collector []prometheus.Metric
+
+// Describe implements prometheus.Collector.
+func (c Collector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (c Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c {
+		ch <- m
+	}
+}
@@ -0,0 +1,51 @@
+package textmetrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	in := `
+# HELP backup_age_seconds Age of the last backup.
+# TYPE backup_age_seconds gauge
+backup_age_seconds{database="orders"} 3600
+backup_age_seconds{database="users"} 7200
+# HELP backup_runs_total Number of backup runs.
+# TYPE backup_runs_total counter
+backup_runs_total 12
+# HELP backup_duration_seconds Duration of backup runs.
+# TYPE backup_duration_seconds histogram
+backup_duration_seconds_bucket{le="60"} 2
+backup_duration_seconds_bucket{le="300"} 10
+backup_duration_seconds_bucket{le="+Inf"} 12
+backup_duration_seconds_sum 2400
+backup_duration_seconds_count 12
+`
+	metrics, err := Parse(strings.NewReader(in))
+	require.NoError(t, err)
+
+	expect := `
+# HELP backup_age_seconds Age of the last backup.
+# TYPE backup_age_seconds gauge
+backup_age_seconds{database="orders"} 3600
+backup_age_seconds{database="users"} 7200
+# HELP backup_duration_seconds Duration of backup runs.
+# TYPE backup_duration_seconds histogram
+backup_duration_seconds_bucket{le="60"} 2
+backup_duration_seconds_bucket{le="300"} 10
+backup_duration_seconds_bucket{le="+Inf"} 12
+backup_duration_seconds_sum 2400
+backup_duration_seconds_count 12
+# HELP backup_runs_total Number of backup runs.
+# TYPE backup_runs_total counter
+backup_runs_total 12
+`
+	require.NoError(t, testutil.CollectAndCompare(Collector(metrics), strings.NewReader(expect)))
+
+	_, err = Parse(strings.NewReader("backup_age_seconds{database=\"orders\" 3600\n"))
+	require.Error(t, err)
+}